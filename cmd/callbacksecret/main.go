@@ -0,0 +1,37 @@
+// Command callbacksecret encrypts gateway callback secret for the gateways.callback_secret column.
+// The HMAC key or RSA public key PEM is read from stdin, the key is taken from CALLBACK_SECRETS_KEY:
+//
+//	CALLBACK_SECRETS_KEY=... go run ./cmd/callbacksecret < public.pem
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"payment-gateway/internal/util"
+)
+
+func main() {
+	key, err := util.ParseSecretKey(os.Getenv("CALLBACK_SECRETS_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	secret, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		log.Fatal("secret is empty")
+	}
+
+	encrypted, err := util.Encrypt(key, secret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(encrypted)
+}
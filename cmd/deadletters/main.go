@@ -0,0 +1,199 @@
+// Command deadletters inspects and replays the messages that could not be published to kafka.
+// It uses the database and kafka settings of the service (DB_*, KAFKA_BROKER_URL):
+//
+//	go run ./cmd/deadletters list -topic transactions.json -status failed
+//	go run ./cmd/deadletters show 42
+//	go run ./cmd/deadletters replay 42
+//	go run ./cmd/deadletters replay -all -topic transactions.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"payment-gateway/db"
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/deadletter"
+)
+
+const usage = `usage:
+  deadletters list [-topic T] [-key K] [-status failed|replayed] [-after-id N] [-limit N]
+  deadletters show ID
+  deadletters replay ID
+  deadletters replay -all [-topic T] [-key K] [-after-id N] [-limit N]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = list(os.Args[2:])
+	case "show":
+		err = show(os.Args[2:])
+	case "replay":
+		err = replay(ctx, os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	filter := filterFlags(flags)
+	status := flags.String("status", "", "failed or replayed")
+	_ = flags.Parse(args)
+	filter.Status = *status
+
+	service, closeService := newService()
+	defer closeService()
+
+	deadLetters, err := service.List(*filter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTOPIC\tKEY\tSTATUS\tATTEMPTS\tCREATED\tERROR")
+	for _, d := range deadLetters {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			d.ID, d.Topic, d.Key, d.Status, d.Attempts, d.CreatedAt.Format(time.RFC3339), d.Error)
+	}
+	return w.Flush()
+}
+
+func show(args []string) error {
+	id, err := deadLetterID(args)
+	if err != nil {
+		return err
+	}
+
+	service, closeService := newService()
+	defer closeService()
+
+	deadLetter, err := service.Get(id)
+	if err != nil {
+		return err
+	}
+	return printDeadLetter(deadLetter)
+}
+
+func replay(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	all := flags.Bool("all", false, "replay the failed dead letters matching the filter")
+	filter := filterFlags(flags)
+	_ = flags.Parse(args)
+
+	service, closeService := newService()
+	defer closeService()
+
+	if !*all {
+		id, err := deadLetterID(flags.Args())
+		if err != nil {
+			return err
+		}
+
+		deadLetter, err := service.Replay(ctx, id)
+		if err != nil {
+			return err
+		}
+		return printDeadLetter(deadLetter)
+	}
+
+	// every page starts after the last dead letter tried, the failed ones are not retried in this run
+	var replayed, failed, skipped int
+	for {
+		result, err := service.ReplayAll(ctx, *filter)
+		replayed += result.Replayed
+		failed += result.Failed
+		skipped += result.Skipped
+		if err != nil {
+			return err
+		}
+		if result.LastID == 0 {
+			break
+		}
+		filter.AfterID = result.LastID
+	}
+
+	fmt.Printf("replayed %d, failed %d, skipped %d superseded\n", replayed, failed, skipped)
+	if failed > 0 {
+		return fmt.Errorf("%d dead letters were not replayed", failed)
+	}
+	return nil
+}
+
+func filterFlags(flags *flag.FlagSet) *models.DeadLetterFilter {
+	var filter models.DeadLetterFilter
+	flags.StringVar(&filter.Topic, "topic", "", "kafka topic")
+	flags.StringVar(&filter.Key, "key", "", "message key, the transaction id for the transaction events")
+	flags.Int64Var(&filter.AfterID, "after-id", 0, "only dead letters with a greater id")
+	flags.IntVar(&filter.Limit, "limit", deadletter.DefaultLimit, "page size")
+	return &filter
+}
+
+func deadLetterID(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("dead letter id is required\n%s", usage)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid dead letter id %q", args[0])
+	}
+	return id, nil
+}
+
+func printDeadLetter(deadLetter models.DeadLetter) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(deadLetter)
+}
+
+func newService() (deadletter.Service, func()) {
+	dbURL := "postgres://" + os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@" + os.Getenv("DB_HOST") + ":" +
+		os.Getenv("DB_PORT") + "/" + os.Getenv("DB_NAME") + "?sslmode=disable"
+
+	dbConnect, err := db.InitializeDB(dbURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kafkaURL := os.Getenv("KAFKA_BROKER_URL")
+	if kafkaURL == "" {
+		kafkaURL = "kafka:9092"
+	}
+
+	// dead letters are published as they were stored, the serializer is not used
+	serializer, err := kafka.NewSerializer("", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	publisher := kafka.NewPublisher(kafkaURL, serializer)
+
+	service := deadletter.NewService(repository.NewDeadLetterRepository(dbConnect), publisher)
+	return service, func() {
+		_ = publisher.Close()
+		_ = dbConnect.Close()
+	}
+}
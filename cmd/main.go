@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"payment-gateway/db"
 	"payment-gateway/internal/api"
+	"payment-gateway/internal/idempotency"
 	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/services/commands"
+	"payment-gateway/internal/services/fx"
+	"payment-gateway/internal/util"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -33,18 +43,96 @@ func main() {
 		kafkaURL = "kafka:9092"
 	}
 
-	kafkaPublisher := kafka.NewPublisher(kafkaURL)
-	defer kafkaPublisher.Close()
+	// Events are published as json unless EVENTS_FORMAT is xml or avro
+	serializer, err := kafka.NewSerializer(os.Getenv("EVENTS_FORMAT"), kafka.NewLocalRegistry())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kafkaPublisher := kafka.NewPublisher(kafkaURL, serializer)
 
 	log.Println("Kafka writer initialized successfully.")
+	// Exchange rates are fetched from FX_RATES_URL or read from FX_RATES_FILE
+	var rates fx.RateProvider
+	if ratesURL := os.Getenv("FX_RATES_URL"); ratesURL != "" {
+		rates = fx.NewHTTPProvider(&http.Client{Timeout: 10 * time.Second}, ratesURL)
+	} else {
+		ratesFile := os.Getenv("FX_RATES_FILE")
+		if ratesFile == "" {
+			ratesFile = "db/fx_rates.json"
+		}
+		rates = fx.NewFileProvider(ratesFile)
+	}
+
+	// Idempotency keys are kept in postgres unless IDEMPOTENCY_STORE=redis
+	var (
+		idempotencyStore idempotency.Store
+		redisClient      *redis.Client
+	)
+	if os.Getenv("IDEMPOTENCY_STORE") == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     os.Getenv("REDIS_ADDR"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		})
+
+		idempotencyStore = idempotency.NewRedisStore(redisClient, idempotency.DefaultTTL, idempotency.DefaultLockTTL)
+	} else {
+		idempotencyStore = idempotency.NewPostgresStore(dbConnect, idempotency.DefaultTTL, idempotency.DefaultLockTTL)
+	}
+
+	// Gateway callback secrets are stored encrypted with CALLBACK_SECRETS_KEY (base64 AES key),
+	// callbacks are rejected while the key is not set
+	var callbackKey []byte
+	if encodedKey := os.Getenv("CALLBACK_SECRETS_KEY"); encodedKey != "" {
+		callbackKey, err = util.ParseSecretKey(encodedKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Println("CALLBACK_SECRETS_KEY is not set, gateway callbacks will be rejected")
+	}
+
+	// Admin endpoints require "Authorization: Bearer <ADMIN_API_TOKEN>", they are rejected while it is not set
+	adminToken := os.Getenv("ADMIN_API_TOKEN")
+	if adminToken == "" {
+		log.Println("ADMIN_API_TOKEN is not set, admin endpoints will be rejected")
+	}
+
+	serverConfig, err := api.ServerConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Set up the HTTP server and routes
-	di := api.GetContainer(dbConnect, kafkaPublisher)
+	di := api.GetContainer(dbConnect, kafkaPublisher, serializer, rates, idempotencyStore, callbackKey, adminToken)
 	router := api.SetupRouter(di)
 
-	// Start the server on port 8080
-	log.Println("Starting server on port 8080...")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatalf("Could not start server: %s\n", err)
+	// Deposits, withdrawals and status updates from the back-office systems are consumed from payments.commands
+	commandConsumer := kafka.NewConsumer(kafkaURL, commands.ConsumerGroup, commands.Topic, commands.DeadLetterTopic,
+		kafka.DefaultMaxAttempts, di.CommandHandler())
+
+	// SIGINT and SIGTERM stop the workers and the server, the workers are waited for once the server has drained
+	// its requests, then the kafka consumer and writer, redis and the database are closed in this order so that
+	// nothing is closed while it is still in use
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	di.StartWorkers(ctx, commandConsumer.Run)
+
+	steps := []api.ShutdownStep{
+		{Name: "workers", Close: di.StopWorkers},
+		{Name: "kafka consumer", Close: func(context.Context) error { return commandConsumer.Close() }},
+		{Name: "kafka writer", Close: func(context.Context) error { return kafkaPublisher.Close() }},
 	}
+	if redisClient != nil {
+		steps = append(steps, api.ShutdownStep{Name: "redis", Close: func(context.Context) error { return redisClient.Close() }})
+	}
+	steps = append(steps, api.ShutdownStep{Name: "database", Close: func(context.Context) error { return dbConnect.Close() }})
 
+	log.Printf("Starting server on %s...", serverConfig.Addr)
+	server := api.NewServer(serverConfig, router)
+	if err := api.ListenAndServe(ctx, server, serverConfig.ShutdownTimeout, steps...); err != nil {
+		log.Fatalf("Server stopped with errors: %v", err)
+	}
+	log.Println("Server stopped")
 }
@@ -5,10 +5,19 @@ BEGIN
             id SERIAL PRIMARY KEY,
             name VARCHAR(255) NOT NULL UNIQUE,
             data_format_supported VARCHAR(50) NOT NULL,  
+            base_url VARCHAR(255) NOT NULL DEFAULT '',
+            health_url VARCHAR(255) NOT NULL DEFAULT '',
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
             updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             priority INT,
-            status VARCHAR(20)
+            status VARCHAR(20),
+            fee_percent DECIMAL(6, 4) NOT NULL DEFAULT 0,
+            transaction_types TEXT[] NOT NULL DEFAULT '{deposit,withdrawal}',
+            settlement_currency CHAR(3),
+            callback_signature VARCHAR(20),
+            callback_secret TEXT,
+            -- seconds a transaction may stay pending before its status is polled, the poller default when NULL
+            pending_poll_after INT
         );
     END IF;
 END $$;
@@ -33,27 +42,79 @@ BEGIN
         CREATE TABLE gateway_countries (
             gateway_id INT NOT NULL, 
             country_id INT NOT NULL,
+            weight INT NOT NULL DEFAULT 1,
             PRIMARY KEY (gateway_id, country_id)
         );
     END IF;
 END $$;
 
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'gateway_currencies') THEN
+        CREATE TABLE gateway_currencies (
+            gateway_id INT NOT NULL,
+            currency CHAR(3) NOT NULL,
+            -- fixed fee and amount limits in the currency, no limit when NULL
+            fee_fixed NUMERIC(20, 4) NOT NULL DEFAULT 0,
+            min_amount NUMERIC(20, 4),
+            max_amount NUMERIC(20, 4),
+            PRIMARY KEY (gateway_id, currency)
+        );
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'country_routing') THEN
+        CREATE TABLE country_routing (
+            country_id INT PRIMARY KEY,
+            strategy VARCHAR(50) NOT NULL DEFAULT 'priority',
+            success_rate_window_minutes INT NOT NULL DEFAULT 60,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+    END IF;
+END $$;
+
 DO $$ 
 BEGIN
     IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'transactions') THEN
         CREATE TABLE transactions (
             id SERIAL PRIMARY KEY,
-            amount DECIMAL(10, 2) NOT NULL,
+            amount NUMERIC(20, 4) NOT NULL,
+            currency CHAR(3) NOT NULL,
             type VARCHAR(50) NOT NULL,
             status VARCHAR(50) NOT NULL,
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  
             gateway_id INT NOT NULL,  
             country_id INT NOT NULL,  
-            user_id INT NOT NULL
+            user_id INT NOT NULL,
+            gateway_reference VARCHAR(255),
+            settlement_currency CHAR(3),
+            settlement_amount NUMERIC(20, 4),
+            fx_rate NUMERIC(24, 8),
+            fx_source VARCHAR(255),
+            fx_rate_at TIMESTAMP,
+            parent_id INT REFERENCES transactions (id),
+            expires_at TIMESTAMP,
+            polled_at TIMESTAMP,
+            -- lease of the void in progress, captures and the expiry are rejected until it ends
+            voiding_until TIMESTAMP
         );
     END IF;
 END $$;
 
+-- keyset indexes of the transaction search, every filter is sorted by created_at or amount with id as the tie-breaker
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_amount ON transactions (amount, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_gateway_id ON transactions (gateway_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_country_id ON transactions (country_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_status ON transactions (status, created_at, id);
+-- refunds of a deposit, summed for its refunded total and checked against its amount
+CREATE INDEX IF NOT EXISTS idx_transactions_parent_id ON transactions (parent_id, created_at, id);
+-- authorizations waiting for their capture, the expiry worker picks the ones past expires_at
+CREATE INDEX IF NOT EXISTS idx_transactions_authorized_expires_at ON transactions (expires_at) WHERE status = 'authorized';
+
 DO $$ 
 BEGIN
     IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'users') THEN
@@ -68,3 +129,214 @@ BEGIN
         );
     END IF;
 END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'transaction_attempts') THEN
+        CREATE TABLE transaction_attempts (
+            id SERIAL PRIMARY KEY,
+            transaction_id INT NOT NULL,
+            gateway_id INT NOT NULL,
+            attempt_no INT NOT NULL,
+            status VARCHAR(20) NOT NULL,
+            error TEXT,
+            gateway_reference VARCHAR(255),
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX idx_transaction_attempts_transaction_id ON transaction_attempts (transaction_id);
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'idempotency_keys') THEN
+        CREATE TABLE idempotency_keys (
+            key VARCHAR(512) PRIMARY KEY,
+            request_hash CHAR(64) NOT NULL,
+            status_code INT,
+            content_type VARCHAR(255),
+            response_body BYTEA,
+            locked_at TIMESTAMP NOT NULL,
+            completed_at TIMESTAMP,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'callback_nonces') THEN
+        CREATE TABLE callback_nonces (
+            gateway_id INT NOT NULL,
+            nonce VARCHAR(255) NOT NULL,
+            received_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (gateway_id, nonce)
+        );
+        CREATE INDEX idx_callback_nonces_received_at ON callback_nonces (received_at);
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'transaction_status_history') THEN
+        CREATE TABLE transaction_status_history (
+            id SERIAL PRIMARY KEY,
+            transaction_id INT NOT NULL,
+            from_status VARCHAR(20) NOT NULL,
+            to_status VARCHAR(20) NOT NULL,
+            source VARCHAR(20) NOT NULL,
+            actor VARCHAR(255) NOT NULL DEFAULT '',
+            reason TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX idx_transaction_status_history_transaction_id ON transaction_status_history (transaction_id);
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'outbox') THEN
+        CREATE TABLE outbox (
+            id BIGSERIAL PRIMARY KEY,
+            transaction_id INT NOT NULL,
+            content_type VARCHAR(100) NOT NULL,
+            payload BYTEA NOT NULL,
+            status VARCHAR(20) NOT NULL DEFAULT 'pending',
+            attempts INT NOT NULL DEFAULT 0,
+            last_error TEXT,
+            next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            published_at TIMESTAMP
+        );
+        CREATE INDEX idx_outbox_pending ON outbox (transaction_id, id) WHERE status = 'pending';
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'dead_letters') THEN
+        CREATE TABLE dead_letters (
+            id BIGSERIAL PRIMARY KEY,
+            topic VARCHAR(255) NOT NULL,
+            message_key VARCHAR(255) NOT NULL,
+            content_type VARCHAR(100) NOT NULL,
+            payload BYTEA NOT NULL,
+            outbox_id BIGINT,
+            error TEXT NOT NULL,
+            attempts INT NOT NULL,
+            status VARCHAR(20) NOT NULL DEFAULT 'failed',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            replayed_at TIMESTAMP
+        );
+        CREATE INDEX idx_dead_letters_status ON dead_letters (status, id);
+        CREATE INDEX idx_dead_letters_topic ON dead_letters (topic, id);
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'ledger_accounts') THEN
+        CREATE TABLE ledger_accounts (
+            id BIGSERIAL PRIMARY KEY,
+            type VARCHAR(30) NOT NULL,
+            owner_id INT NOT NULL DEFAULT 0,
+            currency CHAR(3) NOT NULL,
+            balance BIGINT NOT NULL DEFAULT 0,
+            reserved BIGINT NOT NULL DEFAULT 0,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (type, owner_id, currency),
+            CHECK (reserved >= 0)
+        );
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'journal_entries') THEN
+        CREATE TABLE journal_entries (
+            id BIGSERIAL PRIMARY KEY,
+            transaction_id INT NOT NULL,
+            type VARCHAR(30) NOT NULL,
+            description TEXT,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            UNIQUE (transaction_id, type)
+        );
+
+        CREATE TABLE journal_lines (
+            id BIGSERIAL PRIMARY KEY,
+            entry_id BIGINT NOT NULL REFERENCES journal_entries (id),
+            account_id BIGINT NOT NULL REFERENCES ledger_accounts (id),
+            amount BIGINT NOT NULL,
+            currency CHAR(3) NOT NULL
+        );
+        CREATE INDEX idx_journal_lines_account ON journal_lines (account_id, entry_id);
+
+        -- posted entries are immutable, corrections are posted as new entries
+        CREATE FUNCTION reject_journal_change() RETURNS trigger AS $f$
+        BEGIN
+            RAISE EXCEPTION 'journal entries are immutable';
+        END;
+        $f$ LANGUAGE plpgsql;
+
+        CREATE TRIGGER journal_entries_immutable BEFORE UPDATE OR DELETE ON journal_entries
+            FOR EACH ROW EXECUTE FUNCTION reject_journal_change();
+        CREATE TRIGGER journal_lines_immutable BEFORE UPDATE OR DELETE ON journal_lines
+            FOR EACH ROW EXECUTE FUNCTION reject_journal_change();
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'ledger_holds') THEN
+        CREATE TABLE ledger_holds (
+            transaction_id INT PRIMARY KEY,
+            account_id BIGINT NOT NULL REFERENCES ledger_accounts (id),
+            amount BIGINT NOT NULL,
+            status VARCHAR(20) NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            settled_at TIMESTAMP
+        );
+    END IF;
+END $$;
+
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'disputes') THEN
+        CREATE TABLE disputes (
+            id SERIAL PRIMARY KEY,
+            transaction_id INT NOT NULL UNIQUE REFERENCES transactions (id),
+            gateway_id INT NOT NULL,
+            gateway_reference VARCHAR(255),
+            amount NUMERIC(20, 4) NOT NULL,
+            currency CHAR(3) NOT NULL,
+            reason TEXT NOT NULL DEFAULT '',
+            status VARCHAR(20) NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX idx_disputes_status ON disputes (status, id);
+
+        CREATE TABLE dispute_evidence (
+            id SERIAL PRIMARY KEY,
+            dispute_id INT NOT NULL REFERENCES disputes (id),
+            name VARCHAR(255) NOT NULL,
+            content_type VARCHAR(100) NOT NULL,
+            description TEXT NOT NULL DEFAULT '',
+            content BYTEA NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX idx_dispute_evidence_dispute_id ON dispute_evidence (dispute_id);
+
+        CREATE TABLE dispute_status_history (
+            id SERIAL PRIMARY KEY,
+            dispute_id INT NOT NULL REFERENCES disputes (id),
+            from_status VARCHAR(20) NOT NULL,
+            to_status VARCHAR(20) NOT NULL,
+            source VARCHAR(20) NOT NULL,
+            actor VARCHAR(255) NOT NULL DEFAULT '',
+            reason TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX idx_dispute_status_history_dispute_id ON dispute_status_history (dispute_id);
+    END IF;
+END $$;
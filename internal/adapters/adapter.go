@@ -0,0 +1,221 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+var (
+	ErrAdapterNotFound = errors.New("gateway adapter not found")
+	ErrTimeout         = errors.New("gateway request timed out")
+	ErrUnknownStatus   = errors.New("unknown gateway status")
+	ErrInvalidCallback = errors.New("invalid gateway callback")
+	// ErrUnavailable gateway declined the transaction because it can't process it right now
+	ErrUnavailable = errors.New("gateway declined: service unavailable")
+	// ErrMissingReference refunded, captured or voided transaction has no id on the provider side
+	ErrMissingReference = errors.New("transaction has no gateway reference")
+	// ErrTransport the request could not be exchanged with the provider, e.g. the connection was refused or reset
+	ErrTransport = errors.New("gateway request failed")
+)
+
+// GatewayAdapter builds provider specific requests, sends them to the provider
+// and maps the provider answer to our transaction statuses
+type GatewayAdapter interface {
+	Deposit(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error)
+	Withdrawal(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error)
+	// Refund returns refund.Amount of the original deposit, the provider reports the result of the refund
+	// with callbacks referencing the refund like for the other transactions
+	Refund(ctx context.Context, gw models.Gateway, refund, original models.Transaction) (*Response, error)
+	// Authorize holds the amount without collecting it, approved authorizations are reported as done
+	Authorize(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error)
+	// Capture collects capture.Amount of the authorization, the rest of the authorized amount is released
+	Capture(ctx context.Context, gw models.Gateway, capture, authorization models.Transaction) (*Response, error)
+	// Void releases the authorized amount, an accepted void is reported as done
+	Void(ctx context.Context, gw models.Gateway, authorization models.Transaction) (*Response, error)
+	// Status queries the provider for the current status of the transaction whose result has not been reported,
+	// the transaction is looked up by its provider id or, without one, by our transaction id
+	Status(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error)
+	// ParseCallback decodes the asynchronous notification sent by the provider, the signature is verified by the caller
+	ParseCallback(gw models.Gateway, body []byte) (*Callback, error)
+}
+
+// Response is the provider answer translated to our domain
+type Response struct {
+	// Reference is the transaction id on the provider side
+	Reference string
+	// Status is one of models.TransactionStatus*
+	Status string
+}
+
+// Callback is the provider notification translated to our domain
+type Callback struct {
+	TransactionID int
+	// Reference is the transaction id on the provider side
+	Reference string
+	// Status is one of models.TransactionStatus*
+	Status string
+	// Dispute is set when the notification reports a chargeback of the transaction instead of its result,
+	// Status is empty then
+	Dispute *DisputeCallback
+}
+
+// DisputeCallback chargeback of the transaction reported by the provider
+type DisputeCallback struct {
+	// Reference is the dispute id on the provider side
+	Reference string
+	// Status is one of models.DisputeStatus*
+	Status string
+	// Amount is zero when the whole transaction is disputed
+	Amount money.Money
+	Reason string
+}
+
+// ProviderError is returned when the provider answers with a non 2xx status code
+type ProviderError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("gateway responded with status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsRetryable reports whether the transaction may be sent to another gateway after this error
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable) {
+		return true
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode >= http.StatusInternalServerError || providerErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// IsGatewayFailure reports whether the error is a failure of the provider itself: it is unreachable, times out
+// or answers 5xx or 429. Rejected requests and local errors like codec failures say nothing about its health
+func IsGatewayFailure(err error) bool {
+	return IsRetryable(err) || errors.Is(err, ErrTransport)
+}
+
+// gatewayReference provider id of the transaction refunded, captured or voided
+func gatewayReference(tx models.Transaction) (string, error) {
+	reference := strings.TrimSpace(tx.GatewayReference)
+	if reference == "" {
+		return "", fmt.Errorf("%w: transaction %d", ErrMissingReference, tx.ID)
+	}
+	return reference, nil
+}
+
+// Registry keeps adapters by gateway name (models.Gateway.Name)
+type Registry struct {
+	mu       sync.RWMutex
+	adapters map[string]GatewayAdapter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		adapters: make(map[string]GatewayAdapter),
+	}
+}
+
+// Register adds adapter for the gateway name, names are case-insensitive
+func (r *Registry) Register(name string, adapter GatewayAdapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.adapters[normalizeName(name)] = adapter
+}
+
+// Get returns adapter registered for the gateway name
+func (r *Registry) Get(name string) (GatewayAdapter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	adapter, ok := r.adapters[normalizeName(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAdapterNotFound, name)
+	}
+	return adapter, nil
+}
+
+// NewDefaultRegistry returns registry with the reference adapters shipped with the service
+func NewDefaultRegistry(client *http.Client) *Registry {
+	registry := NewRegistry()
+	registry.Register(JSONPayName, NewJSONPayAdapter(client))
+	registry.Register(XMLPayName, NewXMLPayAdapter(client))
+
+	return registry
+}
+
+// gatewayCodec returns codec for the format declared by the gateway,
+// adapter native format is used when the gateway has none
+func gatewayCodec(gw models.Gateway, nativeFormat string) (codec.Codec, error) {
+	format := gw.DataFormatSupported
+	if strings.TrimSpace(format) == "" {
+		format = nativeFormat
+	}
+	return codec.Lookup(format)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// newCallback builds callback from the provider fields, reference is our transaction id sent to the provider
+func newCallback(reference, providerReference, providerStatus string, mapStatus func(string) (string, error)) (*Callback, error) {
+	txID, err := strconv.Atoi(strings.TrimSpace(reference))
+	if err != nil || txID <= 0 {
+		return nil, fmt.Errorf("%w: invalid transaction reference %q", ErrInvalidCallback, reference)
+	}
+
+	status, err := mapStatus(providerStatus)
+	if err != nil {
+		// callbacks report the result of the transaction, unknown statuses and "unavailable" are rejected
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+
+	return &Callback{
+		TransactionID: txID,
+		Reference:     providerReference,
+		Status:        status,
+	}, nil
+}
+
+// newDisputeCallback builds callback of the chargeback from the provider fields, reference is our transaction id
+// sent to the provider and amount is optional
+func newDisputeCallback(reference, providerReference string, dispute DisputeCallback, providerStatus, amount, currency string,
+	mapStatus func(string) (string, error)) (*Callback, error) {
+	txID, err := strconv.Atoi(strings.TrimSpace(reference))
+	if err != nil || txID <= 0 {
+		return nil, fmt.Errorf("%w: invalid transaction reference %q", ErrInvalidCallback, reference)
+	}
+
+	if strings.TrimSpace(dispute.Reference) == "" {
+		return nil, fmt.Errorf("%w: dispute of transaction %d has no id", ErrInvalidCallback, txID)
+	}
+	if dispute.Status, err = mapStatus(providerStatus); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	if strings.TrimSpace(amount) != "" {
+		if dispute.Amount, err = money.Parse(amount, currency); err != nil {
+			return nil, fmt.Errorf("%w: invalid dispute amount: %v", ErrInvalidCallback, err)
+		}
+	}
+
+	return &Callback{
+		TransactionID: txID,
+		Reference:     providerReference,
+		Dispute:       &dispute,
+	}, nil
+}
@@ -0,0 +1,287 @@
+package adapters
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/models"
+)
+
+const (
+	JSONPayName = "jsonpay"
+
+	jsonPayPaymentsPath       = "/v1/payments"
+	jsonPayAuthorizationsPath = "/v1/authorizations"
+	// refunds of the payment and captures and voids of the authorization with the id
+	jsonPayRefundsPath = "/v1/payments/%s/refunds"
+	jsonPayCapturePath = "/v1/authorizations/%s/capture"
+	jsonPayVoidPath    = "/v1/authorizations/%s/void"
+	// status of the payment, refund, authorization or capture with the id or with our reference
+	jsonPayStatusPath      = "/v1/payments/%s"
+	jsonPayStatusQueryPath = "/v1/payments?merchant_reference=%s"
+
+	// jsonPayOperationVoid operation of the void response, the other operations are the transaction types
+	jsonPayOperationVoid = "void"
+)
+
+// jsonPayAdapter reference adapter for the providers with REST/JSON API
+type jsonPayAdapter struct {
+	client *http.Client
+}
+
+type jsonPayRequest struct {
+	XMLName    xml.Name `json:"-" xml:"payment"`
+	Reference  string   `json:"merchant_reference" xml:"merchant_reference"`
+	Type       string   `json:"type" xml:"type"`
+	Amount     string   `json:"amount" xml:"amount"`
+	Currency   string   `json:"currency" xml:"currency"`
+	CustomerID string   `json:"customer_id" xml:"customer_id"`
+}
+
+type jsonPayRefundRequest struct {
+	XMLName   xml.Name `json:"-" xml:"refund"`
+	Reference string   `json:"merchant_reference" xml:"merchant_reference"`
+	Amount    string   `json:"amount" xml:"amount"`
+	Currency  string   `json:"currency" xml:"currency"`
+}
+
+type jsonPayCaptureRequest struct {
+	XMLName   xml.Name `json:"-" xml:"capture"`
+	Reference string   `json:"merchant_reference" xml:"merchant_reference"`
+	Amount    string   `json:"amount" xml:"amount"`
+	Currency  string   `json:"currency" xml:"currency"`
+}
+
+type jsonPayVoidRequest struct {
+	XMLName   xml.Name `json:"-" xml:"void"`
+	Reference string   `json:"merchant_reference" xml:"merchant_reference"`
+}
+
+type jsonPayResponse struct {
+	XMLName xml.Name `json:"-" xml:"payment"`
+	ID      string   `json:"id" xml:"id"`
+	Status  string   `json:"status" xml:"status"`
+	Message string   `json:"message,omitempty" xml:"message,omitempty"`
+}
+
+// jsonPayCallback notification posted by jsonpay when the payment status changes
+type jsonPayCallback struct {
+	XMLName   xml.Name `json:"-" xml:"payment"`
+	ID        string   `json:"id" xml:"id"`
+	Reference string   `json:"merchant_reference" xml:"merchant_reference"`
+	Status    string   `json:"status" xml:"status"`
+	// Type of the payment, as sent in its request
+	Type string `json:"type,omitempty" xml:"type,omitempty"`
+	// Dispute is sent instead of a new payment status when the payer disputes the payment
+	Dispute *jsonPayDispute `json:"dispute,omitempty" xml:"dispute,omitempty"`
+}
+
+type jsonPayDispute struct {
+	ID       string `json:"id" xml:"id"`
+	Status   string `json:"status" xml:"status"`
+	Amount   string `json:"amount,omitempty" xml:"amount,omitempty"`
+	Currency string `json:"currency,omitempty" xml:"currency,omitempty"`
+	Reason   string `json:"reason,omitempty" xml:"reason,omitempty"`
+}
+
+func NewJSONPayAdapter(client *http.Client) GatewayAdapter {
+	return &jsonPayAdapter{
+		client: client,
+	}
+}
+
+func (a *jsonPayAdapter) Deposit(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.pay(ctx, gw, tx)
+}
+
+func (a *jsonPayAdapter) Withdrawal(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.pay(ctx, gw, tx)
+}
+
+// Refund posts the refund to the payment the deposit has created
+func (a *jsonPayAdapter) Refund(ctx context.Context, gw models.Gateway, refund, original models.Transaction) (*Response, error) {
+	reference, err := gatewayReference(original)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.send(ctx, gw, refund.Type, gw.BaseURL+fmt.Sprintf(jsonPayRefundsPath, url.PathEscape(reference)), jsonPayRefundRequest{
+		Reference: strconv.Itoa(refund.ID),
+		Amount:    refund.GatewayAmount().String(),
+		Currency:  refund.GatewayAmount().Currency(),
+	})
+}
+
+func (a *jsonPayAdapter) Authorize(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.send(ctx, gw, tx.Type, gw.BaseURL+jsonPayAuthorizationsPath, newJSONPayRequest(tx))
+}
+
+func (a *jsonPayAdapter) Capture(ctx context.Context, gw models.Gateway, capture, authorization models.Transaction) (*Response, error) {
+	reference, err := gatewayReference(authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.send(ctx, gw, capture.Type, gw.BaseURL+fmt.Sprintf(jsonPayCapturePath, url.PathEscape(reference)), jsonPayCaptureRequest{
+		Reference: strconv.Itoa(capture.ID),
+		Amount:    capture.GatewayAmount().String(),
+		Currency:  capture.GatewayAmount().Currency(),
+	})
+}
+
+func (a *jsonPayAdapter) Void(ctx context.Context, gw models.Gateway, authorization models.Transaction) (*Response, error) {
+	reference, err := gatewayReference(authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.send(ctx, gw, jsonPayOperationVoid, gw.BaseURL+fmt.Sprintf(jsonPayVoidPath, url.PathEscape(reference)), jsonPayVoidRequest{
+		Reference: strconv.Itoa(authorization.ID),
+	})
+}
+
+// Status gets the payment jsonpay has created for the transaction
+func (a *jsonPayAdapter) Status(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	endpoint := gw.BaseURL + fmt.Sprintf(jsonPayStatusQueryPath, url.QueryEscape(strconv.Itoa(tx.ID)))
+	if reference := strings.TrimSpace(tx.GatewayReference); reference != "" {
+		endpoint = gw.BaseURL + fmt.Sprintf(jsonPayStatusPath, url.PathEscape(reference))
+	}
+
+	c, err := gatewayCodec(gw, codec.FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := send(ctx, a.client, http.MethodGet, endpoint, c.ContentType(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return a.response(c, tx.Type, respBody)
+}
+
+func (a *jsonPayAdapter) pay(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.send(ctx, gw, tx.Type, gw.BaseURL+jsonPayPaymentsPath, newJSONPayRequest(tx))
+}
+
+func newJSONPayRequest(tx models.Transaction) jsonPayRequest {
+	return jsonPayRequest{
+		Reference:  strconv.Itoa(tx.ID),
+		Type:       tx.Type,
+		Amount:     tx.GatewayAmount().String(),
+		Currency:   tx.GatewayAmount().Currency(),
+		CustomerID: strconv.Itoa(tx.UserID),
+	}
+}
+
+// send posts the request of the operation, the response status is mapped for the operation
+func (a *jsonPayAdapter) send(ctx context.Context, gw models.Gateway, operation, endpoint string, req interface{}) (*Response, error) {
+	c, err := gatewayCodec(gw, codec.FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode jsonpay request: %w", err)
+	}
+
+	respBody, err := send(ctx, a.client, http.MethodPost, endpoint, c.ContentType(), body)
+	if err != nil {
+		return nil, err
+	}
+	return a.response(c, operation, respBody)
+}
+
+func (a *jsonPayAdapter) response(c codec.Codec, operation string, respBody []byte) (*Response, error) {
+	var resp jsonPayResponse
+	if err := c.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode jsonpay response: %w", err)
+	}
+
+	status, err := jsonPayStatus(operation)(resp.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Reference: resp.ID,
+		Status:    status,
+	}, nil
+}
+
+func (a *jsonPayAdapter) ParseCallback(gw models.Gateway, body []byte) (*Callback, error) {
+	c, err := gatewayCodec(gw, codec.FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var cb jsonPayCallback
+	if err := c.Unmarshal(body, &cb); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode jsonpay callback: %v", ErrInvalidCallback, err)
+	}
+
+	if cb.Dispute != nil {
+		dispute := DisputeCallback{Reference: cb.Dispute.ID, Reason: cb.Dispute.Reason}
+		return newDisputeCallback(cb.Reference, cb.ID, dispute, cb.Dispute.Status, cb.Dispute.Amount, cb.Dispute.Currency, jsonPayDisputeStatus)
+	}
+	return newCallback(cb.Reference, cb.ID, cb.Status, jsonPayStatus(cb.Type))
+}
+
+func jsonPayDisputeStatus(status string) (string, error) {
+	switch strings.ToLower(status) {
+	case "open", "needs_response":
+		return models.DisputeStatusOpened, nil
+	case "under_review":
+		return models.DisputeStatusEvidenceSubmitted, nil
+	case "won":
+		return models.DisputeStatusWon, nil
+	case "lost":
+		return models.DisputeStatusLost, nil
+	default:
+		return "", fmt.Errorf("%w: dispute %q", ErrUnknownStatus, status)
+	}
+}
+
+// jsonPayStatus maps the statuses of the operation, only authorizations are authorized and voided, a payment
+// is authorized before it is approved and a canceled payment has failed. Callbacks without type are mapped
+// like authorizations
+func jsonPayStatus(operation string) func(string) (string, error) {
+	switch operation {
+	case models.TransactionTypeAuthorization, jsonPayOperationVoid, "":
+		return jsonPayAuthorizationStatus
+	default:
+		return jsonPayPaymentStatus
+	}
+}
+
+func jsonPayAuthorizationStatus(status string) (string, error) {
+	switch strings.ToLower(status) {
+	case "authorized":
+		return models.TransactionStatusAuthorized, nil
+	case "voided", "canceled":
+		return models.TransactionStatusVoided, nil
+	default:
+		return jsonPayPaymentStatus(status)
+	}
+}
+
+func jsonPayPaymentStatus(status string) (string, error) {
+	switch strings.ToLower(status) {
+	case "approved", "succeeded", "captured":
+		return models.TransactionStatusDone, nil
+	case "authorized", "pending", "processing":
+		return models.TransactionStatusPending, nil
+	case "declined", "failed", "canceled", "voided":
+		return models.TransactionStatusFailed, nil
+	case "unavailable":
+		return "", ErrUnavailable
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownStatus, status)
+	}
+}
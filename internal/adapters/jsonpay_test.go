@@ -0,0 +1,306 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPayAdapter_Deposit(t *testing.T) {
+	tests := []struct {
+		name         string
+		respStatus   int
+		respBody     string
+		wantStatus   string
+		wantErr      error
+		wantProvider bool
+	}{
+		{
+			name:       "approved",
+			respStatus: http.StatusOK,
+			respBody:   `{"id":"pay_1","status":"approved"}`,
+			wantStatus: models.TransactionStatusDone,
+		},
+		{
+			name:       "pending",
+			respStatus: http.StatusAccepted,
+			respBody:   `{"id":"pay_1","status":"pending"}`,
+			wantStatus: models.TransactionStatusPending,
+		},
+		{
+			name:       "declined",
+			respStatus: http.StatusOK,
+			respBody:   `{"id":"pay_1","status":"declined"}`,
+			wantStatus: models.TransactionStatusFailed,
+		},
+		{
+			name:       "canceled",
+			respStatus: http.StatusOK,
+			respBody:   `{"id":"pay_1","status":"canceled"}`,
+			wantStatus: models.TransactionStatusFailed,
+		},
+		{
+			name:       "authorized before approval",
+			respStatus: http.StatusAccepted,
+			respBody:   `{"id":"pay_1","status":"authorized"}`,
+			wantStatus: models.TransactionStatusPending,
+		},
+		{
+			name:       "unknown status",
+			respStatus: http.StatusOK,
+			respBody:   `{"id":"pay_1","status":"on_hold"}`,
+			wantErr:    ErrUnknownStatus,
+		},
+		{
+			name:         "provider error",
+			respStatus:   http.StatusBadGateway,
+			respBody:     `{"message":"upstream down"}`,
+			wantProvider: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got jsonPayRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, jsonPayPaymentsPath, r.URL.Path)
+				assert.Equal(t, codec.ContentTypeJSON, r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+				w.WriteHeader(tt.respStatus)
+				_, _ = w.Write([]byte(tt.respBody))
+			}))
+			defer server.Close()
+
+			adapter := NewJSONPayAdapter(NewHTTPClient(time.Second))
+			gw := models.Gateway{Name: JSONPayName, BaseURL: server.URL}
+			tx := models.Transaction{ID: 42, UserID: 7, Amount: money.MustParse("100.5", "EUR"), Type: models.TransactionTypeDeposit}
+
+			resp, err := adapter.Deposit(context.Background(), gw, tx)
+
+			assert.Equal(t, jsonPayRequest{Reference: "42", Type: "deposit", Amount: "100.50", Currency: "EUR", CustomerID: "7"}, got)
+
+			switch {
+			case tt.wantProvider:
+				var providerErr *ProviderError
+				require.ErrorAs(t, err, &providerErr)
+				assert.Equal(t, tt.respStatus, providerErr.StatusCode)
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "pay_1", resp.Reference)
+				assert.Equal(t, tt.wantStatus, resp.Status)
+			}
+		})
+	}
+}
+
+func TestJSONPayAdapter_Refund(t *testing.T) {
+	var got jsonPayRefundRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/payments/pay_1/refunds", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		_, _ = w.Write([]byte(`{"id":"ref_1","status":"succeeded"}`))
+	}))
+	defer server.Close()
+
+	adapter := NewJSONPayAdapter(NewHTTPClient(time.Second))
+	gw := models.Gateway{Name: JSONPayName, BaseURL: server.URL}
+	original := models.Transaction{ID: 42, UserID: 7, Amount: money.MustParse("100.5", "EUR"), Type: models.TransactionTypeDeposit, GatewayReference: "pay_1"}
+	refund := models.Transaction{ID: 43, UserID: 7, Amount: money.MustParse("30", "EUR"), Type: models.TransactionTypeRefund, ParentID: 42}
+
+	resp, err := adapter.Refund(context.Background(), gw, refund, original)
+	require.NoError(t, err)
+
+	assert.Equal(t, jsonPayRefundRequest{Reference: "43", Amount: "30.00", Currency: "EUR"}, got)
+	assert.Equal(t, &Response{Reference: "ref_1", Status: models.TransactionStatusDone}, resp)
+}
+
+func TestJSONPayAdapter_AuthorizeCaptureVoid(t *testing.T) {
+	var paths []string
+	var capture jsonPayCaptureRequest
+	var void jsonPayVoidRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/v1/authorizations":
+			_, _ = w.Write([]byte(`{"id":"auth_1","status":"authorized"}`))
+		case "/v1/authorizations/auth_1/capture":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&capture))
+			_, _ = w.Write([]byte(`{"id":"cap_1","status":"captured"}`))
+		case "/v1/authorizations/auth_1/void":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&void))
+			_, _ = w.Write([]byte(`{"id":"auth_1","status":"voided"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	adapter := NewJSONPayAdapter(NewHTTPClient(time.Second))
+	gw := models.Gateway{Name: JSONPayName, BaseURL: server.URL}
+	authorization := models.Transaction{ID: 50, UserID: 7, Amount: money.MustParse("100", "EUR"), Type: models.TransactionTypeAuthorization}
+
+	resp, err := adapter.Authorize(context.Background(), gw, authorization)
+	require.NoError(t, err)
+	assert.Equal(t, &Response{Reference: "auth_1", Status: models.TransactionStatusAuthorized}, resp)
+
+	authorization.GatewayReference = resp.Reference
+	partial := models.Transaction{ID: 51, UserID: 7, Amount: money.MustParse("60", "EUR"), Type: models.TransactionTypeCapture, ParentID: 50}
+	resp, err = adapter.Capture(context.Background(), gw, partial, authorization)
+	require.NoError(t, err)
+	assert.Equal(t, jsonPayCaptureRequest{Reference: "51", Amount: "60.00", Currency: "EUR"}, capture)
+	assert.Equal(t, &Response{Reference: "cap_1", Status: models.TransactionStatusDone}, resp)
+
+	resp, err = adapter.Void(context.Background(), gw, authorization)
+	require.NoError(t, err)
+	assert.Equal(t, jsonPayVoidRequest{Reference: "50"}, void)
+	assert.Equal(t, &Response{Reference: "auth_1", Status: models.TransactionStatusVoided}, resp)
+
+	assert.Equal(t, []string{"/v1/authorizations", "/v1/authorizations/auth_1/capture", "/v1/authorizations/auth_1/void"}, paths)
+
+	// an authorization the gateway has not answered can't be captured or voided
+	authorization.GatewayReference = ""
+	_, err = adapter.Capture(context.Background(), gw, partial, authorization)
+	assert.ErrorIs(t, err, ErrMissingReference)
+	_, err = adapter.Void(context.Background(), gw, authorization)
+	assert.ErrorIs(t, err, ErrMissingReference)
+}
+
+func TestJSONPayAdapter_Status(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		requests = append(requests, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/v1/payments/pay_1":
+			_, _ = w.Write([]byte(`{"id":"pay_1","status":"approved"}`))
+		case "/v1/payments":
+			_, _ = w.Write([]byte(`{"id":"pay_2","status":"processing"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	adapter := NewJSONPayAdapter(NewHTTPClient(time.Second))
+	gw := models.Gateway{Name: JSONPayName, BaseURL: server.URL}
+
+	resp, err := adapter.Status(context.Background(), gw, models.Transaction{ID: 42, GatewayReference: "pay_1"})
+	require.NoError(t, err)
+	assert.Equal(t, &Response{Reference: "pay_1", Status: models.TransactionStatusDone}, resp)
+
+	// without the jsonpay id the payment is looked up by our reference
+	resp, err = adapter.Status(context.Background(), gw, models.Transaction{ID: 43})
+	require.NoError(t, err)
+	assert.Equal(t, &Response{Reference: "pay_2", Status: models.TransactionStatusPending}, resp)
+
+	assert.Equal(t, []string{"/v1/payments/pay_1", "/v1/payments?merchant_reference=43"}, requests)
+}
+
+func TestJSONPayAdapter_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"id":"pay_1","status":"approved"}`))
+	}))
+	defer server.Close()
+
+	adapter := NewJSONPayAdapter(NewHTTPClient(50 * time.Millisecond))
+	gw := models.Gateway{Name: JSONPayName, BaseURL: server.URL}
+
+	_, err := adapter.Withdrawal(context.Background(), gw, models.Transaction{ID: 1, Amount: money.MustParse("1", "EUR")})
+	assert.True(t, errors.Is(err, ErrTimeout), "expected timeout error, got %v", err)
+}
+
+func TestRegistry_Get(t *testing.T) {
+	registry := NewDefaultRegistry(NewHTTPClient(time.Second))
+
+	adapter, err := registry.Get(" JSONPay ")
+	require.NoError(t, err)
+	assert.NotNil(t, adapter)
+
+	_, err = registry.Get("unknown")
+	assert.ErrorIs(t, err, ErrAdapterNotFound)
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(ErrTimeout))
+	assert.True(t, IsRetryable(ErrUnavailable))
+	assert.True(t, IsRetryable(&ProviderError{StatusCode: http.StatusServiceUnavailable}))
+	assert.True(t, IsRetryable(&ProviderError{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, IsRetryable(&ProviderError{StatusCode: http.StatusBadRequest}))
+	assert.False(t, IsRetryable(ErrUnknownStatus))
+}
+
+func TestJSONPayAdapter_ParseCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    *Callback
+		wantErr error
+	}{
+		{
+			name: "approved",
+			body: `{"id":"jp-1","merchant_reference":"7","status":"approved"}`,
+			want: &Callback{TransactionID: 7, Reference: "jp-1", Status: models.TransactionStatusDone},
+		},
+		{
+			name: "canceled deposit",
+			body: `{"id":"jp-1","merchant_reference":"7","type":"deposit","status":"canceled"}`,
+			want: &Callback{TransactionID: 7, Reference: "jp-1", Status: models.TransactionStatusFailed},
+		},
+		{
+			name: "voided authorization",
+			body: `{"id":"jp-1","merchant_reference":"7","type":"authorization","status":"voided"}`,
+			want: &Callback{TransactionID: 7, Reference: "jp-1", Status: models.TransactionStatusVoided},
+		},
+		{
+			name: "dispute",
+			body: `{"id":"jp-1","merchant_reference":"7","status":"disputed",` +
+				`"dispute":{"id":"dp-1","status":"needs_response","amount":"10.00","currency":"EUR","reason":"fraudulent"}}`,
+			want: &Callback{TransactionID: 7, Reference: "jp-1", Dispute: &DisputeCallback{
+				Reference: "dp-1", Status: models.DisputeStatusOpened, Amount: money.MustParse("10", "EUR"), Reason: "fraudulent",
+			}},
+		},
+		{
+			name: "whole transaction disputed",
+			body: `{"id":"jp-1","merchant_reference":"7","dispute":{"id":"dp-1","status":"lost"}}`,
+			want: &Callback{TransactionID: 7, Reference: "jp-1", Dispute: &DisputeCallback{Reference: "dp-1", Status: models.DisputeStatusLost}},
+		},
+		{name: "unknown dispute status", body: `{"id":"jp-1","merchant_reference":"7","dispute":{"id":"dp-1","status":"closed"}}`, wantErr: ErrInvalidCallback},
+		{name: "dispute without id", body: `{"id":"jp-1","merchant_reference":"7","dispute":{"status":"lost"}}`, wantErr: ErrInvalidCallback},
+		{name: "invalid reference", body: `{"id":"jp-1","merchant_reference":"x","status":"approved"}`, wantErr: ErrInvalidCallback},
+		{name: "unavailable is not a result", body: `{"id":"jp-1","merchant_reference":"7","status":"unavailable"}`, wantErr: ErrInvalidCallback},
+		{name: "malformed", body: `{`, wantErr: ErrInvalidCallback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := NewJSONPayAdapter(nil)
+
+			got, err := adapter.ParseCallback(models.Gateway{Name: JSONPayName}, []byte(tt.body))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package adapters
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/models"
+)
+
+const (
+	XMLPayName = "xmlpay"
+
+	xmlPayTransactionsPath = "/gateway/transactions"
+
+	// xmlPayOperationVoid other operations are the upper-cased transaction types
+	xmlPayOperationVoid = "VOID"
+	// xmlPayOperationInquiry returns the result of the transaction with the reference or the original transaction id
+	xmlPayOperationInquiry = "INQUIRY"
+)
+
+// xmlPayAdapter reference adapter for the providers with XML over HTTP API
+type xmlPayAdapter struct {
+	client *http.Client
+}
+
+type xmlPayRequest struct {
+	XMLName   xml.Name `json:"-" xml:"PaymentRequest"`
+	Reference string   `json:"Reference" xml:"Reference"`
+	Operation string   `json:"Operation" xml:"Operation"`
+	Amount    string   `json:"Amount" xml:"Amount"`
+	Currency  string   `json:"Currency" xml:"Currency"`
+	Customer  string   `json:"Customer" xml:"Customer"`
+	// OriginalTransactionID provider id of the refunded, captured, voided or inquired transaction,
+	// REFUND, CAPTURE, VOID and INQUIRY operations only
+	OriginalTransactionID string `json:"OriginalTransactionID,omitempty" xml:"OriginalTransactionID,omitempty"`
+}
+
+type xmlPayResponse struct {
+	XMLName       xml.Name `json:"-" xml:"PaymentResponse"`
+	TransactionID string   `json:"TransactionID" xml:"TransactionID"`
+	ResultCode    string   `json:"ResultCode" xml:"ResultCode"`
+	Message       string   `json:"Message" xml:"Message"`
+}
+
+// xmlPayNotification notification posted by xmlpay when the result of the transaction is known
+type xmlPayNotification struct {
+	XMLName       xml.Name `json:"-" xml:"PaymentNotification"`
+	Reference     string   `json:"Reference" xml:"Reference"`
+	TransactionID string   `json:"TransactionID" xml:"TransactionID"`
+	ResultCode    string   `json:"ResultCode" xml:"ResultCode"`
+	// Chargeback is sent without a result code when the cardholder disputes the transaction
+	Chargeback *xmlPayChargeback `json:"Chargeback,omitempty" xml:"Chargeback,omitempty"`
+}
+
+type xmlPayChargeback struct {
+	CaseID     string `json:"CaseID" xml:"CaseID"`
+	Stage      string `json:"Stage" xml:"Stage"`
+	Amount     string `json:"Amount,omitempty" xml:"Amount,omitempty"`
+	Currency   string `json:"Currency,omitempty" xml:"Currency,omitempty"`
+	ReasonCode string `json:"ReasonCode,omitempty" xml:"ReasonCode,omitempty"`
+}
+
+func NewXMLPayAdapter(client *http.Client) GatewayAdapter {
+	return &xmlPayAdapter{
+		client: client,
+	}
+}
+
+func (a *xmlPayAdapter) Deposit(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.pay(ctx, gw, tx)
+}
+
+func (a *xmlPayAdapter) Withdrawal(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.pay(ctx, gw, tx)
+}
+
+// Refund sends the REFUND operation referencing the transaction of the deposit
+func (a *xmlPayAdapter) Refund(ctx context.Context, gw models.Gateway, refund, original models.Transaction) (*Response, error) {
+	return a.sendFollowUp(ctx, gw, newXMLPayRequest(refund), original)
+}
+
+func (a *xmlPayAdapter) Authorize(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.send(ctx, gw, newXMLPayRequest(tx))
+}
+
+// Capture sends the CAPTURE operation referencing the transaction of the authorization
+func (a *xmlPayAdapter) Capture(ctx context.Context, gw models.Gateway, capture, authorization models.Transaction) (*Response, error) {
+	return a.sendFollowUp(ctx, gw, newXMLPayRequest(capture), authorization)
+}
+
+// Void sends the VOID operation of the whole authorized amount
+func (a *xmlPayAdapter) Void(ctx context.Context, gw models.Gateway, authorization models.Transaction) (*Response, error) {
+	req := newXMLPayRequest(authorization)
+	req.Operation = xmlPayOperationVoid
+	return a.sendFollowUp(ctx, gw, req, authorization)
+}
+
+// Status sends the INQUIRY operation, the transaction is referenced by its xmlpay id when it has one
+func (a *xmlPayAdapter) Status(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	req := newXMLPayRequest(tx)
+	req.Operation = xmlPayOperationInquiry
+	req.OriginalTransactionID = strings.TrimSpace(tx.GatewayReference)
+	return a.send(ctx, gw, req)
+}
+
+// sendFollowUp sends the operation on the transaction processed before
+func (a *xmlPayAdapter) sendFollowUp(ctx context.Context, gw models.Gateway, req xmlPayRequest, original models.Transaction) (*Response, error) {
+	reference, err := gatewayReference(original)
+	if err != nil {
+		return nil, err
+	}
+
+	req.OriginalTransactionID = reference
+	return a.send(ctx, gw, req)
+}
+
+func (a *xmlPayAdapter) pay(ctx context.Context, gw models.Gateway, tx models.Transaction) (*Response, error) {
+	return a.send(ctx, gw, newXMLPayRequest(tx))
+}
+
+func newXMLPayRequest(tx models.Transaction) xmlPayRequest {
+	return xmlPayRequest{
+		Reference: strconv.Itoa(tx.ID),
+		Operation: strings.ToUpper(tx.Type),
+		Amount:    tx.GatewayAmount().String(),
+		Currency:  tx.GatewayAmount().Currency(),
+		Customer:  strconv.Itoa(tx.UserID),
+	}
+}
+
+func (a *xmlPayAdapter) send(ctx context.Context, gw models.Gateway, req xmlPayRequest) (*Response, error) {
+	c, err := gatewayCodec(gw, codec.FormatXML)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode xmlpay request: %w", err)
+	}
+
+	respBody, err := send(ctx, a.client, http.MethodPost, gw.BaseURL+xmlPayTransactionsPath, c.ContentType(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp xmlPayResponse
+	if err := c.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode xmlpay response: %w", err)
+	}
+
+	status, err := xmlPayStatus(resp.ResultCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Reference: resp.TransactionID,
+		Status:    status,
+	}, nil
+}
+
+func (a *xmlPayAdapter) ParseCallback(gw models.Gateway, body []byte) (*Callback, error) {
+	c, err := gatewayCodec(gw, codec.FormatXML)
+	if err != nil {
+		return nil, err
+	}
+
+	var notification xmlPayNotification
+	if err := c.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode xmlpay callback: %v", ErrInvalidCallback, err)
+	}
+
+	if cb := notification.Chargeback; cb != nil {
+		dispute := DisputeCallback{Reference: cb.CaseID, Reason: cb.ReasonCode}
+		return newDisputeCallback(notification.Reference, notification.TransactionID, dispute, cb.Stage, cb.Amount, cb.Currency, xmlPayChargebackStage)
+	}
+	return newCallback(notification.Reference, notification.TransactionID, notification.ResultCode, xmlPayStatus)
+}
+
+// xmlPayChargebackStage maps xmlpay chargeback stages: OPEN raised by the issuer, REPRESENTED evidence sent back
+// to the issuer, WON and LOST the final decision
+func xmlPayChargebackStage(stage string) (string, error) {
+	switch strings.ToUpper(stage) {
+	case "OPEN":
+		return models.DisputeStatusOpened, nil
+	case "REPRESENTED":
+		return models.DisputeStatusEvidenceSubmitted, nil
+	case "WON":
+		return models.DisputeStatusWon, nil
+	case "LOST":
+		return models.DisputeStatusLost, nil
+	default:
+		return "", fmt.Errorf("%w: chargeback stage %q", ErrUnknownStatus, stage)
+	}
+}
+
+// xmlPayStatus maps xmlpay result codes: 00 approved, 01 in progress, 05 declined, 51 insufficient funds,
+// 91 issuer or switch unavailable
+func xmlPayStatus(code string) (string, error) {
+	switch code {
+	case "00":
+		return models.TransactionStatusDone, nil
+	case "01":
+		return models.TransactionStatusPending, nil
+	case "05", "51":
+		return models.TransactionStatusFailed, nil
+	case "91":
+		return "", ErrUnavailable
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownStatus, code)
+	}
+}
@@ -0,0 +1,254 @@
+package adapters
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXMLPayAdapter_Withdrawal(t *testing.T) {
+	tests := []struct {
+		name       string
+		resultCode string
+		wantStatus string
+		wantErr    error
+	}{
+		{name: "approved", resultCode: "00", wantStatus: models.TransactionStatusDone},
+		{name: "in progress", resultCode: "01", wantStatus: models.TransactionStatusPending},
+		{name: "insufficient funds", resultCode: "51", wantStatus: models.TransactionStatusFailed},
+		{name: "unknown code", resultCode: "99", wantErr: ErrUnknownStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got xmlPayRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, xmlPayTransactionsPath, r.URL.Path)
+				assert.Equal(t, codec.ContentTypeXML, r.Header.Get("Content-Type"))
+				assert.NoError(t, xml.NewDecoder(r.Body).Decode(&got))
+
+				w.Header().Set("Content-Type", codec.ContentTypeXML)
+				_, _ = w.Write([]byte(`<PaymentResponse><TransactionID>X-9</TransactionID><ResultCode>` +
+					tt.resultCode + `</ResultCode></PaymentResponse>`))
+			}))
+			defer server.Close()
+
+			adapter := NewXMLPayAdapter(NewHTTPClient(time.Second))
+			gw := models.Gateway{Name: XMLPayName, BaseURL: server.URL}
+			tx := models.Transaction{ID: 5, UserID: 3, Amount: money.MustParse("12", "GBP"), Type: models.TransactionTypeWithdrawal,
+				// gateway settles in EUR
+				Conversion: &models.FXConversion{Amount: money.MustParse("14", "EUR"), Rate: money.MustParseDecimal("1.16672500")},
+			}
+
+			resp, err := adapter.Withdrawal(context.Background(), gw, tx)
+
+			assert.Equal(t, "5", got.Reference)
+			assert.Equal(t, "WITHDRAWAL", got.Operation)
+			assert.Equal(t, "14.00", got.Amount)
+			assert.Equal(t, "EUR", got.Currency)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "X-9", resp.Reference)
+			assert.Equal(t, tt.wantStatus, resp.Status)
+		})
+	}
+}
+
+func TestXMLPayAdapter_Refund(t *testing.T) {
+	var got xmlPayRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, xmlPayTransactionsPath, r.URL.Path)
+		assert.NoError(t, xml.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", codec.ContentTypeXML)
+		_, _ = w.Write([]byte(`<PaymentResponse><TransactionID>X-10</TransactionID><ResultCode>01</ResultCode></PaymentResponse>`))
+	}))
+	defer server.Close()
+
+	adapter := NewXMLPayAdapter(NewHTTPClient(time.Second))
+	gw := models.Gateway{Name: XMLPayName, BaseURL: server.URL}
+	original := models.Transaction{ID: 5, UserID: 3, Amount: money.MustParse("12", "EUR"), Type: models.TransactionTypeDeposit, GatewayReference: "X-9"}
+	refund := models.Transaction{ID: 6, UserID: 3, Amount: money.MustParse("4", "EUR"), Type: models.TransactionTypeRefund, ParentID: 5}
+
+	resp, err := adapter.Refund(context.Background(), gw, refund, original)
+	require.NoError(t, err)
+
+	assert.Equal(t, xmlPayRequest{
+		XMLName:               xml.Name{Local: "PaymentRequest"},
+		Reference:             "6",
+		Operation:             "REFUND",
+		Amount:                "4.00",
+		Currency:              "EUR",
+		Customer:              "3",
+		OriginalTransactionID: "X-9",
+	}, got)
+	assert.Equal(t, &Response{Reference: "X-10", Status: models.TransactionStatusPending}, resp)
+
+	original.GatewayReference = ""
+	_, err = adapter.Refund(context.Background(), gw, refund, original)
+	assert.ErrorIs(t, err, ErrMissingReference)
+}
+
+func TestXMLPayAdapter_AuthorizeCaptureVoid(t *testing.T) {
+	var got []xmlPayRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req xmlPayRequest
+		assert.NoError(t, xml.NewDecoder(r.Body).Decode(&req))
+		got = append(got, req)
+
+		w.Header().Set("Content-Type", codec.ContentTypeXML)
+		_, _ = w.Write([]byte(`<PaymentResponse><TransactionID>X-` + req.Reference + `</TransactionID><ResultCode>00</ResultCode></PaymentResponse>`))
+	}))
+	defer server.Close()
+
+	adapter := NewXMLPayAdapter(NewHTTPClient(time.Second))
+	gw := models.Gateway{Name: XMLPayName, BaseURL: server.URL}
+	authorization := models.Transaction{ID: 5, UserID: 3, Amount: money.MustParse("12", "EUR"), Type: models.TransactionTypeAuthorization}
+	capture := models.Transaction{ID: 6, UserID: 3, Amount: money.MustParse("12", "EUR"), Type: models.TransactionTypeCapture, ParentID: 5}
+
+	resp, err := adapter.Authorize(context.Background(), gw, authorization)
+	require.NoError(t, err)
+	assert.Equal(t, &Response{Reference: "X-5", Status: models.TransactionStatusDone}, resp)
+
+	authorization.GatewayReference = resp.Reference
+	_, err = adapter.Capture(context.Background(), gw, capture, authorization)
+	require.NoError(t, err)
+	_, err = adapter.Void(context.Background(), gw, authorization)
+	require.NoError(t, err)
+
+	request := func(reference, operation, original string) xmlPayRequest {
+		return xmlPayRequest{XMLName: xml.Name{Local: "PaymentRequest"}, Reference: reference, Operation: operation,
+			Amount: "12.00", Currency: "EUR", Customer: "3", OriginalTransactionID: original}
+	}
+	assert.Equal(t, []xmlPayRequest{
+		request("5", "AUTHORIZATION", ""),
+		request("6", "CAPTURE", "X-5"),
+		request("5", "VOID", "X-5"),
+	}, got)
+}
+
+func TestXMLPayAdapter_Status(t *testing.T) {
+	var got xmlPayRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, xml.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", codec.ContentTypeXML)
+		_, _ = w.Write([]byte(`<PaymentResponse><TransactionID>X-9</TransactionID><ResultCode>05</ResultCode></PaymentResponse>`))
+	}))
+	defer server.Close()
+
+	adapter := NewXMLPayAdapter(NewHTTPClient(time.Second))
+	tx := models.Transaction{ID: 9, UserID: 3, Amount: money.MustParse("12", "EUR"), Type: models.TransactionTypeDeposit, GatewayReference: "X-9"}
+
+	resp, err := adapter.Status(context.Background(), models.Gateway{Name: XMLPayName, BaseURL: server.URL}, tx)
+
+	require.NoError(t, err)
+	assert.Equal(t, &Response{Reference: "X-9", Status: models.TransactionStatusFailed}, resp)
+	assert.Equal(t, xmlPayRequest{XMLName: xml.Name{Local: "PaymentRequest"}, Reference: "9", Operation: "INQUIRY",
+		Amount: "12.00", Currency: "EUR", Customer: "3", OriginalTransactionID: "X-9"}, got)
+}
+
+func TestXMLPayAdapter_MalformedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`not xml`))
+	}))
+	defer server.Close()
+
+	adapter := NewXMLPayAdapter(NewHTTPClient(time.Second))
+	_, err := adapter.Deposit(context.Background(), models.Gateway{BaseURL: server.URL}, models.Transaction{ID: 1, Amount: money.MustParse("1", "EUR")})
+	assert.Error(t, err)
+}
+
+func TestXMLPayAdapter_DeclaredFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		contentType string
+		respBody    string
+	}{
+		{
+			name:        "soap envelope",
+			format:      "soap",
+			contentType: codec.ContentTypeSOAP,
+			respBody: `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body>` +
+				`<PaymentResponse><TransactionID>S-1</TransactionID><ResultCode>00</ResultCode></PaymentResponse>` +
+				`</soap:Body></soap:Envelope>`,
+		},
+		{
+			name:        "form urlencoded",
+			format:      "application/x-www-form-urlencoded",
+			contentType: codec.ContentTypeForm,
+			respBody:    `TransactionID=S-1&ResultCode=00`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got xmlPayRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.contentType, r.Header.Get("Content-Type"))
+
+				c, err := codec.Lookup(tt.format)
+				require.NoError(t, err)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.NoError(t, c.Unmarshal(body, &got))
+
+				_, _ = w.Write([]byte(tt.respBody))
+			}))
+			defer server.Close()
+
+			adapter := NewXMLPayAdapter(NewHTTPClient(time.Second))
+			gw := models.Gateway{Name: XMLPayName, BaseURL: server.URL, DataFormatSupported: tt.format}
+
+			resp, err := adapter.Deposit(context.Background(), gw, models.Transaction{ID: 8, Amount: money.MustParse("3", "EUR"), Type: models.TransactionTypeDeposit})
+			require.NoError(t, err)
+			assert.Equal(t, "8", got.Reference)
+			assert.Equal(t, "DEPOSIT", got.Operation)
+			assert.Equal(t, "S-1", resp.Reference)
+			assert.Equal(t, models.TransactionStatusDone, resp.Status)
+		})
+	}
+}
+
+func TestXMLPayAdapter_ParseCallback(t *testing.T) {
+	adapter := NewXMLPayAdapter(nil)
+
+	got, err := adapter.ParseCallback(models.Gateway{Name: XMLPayName},
+		[]byte(`<PaymentNotification><Reference>7</Reference><TransactionID>X-9</TransactionID><ResultCode>51</ResultCode></PaymentNotification>`))
+
+	require.NoError(t, err)
+	assert.Equal(t, &Callback{TransactionID: 7, Reference: "X-9", Status: models.TransactionStatusFailed}, got)
+}
+
+func TestXMLPayAdapter_ParseCallbackChargeback(t *testing.T) {
+	adapter := NewXMLPayAdapter(nil)
+
+	got, err := adapter.ParseCallback(models.Gateway{Name: XMLPayName}, []byte(`<PaymentNotification><Reference>7</Reference>`+
+		`<TransactionID>X-9</TransactionID><Chargeback><CaseID>CB-3</CaseID><Stage>REPRESENTED</Stage><Amount>5.50</Amount>`+
+		`<Currency>USD</Currency><ReasonCode>4837</ReasonCode></Chargeback></PaymentNotification>`))
+
+	require.NoError(t, err)
+	assert.Equal(t, &Callback{TransactionID: 7, Reference: "X-9", Dispute: &DisputeCallback{
+		Reference: "CB-3", Status: models.DisputeStatusEvidenceSubmitted, Amount: money.MustParse("5.50", "USD"), Reason: "4837",
+	}}, got)
+
+	_, err = adapter.ParseCallback(models.Gateway{Name: XMLPayName}, []byte(`<PaymentNotification><Reference>7</Reference>`+
+		`<Chargeback><CaseID>CB-3</CaseID><Stage>ARBITRATION</Stage></Chargeback></PaymentNotification>`))
+	assert.ErrorIs(t, err, ErrInvalidCallback)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/services/gateway"
+	"payment-gateway/internal/util"
+)
+
+type AdminHandler struct {
+	health gateway.HealthChecker
+}
+
+func NewAdminHandler(health gateway.HealthChecker) *AdminHandler {
+	return &AdminHandler{
+		health: health,
+	}
+}
+
+// GatewaysHealthHandler returns last probe result and circuit breaker state of every gateway
+// (GET /admin/gateways/health)
+func (h *AdminHandler) GatewaysHealthHandler(w http.ResponseWriter, r *http.Request) {
+	err := util.EncodeResponse(w, r, models.APIResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Gateways health",
+		Data: DataResp{
+			"gateways": h.health.Statuses(),
+		},
+	})
+
+	if err != nil {
+		log.Printf("Error EncodeResponse: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantCode      int
+	}{
+		{name: "valid token", token: "s3cret", authorization: "Bearer s3cret", wantCode: http.StatusOK},
+		{name: "wrong token", token: "s3cret", authorization: "Bearer guess", wantCode: http.StatusUnauthorized},
+		{name: "token prefix", token: "s3cret", authorization: "Bearer s3cre", wantCode: http.StatusUnauthorized},
+		{name: "no header", token: "s3cret", wantCode: http.StatusUnauthorized},
+		{name: "basic auth", token: "s3cret", authorization: "Basic czNjcmV0", wantCode: http.StatusUnauthorized},
+		{name: "token not set", authorization: "Bearer ", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/disputes/1/status", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			AdminAuth(tt.token)(ok).ServeHTTP(rr, req)
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+}
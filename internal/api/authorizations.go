@@ -0,0 +1,93 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/util"
+
+	"github.com/gorilla/mux"
+)
+
+// AuthorizeHandler holds the amount on the user's payment method until it is captured, voided or expires
+// Sample Request (POST /authorizations):
+//
+//	{
+//	    "amount": 100.00,
+//	    "user_id": 1,
+//	    "currency": "EUR"
+//	}
+func (h *Handler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.TransactionRequest
+	if err := util.DecodeRequest(r, &request); err != nil {
+		log.Printf("Error util.DecodeRequest: %v", err)
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	authorization, err := h.transactionService.Authorize(request)
+	if err != nil {
+		log.Printf("Error h.TransactionService.Authorize: %v", err)
+		writeTransactionError(w, err, "Error authorization")
+		return
+	}
+
+	data := newDataResp(authorization)
+	data["expiresAt"] = authorization.ExpiresAt
+	writeDataResponse(w, r, "Transaction authorization successfully", data)
+}
+
+// CaptureHandler collects the authorized amount, without amount the whole authorized amount is captured
+// Sample Request (POST /authorizations/{id}/capture):
+//
+//	{
+//	    "amount": 60.00
+//	}
+func (h *Handler) CaptureHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid authorization id", http.StatusBadRequest)
+		return
+	}
+
+	request := models.CaptureRequest{}
+	if r.ContentLength != 0 {
+		if err := util.DecodeRequest(r, &request); err != nil {
+			log.Printf("Error util.DecodeRequest: %v", err)
+			http.Error(w, "invalid capture request", http.StatusBadRequest)
+			return
+		}
+	}
+	request.AuthorizationID = id
+
+	capture, err := h.transactionService.Capture(request)
+	if err != nil {
+		log.Printf("Error h.TransactionService.Capture: %v", err)
+		writeTransactionError(w, err, "Error capture")
+		return
+	}
+
+	data := newDataResp(capture)
+	data["parentID"] = capture.ParentID
+	writeDataResponse(w, r, "Transaction capture successfully", data)
+}
+
+// VoidHandler releases the authorized amount without collecting it (POST /authorizations/{id}/void)
+func (h *Handler) VoidHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid authorization id", http.StatusBadRequest)
+		return
+	}
+
+	authorization, err := h.transactionService.Void(id)
+	if err != nil {
+		log.Printf("Error h.TransactionService.Void: %v", err)
+		writeTransactionError(w, err, "Error void")
+		return
+	}
+
+	writeDataResponse(w, r, "Transaction void successfully", newDataResp(authorization))
+}
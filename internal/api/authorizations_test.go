@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/transaction"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func authorizationsRouter(service transaction.TransactionService) *mux.Router {
+	handler := NewHandler(service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/authorizations", handler.AuthorizeHandler).Methods("POST")
+	router.HandleFunc("/authorizations/{id:[0-9]+}/capture", handler.CaptureHandler).Methods("POST")
+	router.HandleFunc("/authorizations/{id:[0-9]+}/void", handler.VoidHandler).Methods("POST")
+	return router
+}
+
+func TestAuthorizeHandler(t *testing.T) {
+	service := &MockTransactionService{}
+
+	req := httptest.NewRequest("POST", "/authorizations", strings.NewReader(`{"amount":100.00,"user_id":1,"currency":"EUR"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	authorizationsRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.TransactionRequest{Amount: money.MustParseDecimal("100.00"), UserID: 1, Currency: "EUR"}, service.lastAuthorization)
+	assert.Contains(t, rr.Body.String(), `"status":"authorized"`)
+	assert.Contains(t, rr.Body.String(), `"expiresAt":"2024-03-08T12:00:00Z"`)
+}
+
+func TestCaptureHandler(t *testing.T) {
+	service := &MockTransactionService{}
+
+	req := httptest.NewRequest("POST", "/authorizations/321/capture", strings.NewReader(`{"amount":60.00}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	authorizationsRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.CaptureRequest{AuthorizationID: 321, Amount: money.MustParseDecimal("60.00")}, service.lastCapture)
+	assert.Contains(t, rr.Body.String(), `"parentID":321`)
+	assert.Contains(t, rr.Body.String(), `"transactionID":322`)
+}
+
+func TestCaptureHandler_WithoutBodyCapturesEverything(t *testing.T) {
+	service := &MockTransactionService{}
+
+	rr := httptest.NewRecorder()
+	authorizationsRouter(service).ServeHTTP(rr, httptest.NewRequest("POST", "/authorizations/321/capture", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.CaptureRequest{AuthorizationID: 321}, service.lastCapture)
+}
+
+func TestVoidHandler(t *testing.T) {
+	service := &MockTransactionService{}
+
+	rr := httptest.NewRecorder()
+	authorizationsRouter(service).ServeHTTP(rr, httptest.NewRequest("POST", "/authorizations/321/void", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 321, service.lastVoid)
+	assert.Contains(t, rr.Body.String(), `"status":"voided"`)
+}
+
+func TestAuthorizationHandlers_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		err      error
+		wantCode int
+	}{
+		{name: "unknown authorization", path: "/authorizations/321/capture",
+			err: fmt.Errorf("%w with ID: %d", repository.ErrTransactionNotFound, 321), wantCode: http.StatusNotFound},
+		{name: "expired authorization", path: "/authorizations/321/capture",
+			err: fmt.Errorf("%w: transaction 321 is a expired authorization", transaction.ErrNotAuthorized), wantCode: http.StatusConflict},
+		{name: "captured twice", path: "/authorizations/321/capture",
+			err: fmt.Errorf("%w: transaction 321", repository.ErrAlreadyCaptured), wantCode: http.StatusConflict},
+		{name: "captured during the void", path: "/authorizations/321/capture",
+			err: fmt.Errorf("%w: transaction 321", repository.ErrVoidInProgress), wantCode: http.StatusConflict},
+		{name: "exceeds authorized amount", path: "/authorizations/321/capture",
+			err: fmt.Errorf("%w: 100.00 authorized, 120.00 requested", repository.ErrCaptureExceedsAmount), wantCode: http.StatusUnprocessableEntity},
+		{name: "void declined", path: "/authorizations/321/void",
+			err: fmt.Errorf("%w: transaction 321 on gateway jsonpay", transaction.ErrVoidDeclined), wantCode: http.StatusUnprocessableEntity},
+		{name: "gateway failure", path: "/authorizations/321/void", err: transaction.ErrGatewayFailed, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			authorizationsRouter(&MockTransactionService{authorizationErr: tt.err}).ServeHTTP(rr, httptest.NewRequest("POST", tt.path, nil))
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/authorizations", strings.NewReader(`{"amount":`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	authorizationsRouter(&MockTransactionService{}).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
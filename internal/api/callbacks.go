@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/callback"
+	"payment-gateway/internal/services/dispute"
+	"payment-gateway/internal/services/transaction"
+	"payment-gateway/internal/util"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	maxCallbackSize          = 1 << 20
+)
+
+type CallbackHandler struct {
+	callbacks callback.Service
+}
+
+func NewCallbackHandler(callbacks callback.Service) *CallbackHandler {
+	return &CallbackHandler{
+		callbacks: callbacks,
+	}
+}
+
+// GatewayCallbackHandler handles signed notification from the payment system,
+// the body is in the gateway's own format and is parsed by its adapter
+// (POST /callbacks/{gateway})
+func (h *CallbackHandler) GatewayCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackSize))
+	if err != nil {
+		log.Printf("Error io.ReadAll: %v", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	err = h.callbacks.Handle(callback.Request{
+		Gateway:   mux.Vars(r)["gateway"],
+		Signature: r.Header.Get(SignatureHeader),
+		Timestamp: r.Header.Get(SignatureTimestampHeader),
+		Nonce:     r.Header.Get(SignatureNonceHeader),
+		Body:      body,
+	})
+	if err != nil {
+		log.Printf("Error h.callbacks.Handle: %v", err)
+		writeCallbackError(w, err)
+		return
+	}
+
+	err = util.EncodeResponse(w, r, models.APIResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Transaction Callback successfully",
+		Data:       DataResp{},
+	})
+
+	if err != nil {
+		log.Printf("Error EncodeResponse: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeCallbackError signature failures are not explained to the caller
+func writeCallbackError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, callback.ErrUnknownGateway):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, callback.ErrInvalidSignature),
+		errors.Is(err, callback.ErrStaleCallback):
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, callback.ErrReplayedCallback):
+		http.Error(w, "Callback already processed", http.StatusConflict)
+	case isStatusConflict(err),
+		errors.Is(err, dispute.ErrIllegalTransition),
+		errors.Is(err, dispute.ErrNotDisputable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, transaction.ErrGatewayMismatch):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+	case errors.Is(err, adapters.ErrInvalidCallback),
+		errors.Is(err, transaction.ErrInvalidStatus),
+		errors.Is(err, dispute.ErrInvalidStatus),
+		errors.Is(err, dispute.ErrInvalidAmount):
+		http.Error(w, "Bad request", http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/services/callback"
+	"payment-gateway/internal/services/dispute"
+	"payment-gateway/internal/services/transaction"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type callbackServiceFunc func(req callback.Request) error
+
+func (f callbackServiceFunc) Handle(req callback.Request) error {
+	return f(req)
+}
+
+func TestGatewayCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceErr     error
+		wantStatusCode int
+	}{
+		{name: "success", wantStatusCode: http.StatusOK},
+		{name: "unknown gateway", serviceErr: callback.ErrUnknownGateway, wantStatusCode: http.StatusNotFound},
+		{name: "invalid signature", serviceErr: callback.ErrInvalidSignature, wantStatusCode: http.StatusUnauthorized},
+		{name: "stale timestamp", serviceErr: fmt.Errorf("%w: old", callback.ErrStaleCallback), wantStatusCode: http.StatusUnauthorized},
+		{name: "replayed nonce", serviceErr: callback.ErrReplayedCallback, wantStatusCode: http.StatusConflict},
+		{name: "illegal transition", serviceErr: &transaction.IllegalTransitionError{TransactionID: 7, From: "failed", To: "done"}, wantStatusCode: http.StatusConflict},
+		{name: "invalid status", serviceErr: transaction.ErrInvalidStatus, wantStatusCode: http.StatusBadRequest},
+		{name: "another gateway", serviceErr: transaction.ErrGatewayMismatch, wantStatusCode: http.StatusForbidden},
+		{name: "invalid payload", serviceErr: adapters.ErrInvalidCallback, wantStatusCode: http.StatusBadRequest},
+		{name: "dispute decided", serviceErr: fmt.Errorf("%w: dispute 3 from won to lost", dispute.ErrIllegalTransition), wantStatusCode: http.StatusConflict},
+		{name: "dispute exceeds amount", serviceErr: dispute.ErrInvalidAmount, wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got callback.Request
+			handler := NewCallbackHandler(callbackServiceFunc(func(req callback.Request) error {
+				got = req
+				return tt.serviceErr
+			}))
+
+			router := mux.NewRouter()
+			router.HandleFunc("/callbacks/{gateway}", handler.GatewayCallbackHandler).Methods("POST")
+
+			req := httptest.NewRequest("POST", "/callbacks/jsonpay", bytes.NewBufferString(`{"status":"approved"}`))
+			req.Header.Set(SignatureHeader, "c2lnbmF0dXJl")
+			req.Header.Set(SignatureTimestampHeader, "1714564800")
+			req.Header.Set(SignatureNonceHeader, "n-1")
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+			assert.Equal(t, callback.Request{
+				Gateway:   "jsonpay",
+				Signature: "c2lnbmF0dXJl",
+				Timestamp: "1714564800",
+				Nonce:     "n-1",
+				Body:      []byte(`{"status":"approved"}`),
+			}, got)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/deadletter"
+
+	"github.com/gorilla/mux"
+)
+
+type DeadLetterHandler struct {
+	deadLetters deadletter.Service
+}
+
+func NewDeadLetterHandler(deadLetters deadletter.Service) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		deadLetters: deadLetters,
+	}
+}
+
+// ListDeadLettersHandler filters by topic, key and status, pages with after_id and limit
+// (GET /admin/dead-letters)
+func (h *DeadLetterHandler) ListDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := deadLetterFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deadLetters, err := h.deadLetters.List(filter)
+	if err != nil {
+		log.Printf("Error h.deadLetters.List: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	data := DataResp{
+		"deadLetters": deadLetters,
+	}
+	if len(deadLetters) > 0 {
+		data["nextAfterID"] = deadLetters[len(deadLetters)-1].ID
+	}
+
+	writeDataResponse(w, r, "Dead letters", data)
+}
+
+// GetDeadLetterHandler (GET /admin/dead-letters/{id})
+func (h *DeadLetterHandler) GetDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+
+	deadLetter, err := h.deadLetters.Get(id)
+	if err != nil {
+		log.Printf("Error h.deadLetters.Get: %v", err)
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	writeDataResponse(w, r, "Dead letter", DataResp{"deadLetter": deadLetter})
+}
+
+// ReplayDeadLetterHandler publishes the dead letter to its topic again
+// (POST /admin/dead-letters/{id}/replay)
+func (h *DeadLetterHandler) ReplayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid dead letter id", http.StatusBadRequest)
+		return
+	}
+
+	deadLetter, err := h.deadLetters.Replay(r.Context(), id)
+	if err != nil {
+		log.Printf("Error h.deadLetters.Replay: %v", err)
+		writeDeadLetterError(w, err)
+		return
+	}
+
+	writeDataResponse(w, r, "Dead letter replayed", DataResp{"deadLetter": deadLetter})
+}
+
+// ReplayDeadLettersHandler replays one page of failed dead letters matching the filter
+// (POST /admin/dead-letters/replay)
+func (h *DeadLetterHandler) ReplayDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := deadLetterFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.deadLetters.ReplayAll(r.Context(), filter)
+	if err != nil {
+		log.Printf("Error h.deadLetters.ReplayAll: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeDataResponse(w, r, "Dead letters replayed", DataResp{"result": result})
+}
+
+func deadLetterFilter(r *http.Request) (models.DeadLetterFilter, error) {
+	query := r.URL.Query()
+	filter := models.DeadLetterFilter{
+		Topic:  query.Get("topic"),
+		Key:    query.Get("key"),
+		Status: query.Get("status"),
+	}
+
+	switch filter.Status {
+	case "", models.DeadLetterStatusFailed, models.DeadLetterStatusReplayed:
+	default:
+		return filter, errors.New("status must be failed or replayed")
+	}
+
+	if afterID := query.Get("after_id"); afterID != "" {
+		id, err := strconv.ParseInt(afterID, 10, 64)
+		if err != nil || id < 0 {
+			return filter, errors.New("invalid after_id")
+		}
+		filter.AfterID = id
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > deadletter.MaxLimit {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(deadletter.MaxLimit))
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// writeDeadLetterError a failed replay means kafka is still unavailable
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrDeadLetterNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, deadletter.ErrAlreadyReplayed), errors.Is(err, deadletter.ErrSuperseded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, deadletter.ErrReplayFailed):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/deadletter"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubDeadLetterService struct {
+	lastFilter models.DeadLetterFilter
+	deadLetter models.DeadLetter
+	err        error
+}
+
+func (s *stubDeadLetterService) List(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	s.lastFilter = filter
+	return []models.DeadLetter{s.deadLetter}, s.err
+}
+
+func (s *stubDeadLetterService) Get(int64) (models.DeadLetter, error) {
+	return s.deadLetter, s.err
+}
+
+func (s *stubDeadLetterService) Replay(context.Context, int64) (models.DeadLetter, error) {
+	return s.deadLetter, s.err
+}
+
+func (s *stubDeadLetterService) ReplayAll(_ context.Context, filter models.DeadLetterFilter) (deadletter.ReplayResult, error) {
+	s.lastFilter = filter
+	return deadletter.ReplayResult{Replayed: 1, LastID: s.deadLetter.ID}, s.err
+}
+
+func deadLetterRouter(service deadletter.Service) *mux.Router {
+	handler := NewDeadLetterHandler(service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/dead-letters", handler.ListDeadLettersHandler).Methods("GET")
+	router.HandleFunc("/admin/dead-letters/replay", handler.ReplayDeadLettersHandler).Methods("POST")
+	router.HandleFunc("/admin/dead-letters/{id:[0-9]+}", handler.GetDeadLetterHandler).Methods("GET")
+	router.HandleFunc("/admin/dead-letters/{id:[0-9]+}/replay", handler.ReplayDeadLetterHandler).Methods("POST")
+	return router
+}
+
+func TestListDeadLettersHandler(t *testing.T) {
+	service := &stubDeadLetterService{deadLetter: models.DeadLetter{ID: 42, Topic: "transactions.json", Key: "7"}}
+
+	rr := httptest.NewRecorder()
+	deadLetterRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/dead-letters?topic=transactions.json&status=failed&after_id=10&limit=5", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.DeadLetterFilter{Topic: "transactions.json", Status: models.DeadLetterStatusFailed, AfterID: 10, Limit: 5}, service.lastFilter)
+
+	var resp models.APIResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, float64(42), resp.Data["nextAfterID"])
+	assert.Len(t, resp.Data["deadLetters"], 1)
+}
+
+func TestListDeadLettersHandler_InvalidFilter(t *testing.T) {
+	for _, query := range []string{"status=dead", "after_id=-1", "limit=0", "limit=5000", "limit=abc"} {
+		rr := httptest.NewRecorder()
+		deadLetterRouter(&stubDeadLetterService{}).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/dead-letters?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestReplayDeadLetterHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceErr     error
+		wantStatusCode int
+	}{
+		{name: "replayed", wantStatusCode: http.StatusOK},
+		{name: "not found", serviceErr: fmt.Errorf("%w with ID: 42", repository.ErrDeadLetterNotFound), wantStatusCode: http.StatusNotFound},
+		{name: "already replayed", serviceErr: deadletter.ErrAlreadyReplayed, wantStatusCode: http.StatusConflict},
+		{name: "superseded", serviceErr: deadletter.ErrSuperseded, wantStatusCode: http.StatusConflict},
+		{name: "kafka unavailable", serviceErr: fmt.Errorf("%w: circuit breaker is open", deadletter.ErrReplayFailed), wantStatusCode: http.StatusBadGateway},
+		{name: "database error", serviceErr: errors.New("connection refused"), wantStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &stubDeadLetterService{deadLetter: models.DeadLetter{ID: 42}, err: tt.serviceErr}
+
+			rr := httptest.NewRecorder()
+			deadLetterRouter(service).ServeHTTP(rr, httptest.NewRequest("POST", "/admin/dead-letters/42/replay", nil))
+
+			assert.Equal(t, tt.wantStatusCode, rr.Code)
+		})
+	}
+}
+
+func TestReplayDeadLettersHandler(t *testing.T) {
+	service := &stubDeadLetterService{deadLetter: models.DeadLetter{ID: 42}}
+
+	rr := httptest.NewRecorder()
+	deadLetterRouter(service).ServeHTTP(rr, httptest.NewRequest("POST", "/admin/dead-letters/replay?key=7", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.DeadLetterFilter{Key: "7"}, service.lastFilter)
+	assert.Contains(t, rr.Body.String(), `"replayed":1`)
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/dispute"
+	"payment-gateway/internal/util"
+
+	"github.com/gorilla/mux"
+)
+
+// evidenceFormOverhead room for the multipart headers and the description around the evidence document
+const evidenceFormOverhead = 64 << 10
+
+type DisputeHandler struct {
+	disputes dispute.Service
+}
+
+func NewDisputeHandler(disputes dispute.Service) *DisputeHandler {
+	return &DisputeHandler{
+		disputes: disputes,
+	}
+}
+
+type disputeView struct {
+	ID               int       `json:"id" xml:"id"`
+	TransactionID    int       `json:"transactionID" xml:"transactionID"`
+	GatewayID        int       `json:"gatewayID" xml:"gatewayID"`
+	GatewayReference string    `json:"gatewayReference,omitempty" xml:"gatewayReference,omitempty"`
+	Status           string    `json:"status" xml:"status"`
+	Amount           string    `json:"amount" xml:"amount"`
+	Currency         string    `json:"currency" xml:"currency"`
+	Reason           string    `json:"reason,omitempty" xml:"reason,omitempty"`
+	CreatedAt        time.Time `json:"createdAt" xml:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt" xml:"updatedAt"`
+}
+
+type disputeDetailView struct {
+	disputeView
+	Evidence []models.DisputeEvidence `json:"evidence" xml:"evidence>document"`
+	History  []statusChangeView       `json:"history" xml:"history>statusChange"`
+}
+
+// ListDisputesHandler filters by transaction_id, gateway_id and status, pages with after_id and limit
+// (GET /admin/disputes)
+func (h *DisputeHandler) ListDisputesHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := disputeFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	disputes, err := h.disputes.List(filter)
+	if err != nil {
+		log.Printf("Error h.disputes.List: %v", err)
+		writeDisputeError(w, err)
+		return
+	}
+
+	views := make([]disputeView, 0, len(disputes))
+	for _, d := range disputes {
+		views = append(views, newDisputeView(d))
+	}
+
+	data := DataResp{
+		"disputes": views,
+	}
+	if len(disputes) > 0 {
+		data["nextAfterID"] = disputes[len(disputes)-1].ID
+	}
+
+	writeDataResponse(w, r, "Disputes", data)
+}
+
+// GetDisputeHandler returns the dispute with its evidence and status history
+// (GET /admin/disputes/{id})
+func (h *DisputeHandler) GetDisputeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid dispute id", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := h.disputes.Get(id)
+	if err != nil {
+		log.Printf("Error h.disputes.Get: %v", err)
+		writeDisputeError(w, err)
+		return
+	}
+
+	view := disputeDetailView{
+		disputeView: newDisputeView(detail.Dispute),
+		Evidence:    detail.Evidence,
+		History:     make([]statusChangeView, 0, len(detail.History)),
+	}
+	if view.Evidence == nil {
+		view.Evidence = []models.DisputeEvidence{}
+	}
+	for _, change := range detail.History {
+		view.History = append(view.History, statusChangeView{
+			From: change.From, To: change.To, Source: change.Source, Actor: change.Actor, Reason: change.Reason, CreatedAt: change.CreatedAt,
+		})
+	}
+
+	writeDataResponse(w, r, "Dispute", DataResp{"dispute": view})
+}
+
+// AddEvidenceHandler attaches the document of the multipart form field "document" to the dispute,
+// the optional "description" field explains it
+// (POST /admin/disputes/{id}/evidence)
+func (h *DisputeHandler) AddEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid dispute id", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, dispute.MaxEvidenceSize+evidenceFormOverhead)
+	file, header, err := r.FormFile("document")
+	if err != nil {
+		log.Printf("Error r.FormFile: %v", err)
+		http.Error(w, "invalid evidence, a multipart document of at most "+strconv.Itoa(dispute.MaxEvidenceSize)+" bytes is required",
+			http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("Error io.ReadAll: %v", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	evidence, err := h.disputes.AddEvidence(models.DisputeEvidence{
+		DisputeID:   id,
+		Name:        header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Description: r.FormValue("description"),
+		Content:     content,
+	})
+	if err != nil {
+		log.Printf("Error h.disputes.AddEvidence: %v", err)
+		writeDisputeError(w, err)
+		return
+	}
+
+	writeDataResponse(w, r, "Dispute evidence added", DataResp{"evidence": evidence})
+}
+
+// GetEvidenceHandler downloads the evidence document as it was uploaded
+// (GET /admin/disputes/{id}/evidence/{evidenceId})
+func (h *DisputeHandler) GetEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid dispute id", http.StatusBadRequest)
+		return
+	}
+	evidenceID, err := strconv.Atoi(mux.Vars(r)["evidenceId"])
+	if err != nil {
+		http.Error(w, "invalid evidence id", http.StatusBadRequest)
+		return
+	}
+
+	evidence, err := h.disputes.GetEvidence(id, evidenceID)
+	if err != nil {
+		log.Printf("Error h.disputes.GetEvidence: %v", err)
+		writeDisputeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", evidence.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": evidence.Name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(evidence.Content)))
+	if _, err := w.Write(evidence.Content); err != nil {
+		log.Printf("Error w.Write: %v", err)
+	}
+}
+
+// UpdateDisputeStatusHandler moves the dispute to the status decided by an operator, a lost dispute reverses
+// the disputed amount from the user wallet
+// Sample Request (POST /admin/disputes/{id}/status):
+//
+//	{
+//	    "status": "lost",
+//	    "actor": "alice",
+//	    "reason": "evidence rejected by the issuer"
+//	}
+func (h *DisputeHandler) UpdateDisputeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid dispute id", http.StatusBadRequest)
+		return
+	}
+
+	update := models.DisputeStatusUpdate{}
+	if err := util.DecodeRequest(r, &update); err != nil {
+		log.Printf("Error util.DecodeRequest: %v", err)
+		http.Error(w, "invalid status request", http.StatusBadRequest)
+		return
+	}
+	update.DisputeID = id
+	update.Source = models.StatusSourceAdmin
+
+	d, err := h.disputes.UpdateStatus(update)
+	if err != nil {
+		log.Printf("Error h.disputes.UpdateStatus: %v", err)
+		writeDisputeError(w, err)
+		return
+	}
+
+	writeDataResponse(w, r, "Dispute status updated", DataResp{"dispute": newDisputeView(*d)})
+}
+
+func newDisputeView(d models.Dispute) disputeView {
+	return disputeView{
+		ID:               d.ID,
+		TransactionID:    d.TransactionID,
+		GatewayID:        d.GatewayID,
+		GatewayReference: d.GatewayReference,
+		Status:           d.Status,
+		Amount:           d.Amount.String(),
+		Currency:         d.Amount.Currency(),
+		Reason:           d.Reason,
+		CreatedAt:        d.CreatedAt,
+		UpdatedAt:        d.UpdatedAt,
+	}
+}
+
+func disputeFilter(r *http.Request) (models.DisputeFilter, error) {
+	query := r.URL.Query()
+	filter := models.DisputeFilter{
+		Status: query.Get("status"),
+	}
+
+	ids := map[string]*int{"transaction_id": &filter.TransactionID, "gateway_id": &filter.GatewayID, "after_id": &filter.AfterID}
+	for name, target := range ids {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, errors.New("invalid " + name)
+			}
+			*target = n
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > dispute.MaxLimit {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(dispute.MaxLimit))
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+func writeDisputeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrDisputeNotFound), errors.Is(err, repository.ErrEvidenceNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, dispute.ErrInvalidStatus), errors.Is(err, dispute.ErrInvalidEvidence):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, dispute.ErrIllegalTransition), errors.Is(err, dispute.ErrDisputeClosed),
+		errors.Is(err, repository.ErrStatusConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/dispute"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubDisputeService struct {
+	lastFilter   models.DisputeFilter
+	lastEvidence models.DisputeEvidence
+	lastUpdate   models.DisputeStatusUpdate
+	dispute      models.Dispute
+	evidence     models.DisputeEvidence
+	err          error
+}
+
+func (s *stubDisputeService) Ingest(models.DisputeNotice) (*models.Dispute, error) {
+	return &s.dispute, s.err
+}
+
+func (s *stubDisputeService) List(filter models.DisputeFilter) ([]models.Dispute, error) {
+	s.lastFilter = filter
+	return []models.Dispute{s.dispute}, s.err
+}
+
+func (s *stubDisputeService) Get(int) (*models.DisputeDetail, error) {
+	return &models.DisputeDetail{
+		Dispute:  s.dispute,
+		Evidence: []models.DisputeEvidence{s.evidence},
+		History:  []models.DisputeTransition{{From: models.DisputeStatusOpened, To: s.dispute.Status, Source: models.StatusSourceCallback}},
+	}, s.err
+}
+
+func (s *stubDisputeService) AddEvidence(evidence models.DisputeEvidence) (*models.DisputeEvidence, error) {
+	s.lastEvidence = evidence
+	return &s.evidence, s.err
+}
+
+func (s *stubDisputeService) GetEvidence(int, int) (*models.DisputeEvidence, error) {
+	return &s.evidence, s.err
+}
+
+func (s *stubDisputeService) UpdateStatus(update models.DisputeStatusUpdate) (*models.Dispute, error) {
+	s.lastUpdate = update
+	return &s.dispute, s.err
+}
+
+func disputeRouter(service dispute.Service) *mux.Router {
+	handler := NewDisputeHandler(service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/disputes", handler.ListDisputesHandler).Methods("GET")
+	router.HandleFunc("/admin/disputes/{id:[0-9]+}", handler.GetDisputeHandler).Methods("GET")
+	router.HandleFunc("/admin/disputes/{id:[0-9]+}/evidence", handler.AddEvidenceHandler).Methods("POST")
+	router.HandleFunc("/admin/disputes/{id:[0-9]+}/evidence/{evidenceId:[0-9]+}", handler.GetEvidenceHandler).Methods("GET")
+	router.HandleFunc("/admin/disputes/{id:[0-9]+}/status", handler.UpdateDisputeStatusHandler).Methods("POST")
+	return router
+}
+
+func lostDispute() models.Dispute {
+	return models.Dispute{
+		ID: 3, TransactionID: 7, GatewayID: 10, GatewayReference: "dp_1", Amount: money.MustParse("40", "EUR"),
+		Reason: "fraudulent", Status: models.DisputeStatusLost, CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestListDisputesHandler(t *testing.T) {
+	service := &stubDisputeService{dispute: lostDispute()}
+
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/disputes?transaction_id=7&status=lost&after_id=2&limit=5", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.DisputeFilter{TransactionID: 7, Status: models.DisputeStatusLost, AfterID: 2, Limit: 5}, service.lastFilter)
+
+	var resp models.APIResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, float64(3), resp.Data["nextAfterID"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"id": float64(3), "transactionID": float64(7), "gatewayID": float64(10), "gatewayReference": "dp_1", "status": "lost",
+		"amount": "40.00", "currency": "EUR", "reason": "fraudulent", "createdAt": "2024-05-01T12:00:00Z", "updatedAt": "0001-01-01T00:00:00Z",
+	}}, resp.Data["disputes"])
+}
+
+func TestListDisputesHandler_InvalidFilter(t *testing.T) {
+	for _, query := range []string{"transaction_id=abc", "after_id=-1", "limit=0", "limit=5000"} {
+		rr := httptest.NewRecorder()
+		disputeRouter(&stubDisputeService{}).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/disputes?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	service := &stubDisputeService{err: fmt.Errorf("%w: %q", dispute.ErrInvalidStatus, "closed")}
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/disputes?status=closed", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetDisputeHandler(t *testing.T) {
+	service := &stubDisputeService{dispute: lostDispute(), evidence: models.DisputeEvidence{ID: 5, DisputeID: 3, Name: "receipt.pdf", Size: 4}}
+
+	req := httptest.NewRequest("GET", "/admin/disputes/3", nil)
+	req.Header.Set("Accept", "application/xml")
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<dispute><id>3</id><transactionID>7</transactionID>`)
+	assert.Contains(t, rr.Body.String(), `<evidence><document><id>5</id><disputeID>3</disputeID><name>receipt.pdf</name>`)
+	assert.Contains(t, rr.Body.String(), `<history><statusChange><from>opened</from><to>lost</to><source>callback</source>`)
+}
+
+func TestGetDisputeHandler_NotFound(t *testing.T) {
+	service := &stubDisputeService{err: fmt.Errorf("%w with ID: %d", repository.ErrDisputeNotFound, 3)}
+
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/disputes/3", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAddEvidenceHandler(t *testing.T) {
+	service := &stubDisputeService{evidence: models.DisputeEvidence{ID: 5, DisputeID: 3, Name: "receipt.pdf", Size: 4}}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("description", "signed delivery receipt"))
+	part, err := form.CreateFormFile("document", "receipt.pdf")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("%PDF"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/admin/disputes/3/evidence", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.DisputeEvidence{
+		DisputeID: 3, Name: "receipt.pdf", ContentType: "application/octet-stream", Description: "signed delivery receipt", Content: []byte("%PDF"),
+	}, service.lastEvidence)
+	assert.Contains(t, rr.Body.String(), `"evidence":{"id":5,"disputeID":3,"name":"receipt.pdf"`)
+}
+
+func TestAddEvidenceHandler_Errors(t *testing.T) {
+	rr := httptest.NewRecorder()
+	disputeRouter(&stubDisputeService{}).ServeHTTP(rr, httptest.NewRequest("POST", "/admin/disputes/3/evidence", strings.NewReader("receipt")))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("document", "receipt.pdf")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("%PDF"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	service := &stubDisputeService{err: fmt.Errorf("%w: dispute 3 is lost", dispute.ErrDisputeClosed)}
+	req := httptest.NewRequest("POST", "/admin/disputes/3/evidence", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr = httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestGetEvidenceHandler(t *testing.T) {
+	service := &stubDisputeService{evidence: models.DisputeEvidence{
+		ID: 5, DisputeID: 3, Name: "receipt.pdf", ContentType: "application/pdf", Content: []byte("%PDF"),
+	}}
+
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/disputes/3/evidence/5", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=receipt.pdf`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "%PDF", rr.Body.String())
+}
+
+func TestUpdateDisputeStatusHandler(t *testing.T) {
+	service := &stubDisputeService{dispute: lostDispute()}
+
+	req := httptest.NewRequest("POST", "/admin/disputes/3/status", strings.NewReader(`{"status":"lost","actor":"alice","reason":"evidence rejected"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	disputeRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.DisputeStatusUpdate{
+		DisputeID: 3, Status: models.DisputeStatusLost, Source: models.StatusSourceAdmin, Actor: "alice", Reason: "evidence rejected",
+	}, service.lastUpdate)
+	assert.Contains(t, rr.Body.String(), `"status":"lost"`)
+}
+
+func TestUpdateDisputeStatusHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "unknown dispute", err: fmt.Errorf("%w with ID: %d", repository.ErrDisputeNotFound, 3), wantCode: http.StatusNotFound},
+		{name: "invalid status", err: fmt.Errorf("%w: %q", dispute.ErrInvalidStatus, "closed"), wantCode: http.StatusBadRequest},
+		{name: "decided", err: fmt.Errorf("%w: dispute 3 from won to lost", dispute.ErrIllegalTransition), wantCode: http.StatusConflict},
+		{name: "database", err: fmt.Errorf("failed to update dispute status"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/disputes/3/status", strings.NewReader(`{"status":"lost"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			disputeRouter(&stubDisputeService{err: tt.err}).ServeHTTP(rr, req)
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
-	"strconv"
 
+	"payment-gateway/internal/ledger"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
 	"payment-gateway/internal/services/transaction"
 	"payment-gateway/internal/util"
 )
@@ -41,7 +43,7 @@ func (h *Handler) DepositHandler(w http.ResponseWriter, r *http.Request) {
 	tx, err := h.transactionService.Deposit(request)
 	if err != nil {
 		log.Printf("Error h.TransactionService.Deposit: %v", err)
-		http.Error(w, "Error deposit", http.StatusInternalServerError)
+		writeTransactionError(w, err, "Error deposit")
 		return
 	}
 
@@ -80,7 +82,7 @@ func (h *Handler) WithdrawalHandler(w http.ResponseWriter, r *http.Request) {
 	tx, err := h.transactionService.Withdrawal(request)
 	if err != nil {
 		log.Printf("Error h.TransactionService.Withdrawal: %v", err)
-		http.Error(w, "Error deposit", http.StatusInternalServerError)
+		writeTransactionError(w, err, "Error withdrawal")
 		return
 	}
 
@@ -97,59 +99,72 @@ func (h *Handler) WithdrawalHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// CallbackHandler handle postback query from payment system
-// (GET /callback?id=101&status=done&gateway=1)
-func (h *Handler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	status := r.URL.Query().Get("status")
-	gateway := r.URL.Query().Get("gateway")
-
-	if gateway == "" || status == "" {
-		log.Printf("Error Query Params: gateway=%s status=%s", gateway, status)
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
-	}
-
-	txID, err := strconv.ParseInt(id, 10, 64)
-	if err != nil {
-		log.Printf("Error Parse Transaction ID: %v", err)
+// writeTransactionError validation errors are returned to the client as is,
+// everything else is hidden behind the generic message
+func writeTransactionError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, transaction.ErrInvalidAmount),
+		errors.Is(err, transaction.ErrInvalidUser),
+		errors.Is(err, transaction.ErrInvalidCurrency):
 		http.Error(w, err.Error(), http.StatusBadRequest)
-
-		return
+	case errors.Is(err, transaction.ErrCurrencyNotAllowed),
+		errors.Is(err, ledger.ErrInsufficientFunds),
+		errors.Is(err, repository.ErrRefundExceedsAmount),
+		errors.Is(err, repository.ErrCaptureExceedsAmount),
+		errors.Is(err, transaction.ErrVoidDeclined):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case isStatusConflict(err),
+		errors.Is(err, transaction.ErrNotRefundable),
+		errors.Is(err, transaction.ErrNotAuthorized),
+		errors.Is(err, repository.ErrAlreadyCaptured),
+		errors.Is(err, repository.ErrVoidInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
 	}
+}
 
-	gatewayID, err := strconv.ParseInt(gateway, 10, 64)
-	if err != nil {
-		log.Printf("Error Parse Transaction ID: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-
-		return
-	}
+// isStatusConflict the transaction can't move to the requested status from the one it is in
+func isStatusConflict(err error) bool {
+	var illegal *transaction.IllegalTransitionError
+	return errors.As(err, &illegal) || errors.Is(err, repository.ErrStatusConflict)
+}
 
-	err = h.transactionService.UpdateStatus(int(txID), gatewayID, status)
-	if err != nil {
-		log.Printf("Error h.TransactionService.UpdateStatus: %v", err)
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
-	}
+type DataResp map[string]interface{}
 
-	err = util.EncodeResponse(w, r, models.APIResponse{
+// writeDataResponse writes 200 with the data in the format negotiated by util.EncodeResponse
+func writeDataResponse(w http.ResponseWriter, r *http.Request, message string, data DataResp) {
+	err := util.EncodeResponse(w, r, models.APIResponse{
 		StatusCode: http.StatusOK,
-		Message:    "Transaction Callback successfully",
-		Data:       DataResp{},
+		Message:    message,
+		Data:       data,
 	})
 
 	if err != nil {
+		log.Printf("Error EncodeResponse: %v", err)
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
 }
 
-type DataResp map[string]interface{}
-
 func newDataResp(tx *models.Transaction) DataResp {
-	return DataResp{
+	data := DataResp{
 		"transactionID": tx.ID,
 		"status":        tx.Status,
+		"amount":        tx.Amount.String(),
+		"currency":      tx.Amount.Currency(),
 	}
+
+	// amount the gateway has received when it settles in another currency
+	if tx.Conversion != nil {
+		data["settlementAmount"] = tx.Conversion.Amount.String()
+		data["settlementCurrency"] = tx.Conversion.Amount.Currency()
+		data["fxRate"] = tx.Conversion.Rate.String()
+		data["fxSource"] = tx.Conversion.Source
+		data["fxRateAt"] = tx.Conversion.RateAt
+	}
+
+	return data
 }
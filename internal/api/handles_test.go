@@ -2,12 +2,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"payment-gateway/internal/models"
-	"strconv"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/services/transaction"
 	"testing"
+	"time"
 )
 
 // MockTransactionService implements TransactionService for testing
@@ -15,12 +19,24 @@ type MockTransactionService struct {
 	shouldFailDeposit    bool
 	shouldFailWithdrawal bool
 	shouldFailUpdate     bool
-	lastTransactionID    int
-	lastGatewayID        int64
-	lastStatus           string
+	depositErr           error
+	lastUpdate           models.StatusUpdate
+	lastFilter           models.TransactionFilter
+	page                 models.TransactionPage
+	detail               *models.TransactionDetail
+	readErr              error
+	lastRefund           models.RefundRequest
+	refundErr            error
+	lastAuthorization    models.TransactionRequest
+	lastCapture          models.CaptureRequest
+	lastVoid             int
+	authorizationErr     error
 }
 
 func (m *MockTransactionService) Deposit(req models.TransactionRequest) (*models.Transaction, error) {
+	if m.depositErr != nil {
+		return nil, m.depositErr
+	}
 	if m.shouldFailDeposit {
 		return nil, errors.New("deposit failed")
 	}
@@ -34,21 +50,70 @@ func (m *MockTransactionService) Withdrawal(req models.TransactionRequest) (*mod
 	return &models.Transaction{ID: 456, Status: "processed"}, nil
 }
 
-func (m *MockTransactionService) UpdateStatus(txID int, gatewayID int64, status string) error {
+func (m *MockTransactionService) UpdateStatus(update models.StatusUpdate) error {
 	if m.shouldFailUpdate {
 		return errors.New("update failed")
 	}
-	m.lastTransactionID = txID
-	m.lastGatewayID = gatewayID
-	m.lastStatus = status
+	m.lastUpdate = update
 	return nil
 }
 
+func (m *MockTransactionService) Refund(req models.RefundRequest) (*models.Transaction, error) {
+	m.lastRefund = req
+	if m.refundErr != nil {
+		return nil, m.refundErr
+	}
+	return &models.Transaction{ID: 789, Status: models.TransactionStatusPending, Amount: money.MustParse("25", "EUR"), ParentID: req.TransactionID}, nil
+}
+
+func (m *MockTransactionService) Authorize(req models.TransactionRequest) (*models.Transaction, error) {
+	m.lastAuthorization = req
+	if m.authorizationErr != nil {
+		return nil, m.authorizationErr
+	}
+	return &models.Transaction{ID: 321, Status: models.TransactionStatusAuthorized, Amount: money.MustParse("100", "EUR"),
+		ExpiresAt: time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)}, nil
+}
+
+func (m *MockTransactionService) Capture(req models.CaptureRequest) (*models.Transaction, error) {
+	m.lastCapture = req
+	if m.authorizationErr != nil {
+		return nil, m.authorizationErr
+	}
+	return &models.Transaction{ID: 322, Status: models.TransactionStatusDone, Amount: money.MustParse("60", "EUR"), ParentID: req.AuthorizationID}, nil
+}
+
+func (m *MockTransactionService) Void(authorizationID int) (*models.Transaction, error) {
+	m.lastVoid = authorizationID
+	if m.authorizationErr != nil {
+		return nil, m.authorizationErr
+	}
+	return &models.Transaction{ID: authorizationID, Status: models.TransactionStatusVoided, Amount: money.MustParse("100", "EUR")}, nil
+}
+
+func (m *MockTransactionService) ExpireAuthorizations(time.Time, int) (int, error) {
+	return 0, nil
+}
+
+func (m *MockTransactionService) PollPending(context.Context, time.Time, int) (int, error) {
+	return 0, nil
+}
+
+func (m *MockTransactionService) SearchTransactions(filter models.TransactionFilter) (models.TransactionPage, error) {
+	m.lastFilter = filter
+	return m.page, m.readErr
+}
+
+func (m *MockTransactionService) GetTransaction(int) (*models.TransactionDetail, error) {
+	return m.detail, m.readErr
+}
+
 func TestDepositHandler(t *testing.T) {
 	tests := []struct {
 		name           string
 		requestBody    string
 		serviceFail    bool
+		serviceErr     error
 		wantStatusCode int
 	}{
 		{
@@ -67,11 +132,23 @@ func TestDepositHandler(t *testing.T) {
 			serviceFail:    true,
 			wantStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name:           "invalid currency",
+			requestBody:    `{"amount":100.00,"user_id":1,"currency":"XXX"}`,
+			serviceErr:     transaction.ErrInvalidCurrency,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "currency not allowed in country",
+			requestBody:    `{"amount":100.00,"user_id":1,"currency":"USD"}`,
+			serviceErr:     fmt.Errorf("%w: USD", transaction.ErrCurrencyNotAllowed),
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockService := &MockTransactionService{shouldFailDeposit: tt.serviceFail}
+			mockService := &MockTransactionService{shouldFailDeposit: tt.serviceFail, depositErr: tt.serviceErr}
 			handler := NewHandler(mockService)
 
 			req := httptest.NewRequest("POST", "/deposit", bytes.NewBufferString(tt.requestBody))
@@ -130,87 +207,20 @@ func TestWithdrawalHandler(t *testing.T) {
 	}
 }
 
-func TestCallbackHandler(t *testing.T) {
-	tests := []struct {
-		name           string
-		queryParams    map[string]string
-		serviceFail    bool
-		wantStatusCode int
-	}{
-		{
-			name: "successful callback",
-			queryParams: map[string]string{
-				"id":      "123",
-				"status":  "completed",
-				"gateway": "456",
-			},
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name: "missing parameters",
-			queryParams: map[string]string{
-				"id": "123",
-			},
-			wantStatusCode: http.StatusBadRequest,
-		},
-		{
-			name: "invalid transaction ID",
-			queryParams: map[string]string{
-				"id":      "invalid",
-				"status":  "completed",
-				"gateway": "456",
-			},
-			wantStatusCode: http.StatusBadRequest,
-		},
-		{
-			name: "service update failure",
-			queryParams: map[string]string{
-				"id":      "123",
-				"status":  "completed",
-				"gateway": "456",
-			},
-			serviceFail:    true,
-			wantStatusCode: http.StatusBadRequest,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockService := &MockTransactionService{shouldFailUpdate: tt.serviceFail}
-			handler := NewHandler(mockService)
-
-			req := httptest.NewRequest("GET", "/callback", nil)
-			q := req.URL.Query()
-			for k, v := range tt.queryParams {
-				q.Add(k, v)
-			}
-			req.URL.RawQuery = q.Encode()
-
-			rr := httptest.NewRecorder()
-			handler.CallbackHandler(rr, req)
+func TestNewDataResp_Conversion(t *testing.T) {
+	tx := &models.Transaction{ID: 1, Status: models.TransactionStatusDone, Amount: money.MustParse("100", "EUR")}
 
-			if status := rr.Code; status != tt.wantStatusCode {
-				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.wantStatusCode)
-			}
+	data := newDataResp(tx)
+	if data["amount"] != "100.00" || data["currency"] != "EUR" {
+		t.Errorf("unexpected amount: %v %v", data["amount"], data["currency"])
+	}
+	if _, ok := data["settlementAmount"]; ok {
+		t.Errorf("settlement amount must be omitted without conversion")
+	}
 
-			// Verify service call parameters for successful cases
-			if tt.wantStatusCode == http.StatusOK {
-				expectedID, _ := strconv.ParseInt(tt.queryParams["id"], 10, 64)
-				expectedGateway, _ := strconv.ParseInt(tt.queryParams["gateway"], 10, 64)
-
-				if mockService.lastTransactionID != int(expectedID) ||
-					mockService.lastGatewayID != expectedGateway ||
-					mockService.lastStatus != tt.queryParams["status"] {
-					t.Errorf("service called with unexpected parameters: got %d/%d/%s want %d/%d/%s",
-						mockService.lastTransactionID,
-						mockService.lastGatewayID,
-						mockService.lastStatus,
-						expectedID,
-						expectedGateway,
-						tt.queryParams["status"],
-					)
-				}
-			}
-		})
+	tx.Conversion = &models.FXConversion{Amount: money.MustParse("108.45", "USD"), Rate: money.MustParseDecimal("1.08450000")}
+	data = newDataResp(tx)
+	if data["settlementAmount"] != "108.45" || data["settlementCurrency"] != "USD" || data["fxRate"] != "1.08450000" {
+		t.Errorf("unexpected conversion: %v", data)
 	}
 }
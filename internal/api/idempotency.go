@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"payment-gateway/internal/idempotency"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	// maxIdempotentBodySize bodies of the requests with a key are read to hash them
+	maxIdempotentBodySize    = 1 << 20
+	idempotencyKeyErr        = "Idempotency-Key is too long"
+	idempotencyMismatchErr   = "Idempotency-Key is already used with another request body"
+	idempotencyInProgressErr = "request with the same Idempotency-Key is in progress"
+)
+
+// Idempotency replays the stored response for requests repeated with the same Idempotency-Key header.
+// The same key with another body is rejected with 422 and a duplicate of the in-flight request with 409.
+// Only 503 responses, the request had no effect, are not stored so the client can retry them. Other 5xx
+// are replayed, e.g. after a gateway timeout the deposit may have been charged and must not be sent again
+func Idempotency(store idempotency.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, idempotencyKeyErr, http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+			if err != nil {
+				log.Printf("Error io.ReadAll: %v", err)
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// keys are scoped by the endpoint, the same key may be used for a deposit and a withdrawal
+			key = r.Method + " " + r.URL.Path + " " + key
+			sum := sha256.Sum256(body)
+			hash := hex.EncodeToString(sum[:])
+
+			record, err := store.Acquire(r.Context(), key, hash)
+			if err != nil {
+				log.Printf("Error store.Acquire: %v", err)
+				http.Error(w, "Internal error", http.StatusInternalServerError)
+				return
+			}
+
+			if record != nil {
+				switch {
+				case record.RequestHash != hash:
+					http.Error(w, idempotencyMismatchErr, http.StatusUnprocessableEntity)
+				case record.Response == nil:
+					http.Error(w, idempotencyInProgressErr, http.StatusConflict)
+				default:
+					replay(w, record.Response)
+				}
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// a client which has disconnected is going to retry, the outcome is stored all the same
+			ctx := context.WithoutCancel(r.Context())
+
+			if rec.statusCode == http.StatusServiceUnavailable {
+				if err := store.Release(ctx, key); err != nil {
+					log.Printf("Error store.Release: %v", err)
+				}
+				return
+			}
+
+			err = store.Complete(ctx, key, idempotency.Record{
+				RequestHash: hash,
+				Response: &idempotency.Response{
+					StatusCode:  rec.statusCode,
+					ContentType: rec.Header().Get("Content-Type"),
+					Body:        rec.body.Bytes(),
+				},
+			})
+			if err != nil {
+				log.Printf("Error store.Complete: %v", err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, resp *idempotency.Response) {
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write(resp.Body); err != nil {
+		log.Printf("Error replay response: %v", err)
+	}
+}
+
+// responseRecorder writes the response to the client and keeps a copy for the store
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/idempotency"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisIdempotencyStore(t *testing.T) (idempotency.Store, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return idempotency.NewRedisStore(client, time.Hour, time.Minute), server
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/deposit", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	store, _ := newRedisIdempotencyStore(t)
+
+	var calls int32
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"transactionID":` + strconv.Itoa(int(n)) + `}`))
+	}))
+
+	body := `{"amount":100.00,"user_id":1,"currency":"EUR"}`
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, idempotentRequest("key-1", body))
+	require.Equal(t, http.StatusOK, first.Code)
+
+	replayed := httptest.NewRecorder()
+	handler.ServeHTTP(replayed, idempotentRequest("key-1", body))
+	assert.Equal(t, http.StatusOK, replayed.Code)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	assert.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+
+	mismatch := httptest.NewRecorder()
+	handler.ServeHTTP(mismatch, idempotentRequest("key-1", `{"amount":200.00,"user_id":1,"currency":"EUR"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+
+	// requests without the key are not deduplicated
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", body))
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", body))
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotency_InFlightDuplicate(t *testing.T) {
+	store, _ := newRedisIdempotencyStore(t)
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	body := `{"amount":1,"user_id":1,"currency":"EUR"}`
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, idempotentRequest("key-2", body))
+		done <- rr
+	}()
+	<-started
+
+	duplicate := httptest.NewRecorder()
+	handler.ServeHTTP(duplicate, idempotentRequest("key-2", body))
+	assert.Equal(t, http.StatusConflict, duplicate.Code)
+
+	close(finish)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+}
+
+func TestIdempotency_UnavailableIsNotStored(t *testing.T) {
+	store, server := newRedisIdempotencyStore(t)
+
+	status := http.StatusServiceUnavailable
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	body := `{"amount":1,"user_id":1,"currency":"EUR"}`
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-3", body))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.False(t, server.Exists("idempotency:POST /deposit key-3"))
+
+	status = http.StatusCreated
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-3", body))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.True(t, server.Exists("idempotency:POST /deposit key-3"))
+}
+
+func TestIdempotency_UnknownOutcomeIsReplayed(t *testing.T) {
+	store, _ := newRedisIdempotencyStore(t)
+
+	var calls int32
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// the gateway has timed out, the deposit may have been charged
+		http.Error(w, "Error deposit", http.StatusInternalServerError)
+	}))
+
+	body := `{"amount":1,"user_id":1,"currency":"EUR"}`
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-5", body))
+
+	retry := httptest.NewRecorder()
+	handler.ServeHTTP(retry, idempotentRequest("key-5", body))
+	assert.Equal(t, http.StatusInternalServerError, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotency_DisconnectedClientResponseIsStored(t *testing.T) {
+	store, server := newRedisIdempotencyStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the client goes away while the deposit is processed
+		cancel()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-6", `{"amount":1}`).WithContext(ctx))
+
+	record, err := store.Acquire(context.Background(), "POST /deposit key-6", "")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.NotNil(t, record.Response)
+	assert.Equal(t, http.StatusOK, record.Response.StatusCode)
+	assert.True(t, server.Exists("idempotency:POST /deposit key-6"))
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	store, _ := newRedisIdempotencyStore(t)
+
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler called with a body over the limit")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-7", strings.Repeat("a", maxIdempotentBodySize+1)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestIdempotency_AbandonedLockExpires(t *testing.T) {
+	store, server := newRedisIdempotencyStore(t)
+
+	record, err := store.Acquire(context.Background(), "key-4", "hash")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	record, err = store.Acquire(context.Background(), "key-4", "hash")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Nil(t, record.Response)
+
+	server.FastForward(time.Minute)
+
+	record, err = store.Acquire(context.Background(), "key-4", "hash")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
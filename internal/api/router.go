@@ -1,43 +1,175 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/idempotency"
 	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/ledger"
 	repo "payment-gateway/internal/repository"
+	"payment-gateway/internal/services/account"
+	"payment-gateway/internal/services/callback"
+	"payment-gateway/internal/services/commands"
+	"payment-gateway/internal/services/deadletter"
+	"payment-gateway/internal/services/dispute"
+	"payment-gateway/internal/services/fx"
 	"payment-gateway/internal/services/gateway"
+	"payment-gateway/internal/services/outbox"
 	"payment-gateway/internal/services/transaction"
 
 	"github.com/gorilla/mux"
 )
 
+const gatewayTimeout = 10 * time.Second
+
 type DiContainer struct {
-	handler *Handler
+	handler       *Handler
+	adminHandler  *AdminHandler
+	callbacks     *CallbackHandler
+	deadLetters   *DeadLetterHandler
+	disputes      *DisputeHandler
+	users         *UserHandler
+	healthChecker gateway.HealthChecker
+	outboxRelay   outbox.Relay
+	expirer       transaction.Expirer
+	poller        transaction.Poller
+	commands      commands.Handler
+	idempotency   idempotency.Store
+	adminToken    string
+
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
 }
 
-func GetContainer(db *sql.DB, kf kafka.KafkaPublisher) *DiContainer {
+// GetContainer callbackKey decrypts the gateway callback secrets, see util.Encrypt,
+// serializer encodes the transaction events published to kafka, adminToken is the bearer token of the admin endpoints
+func GetContainer(db *sql.DB, kf kafka.KafkaPublisher, serializer kafka.Serializer, rates fx.RateProvider, idempotencyStore idempotency.Store, callbackKey []byte, adminToken string) *DiContainer {
 	gatewayRepo := repo.NewGatewayRepository(db)
 	userRepo := repo.NewUserRepository(db)
+	countryRepo := repo.NewCountryRepository(db)
 	transRepo := repo.NewTransactionRepository(db)
+	attemptRepo := repo.NewAttemptRepository(db)
+	routingRepo := repo.NewRoutingRepository(db)
+	nonceRepo := repo.NewCallbackNonceRepository(db)
+	outboxRepo := repo.NewOutboxRepository(db)
+	deadLetterRepo := repo.NewDeadLetterRepository(db)
+	disputeRepo := repo.NewDisputeRepository(db)
+
+	httpClient := adapters.NewHTTPClient(gatewayTimeout)
+	registry := adapters.NewDefaultRegistry(httpClient)
+	healthChecker := gateway.NewHealthChecker(gatewayRepo, httpClient, gateway.DefaultProbeInterval)
+	gatewayService := gateway.NewServiceGateway(gatewayRepo, routingRepo, registry, healthChecker, gateway.DefaultStrategies(attemptRepo))
+
+	fxService := fx.NewService(rates, fx.DefaultCacheTTL)
+
+	userLedger := ledger.NewPostgresLedger(db)
+	transactionService := transaction.NewTransactionService(gatewayService, userRepo, countryRepo, transRepo, attemptRepo, fxService, userLedger, serializer)
 
-	gatewayService := gateway.NewServiceGateway(gatewayRepo)
+	disputeService := dispute.NewService(disputeRepo, transRepo, serializer)
 
-	transactionService := transaction.NewTransactionService(gatewayService, userRepo, transRepo, kf)
+	callbackService := callback.NewService(gatewayRepo, nonceRepo, registry, transactionService, disputeService, callbackKey, callback.DefaultTolerance)
+
+	// one replica polls the pending transactions and one expires the authorizations, the others take over
+	// when its session ends
+	pollerLock := repo.NewAdvisoryLock(db, repo.PendingPollerLockKey)
+	expiryLock := repo.NewAdvisoryLock(db, repo.AuthorizationExpiryLockKey)
 
 	handler := NewHandler(transactionService)
 
 	return &DiContainer{
-		handler: handler,
+		handler:       handler,
+		adminHandler:  NewAdminHandler(healthChecker),
+		callbacks:     NewCallbackHandler(callbackService),
+		deadLetters:   NewDeadLetterHandler(deadletter.NewService(deadLetterRepo, kf)),
+		disputes:      NewDisputeHandler(disputeService),
+		users:         NewUserHandler(account.NewService(userRepo, transRepo, userLedger)),
+		healthChecker: healthChecker,
+		outboxRelay:   outbox.NewRelay(outboxRepo, kf, outbox.DefaultInterval, outbox.DefaultBatchSize, outbox.DefaultMaxAttempts),
+		expirer:       transaction.NewExpirer(transactionService, expiryLock, transaction.DefaultExpiryInterval, transaction.DefaultExpiryBatchSize),
+		poller:        transaction.NewPoller(transactionService, pollerLock, transaction.DefaultPollInterval, transaction.DefaultPollBatchSize),
+		idempotency:   idempotencyStore,
+		commands:      commands.NewHandler(transactionService, kf, idempotencyStore),
+		adminToken:    adminToken,
 	}
 
 }
 
+// StartWorkers runs the background workers and the extra ones, e.g. the kafka consumers, until ctx is done
+// or StopWorkers is called
+func (di *DiContainer) StartWorkers(ctx context.Context, extra ...func(ctx context.Context)) {
+	ctx, di.stopWorkers = context.WithCancel(ctx)
+
+	workers := append([]func(ctx context.Context){di.healthChecker.Run, di.outboxRelay.Run, di.expirer.Run, di.poller.Run}, extra...)
+	for _, run := range workers {
+		di.workers.Add(1)
+		go func(run func(ctx context.Context)) {
+			defer di.workers.Done()
+			run(ctx)
+		}(run)
+	}
+}
+
+// StopWorkers stops the workers and waits until they have returned, e.g. the outbox relay has finished its batch
+// and the poller has released its lock, or ctx is done
+func (di *DiContainer) StopWorkers(ctx context.Context) error {
+	if di.stopWorkers == nil {
+		return nil
+	}
+	di.stopWorkers()
+
+	stopped := make(chan struct{})
+	go func() {
+		di.workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workers still running: %w", ctx.Err())
+	}
+}
+
+// CommandHandler handles the messages of the commands topic, see commands.Topic
+func (di *DiContainer) CommandHandler() kafka.MessageHandler {
+	return di.commands.Handle
+}
+
 func SetupRouter(di *DiContainer) *mux.Router {
 	router := mux.NewRouter()
 
-	router.Handle("/deposit", http.HandlerFunc(di.handler.DepositHandler)).Methods("POST")
-	router.Handle("/withdrawal", http.HandlerFunc(di.handler.WithdrawalHandler)).Methods("POST")
-	router.Handle("/callback", http.HandlerFunc(di.handler.CallbackHandler)).Methods("GET")
+	idempotent := Idempotency(di.idempotency)
+	admin := AdminAuth(di.adminToken)
+
+	router.Handle("/deposit", idempotent(http.HandlerFunc(di.handler.DepositHandler))).Methods("POST")
+	router.Handle("/withdrawal", idempotent(http.HandlerFunc(di.handler.WithdrawalHandler))).Methods("POST")
+	router.Handle("/transactions", http.HandlerFunc(di.handler.SearchTransactionsHandler)).Methods("GET")
+	router.Handle("/transactions/{id:[0-9]+}", http.HandlerFunc(di.handler.GetTransactionHandler)).Methods("GET")
+	router.Handle("/transactions/{id:[0-9]+}/refunds", idempotent(http.HandlerFunc(di.handler.RefundHandler))).Methods("POST")
+	router.Handle("/authorizations", idempotent(http.HandlerFunc(di.handler.AuthorizeHandler))).Methods("POST")
+	router.Handle("/authorizations/{id:[0-9]+}/capture", idempotent(http.HandlerFunc(di.handler.CaptureHandler))).Methods("POST")
+	router.Handle("/authorizations/{id:[0-9]+}/void", idempotent(http.HandlerFunc(di.handler.VoidHandler))).Methods("POST")
+	router.Handle("/users/{id:[0-9]+}/balance", http.HandlerFunc(di.users.BalanceHandler)).Methods("GET")
+	router.Handle("/users/{id:[0-9]+}/statement", http.HandlerFunc(di.users.StatementHandler)).Methods("GET")
+	router.Handle("/callbacks/{gateway}", http.HandlerFunc(di.callbacks.GatewayCallbackHandler)).Methods("POST")
+
+	router.Handle("/admin/gateways/health", admin(http.HandlerFunc(di.adminHandler.GatewaysHealthHandler))).Methods("GET")
+	router.Handle("/admin/dead-letters", admin(http.HandlerFunc(di.deadLetters.ListDeadLettersHandler))).Methods("GET")
+	router.Handle("/admin/dead-letters/replay", admin(http.HandlerFunc(di.deadLetters.ReplayDeadLettersHandler))).Methods("POST")
+	router.Handle("/admin/dead-letters/{id:[0-9]+}", admin(http.HandlerFunc(di.deadLetters.GetDeadLetterHandler))).Methods("GET")
+	router.Handle("/admin/dead-letters/{id:[0-9]+}/replay", admin(http.HandlerFunc(di.deadLetters.ReplayDeadLetterHandler))).Methods("POST")
+	router.Handle("/admin/disputes", admin(http.HandlerFunc(di.disputes.ListDisputesHandler))).Methods("GET")
+	router.Handle("/admin/disputes/{id:[0-9]+}", admin(http.HandlerFunc(di.disputes.GetDisputeHandler))).Methods("GET")
+	router.Handle("/admin/disputes/{id:[0-9]+}/evidence", admin(http.HandlerFunc(di.disputes.AddEvidenceHandler))).Methods("POST")
+	router.Handle("/admin/disputes/{id:[0-9]+}/evidence/{evidenceId:[0-9]+}", admin(http.HandlerFunc(di.disputes.GetEvidenceHandler))).Methods("GET")
+	router.Handle("/admin/disputes/{id:[0-9]+}/status", admin(http.HandlerFunc(di.disputes.UpdateDisputeStatusHandler))).Methods("POST")
 
 	return router
 }
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ServerConfig address and timeouts of the HTTP server, ShutdownTimeout bounds draining the in-flight requests
+// and then closing the resources once the server has stopped
+type ServerConfig struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+}
+
+// DefaultServerConfig the write timeout leaves room for a deposit routed through several gateways, see gatewayTimeout
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Addr:              ":8080",
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+	}
+}
+
+// ServerConfigFromEnv overrides the defaults with HTTP_ADDR and the HTTP_READ_HEADER_TIMEOUT, HTTP_READ_TIMEOUT,
+// HTTP_WRITE_TIMEOUT, HTTP_IDLE_TIMEOUT and HTTP_SHUTDOWN_TIMEOUT durations, e.g. 30s
+func ServerConfigFromEnv() (ServerConfig, error) {
+	cfg := DefaultServerConfig()
+	if addr := os.Getenv("HTTP_ADDR"); addr != "" {
+		cfg.Addr = addr
+	}
+
+	timeouts := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &cfg.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", &cfg.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", &cfg.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", &cfg.IdleTimeout},
+		{"HTTP_SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		value := os.Getenv(timeout.name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid %s %q, must be a positive duration", timeout.name, value)
+		}
+		*timeout.target = d
+	}
+
+	return cfg, nil
+}
+
+// NewServer HTTP server of the handler with the timeouts of the config
+func NewServer(cfg ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// ShutdownStep resource closed after the server has drained its requests, e.g. the workers, the kafka writer
+// or the database pool
+type ShutdownStep struct {
+	Name  string
+	Close func(ctx context.Context) error
+}
+
+// ListenAndServe serves on the address of the server until ctx is done, see Serve
+func ListenAndServe(ctx context.Context, srv *http.Server, shutdownTimeout time.Duration, steps ...ShutdownStep) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		// nothing has been served but the resources are still released
+		return errors.Join(fmt.Errorf("failed to listen on %s: %w", srv.Addr, err), shutdown(shutdownTimeout, steps))
+	}
+	return Serve(ctx, srv, ln, shutdownTimeout, steps...)
+}
+
+// Serve serves the listener until ctx is done or the server fails. The server stops accepting connections and
+// waits for the in-flight requests, then the steps are closed in order. Requests still running after
+// shutdownTimeout are cut off, the steps have another shutdownTimeout and a step is closed even if the previous
+// ones have failed
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration, steps ...ShutdownStep) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	var errs []error
+	select {
+	case err := <-serveErr:
+		errs = append(errs, fmt.Errorf("failed to serve: %w", err))
+	case <-ctx.Done():
+		log.Println("Shutting down the server, draining in-flight requests...")
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Printf("Error srv.Shutdown: %v", err)
+		// the connections left are closed, their handlers are not waited for
+		errs = append(errs, fmt.Errorf("failed to drain requests: %w", err), srv.Close())
+	}
+
+	errs = append(errs, shutdown(shutdownTimeout, steps))
+	return errors.Join(errs...)
+}
+
+// shutdown closes the steps in order, together they have the timeout
+func shutdown(timeout time.Duration, steps []ShutdownStep) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for _, step := range steps {
+		if err := step.Close(ctx); err != nil {
+			log.Printf("Error closing %s: %v", step.Name, err)
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", step.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
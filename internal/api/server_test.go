@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/services/gateway"
+	"payment-gateway/internal/services/outbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shutdownRecorder records the closed steps and what the handler has done before
+type shutdownRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *shutdownRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *shutdownRecorder) step(name string, err error) ShutdownStep {
+	return ShutdownStep{Name: name, Close: func(context.Context) error {
+		r.record(name)
+		return err
+	}}
+}
+
+func (r *shutdownRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func listen(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return ln
+}
+
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration, steps ...ShutdownStep) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, srv, ln, shutdownTimeout, steps...)
+	}()
+	return done
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		recorder.record("request")
+		_, _ = io.WriteString(w, "deposit done")
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := serve(ctx, NewServer(DefaultServerConfig(), handler), ln, time.Second,
+		recorder.step("workers", nil), recorder.step("kafka writer", nil), recorder.step("database", nil))
+
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// the server waits for the request, nothing is closed meanwhile
+	select {
+	case err := <-done:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Empty(t, recorder.recorded())
+
+	_, err := net.DialTimeout("tcp", ln.Addr().String(), 50*time.Millisecond)
+	assert.Error(t, err, "new connections are refused while draining")
+
+	close(release)
+
+	got := <-response
+	require.NoError(t, got.err)
+	assert.Equal(t, "deposit done", got.body)
+
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"request", "workers", "kafka writer", "database"}, recorder.recorded())
+}
+
+func TestServe_ShutdownTimeoutCutsOffRequests(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := serve(ctx, NewServer(DefaultServerConfig(), handler), ln, 50*time.Millisecond,
+		recorder.step("workers", nil), recorder.step("database", nil))
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+
+	<-started
+	cancel()
+
+	err := <-done
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "failed to drain requests")
+	assert.Error(t, <-requestErr, "the connection of the request is closed")
+
+	// the resources are released all the same
+	assert.Equal(t, []string{"workers", "database"}, recorder.recorded())
+}
+
+func TestServe_ClosesEveryStep(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	errFlush := errors.New("flush failed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Serve(ctx, NewServer(DefaultServerConfig(), http.NotFoundHandler()), listen(t), time.Second,
+		recorder.step("kafka writer", errFlush), recorder.step("database", nil))
+
+	assert.ErrorIs(t, err, errFlush)
+	assert.ErrorContains(t, err, "failed to close kafka writer")
+	assert.Equal(t, []string{"kafka writer", "database"}, recorder.recorded())
+}
+
+func TestListenAndServe_ListenError(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	ln := listen(t)
+	defer ln.Close()
+
+	cfg := DefaultServerConfig()
+	cfg.Addr = ln.Addr().String()
+
+	err := ListenAndServe(context.Background(), NewServer(cfg, http.NotFoundHandler()), time.Second, recorder.step("database", nil))
+
+	assert.ErrorContains(t, err, "failed to listen on "+cfg.Addr)
+	assert.Equal(t, []string{"database"}, recorder.recorded())
+}
+
+func TestServerConfigFromEnv(t *testing.T) {
+	t.Setenv("HTTP_ADDR", ":9090")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "90s")
+	t.Setenv("HTTP_SHUTDOWN_TIMEOUT", "1m")
+
+	cfg, err := ServerConfigFromEnv()
+	require.NoError(t, err)
+
+	want := DefaultServerConfig()
+	want.Addr = ":9090"
+	want.WriteTimeout = 90 * time.Second
+	want.ShutdownTimeout = time.Minute
+	assert.Equal(t, want, cfg)
+
+	srv := NewServer(cfg, http.NotFoundHandler())
+	assert.Equal(t, ":9090", srv.Addr)
+	assert.Equal(t, want.ReadHeaderTimeout, srv.ReadHeaderTimeout)
+	assert.Equal(t, want.ReadTimeout, srv.ReadTimeout)
+	assert.Equal(t, 90*time.Second, srv.WriteTimeout)
+	assert.Equal(t, want.IdleTimeout, srv.IdleTimeout)
+}
+
+func TestServerConfigFromEnv_InvalidTimeout(t *testing.T) {
+	for _, value := range []string{"soon", "0s", "-5s"} {
+		t.Setenv("HTTP_READ_TIMEOUT", value)
+
+		_, err := ServerConfigFromEnv()
+		assert.ErrorContains(t, err, "invalid HTTP_READ_TIMEOUT", value)
+	}
+}
+
+// testWorker stops stopDelay after its context is done
+type testWorker struct {
+	stopDelay time.Duration
+	stopped   *atomic.Int32
+}
+
+func (w testWorker) Run(ctx context.Context) {
+	<-ctx.Done()
+	time.Sleep(w.stopDelay)
+	w.stopped.Add(1)
+}
+
+type testHealthChecker struct {
+	gateway.HealthChecker
+	worker testWorker
+}
+
+func (h testHealthChecker) Run(ctx context.Context) { h.worker.Run(ctx) }
+
+type testRelay struct {
+	outbox.Relay
+	worker testWorker
+}
+
+func (r testRelay) Run(ctx context.Context) { r.worker.Run(ctx) }
+
+func workersContainer(worker testWorker) *DiContainer {
+	return &DiContainer{
+		healthChecker: testHealthChecker{worker: worker},
+		outboxRelay:   testRelay{worker: worker},
+		expirer:       worker,
+		poller:        worker,
+	}
+}
+
+func TestDiContainer_StopWorkers(t *testing.T) {
+	stopped := &atomic.Int32{}
+	worker := testWorker{stopDelay: 20 * time.Millisecond, stopped: stopped}
+	di := workersContainer(worker)
+
+	di.StartWorkers(context.Background(), worker.Run)
+
+	require.NoError(t, di.StopWorkers(context.Background()))
+	assert.Equal(t, int32(5), stopped.Load(), "every worker has returned")
+}
+
+func TestDiContainer_StopWorkersTimeout(t *testing.T) {
+	stopped := &atomic.Int32{}
+	di := workersContainer(testWorker{stopDelay: time.Second, stopped: stopped})
+
+	di.StartWorkers(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := di.StopWorkers(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, stopped.Load())
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/transaction"
+	"payment-gateway/internal/util"
+
+	"github.com/gorilla/mux"
+)
+
+// transactionView transaction in the search and detail responses, the fields of newDataResp and where it was routed
+type transactionView struct {
+	TransactionID      int        `json:"transactionID" xml:"transactionID"`
+	Type               string     `json:"type" xml:"type"`
+	Status             string     `json:"status" xml:"status"`
+	Amount             string     `json:"amount" xml:"amount"`
+	Currency           string     `json:"currency" xml:"currency"`
+	UserID             int        `json:"userID" xml:"userID"`
+	GatewayID          int        `json:"gatewayID" xml:"gatewayID"`
+	CountryID          int        `json:"countryID" xml:"countryID"`
+	GatewayReference   string     `json:"gatewayReference,omitempty" xml:"gatewayReference,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt" xml:"createdAt"`
+	SettlementAmount   string     `json:"settlementAmount,omitempty" xml:"settlementAmount,omitempty"`
+	SettlementCurrency string     `json:"settlementCurrency,omitempty" xml:"settlementCurrency,omitempty"`
+	FXRate             string     `json:"fxRate,omitempty" xml:"fxRate,omitempty"`
+	FXSource           string     `json:"fxSource,omitempty" xml:"fxSource,omitempty"`
+	FXRateAt           *time.Time `json:"fxRateAt,omitempty" xml:"fxRateAt,omitempty"`
+	// ParentID deposit of the refund, authorization of the capture
+	ParentID int `json:"parentID,omitempty" xml:"parentID,omitempty"`
+	// RefundedAmount done refunds of the deposit
+	RefundedAmount string `json:"refundedAmount,omitempty" xml:"refundedAmount,omitempty"`
+	// ExpiresAt end of the authorization, it can't be captured afterwards
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xml:"expiresAt,omitempty"`
+}
+
+type statusChangeView struct {
+	From      string    `json:"from" xml:"from"`
+	To        string    `json:"to" xml:"to"`
+	Source    string    `json:"source" xml:"source"`
+	Actor     string    `json:"actor,omitempty" xml:"actor,omitempty"`
+	Reason    string    `json:"reason,omitempty" xml:"reason,omitempty"`
+	CreatedAt time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+type attemptView struct {
+	AttemptNo        int       `json:"attemptNo" xml:"attemptNo"`
+	GatewayID        int       `json:"gatewayID" xml:"gatewayID"`
+	Status           string    `json:"status" xml:"status"`
+	Error            string    `json:"error,omitempty" xml:"error,omitempty"`
+	GatewayReference string    `json:"gatewayReference,omitempty" xml:"gatewayReference,omitempty"`
+	CreatedAt        time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+type transactionDetailView struct {
+	transactionView
+	History  []statusChangeView `json:"history" xml:"history>statusChange"`
+	Attempts []attemptView      `json:"attempts" xml:"attempts>attempt"`
+}
+
+// SearchTransactionsHandler filters by user_id, gateway_id, country_id, parent_id, type, status, currency, min_amount,
+// max_amount, created_from and created_to, sorts by created_at or amount and pages with cursor and limit
+// (GET /transactions?user_id=1&status=done&sort=amount&order=desc&limit=20)
+func (h *Handler) SearchTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := transactionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.transactionService.SearchTransactions(filter)
+	if err != nil {
+		log.Printf("Error h.TransactionService.SearchTransactions: %v", err)
+		writeSearchError(w, err)
+		return
+	}
+
+	transactions := make([]transactionView, 0, len(page.Transactions))
+	for _, tx := range page.Transactions {
+		transactions = append(transactions, newTransactionView(tx))
+	}
+
+	data := DataResp{
+		"transactions": transactions,
+	}
+	if page.NextCursor != "" {
+		data["nextCursor"] = page.NextCursor
+	}
+
+	writeDataResponse(w, r, "Transactions", data)
+}
+
+// GetTransactionHandler returns the transaction with its status history and gateway attempts
+// (GET /transactions/{id})
+func (h *Handler) GetTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := h.transactionService.GetTransaction(id)
+	if err != nil {
+		log.Printf("Error h.TransactionService.GetTransaction: %v", err)
+		writeSearchError(w, err)
+		return
+	}
+
+	view := transactionDetailView{
+		transactionView: newTransactionView(detail.Transaction),
+		History:         make([]statusChangeView, 0, len(detail.History)),
+		Attempts:        make([]attemptView, 0, len(detail.Attempts)),
+	}
+	for _, change := range detail.History {
+		view.History = append(view.History, statusChangeView{
+			From: change.From, To: change.To, Source: change.Source, Actor: change.Actor, Reason: change.Reason, CreatedAt: change.CreatedAt,
+		})
+	}
+	for _, attempt := range detail.Attempts {
+		view.Attempts = append(view.Attempts, attemptView{
+			AttemptNo: attempt.AttemptNo, GatewayID: attempt.GatewayID, Status: attempt.Status, Error: attempt.Error,
+			GatewayReference: attempt.GatewayReference, CreatedAt: attempt.CreatedAt,
+		})
+	}
+
+	writeDataResponse(w, r, "Transaction", DataResp{"transaction": view})
+}
+
+// RefundHandler refunds the done deposit through its gateway, without amount the whole refundable amount is refunded
+// Sample Request (POST /transactions/{id}/refunds):
+//
+//	{
+//	    "amount": 25.00,
+//	    "reason": "order cancelled"
+//	}
+func (h *Handler) RefundHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	request := models.RefundRequest{}
+	if r.ContentLength != 0 {
+		if err := util.DecodeRequest(r, &request); err != nil {
+			log.Printf("Error util.DecodeRequest: %v", err)
+			http.Error(w, "invalid refund request", http.StatusBadRequest)
+			return
+		}
+	}
+	request.TransactionID = id
+
+	refund, err := h.transactionService.Refund(request)
+	if err != nil {
+		log.Printf("Error h.TransactionService.Refund: %v", err)
+		writeTransactionError(w, err, "Error refund")
+		return
+	}
+
+	data := newDataResp(refund)
+	data["parentID"] = refund.ParentID
+	writeDataResponse(w, r, "Transaction refund successfully", data)
+}
+
+func newTransactionView(tx models.Transaction) transactionView {
+	view := transactionView{
+		TransactionID:    tx.ID,
+		Type:             tx.Type,
+		Status:           tx.Status,
+		Amount:           tx.Amount.String(),
+		Currency:         tx.Amount.Currency(),
+		UserID:           tx.UserID,
+		GatewayID:        tx.GatewayID,
+		CountryID:        tx.CountryID,
+		GatewayReference: tx.GatewayReference,
+		CreatedAt:        tx.CreatedAt,
+		ParentID:         tx.ParentID,
+	}
+	if tx.Type == models.TransactionTypeDeposit {
+		view.RefundedAmount = tx.Refunded.String()
+	}
+	if !tx.ExpiresAt.IsZero() {
+		expiresAt := tx.ExpiresAt
+		view.ExpiresAt = &expiresAt
+	}
+
+	if tx.Conversion != nil {
+		rateAt := tx.Conversion.RateAt
+		view.SettlementAmount = tx.Conversion.Amount.String()
+		view.SettlementCurrency = tx.Conversion.Amount.Currency()
+		view.FXRate = tx.Conversion.Rate.String()
+		view.FXSource = tx.Conversion.Source
+		view.FXRateAt = &rateAt
+	}
+	return view
+}
+
+func transactionFilter(r *http.Request) (models.TransactionFilter, error) {
+	query := r.URL.Query()
+	filter := models.TransactionFilter{
+		Type:     query.Get("type"),
+		Status:   query.Get("status"),
+		Currency: query.Get("currency"),
+		Sort:     query.Get("sort"),
+		Cursor:   query.Get("cursor"),
+	}
+
+	ids := map[string]*int{"user_id": &filter.UserID, "gateway_id": &filter.GatewayID, "country_id": &filter.CountryID,
+		"parent_id": &filter.ParentID, "limit": &filter.Limit}
+	for name, target := range ids {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return filter, fmt.Errorf("invalid %s", name)
+			}
+			*target = n
+		}
+	}
+
+	amounts := map[string]**money.Decimal{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount}
+	for name, target := range amounts {
+		if value := query.Get(name); value != "" {
+			amount, err := money.ParseDecimal(value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s", name)
+			}
+			*target = &amount
+		}
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseQueryTime(query.Get("created_from"), false); err != nil {
+		return filter, fmt.Errorf("invalid created_from: %v", err)
+	}
+	if filter.CreatedTo, err = parseQueryTime(query.Get("created_to"), true); err != nil {
+		return filter, fmt.Errorf("invalid created_to: %v", err)
+	}
+
+	// newest first unless asked otherwise
+	switch query.Get("order") {
+	case "", "desc":
+		filter.Desc = true
+	case "asc":
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	return filter, nil
+}
+
+func writeSearchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, transaction.ErrInvalidFilter):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
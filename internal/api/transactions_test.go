@@ -0,0 +1,202 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/transaction"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transactionsRouter(service transaction.TransactionService) *mux.Router {
+	handler := NewHandler(service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/transactions", handler.SearchTransactionsHandler).Methods("GET")
+	router.HandleFunc("/transactions/{id:[0-9]+}", handler.GetTransactionHandler).Methods("GET")
+	router.HandleFunc("/transactions/{id:[0-9]+}/refunds", handler.RefundHandler).Methods("POST")
+	return router
+}
+
+func TestSearchTransactionsHandler(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := &MockTransactionService{page: models.TransactionPage{
+		Transactions: []models.Transaction{{
+			ID: 7, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusDone, Amount: money.MustParse("25", "EUR"),
+			UserID: 1, GatewayID: 10, CountryID: 2, CreatedAt: created,
+		}},
+		NextCursor: "next",
+	}}
+
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("GET",
+		"/transactions?user_id=1&gateway_id=10&status=done&currency=EUR&min_amount=10&max_amount=100.50"+
+			"&created_from=2024-03-01&created_to=2024-03-31&sort=amount&order=asc&limit=20&cursor=abc", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	minAmount, maxAmount := money.MustParseDecimal("10"), money.MustParseDecimal("100.50")
+	assert.Equal(t, models.TransactionFilter{
+		UserID:      1,
+		GatewayID:   10,
+		Status:      models.TransactionStatusDone,
+		Currency:    "EUR",
+		MinAmount:   &minAmount,
+		MaxAmount:   &maxAmount,
+		CreatedFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		Sort:        models.TransactionSortAmount,
+		Cursor:      "abc",
+		Limit:       20,
+	}, service.lastFilter)
+
+	assert.Contains(t, rr.Body.String(), `"nextCursor":"next"`)
+	assert.Contains(t, rr.Body.String(), `{"transactionID":7,"type":"deposit","status":"done","amount":"25.00","currency":"EUR"`)
+}
+
+func TestSearchTransactionsHandler_NewestFirstByDefault(t *testing.T) {
+	service := &MockTransactionService{}
+
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/transactions", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.TransactionFilter{Desc: true}, service.lastFilter)
+	assert.NotContains(t, rr.Body.String(), "nextCursor")
+}
+
+func TestSearchTransactionsHandler_InvalidFilter(t *testing.T) {
+	for _, query := range []string{"user_id=abc", "limit=0", "min_amount=ten", "created_from=yesterday", "order=up"} {
+		rr := httptest.NewRecorder()
+		transactionsRouter(&MockTransactionService{}).ServeHTTP(rr, httptest.NewRequest("GET", "/transactions?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	service := &MockTransactionService{readErr: fmt.Errorf("%w: unknown status %q", transaction.ErrInvalidFilter, "processing")}
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/transactions?status=processing", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetTransactionHandler(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := &MockTransactionService{detail: &models.TransactionDetail{
+		Transaction: models.Transaction{ID: 7, Status: models.TransactionStatusDone, Amount: money.MustParse("25", "EUR"), CreatedAt: created},
+		History: []models.StatusTransition{
+			{From: models.TransactionStatusCreated, To: models.TransactionStatusSubmitted, Source: models.StatusSourceAPI, CreatedAt: created},
+		},
+		Attempts: []models.TransactionAttempt{
+			{AttemptNo: 1, GatewayID: 10, Status: models.AttemptStatusFailed, Error: "timeout", CreatedAt: created},
+		},
+	}}
+
+	req := httptest.NewRequest("GET", "/transactions/7", nil)
+	req.Header.Set("Accept", "application/xml")
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<transaction><transactionID>7</transactionID>`)
+	assert.Contains(t, rr.Body.String(), `<history><statusChange><from>created</from><to>submitted</to><source>api</source>`)
+	assert.Contains(t, rr.Body.String(), `<attempts><attempt><attemptNo>1</attemptNo><gatewayID>10</gatewayID><status>failed</status><error>timeout</error>`)
+}
+
+func TestGetTransactionHandler_NotFound(t *testing.T) {
+	service := &MockTransactionService{readErr: fmt.Errorf("%w with ID: %d", repository.ErrTransactionNotFound, 7)}
+
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/transactions/7", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestSearchTransactionsHandler_RefundsOfDeposit(t *testing.T) {
+	service := &MockTransactionService{page: models.TransactionPage{
+		Transactions: []models.Transaction{
+			{ID: 8, Type: models.TransactionTypeRefund, Status: models.TransactionStatusDone, Amount: money.MustParse("10", "EUR"), ParentID: 7},
+		},
+	}}
+
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/transactions?parent_id=7", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 7, service.lastFilter.ParentID)
+	assert.Contains(t, rr.Body.String(), `"parentID":7`)
+	assert.NotContains(t, rr.Body.String(), "refundedAmount")
+}
+
+func TestGetTransactionHandler_RefundedAmount(t *testing.T) {
+	service := &MockTransactionService{detail: &models.TransactionDetail{
+		Transaction: models.Transaction{ID: 7, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusDone,
+			Amount: money.MustParse("25", "EUR"), Refunded: money.MustParse("10", "EUR")},
+	}}
+
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/transactions/7", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"refundedAmount":"10.00"`)
+}
+
+func TestRefundHandler(t *testing.T) {
+	service := &MockTransactionService{}
+
+	req := httptest.NewRequest("POST", "/transactions/7/refunds", strings.NewReader(`{"amount":25.00,"reason":"order cancelled"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.RefundRequest{TransactionID: 7, Amount: money.MustParseDecimal("25.00"), Reason: "order cancelled"}, service.lastRefund)
+	assert.Contains(t, rr.Body.String(), `"parentID":7`)
+	assert.Contains(t, rr.Body.String(), `"transactionID":789`)
+}
+
+func TestRefundHandler_WithoutBodyRefundsEverything(t *testing.T) {
+	service := &MockTransactionService{}
+
+	rr := httptest.NewRecorder()
+	transactionsRouter(service).ServeHTTP(rr, httptest.NewRequest("POST", "/transactions/7/refunds", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, models.RefundRequest{TransactionID: 7}, service.lastRefund)
+}
+
+func TestRefundHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "unknown transaction", err: fmt.Errorf("%w with ID: %d", repository.ErrTransactionNotFound, 7), wantCode: http.StatusNotFound},
+		{name: "not refundable", err: fmt.Errorf("%w: transaction 7 is a pending deposit", transaction.ErrNotRefundable), wantCode: http.StatusConflict},
+		{name: "exceeds amount", err: fmt.Errorf("%w: 20.00 of 25.00 refunded", repository.ErrRefundExceedsAmount), wantCode: http.StatusUnprocessableEntity},
+		{name: "invalid amount", err: fmt.Errorf("%w, must be greater than zero", transaction.ErrInvalidAmount), wantCode: http.StatusBadRequest},
+		{name: "gateway failure", err: transaction.ErrGatewayFailed, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			transactionsRouter(&MockTransactionService{refundErr: tt.err}).ServeHTTP(rr, httptest.NewRequest("POST", "/transactions/7/refunds", nil))
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/transactions/7/refunds", strings.NewReader(`{"amount":`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	transactionsRouter(&MockTransactionService{}).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
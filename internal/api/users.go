@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/account"
+
+	"github.com/gorilla/mux"
+)
+
+// dateLayout query dates without time, a date ending a period includes the whole day
+const dateLayout = "2006-01-02"
+
+type UserHandler struct {
+	accounts account.Service
+}
+
+func NewUserHandler(accounts account.Service) *UserHandler {
+	return &UserHandler{
+		accounts: accounts,
+	}
+}
+
+// BalanceHandler returns the balance, available, reserved and pending amounts of the user per currency
+// (GET /users/{id}/balance)
+func (h *UserHandler) BalanceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	balances, err := h.accounts.Balances(userID)
+	if err != nil {
+		log.Printf("Error h.accounts.Balances: %v", err)
+		writeAccountError(w, err)
+		return
+	}
+
+	writeDataResponse(w, r, "User balance", DataResp{"balances": balances})
+}
+
+// StatementHandler returns the postings of the user wallet in the currency with opening, closing and running
+// balances, from and to are RFC 3339 times or dates (GET /users/{id}/statement?currency=EUR&from=2024-03-01&to=2024-03-31).
+// The statement is JSON, XML or CSV depending on the Accept header
+func (h *UserHandler) StatementHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := parseQueryTime(query.Get("from"), false)
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseQueryTime(query.Get("to"), true)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statement, err := h.accounts.Statement(userID, query.Get("currency"), from, to)
+	if err != nil {
+		log.Printf("Error h.accounts.Statement: %v", err)
+		writeAccountError(w, err)
+		return
+	}
+
+	writeDataResponse(w, r, "User statement", DataResp{"statement": statement})
+}
+
+// parseQueryTime RFC 3339 time or date of a query parameter, empty value is the zero time,
+// endOfDay moves a date to the start of the next day
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time or a %s date", value, dateLayout)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func writeAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, account.ErrInvalidCurrency), errors.Is(err, account.ErrInvalidPeriod):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/account"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAccountService struct {
+	from, to  time.Time
+	currency  string
+	balances  []models.UserBalance
+	statement models.Statement
+	err       error
+}
+
+func (s *stubAccountService) Balances(int) ([]models.UserBalance, error) {
+	return s.balances, s.err
+}
+
+func (s *stubAccountService) Statement(_ int, currency string, from, to time.Time) (models.Statement, error) {
+	s.currency, s.from, s.to = currency, from, to
+	return s.statement, s.err
+}
+
+func userRouter(service account.Service) *mux.Router {
+	handler := NewUserHandler(service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id:[0-9]+}/balance", handler.BalanceHandler).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}/statement", handler.StatementHandler).Methods("GET")
+	return router
+}
+
+func testStatement() models.Statement {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return models.Statement{
+		UserID:   1,
+		Currency: "EUR",
+		From:     from,
+		To:       from.AddDate(0, 1, 0),
+		Opening:  money.MustParse("10", "EUR"),
+		Closing:  money.MustParse("7.50", "EUR"),
+		Lines: []models.StatementLine{
+			{EntryID: 3, TransactionID: 7, Type: "deposit", Description: "deposit of transaction 7",
+				Amount: money.MustParse("25", "EUR"), Balance: money.MustParse("35", "EUR"), CreatedAt: from.Add(time.Hour)},
+			{EntryID: 4, TransactionID: 8, Type: "withdrawal", Description: "withdrawal of transaction 8",
+				Amount: money.MustParse("-27.50", "EUR"), Balance: money.MustParse("7.50", "EUR"), CreatedAt: from.Add(2 * time.Hour)},
+		},
+	}
+}
+
+func TestBalanceHandler(t *testing.T) {
+	service := &stubAccountService{balances: []models.UserBalance{{
+		Currency:  "EUR",
+		Balance:   money.MustParse("100", "EUR"),
+		Available: money.MustParse("70", "EUR"),
+		Reserved:  money.MustParse("30", "EUR"),
+		Pending:   money.MustParse("20", "EUR"),
+	}}}
+
+	rr := httptest.NewRecorder()
+	userRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/users/1/balance", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"available":{"amount":"70.00","currency":"EUR"}`)
+	assert.Contains(t, rr.Body.String(), `"pending":{"amount":"20.00","currency":"EUR"}`)
+}
+
+func TestBalanceHandler_UnknownUser(t *testing.T) {
+	service := &stubAccountService{err: fmt.Errorf("%w with ID: 9", repository.ErrUserNotFound)}
+
+	rr := httptest.NewRecorder()
+	userRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/users/9/balance", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestStatementHandler_JSON(t *testing.T) {
+	service := &stubAccountService{statement: testStatement()}
+
+	rr := httptest.NewRecorder()
+	userRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/users/1/statement?currency=EUR&from=2024-03-01&to=2024-03-31", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "EUR", service.currency)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), service.from)
+	// the to date includes the whole day
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), service.to)
+
+	var resp struct {
+		Data struct {
+			Statement models.Statement `json:"statement"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, testStatement(), resp.Data.Statement)
+}
+
+func TestStatementHandler_XML(t *testing.T) {
+	service := &stubAccountService{statement: testStatement()}
+
+	req := httptest.NewRequest("GET", "/users/1/statement?currency=EUR", nil)
+	req.Header.Set("Accept", "application/xml")
+	rr := httptest.NewRecorder()
+	userRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/xml", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `<data><statement><userID>1</userID><currency>EUR</currency>`)
+	assert.Contains(t, rr.Body.String(), `<openingBalance><amount>10.00</amount><currency>EUR</currency></openingBalance>`)
+	assert.Contains(t, rr.Body.String(), `<lines><line><entryID>3</entryID>`)
+}
+
+func TestStatementHandler_CSV(t *testing.T) {
+	service := &stubAccountService{statement: testStatement()}
+
+	req := httptest.NewRequest("GET", "/users/1/statement?currency=EUR", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	userRouter(service).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"createdAt", "entryID", "transactionID", "type", "description", "amount", "balance", "currency"},
+		{"2024-03-01T00:00:00Z", "", "", "opening_balance", "", "", "10.00", "EUR"},
+		{"2024-03-01T01:00:00Z", "3", "7", "deposit", "deposit of transaction 7", "25.00", "35.00", "EUR"},
+		{"2024-03-01T02:00:00Z", "4", "8", "withdrawal", "withdrawal of transaction 8", "-27.50", "7.50", "EUR"},
+		{"2024-04-01T00:00:00Z", "", "", "closing_balance", "", "", "7.50", "EUR"},
+	}, records)
+}
+
+func TestBalanceHandler_CSVNotSupported(t *testing.T) {
+	req := httptest.NewRequest("GET", "/users/1/balance", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	userRouter(&stubAccountService{}).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+}
+
+func TestStatementHandler_InvalidRequest(t *testing.T) {
+	for _, query := range []string{"from=yesterday", "to=2024-13-01"} {
+		rr := httptest.NewRecorder()
+		userRouter(&stubAccountService{}).ServeHTTP(rr, httptest.NewRequest("GET", "/users/1/statement?currency=EUR&"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	rr := httptest.NewRecorder()
+	service := &stubAccountService{err: fmt.Errorf("%w: from must be before to", account.ErrInvalidPeriod)}
+	userRouter(service).ServeHTTP(rr, httptest.NewRequest("GET", "/users/1/statement?currency=EUR&from=2024-03-02&to=2024-03-01", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+)
+
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+	FormatSOAP = "soap"
+	FormatForm = "form"
+
+	ContentTypeJSON = "application/json"
+	ContentTypeXML  = "application/xml"
+	ContentTypeSOAP = "application/soap+xml"
+	ContentTypeForm = "application/x-www-form-urlencoded"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported data format")
+
+// Codec encodes and decodes messages exchanged with the gateways in one wire format
+type Codec interface {
+	// Format short name stored in gateways.data_format_supported
+	Format() string
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Registry keeps codecs by format name and by content type
+type Registry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		codecs: make(map[string]Codec),
+	}
+}
+
+// NewDefaultRegistry returns registry with json, xml, soap and form codecs
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(jsonCodec{}, "text/json")
+	registry.Register(xmlCodec{}, "text/xml")
+	registry.Register(soapCodec{})
+	registry.Register(formCodec{})
+
+	return registry
+}
+
+// Register adds codec under its format, content type and the extra aliases
+func (r *Registry) Register(c Codec, aliases ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range append([]string{c.Format(), c.ContentType()}, aliases...) {
+		r.codecs[normalize(name)] = c
+	}
+}
+
+// Get returns codec by format name ("json", "soap") or content type ("application/xml; charset=utf-8")
+func (r *Registry) Get(format string) (Codec, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.codecs[normalize(format)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	return c, nil
+}
+
+var defaultRegistry = NewDefaultRegistry()
+
+// Lookup returns codec from the default registry
+func Lookup(format string) (Codec, error) {
+	return defaultRegistry.Get(format)
+}
+
+func normalize(format string) string {
+	format = strings.TrimSpace(format)
+	if mediaType, _, err := mime.ParseMediaType(format); err == nil {
+		format = mediaType
+	}
+	return strings.ToLower(format)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Format() string                     { return FormatJSON }
+func (jsonCodec) ContentType() string                { return ContentTypeJSON }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Format() string                     { return FormatXML }
+func (xmlCodec) ContentType() string                { return ContentTypeXML }
+func (xmlCodec) Marshal(v any) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
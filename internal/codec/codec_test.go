@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type payment struct {
+	XMLName  xml.Name `json:"-" xml:"payment"`
+	ID       string   `json:"id" xml:"id"`
+	Amount   string   `json:"amount" xml:"amount"`
+	Attempts int      `json:"attempts" xml:"attempts" form:"tries"`
+	Internal string   `json:"-" xml:"-"`
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "json", want: FormatJSON},
+		{format: "JSON", want: FormatJSON},
+		{format: "application/json; charset=utf-8", want: FormatJSON},
+		{format: "xml", want: FormatXML},
+		{format: "text/xml", want: FormatXML},
+		{format: "soap", want: FormatSOAP},
+		{format: "application/soap+xml", want: FormatSOAP},
+		{format: "form", want: FormatForm},
+		{format: "application/x-www-form-urlencoded", want: FormatForm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			c, err := Lookup(tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Format())
+		})
+	}
+
+	_, err := Lookup("yaml")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	in := payment{ID: "p-1", Amount: "10.50", Attempts: 2, Internal: "secret"}
+
+	for _, format := range []string{FormatJSON, FormatXML, FormatSOAP, FormatForm} {
+		t.Run(format, func(t *testing.T) {
+			c, err := Lookup(format)
+			require.NoError(t, err)
+
+			data, err := c.Marshal(in)
+			require.NoError(t, err)
+			assert.NotContains(t, string(data), "secret")
+
+			var out payment
+			require.NoError(t, c.Unmarshal(data, &out))
+			assert.Equal(t, in.ID, out.ID)
+			assert.Equal(t, in.Amount, out.Amount)
+			assert.Equal(t, in.Attempts, out.Attempts)
+		})
+	}
+}
+
+func TestSOAPCodec(t *testing.T) {
+	c := soapCodec{}
+
+	data, err := c.Marshal(payment{ID: "p-1"})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body><payment>`)
+
+	fault := `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+		`<s:Fault><faultcode>s:Server</faultcode><faultstring>card expired</faultstring></s:Fault>` +
+		`</s:Body></s:Envelope>`
+	err = c.Unmarshal([]byte(fault), &payment{})
+	assert.ErrorIs(t, err, ErrSOAPFault)
+	assert.Contains(t, err.Error(), "card expired")
+}
+
+func TestFormCodec(t *testing.T) {
+	c := formCodec{}
+
+	data, err := c.Marshal(payment{ID: "p 1", Amount: "1.00", Attempts: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "amount=1.00&id=p+1&tries=3", string(data))
+
+	values := map[string]string{}
+	require.NoError(t, c.Unmarshal([]byte("status=ok&id=7"), &values))
+	assert.Equal(t, map[string]string{"status": "ok", "id": "7"}, values)
+
+	_, err = c.Marshal([]string{"a"})
+	assert.ErrorIs(t, err, ErrFormUnsupportedType)
+}
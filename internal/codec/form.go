@@ -0,0 +1,171 @@
+package codec
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrFormUnsupportedType = errors.New("form codec supports only flat structs and string maps")
+
+var xmlNameType = reflect.TypeOf(xml.Name{})
+
+// formCodec encodes flat structs as application/x-www-form-urlencoded,
+// field names are taken from the `form` tag, then from the `json` and `xml` tags
+type formCodec struct{}
+
+func (formCodec) Format() string      { return FormatForm }
+func (formCodec) ContentType() string { return ContentTypeForm }
+
+func (formCodec) Marshal(v any) ([]byte, error) {
+	values := url.Values{}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.Type().Elem().Kind() != reflect.String {
+			return nil, ErrFormUnsupportedType
+		}
+		for _, key := range rv.MapKeys() {
+			values.Set(key.String(), rv.MapIndex(key).String())
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			name, ok := formFieldName(field)
+			if !ok {
+				continue
+			}
+			value, err := formatValue(rv.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			values.Set(name, value)
+		}
+	default:
+		return nil, ErrFormUnsupportedType
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse form: %w", err)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrFormUnsupportedType
+	}
+	rv = rv.Elem()
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.Type().Elem().Kind() != reflect.String {
+			return ErrFormUnsupportedType
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for key := range values {
+			rv.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(values.Get(key)))
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			name, ok := formFieldName(field)
+			if !ok || !values.Has(name) {
+				continue
+			}
+			if err := parseValue(rv.Field(i), values.Get(name)); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+	default:
+		return ErrFormUnsupportedType
+	}
+
+	return nil
+}
+
+func formFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() || field.Type == xmlNameType {
+		return "", false
+	}
+
+	for _, key := range []string{"form", "json", "xml"} {
+		tag, ok := field.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return field.Name, true
+}
+
+func formatValue(v reflect.Value) (string, error) {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	default:
+		return "", ErrFormUnsupportedType
+	}
+}
+
+func parseValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return ErrFormUnsupportedType
+	}
+	return nil
+}
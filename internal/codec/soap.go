@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+const soapEnvelopeNamespace = "http://www.w3.org/2003/05/soap-envelope"
+
+var ErrSOAPFault = errors.New("soap fault")
+
+// soapCodec wraps the XML payload into SOAP 1.2 envelope
+type soapCodec struct{}
+
+type soapEnvelopeOut struct {
+	XMLName xml.Name `xml:"soap:Envelope"`
+	NS      string   `xml:"xmlns:soap,attr"`
+	Body    soapBodyOut
+}
+
+type soapBodyOut struct {
+	XMLName xml.Name `xml:"soap:Body"`
+	Content any
+}
+
+// soapEnvelopeIn matches envelopes of both SOAP 1.1 and 1.2 by the local names
+type soapEnvelopeIn struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Body    struct {
+		Fault *struct {
+			Reason string `xml:"Reason>Text"`
+			String string `xml:"faultstring"`
+		} `xml:"Fault"`
+		Content []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+func (soapCodec) Format() string      { return FormatSOAP }
+func (soapCodec) ContentType() string { return ContentTypeSOAP }
+
+func (soapCodec) Marshal(v any) ([]byte, error) {
+	body, err := xml.Marshal(soapEnvelopeOut{
+		NS:   soapEnvelopeNamespace,
+		Body: soapBodyOut{Content: v},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode soap envelope: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func (soapCodec) Unmarshal(data []byte, v any) error {
+	var envelope soapEnvelopeIn
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("failed to decode soap envelope: %w", err)
+	}
+
+	if fault := envelope.Body.Fault; fault != nil {
+		reason := fault.Reason
+		if reason == "" {
+			reason = fault.String
+		}
+		return fmt.Errorf("%w: %s", ErrSOAPFault, reason)
+	}
+
+	return xml.Unmarshal(envelope.Body.Content, v)
+}
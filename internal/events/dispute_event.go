@@ -0,0 +1,128 @@
+package events
+
+import (
+	_ "embed"
+	"encoding/xml"
+	"time"
+
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Dispute lifecycle event types
+const (
+	TypeDisputeOpened            = "dispute.opened"
+	TypeDisputeEvidenceSubmitted = "dispute.evidence_submitted"
+	TypeDisputeWon               = "dispute.won"
+	TypeDisputeLost              = "dispute.lost"
+)
+
+// DisputeAvroSubject schema registry subject of DisputeEvent
+const DisputeAvroSubject = "payments.events.DisputeEvent"
+
+//go:embed dispute_event.avsc
+var disputeEventSchema string
+
+// DisputeEvent published on every change of the dispute status, keyed by the disputed transaction id
+// so that it is ordered with the events of the transaction
+type DisputeEvent struct {
+	XMLName       xml.Name  `json:"-" xml:"dispute_event"`
+	EventID       string    `json:"event_id" xml:"event_id"`
+	EventType     string    `json:"event_type" xml:"event_type"`
+	SchemaVersion int       `json:"schema_version" xml:"schema_version"`
+	OccurredAt    time.Time `json:"occurred_at" xml:"occurred_at"`
+
+	DisputeID        int    `json:"dispute_id" xml:"dispute_id"`
+	TransactionID    int    `json:"transaction_id" xml:"transaction_id"`
+	Status           string `json:"status" xml:"status"`
+	PreviousStatus   string `json:"previous_status,omitempty" xml:"previous_status,omitempty"`
+	Amount           string `json:"amount" xml:"amount"`
+	Currency         string `json:"currency" xml:"currency"`
+	UserID           int    `json:"user_id" xml:"user_id"`
+	GatewayID        int    `json:"gateway_id" xml:"gateway_id"`
+	GatewayReference string `json:"gateway_reference,omitempty" xml:"gateway_reference,omitempty"`
+	// DisputeReason reason of the payer given by the gateway
+	DisputeReason string `json:"dispute_reason,omitempty" xml:"dispute_reason,omitempty"`
+	// Source and Reason of the status change
+	Source string `json:"source,omitempty" xml:"source,omitempty"`
+	Reason string `json:"reason,omitempty" xml:"reason,omitempty"`
+}
+
+// TypeForDisputeStatus event type emitted when the dispute moves to the status
+func TypeForDisputeStatus(status string) string {
+	switch status {
+	case models.DisputeStatusEvidenceSubmitted:
+		return TypeDisputeEvidenceSubmitted
+	case models.DisputeStatusWon:
+		return TypeDisputeWon
+	case models.DisputeStatusLost:
+		return TypeDisputeLost
+	default:
+		return TypeDisputeOpened
+	}
+}
+
+// NewDisputeEvent describes the dispute of tx in its current status, update is the change which has moved it there
+func NewDisputeEvent(dispute models.Dispute, tx models.Transaction, previousStatus string, update models.DisputeStatusUpdate, occurredAt time.Time) DisputeEvent {
+	return DisputeEvent{
+		EventID:          uuid.NewString(),
+		EventType:        TypeForDisputeStatus(dispute.Status),
+		SchemaVersion:    SchemaVersion,
+		OccurredAt:       occurredAt.UTC(),
+		DisputeID:        dispute.ID,
+		TransactionID:    dispute.TransactionID,
+		Status:           dispute.Status,
+		PreviousStatus:   previousStatus,
+		Amount:           dispute.Amount.String(),
+		Currency:         dispute.Amount.Currency(),
+		UserID:           tx.UserID,
+		GatewayID:        dispute.GatewayID,
+		GatewayReference: dispute.GatewayReference,
+		DisputeReason:    dispute.Reason,
+		Source:           update.Source,
+		Reason:           update.Reason,
+	}
+}
+
+// AvroSchema schema of DisputeEvent, it is registered under DisputeAvroSubject
+func (e DisputeEvent) AvroSchema() (string, string) {
+	return DisputeAvroSubject, disputeEventSchema
+}
+
+// AvroNative goavro representation of the event
+func (e DisputeEvent) AvroNative() map[string]any {
+	return map[string]any{
+		"event_id":          e.EventID,
+		"event_type":        e.EventType,
+		"schema_version":    int32(e.SchemaVersion),
+		"occurred_at":       e.OccurredAt,
+		"dispute_id":        int32(e.DisputeID),
+		"transaction_id":    int32(e.TransactionID),
+		"status":            e.Status,
+		"previous_status":   e.PreviousStatus,
+		"amount":            e.Amount,
+		"currency":          e.Currency,
+		"user_id":           int32(e.UserID),
+		"gateway_id":        int32(e.GatewayID),
+		"gateway_reference": e.GatewayReference,
+		"dispute_reason":    e.DisputeReason,
+		"source":            e.Source,
+		"reason":            e.Reason,
+	}
+}
+
+// OutboxMessage serializes the event for the outbox relay in the format of the serializer
+func (e DisputeEvent) OutboxMessage(serializer kafka.Serializer) (models.OutboxMessage, error) {
+	payload, err := serializer.Serialize(e)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	return models.OutboxMessage{
+		TransactionID: e.TransactionID,
+		ContentType:   serializer.ContentType(),
+		Payload:       payload,
+	}, nil
+}
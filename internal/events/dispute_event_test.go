@@ -0,0 +1,74 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeForDisputeStatus(t *testing.T) {
+	tests := map[string]string{
+		models.DisputeStatusOpened:            TypeDisputeOpened,
+		models.DisputeStatusEvidenceSubmitted: TypeDisputeEvidenceSubmitted,
+		models.DisputeStatusWon:               TypeDisputeWon,
+		models.DisputeStatusLost:              TypeDisputeLost,
+	}
+
+	for status, want := range tests {
+		assert.Equal(t, want, TypeForDisputeStatus(status), status)
+	}
+}
+
+func TestDisputeEvent_OutboxMessage(t *testing.T) {
+	dispute := models.Dispute{
+		ID: 3, TransactionID: 7, GatewayID: 10, GatewayReference: "dp_1", Amount: money.MustParse("20", "EUR"),
+		Reason: "fraudulent", Status: models.DisputeStatusLost,
+	}
+	tx := models.Transaction{ID: 7, UserID: 1}
+	update := models.DisputeStatusUpdate{Source: models.StatusSourceAdmin, Reason: "no evidence"}
+
+	serializer, err := kafka.NewSerializer("json", nil)
+	require.NoError(t, err)
+	message, err := NewDisputeEvent(dispute, tx, models.DisputeStatusOpened, update, time.Now()).OutboxMessage(serializer)
+	require.NoError(t, err)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(message.Payload, &got))
+
+	// keyed by the transaction, the dispute events are ordered with the transaction events
+	assert.Equal(t, 7, message.TransactionID)
+	assert.Equal(t, TypeDisputeLost, got["event_type"])
+	assert.Equal(t, float64(3), got["dispute_id"])
+	assert.Equal(t, models.DisputeStatusOpened, got["previous_status"])
+	assert.Equal(t, "20.00", got["amount"])
+	assert.Equal(t, float64(1), got["user_id"])
+	assert.Equal(t, "fraudulent", got["dispute_reason"])
+}
+
+func TestDisputeEvent_OutboxMessageAvro(t *testing.T) {
+	dispute := models.Dispute{ID: 3, TransactionID: 7, GatewayID: 10, Amount: money.MustParse("20", "EUR"), Status: models.DisputeStatusOpened}
+	occurredAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	registry := kafka.NewLocalRegistry()
+	serializer, err := kafka.NewSerializer("avro", registry)
+	require.NoError(t, err)
+	message, err := NewDisputeEvent(dispute, models.Transaction{ID: 7, UserID: 1}, "", models.DisputeStatusUpdate{}, occurredAt).OutboxMessage(serializer)
+	require.NoError(t, err)
+
+	native, err := kafka.DecodeAvro(registry, message.Payload)
+	require.NoError(t, err)
+	got := native.(map[string]any)
+
+	assert.Equal(t, TypeDisputeOpened, got["event_type"])
+	assert.Equal(t, int32(3), got["dispute_id"])
+	assert.Equal(t, int32(7), got["transaction_id"])
+	assert.Equal(t, "", got["previous_status"])
+	assert.True(t, occurredAt.Equal(got["occurred_at"].(time.Time)))
+}
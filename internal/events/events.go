@@ -0,0 +1,159 @@
+package events
+
+import (
+	_ "embed"
+	"encoding/xml"
+	"time"
+
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion version of the TransactionEvent schema, bumped on breaking changes
+const SchemaVersion = 1
+
+// Transaction lifecycle event types
+const (
+	TypeCreated   = "transaction.created"
+	TypeSubmitted = "transaction.submitted"
+	TypePending   = "transaction.pending"
+	TypeCompleted = "transaction.completed"
+	TypeFailed    = "transaction.failed"
+	TypeExpired   = "transaction.expired"
+	TypeRefunded  = "transaction.refunded"
+	// authorization events, the capture has the lifecycle events of the other transactions
+	TypeAuthorized = "transaction.authorized"
+	TypeCaptured   = "transaction.captured"
+	TypeVoided     = "transaction.voided"
+)
+
+// AvroSubject schema registry subject of TransactionEvent
+const AvroSubject = "payments.events.TransactionEvent"
+
+//go:embed transaction_event.avsc
+var transactionEventSchema string
+
+// TransactionEvent published on every change of the transaction status, keyed by the transaction id
+type TransactionEvent struct {
+	XMLName       xml.Name  `json:"-" xml:"transaction_event"`
+	EventID       string    `json:"event_id" xml:"event_id"`
+	EventType     string    `json:"event_type" xml:"event_type"`
+	SchemaVersion int       `json:"schema_version" xml:"schema_version"`
+	OccurredAt    time.Time `json:"occurred_at" xml:"occurred_at"`
+
+	TransactionID    int    `json:"transaction_id" xml:"transaction_id"`
+	Type             string `json:"type" xml:"type"`
+	Status           string `json:"status" xml:"status"`
+	PreviousStatus   string `json:"previous_status,omitempty" xml:"previous_status,omitempty"`
+	Amount           string `json:"amount" xml:"amount"`
+	Currency         string `json:"currency" xml:"currency"`
+	UserID           int    `json:"user_id" xml:"user_id"`
+	GatewayID        int    `json:"gateway_id" xml:"gateway_id"`
+	CountryID        int    `json:"country_id" xml:"country_id"`
+	GatewayReference string `json:"gateway_reference,omitempty" xml:"gateway_reference,omitempty"`
+	// ParentTransactionID deposit refunded by the refund or authorization collected by the capture,
+	// 0 for the other types
+	ParentTransactionID int `json:"parent_transaction_id,omitempty" xml:"parent_transaction_id,omitempty"`
+	// Source and Reason of the status change, see models.StatusSource*
+	Source string `json:"source,omitempty" xml:"source,omitempty"`
+	Reason string `json:"reason,omitempty" xml:"reason,omitempty"`
+}
+
+// TypeForStatus event type emitted when the transaction moves to the status
+func TypeForStatus(status string) string {
+	switch status {
+	case models.TransactionStatusSubmitted:
+		return TypeSubmitted
+	case models.TransactionStatusPending:
+		return TypePending
+	case models.TransactionStatusDone:
+		return TypeCompleted
+	case models.TransactionStatusFailed:
+		return TypeFailed
+	case models.TransactionStatusExpired:
+		return TypeExpired
+	case models.TransactionStatusReversed:
+		return TypeRefunded
+	case models.TransactionStatusAuthorized:
+		return TypeAuthorized
+	case models.TransactionStatusCaptured:
+		return TypeCaptured
+	case models.TransactionStatusVoided:
+		return TypeVoided
+	default:
+		return TypeCreated
+	}
+}
+
+// NewTransactionEvent describes tx in its current status, update is the change which has moved it there
+// and is empty for the created event
+func NewTransactionEvent(tx models.Transaction, previousStatus string, update models.StatusUpdate, occurredAt time.Time) TransactionEvent {
+	eventType := TypeCreated
+	if previousStatus != "" {
+		eventType = TypeForStatus(tx.Status)
+	}
+
+	return TransactionEvent{
+		EventID:             uuid.NewString(),
+		EventType:           eventType,
+		SchemaVersion:       SchemaVersion,
+		OccurredAt:          occurredAt.UTC(),
+		TransactionID:       tx.ID,
+		Type:                tx.Type,
+		Status:              tx.Status,
+		PreviousStatus:      previousStatus,
+		Amount:              tx.Amount.String(),
+		Currency:            tx.Amount.Currency(),
+		UserID:              tx.UserID,
+		GatewayID:           tx.GatewayID,
+		CountryID:           tx.CountryID,
+		GatewayReference:    tx.GatewayReference,
+		Source:              update.Source,
+		Reason:              update.Reason,
+		ParentTransactionID: tx.ParentID,
+	}
+}
+
+// AvroSchema schema of TransactionEvent, it is registered under AvroSubject
+func (e TransactionEvent) AvroSchema() (string, string) {
+	return AvroSubject, transactionEventSchema
+}
+
+// AvroNative goavro representation of the event
+func (e TransactionEvent) AvroNative() map[string]any {
+	return map[string]any{
+		"event_id":              e.EventID,
+		"event_type":            e.EventType,
+		"schema_version":        int32(e.SchemaVersion),
+		"occurred_at":           e.OccurredAt,
+		"transaction_id":        int32(e.TransactionID),
+		"type":                  e.Type,
+		"status":                e.Status,
+		"previous_status":       e.PreviousStatus,
+		"amount":                e.Amount,
+		"currency":              e.Currency,
+		"user_id":               int32(e.UserID),
+		"gateway_id":            int32(e.GatewayID),
+		"country_id":            int32(e.CountryID),
+		"gateway_reference":     e.GatewayReference,
+		"source":                e.Source,
+		"reason":                e.Reason,
+		"parent_transaction_id": int32(e.ParentTransactionID),
+	}
+}
+
+// OutboxMessage serializes the event for the outbox relay in the format of the serializer
+func (e TransactionEvent) OutboxMessage(serializer kafka.Serializer) (models.OutboxMessage, error) {
+	payload, err := serializer.Serialize(e)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	return models.OutboxMessage{
+		TransactionID: e.TransactionID,
+		ContentType:   serializer.ContentType(),
+		Payload:       payload,
+	}, nil
+}
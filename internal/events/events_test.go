@@ -0,0 +1,112 @@
+package events
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeForStatus(t *testing.T) {
+	tests := map[string]string{
+		models.TransactionStatusSubmitted:  TypeSubmitted,
+		models.TransactionStatusPending:    TypePending,
+		models.TransactionStatusDone:       TypeCompleted,
+		models.TransactionStatusFailed:     TypeFailed,
+		models.TransactionStatusExpired:    TypeExpired,
+		models.TransactionStatusReversed:   TypeRefunded,
+		models.TransactionStatusAuthorized: TypeAuthorized,
+		models.TransactionStatusCaptured:   TypeCaptured,
+		models.TransactionStatusVoided:     TypeVoided,
+	}
+
+	for status, want := range tests {
+		assert.Equal(t, want, TypeForStatus(status), status)
+	}
+}
+
+func TestNewTransactionEvent_Created(t *testing.T) {
+	tx := models.Transaction{
+		ID: 7, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusCreated,
+		Amount: money.MustParse("12.5", "EUR"), UserID: 1, GatewayID: 3, CountryID: 2,
+	}
+	occurredAt := time.Date(2024, 5, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	event := NewTransactionEvent(tx, "", models.StatusUpdate{Source: models.StatusSourceAPI}, occurredAt)
+	serializer, err := kafka.NewSerializer("json", nil)
+	require.NoError(t, err)
+	message, err := event.OutboxMessage(serializer)
+	require.NoError(t, err)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(message.Payload, &got))
+
+	assert.Equal(t, 7, message.TransactionID)
+	assert.Equal(t, codec.ContentTypeJSON, message.ContentType)
+	assert.Equal(t, TypeCreated, got["event_type"])
+	assert.Equal(t, float64(SchemaVersion), got["schema_version"])
+	assert.Equal(t, "2024-05-01T12:00:00Z", got["occurred_at"])
+	assert.Equal(t, "12.50", got["amount"])
+	assert.Equal(t, "EUR", got["currency"])
+	assert.NotContains(t, got, "previous_status")
+	assert.Len(t, got["event_id"], 36)
+}
+
+func TestTransactionEvent_OutboxMessageXML(t *testing.T) {
+	tx := models.Transaction{
+		ID: 7, Type: models.TransactionTypeWithdrawal, Status: models.TransactionStatusDone,
+		Amount: money.MustParse("3", "USD"), UserID: 1, GatewayID: 3, CountryID: 2,
+	}
+	update := models.StatusUpdate{Source: models.StatusSourceCallback, Reason: "paid"}
+
+	serializer, err := kafka.NewSerializer("xml", nil)
+	require.NoError(t, err)
+	message, err := NewTransactionEvent(tx, models.TransactionStatusPending, update, time.Now()).OutboxMessage(serializer)
+	require.NoError(t, err)
+
+	var got TransactionEvent
+	require.NoError(t, xml.Unmarshal(message.Payload, &got))
+
+	assert.Equal(t, codec.ContentTypeXML, message.ContentType)
+	assert.Equal(t, "transaction_event", got.XMLName.Local)
+	assert.Equal(t, TypeCompleted, got.EventType)
+	assert.Equal(t, models.TransactionStatusPending, got.PreviousStatus)
+	assert.Equal(t, "3.00", got.Amount)
+	assert.Equal(t, "paid", got.Reason)
+}
+
+func TestTransactionEvent_OutboxMessageAvro(t *testing.T) {
+	tx := models.Transaction{
+		ID: 7, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusSubmitted,
+		Amount: money.MustParse("12.5", "EUR"), UserID: 1, GatewayID: 3, CountryID: 2,
+	}
+	occurredAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	registry := kafka.NewLocalRegistry()
+	serializer, err := kafka.NewSerializer("avro", registry)
+	require.NoError(t, err)
+	message, err := NewTransactionEvent(tx, models.TransactionStatusCreated, models.StatusUpdate{}, occurredAt).OutboxMessage(serializer)
+	require.NoError(t, err)
+	assert.Equal(t, kafka.ContentTypeAvro, message.ContentType)
+
+	native, err := kafka.DecodeAvro(registry, message.Payload)
+	require.NoError(t, err)
+	got := native.(map[string]any)
+
+	assert.Equal(t, TypeSubmitted, got["event_type"])
+	assert.Equal(t, int32(SchemaVersion), got["schema_version"])
+	assert.True(t, occurredAt.Equal(got["occurred_at"].(time.Time)))
+	assert.Equal(t, int32(7), got["transaction_id"])
+	assert.Equal(t, models.TransactionStatusCreated, got["previous_status"])
+	assert.Equal(t, "12.50", got["amount"])
+	assert.Equal(t, "", got["reason"])
+	assert.Equal(t, int32(0), got["parent_transaction_id"])
+}
@@ -0,0 +1,97 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type postgresStore struct {
+	db      *sql.DB
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+// NewPostgresStore keeps the keys in the idempotency_keys table
+func NewPostgresStore(db *sql.DB, ttl, lockTTL time.Duration) Store {
+	return &postgresStore{
+		db:      db,
+		ttl:     ttl,
+		lockTTL: lockTTL,
+	}
+}
+
+func (s *postgresStore) Acquire(ctx context.Context, key string, requestHash string) (*Record, error) {
+	now := time.Now()
+
+	// expired keys and abandoned locks are taken over by the new request
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, locked_at, created_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    status_code = NULL,
+		    content_type = NULL,
+		    response_body = NULL,
+		    completed_at = NULL,
+		    locked_at = EXCLUDED.locked_at,
+		    created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at < $4
+		   OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.locked_at < $5)
+		RETURNING key
+	`
+	var locked string
+	err := s.db.QueryRowContext(ctx, query, key, requestHash, now, now.Add(-s.ttl), now.Add(-s.lockTTL)).Scan(&locked)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to lock idempotency key: %v", err)
+	}
+
+	var (
+		record      Record
+		statusCode  sql.NullInt64
+		contentType sql.NullString
+		body        []byte
+	)
+	query = `SELECT request_hash, status_code, content_type, response_body FROM idempotency_keys WHERE key = $1`
+	err = s.db.QueryRowContext(ctx, query, key).Scan(&record.RequestHash, &statusCode, &contentType, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch idempotency key: %v", err)
+	}
+
+	if statusCode.Valid {
+		record.Response = &Response{
+			StatusCode:  int(statusCode.Int64),
+			ContentType: contentType.String,
+			Body:        body,
+		}
+	}
+
+	return &record, nil
+}
+
+func (s *postgresStore) Complete(ctx context.Context, key string, record Record) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, response_body = $3, completed_at = $4
+		WHERE key = $5 AND request_hash = $6
+	`
+	_, err := s.db.ExecContext(ctx, query, record.Response.StatusCode, record.Response.ContentType, record.Response.Body,
+		time.Now(), key, record.RequestHash)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %v", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND completed_at IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %v", err)
+	}
+	return nil
+}
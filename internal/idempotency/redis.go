@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "idempotency:"
+
+type redisStore struct {
+	client  redis.Cmdable
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+// NewRedisStore keeps the keys in redis, in-flight keys expire after lockTTL and completed ones after ttl
+func NewRedisStore(client redis.Cmdable, ttl, lockTTL time.Duration) Store {
+	return &redisStore{
+		client:  client,
+		ttl:     ttl,
+		lockTTL: lockTTL,
+	}
+}
+
+func (s *redisStore) Acquire(ctx context.Context, key string, requestHash string) (*Record, error) {
+	value, err := json.Marshal(Record{RequestHash: requestHash})
+	if err != nil {
+		return nil, err
+	}
+
+	// the key may expire between SETNX and GET, then it is free to lock again
+	for i := 0; i < 2; i++ {
+		locked, err := s.client.SetNX(ctx, redisKeyPrefix+key, value, s.lockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock idempotency key: %v", err)
+		}
+		if locked {
+			return nil, nil
+		}
+
+		data, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch idempotency key: %v", err)
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode idempotency key: %v", err)
+		}
+		return &record, nil
+	}
+
+	return nil, fmt.Errorf("failed to lock idempotency key %s", key)
+}
+
+func (s *redisStore) Complete(ctx context.Context, key string, record Record) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Set(ctx, redisKeyPrefix+key, value, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %v", err)
+	}
+	return nil
+}
+
+func (s *redisStore) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, redisKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %v", err)
+	}
+	return nil
+}
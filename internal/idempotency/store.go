@@ -0,0 +1,36 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultTTL how long the response is replayed for the key
+	DefaultTTL = 24 * time.Hour
+	// DefaultLockTTL in-flight requests older than this are considered abandoned and the key can be taken again
+	DefaultLockTTL = time.Minute
+)
+
+// Record request stored under the idempotency key, Response is nil while the request is in flight
+type Record struct {
+	RequestHash string    `json:"request_hash"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+// Response captured response replayed to the retries
+type Response struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+type Store interface {
+	// Acquire locks the key for the request with the body hash, it returns nil when the lock is taken
+	// and the existing record when the key is already in use
+	Acquire(ctx context.Context, key string, requestHash string) (*Record, error)
+	// Complete stores the response and releases the lock
+	Complete(ctx context.Context, key string, record Record) error
+	// Release drops the in-flight key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// DefaultMaxAttempts handler attempts before the message goes to the dead-letter topic
+	DefaultMaxAttempts = 10
+
+	// headers added to the dead-lettered messages
+	DeadLetterErrorHeader     = "dead-letter-error"
+	DeadLetterTopicHeader     = "dead-letter-topic"
+	DeadLetterPartitionHeader = "dead-letter-partition"
+	DeadLetterOffsetHeader    = "dead-letter-offset"
+
+	minRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff = 30 * time.Second
+)
+
+// ErrPoisonMessage is wrapped by the handlers for messages that can never be handled,
+// they are dead-lettered without retries
+var ErrPoisonMessage = errors.New("poison message")
+
+// Message consumed from kafka
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+}
+
+// ContentType data format of the message set by the producer, empty when the header is missing
+func (m Message) ContentType() string {
+	return m.Headers[contentTypeHeader]
+}
+
+// MessageHandler handles the message, the message is redelivered while the handler returns an error
+type MessageHandler func(ctx context.Context, msg Message) error
+
+// Consumer reads the topic in a consumer group with at-least-once delivery: the offset is committed
+// only after the message is handled or dead-lettered, so the handlers must be idempotent
+type Consumer interface {
+	// Run consumes messages until ctx is done
+	Run(ctx context.Context)
+	Close() error
+}
+
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type consumer struct {
+	reader          messageReader
+	deadLetters     messageWriter
+	deadLetterTopic string
+	handler         MessageHandler
+	maxAttempts     int
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+}
+
+// NewConsumer consumes the topic as member of groupID, messages failing maxAttempts times
+// or rejected as ErrPoisonMessage are moved to deadLetterTopic
+func NewConsumer(kafkaURL, groupID, topic, deadLetterTopic string, maxAttempts int, handler MessageHandler) Consumer {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{kafkaURL},
+		GroupID:     groupID,
+		Topic:       topic,
+		StartOffset: kafka.FirstOffset,
+		// offsets are committed synchronously by the consumer
+		CommitInterval: 0,
+	})
+
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(kafkaURL),
+		Balancer:               &kafka.LeastBytes{},
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           10 * time.Millisecond,
+		RequiredAcks:           kafka.RequireAll,
+	}
+
+	return newConsumer(reader, writer, deadLetterTopic, maxAttempts, handler)
+}
+
+func newConsumer(reader messageReader, deadLetters messageWriter, deadLetterTopic string, maxAttempts int, handler MessageHandler) *consumer {
+	return &consumer{
+		reader:          reader,
+		deadLetters:     deadLetters,
+		deadLetterTopic: deadLetterTopic,
+		handler:         handler,
+		maxAttempts:     maxAttempts,
+		minBackoff:      minRetryBackoff,
+		maxBackoff:      maxRetryBackoff,
+	}
+}
+
+func (c *consumer) Run(ctx context.Context) {
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Error kafka fetch: %v", err)
+			if !sleep(ctx, c.minBackoff) {
+				return
+			}
+			continue
+		}
+
+		if err := c.process(ctx, msg); err != nil {
+			// stopped while handling, the message is redelivered after restart
+			return
+		}
+
+		// a failed commit redelivers the message to the next member of the group
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("Error kafka commit: %v", err)
+		}
+	}
+}
+
+// process handles the message until it succeeds or is dead-lettered, it fails only when ctx is done
+func (c *consumer) process(ctx context.Context, msg kafka.Message) error {
+	message := newMessage(msg)
+	backoff := c.minBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := c.handler(ctx, message)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if errors.Is(err, ErrPoisonMessage) || attempt >= c.maxAttempts {
+			log.Printf("Dead-lettering message %s/%d/%d after %d attempts: %v", msg.Topic, msg.Partition, msg.Offset, attempt, err)
+			return c.deadLetter(ctx, msg, err)
+		}
+
+		log.Printf("Error handling message %s/%d/%d, retrying in %s: %v", msg.Topic, msg.Partition, msg.Offset, backoff, err)
+		if !sleep(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+	}
+}
+
+// deadLetter writes the message with the error to the dead-letter topic, it is retried until it succeeds
+// since the offset can't be committed before
+func (c *consumer) deadLetter(ctx context.Context, msg kafka.Message, cause error) error {
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())},
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(msg.Topic)},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+
+	deadLetter := kafka.Message{
+		Topic:   c.deadLetterTopic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+
+	backoff := c.minBackoff
+	for {
+		err := c.deadLetters.WriteMessages(ctx, deadLetter)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("Error writing dead letter to %s, retrying in %s: %v", c.deadLetterTopic, backoff, err)
+		if !sleep(ctx, backoff) {
+			return fmt.Errorf("dead letter not written: %w", ctx.Err())
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+	}
+}
+
+func (c *consumer) Close() error {
+	return errors.Join(c.reader.Close(), c.deadLetters.Close())
+}
+
+func newMessage(msg kafka.Message) Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+
+	return Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Time:      msg.Time,
+	}
+}
+
+// sleep waits for d, it returns false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
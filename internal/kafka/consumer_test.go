@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader serves the messages once and blocks until ctx is done
+type fakeReader struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed []int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.messages) > 0 {
+		msg := r.messages[0]
+		r.messages = r.messages[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		r.committed = append(r.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	messages []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker not available")
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func runConsumer(t *testing.T, c *consumer, reader *fakeReader, committed int) {
+	t.Helper()
+	c.minBackoff = time.Millisecond
+	c.maxBackoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		reader.mu.Lock()
+		defer reader.mu.Unlock()
+		return len(reader.committed) == committed
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestConsumer_CommitsHandledMessages(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{
+		{Topic: "commands", Offset: 1, Value: []byte("a"), Headers: []kafka.Header{{Key: contentTypeHeader, Value: []byte("application/json")}}},
+		{Topic: "commands", Offset: 2, Value: []byte("b")},
+	}}
+	writer := &fakeWriter{}
+
+	var handled []Message
+	c := newConsumer(reader, writer, "commands.dlq", 3, func(_ context.Context, msg Message) error {
+		handled = append(handled, msg)
+		return nil
+	})
+	runConsumer(t, c, reader, 2)
+
+	assert.Equal(t, []int64{1, 2}, reader.committed)
+	require.Len(t, handled, 2)
+	assert.Equal(t, "application/json", handled[0].ContentType())
+	assert.Equal(t, "", handled[1].ContentType())
+	assert.Empty(t, writer.messages)
+}
+
+func TestConsumer_RetriesTransientErrors(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{{Topic: "commands", Offset: 1}}}
+	writer := &fakeWriter{}
+
+	attempts := 0
+	c := newConsumer(reader, writer, "commands.dlq", 3, func(context.Context, Message) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("database is down")
+		}
+		return nil
+	})
+	runConsumer(t, c, reader, 1)
+
+	assert.Equal(t, 3, attempts)
+	assert.Empty(t, writer.messages)
+}
+
+func TestConsumer_DeadLetters(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		attempts int
+	}{
+		"poison message":     {err: fmt.Errorf("%w: invalid json", ErrPoisonMessage), attempts: 1},
+		"attempts exhausted": {err: errors.New("database is down"), attempts: 3},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := &fakeReader{messages: []kafka.Message{
+				{Topic: "commands", Partition: 2, Offset: 7, Key: []byte("k"), Value: []byte("v"),
+					Headers: []kafka.Header{{Key: contentTypeHeader, Value: []byte("application/json")}}},
+			}}
+			// the dead letter is retried until it is written
+			writer := &fakeWriter{failures: 2}
+
+			attempts := 0
+			c := newConsumer(reader, writer, "commands.dlq", 3, func(context.Context, Message) error {
+				attempts++
+				return tt.err
+			})
+			runConsumer(t, c, reader, 1)
+
+			assert.Equal(t, tt.attempts, attempts)
+			require.Len(t, writer.messages, 1)
+
+			deadLetter := newMessage(writer.messages[0])
+			assert.Equal(t, "commands.dlq", deadLetter.Topic)
+			assert.Equal(t, []byte("k"), deadLetter.Key)
+			assert.Equal(t, []byte("v"), deadLetter.Value)
+			assert.Equal(t, "application/json", deadLetter.ContentType())
+			assert.Equal(t, tt.err.Error(), deadLetter.Headers[DeadLetterErrorHeader])
+			assert.Equal(t, "commands", deadLetter.Headers[DeadLetterTopicHeader])
+			assert.Equal(t, "2", deadLetter.Headers[DeadLetterPartitionHeader])
+			assert.Equal(t, "7", deadLetter.Headers[DeadLetterOffsetHeader])
+		})
+	}
+}
+
+func TestConsumer_StopsWithoutCommit(t *testing.T) {
+	reader := &fakeReader{messages: []kafka.Message{{Topic: "commands", Offset: 1}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newConsumer(reader, &fakeWriter{}, "commands.dlq", DefaultMaxAttempts, func(context.Context, Message) error {
+		cancel()
+		return errors.New("interrupted")
+	})
+	c.Run(ctx)
+
+	assert.Empty(t, reader.committed)
+}
@@ -48,6 +48,34 @@ func (mr *MockKafkaPublisherMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockKafkaPublisher)(nil).Close))
 }
 
+// Publish mocks base method.
+func (m *MockKafkaPublisher) Publish(ctx context.Context, topic, key string, v any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, topic, key, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockKafkaPublisherMockRecorder) Publish(ctx, topic, key, v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockKafkaPublisher)(nil).Publish), ctx, topic, key, v)
+}
+
+// PublishMessage mocks base method.
+func (m *MockKafkaPublisher) PublishMessage(ctx context.Context, topic, key string, message []byte, dataFormat string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishMessage", ctx, topic, key, message, dataFormat)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishMessage indicates an expected call of PublishMessage.
+func (mr *MockKafkaPublisherMockRecorder) PublishMessage(ctx, topic, key, message, dataFormat interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishMessage", reflect.TypeOf((*MockKafkaPublisher)(nil).PublishMessage), ctx, topic, key, message, dataFormat)
+}
+
 // PublishTransaction mocks base method.
 func (m *MockKafkaPublisher) PublishTransaction(ctx context.Context, transactionID string, message []byte, dataFormat string) error {
 	m.ctrl.T.Helper()
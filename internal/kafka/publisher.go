@@ -12,13 +12,21 @@ import (
 	"github.com/sony/gobreaker"
 )
 
+const contentTypeHeader = "content-type"
+
 type kafkaPublisher struct {
 	writer         *kafka.Writer
 	circuitBreaker *gobreaker.CircuitBreaker
+	serializer     Serializer
 }
 
 type KafkaPublisher interface {
+	// PublishTransaction publishes the serialized message to the transactions topic of its data format
 	PublishTransaction(ctx context.Context, transactionID string, message []byte, dataFormat string) error
+	// PublishMessage publishes the serialized message to the topic, e.g. when a dead letter is replayed
+	PublishMessage(ctx context.Context, topic, key string, message []byte, dataFormat string) error
+	// Publish serializes v with the publisher serializer and publishes it to the topic
+	Publish(ctx context.Context, topic, key string, v any) error
 	Close() error
 }
 
@@ -34,18 +42,21 @@ var (
 	}
 )
 
-func NewPublisher(kafkaURL string) KafkaPublisher {
+// NewPublisher serializer encodes the values passed to Publish
+func NewPublisher(kafkaURL string, serializer Serializer) KafkaPublisher {
+	// writes are synchronous, the outbox relay marks the message published only after kafka has acked it
 	writer := &kafka.Writer{
 		Addr:                   kafka.TCP(kafkaURL),
 		Balancer:               &kafka.LeastBytes{},
 		AllowAutoTopicCreation: true,
-		BatchTimeout:           100 * time.Millisecond,
-		Async:                  true,
+		BatchTimeout:           10 * time.Millisecond,
+		RequiredAcks:           kafka.RequireAll,
 	}
 
 	return &kafkaPublisher{
 		writer:         writer,
 		circuitBreaker: gobreaker.NewCircuitBreaker(defaultCircuitBreakerSettings),
+		serializer:     serializer,
 	}
 }
 
@@ -54,26 +65,55 @@ func (p *kafkaPublisher) PublishTransaction(ctx context.Context, transactionID s
 		return fmt.Errorf("kafka writer not initialized")
 	}
 
-	topic, err := getTopic(dataFormat)
+	topic, err := TransactionTopic(dataFormat)
 	if err != nil {
 		return fmt.Errorf("topic resolution failed: %w", err)
 	}
 
-	// Execute with circuit breaker protection
-	_, err = p.circuitBreaker.Execute(func() (interface{}, error) {
-		msg := kafka.Message{
-			Key:   []byte(transactionID),
-			Value: message,
-			Topic: topic,
-		}
+	return p.PublishMessage(ctx, topic, transactionID, message, dataFormat)
+}
+
+func (p *kafkaPublisher) PublishMessage(ctx context.Context, topic, key string, message []byte, dataFormat string) error {
+	if p.writer == nil {
+		return fmt.Errorf("kafka writer not initialized")
+	}
+
+	return p.write(ctx, kafka.Message{
+		Key:     []byte(key),
+		Value:   message,
+		Topic:   topic,
+		Headers: []kafka.Header{{Key: contentTypeHeader, Value: []byte(dataFormat)}},
+	})
+}
 
+func (p *kafkaPublisher) Publish(ctx context.Context, topic, key string, v any) error {
+	if p.writer == nil {
+		return fmt.Errorf("kafka writer not initialized")
+	}
+
+	message, err := p.serializer.Serialize(v)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	return p.write(ctx, kafka.Message{
+		Key:     []byte(key),
+		Value:   message,
+		Topic:   topic,
+		Headers: []kafka.Header{{Key: contentTypeHeader, Value: []byte(p.serializer.ContentType())}},
+	})
+}
+
+func (p *kafkaPublisher) write(ctx context.Context, msg kafka.Message) error {
+	// Execute with circuit breaker protection
+	_, err := p.circuitBreaker.Execute(func() (interface{}, error) {
 		err := p.writer.WriteMessages(ctx, msg)
 		if err != nil {
 			log.Printf("Kafka publish error: %v", err)
 			return nil, fmt.Errorf("kafka write failed: %w", err)
 		}
 
-		log.Printf("Successfully published message to topic: %s", topic)
+		log.Printf("Successfully published message to topic: %s", msg.Topic)
 		return nil, nil
 	})
 
@@ -89,13 +129,16 @@ func (p *kafkaPublisher) Close() error {
 	return nil
 }
 
-func getTopic(dataFormat string) (string, error) {
+// TransactionTopic topic of the transaction messages in the data format
+func TransactionTopic(dataFormat string) (string, error) {
 	switch dataFormat {
 	case "application/json":
 		return "transactions.json", nil
 	case "text/xml", "application/xml":
 		return "transactions.xml", nil
+	case ContentTypeAvro:
+		return "transactions.avro", nil
 	default:
-		return "", fmt.Errorf("unsupported data format '%s' - allowed formats: JSON, XML", dataFormat)
+		return "", fmt.Errorf("unsupported data format '%s' - allowed formats: JSON, XML, Avro", dataFormat)
 	}
 }
@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// SchemaRegistry assigns ids to the schemas of the published events, the id is sent with every message
+// so the consumers can look up the schema the message was written with
+type SchemaRegistry interface {
+	// Register returns id of the schema under the subject, registering the same schema again returns the same id
+	Register(subject, schema string) (int, error)
+	// Schema returns the schema registered with the id
+	Schema(id int) (string, error)
+}
+
+// localRegistry keeps the schemas in memory, ids are stable as long as the schemas are registered in the same order
+type localRegistry struct {
+	mu      sync.RWMutex
+	ids     map[string]int
+	schemas map[int]string
+}
+
+func NewLocalRegistry() SchemaRegistry {
+	return &localRegistry{
+		ids:     make(map[string]int),
+		schemas: make(map[int]string),
+	}
+}
+
+func (r *localRegistry) Register(subject, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := subject + "\x00" + schema
+	if id, ok := r.ids[key]; ok {
+		return id, nil
+	}
+
+	id := len(r.schemas) + 1
+	r.ids[key] = id
+	r.schemas[id] = schema
+	return id, nil
+}
+
+func (r *localRegistry) Schema(id int) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, ok := r.schemas[id]
+	if !ok {
+		return "", fmt.Errorf("%w: id %d", ErrSchemaNotFound, id)
+	}
+	return schema, nil
+}
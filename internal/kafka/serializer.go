@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"payment-gateway/internal/codec"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const (
+	FormatAvro      = "avro"
+	ContentTypeAvro = "avro/binary"
+
+	// avroMagicByte starts the schema registry wire format: magic byte, 4 bytes schema id, avro binary
+	avroMagicByte    = 0
+	avroHeaderLength = 5
+)
+
+var ErrNotAvroRecord = errors.New("value is not an avro record")
+
+// Serializer encodes the published events in the format configured for their consumers
+type Serializer interface {
+	ContentType() string
+	Serialize(v any) ([]byte, error)
+}
+
+// AvroRecord is implemented by the events published with the avro serializer
+type AvroRecord interface {
+	// AvroSchema returns the registry subject and the schema of the record
+	AvroSchema() (subject string, schema string)
+	// AvroNative returns the record as goavro native map
+	AvroNative() map[string]any
+}
+
+// NewSerializer returns serializer for the format: json, xml or avro, json is used when the format is empty
+func NewSerializer(format string, registry SchemaRegistry) (Serializer, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "":
+		format = codec.FormatJSON
+	case FormatAvro, ContentTypeAvro:
+		return NewAvroSerializer(registry), nil
+	}
+
+	c, err := codec.Lookup(format)
+	if err != nil {
+		return nil, err
+	}
+	// events are records, soap envelopes and forms are gateway wire formats only
+	if c.Format() != codec.FormatJSON && c.Format() != codec.FormatXML {
+		return nil, fmt.Errorf("%w: %q", codec.ErrUnsupportedFormat, format)
+	}
+	return NewCodecSerializer(c), nil
+}
+
+type codecSerializer struct {
+	codec codec.Codec
+}
+
+// NewCodecSerializer serializes events with the codec, e.g. json or xml
+func NewCodecSerializer(c codec.Codec) Serializer {
+	return &codecSerializer{
+		codec: c,
+	}
+}
+
+func (s *codecSerializer) ContentType() string {
+	return s.codec.ContentType()
+}
+
+func (s *codecSerializer) Serialize(v any) ([]byte, error) {
+	return s.codec.Marshal(v)
+}
+
+type avroCodec struct {
+	id    int
+	codec *goavro.Codec
+}
+
+type avroSerializer struct {
+	registry SchemaRegistry
+
+	mu     sync.Mutex
+	codecs map[string]avroCodec
+}
+
+// NewAvroSerializer serializes AvroRecord values in the schema registry wire format,
+// schemas are registered on first use
+func NewAvroSerializer(registry SchemaRegistry) Serializer {
+	return &avroSerializer{
+		registry: registry,
+		codecs:   make(map[string]avroCodec),
+	}
+}
+
+func (s *avroSerializer) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (s *avroSerializer) Serialize(v any) ([]byte, error) {
+	record, ok := v.(AvroRecord)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotAvroRecord, v)
+	}
+
+	c, err := s.codec(record.AvroSchema())
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, avroHeaderLength, 256)
+	buf[0] = avroMagicByte
+	binary.BigEndian.PutUint32(buf[1:avroHeaderLength], uint32(c.id))
+
+	data, err := c.codec.BinaryFromNative(buf, record.AvroNative())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode avro record: %w", err)
+	}
+	return data, nil
+}
+
+func (s *avroSerializer) codec(subject, schema string) (avroCodec, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.codecs[subject+"\x00"+schema]; ok {
+		return c, nil
+	}
+
+	avro, err := goavro.NewCodec(schema)
+	if err != nil {
+		return avroCodec{}, fmt.Errorf("invalid avro schema of %s: %w", subject, err)
+	}
+
+	id, err := s.registry.Register(subject, schema)
+	if err != nil {
+		return avroCodec{}, fmt.Errorf("failed to register avro schema of %s: %w", subject, err)
+	}
+
+	c := avroCodec{id: id, codec: avro}
+	s.codecs[subject+"\x00"+schema] = c
+	return c, nil
+}
+
+// DecodeAvro decodes message written by the avro serializer to goavro native value
+// with the schema the message was written with
+func DecodeAvro(registry SchemaRegistry, data []byte) (any, error) {
+	if len(data) < avroHeaderLength || data[0] != avroMagicByte {
+		return nil, errors.New("message is not in the schema registry wire format")
+	}
+
+	schema, err := registry.Schema(int(binary.BigEndian.Uint32(data[1:avroHeaderLength])))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+
+	native, _, err := c.NativeFromBinary(data[avroHeaderLength:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode avro record: %w", err)
+	}
+	return native, nil
+}
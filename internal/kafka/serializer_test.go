@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"testing"
+
+	"payment-gateway/internal/codec"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRecord struct {
+	ID   int
+	Name string
+}
+
+const testRecordSchema = `{"type":"record","name":"Test","fields":[{"name":"id","type":"int"},{"name":"name","type":"string"}]}`
+
+func (r testRecord) AvroSchema() (string, string) {
+	return "test", testRecordSchema
+}
+
+func (r testRecord) AvroNative() map[string]any {
+	return map[string]any{"id": int32(r.ID), "name": r.Name}
+}
+
+func TestNewSerializer(t *testing.T) {
+	tests := map[string]string{
+		"":                 codec.ContentTypeJSON,
+		"json":             codec.ContentTypeJSON,
+		"application/json": codec.ContentTypeJSON,
+		"XML":              codec.ContentTypeXML,
+		"avro":             ContentTypeAvro,
+		ContentTypeAvro:    ContentTypeAvro,
+	}
+
+	for format, want := range tests {
+		serializer, err := NewSerializer(format, NewLocalRegistry())
+		require.NoError(t, err, format)
+		assert.Equal(t, want, serializer.ContentType(), format)
+	}
+
+	for _, format := range []string{"soap", "form", "protobuf"} {
+		_, err := NewSerializer(format, NewLocalRegistry())
+		assert.ErrorIs(t, err, codec.ErrUnsupportedFormat, format)
+	}
+}
+
+func TestAvroSerializer_RoundTrip(t *testing.T) {
+	registry := NewLocalRegistry()
+	serializer := NewAvroSerializer(registry)
+
+	first, err := serializer.Serialize(testRecord{ID: 7, Name: "seven"})
+	require.NoError(t, err)
+	second, err := serializer.Serialize(testRecord{ID: 8, Name: "eight"})
+	require.NoError(t, err)
+
+	// the schema is registered once, both messages carry the same id
+	assert.Equal(t, []byte{avroMagicByte, 0, 0, 0, 1}, first[:avroHeaderLength])
+	assert.Equal(t, first[:avroHeaderLength], second[:avroHeaderLength])
+
+	native, err := DecodeAvro(registry, first)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": int32(7), "name": "seven"}, native)
+}
+
+func TestAvroSerializer_Errors(t *testing.T) {
+	serializer := NewAvroSerializer(NewLocalRegistry())
+
+	_, err := serializer.Serialize(struct{ ID int }{ID: 7})
+	assert.ErrorIs(t, err, ErrNotAvroRecord)
+
+	_, err = DecodeAvro(NewLocalRegistry(), []byte{avroMagicByte, 0, 0, 0, 9, 2})
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	_, err = DecodeAvro(NewLocalRegistry(), []byte(`{"id":7}`))
+	assert.Error(t, err)
+}
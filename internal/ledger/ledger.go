@@ -0,0 +1,213 @@
+//go:generate mockgen -source ledger.go -destination mocks/ledger.go -package mocks
+
+// Package ledger keeps user balances in a double-entry ledger. Every journal line moves a signed amount
+// of one account, credits are positive and debits negative, and the lines of an entry sum to zero in
+// every currency. Posted entries are never changed, corrections are new entries.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+// Account types
+const (
+	// AccountUserWallet funds the service owes the user, owned by the user
+	AccountUserWallet = "user_wallet"
+	// AccountGatewayClearing funds in transit with the gateway, owned by the gateway
+	AccountGatewayClearing = "gateway_clearing"
+)
+
+// Entry types, a transaction has at most one entry of every type
+const (
+	EntryDeposit    = "deposit"
+	EntryWithdrawal = "withdrawal"
+	EntryRefund     = "refund"
+	EntryCapture    = "capture"
+	// EntryChargeback takes the amount of a lost dispute back out of the wallet
+	EntryChargeback = "chargeback"
+)
+
+// Hold statuses, funds reserved by a withdrawal or a refund are captured when it is done and released when it fails
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+)
+
+var (
+	ErrUnbalancedEntry   = errors.New("journal entry does not balance")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// Account ledger account, there is one account of the type, owner and currency
+type Account struct {
+	Type string
+	// OwnerID user or gateway id, 0 for the service accounts
+	OwnerID  int
+	Currency string
+}
+
+func (a Account) String() string {
+	return fmt.Sprintf("%s:%d:%s", a.Type, a.OwnerID, a.Currency)
+}
+
+func UserWallet(userID int, currency string) Account {
+	return Account{Type: AccountUserWallet, OwnerID: userID, Currency: money.NormalizeCurrency(currency)}
+}
+
+func GatewayClearing(gatewayID int, currency string) Account {
+	return Account{Type: AccountGatewayClearing, OwnerID: gatewayID, Currency: money.NormalizeCurrency(currency)}
+}
+
+// Line signed amount moved on the account, credits are positive
+type Line struct {
+	Account Account
+	Amount  money.Money
+}
+
+// Entry journal entry of the transaction
+type Entry struct {
+	TransactionID int
+	Type          string
+	Description   string
+	Lines         []Line
+}
+
+// Validate the entry has at least two lines in the currencies of their accounts and balances in every currency
+func (e Entry) Validate() error {
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("%w: entry %s of transaction %d has %d lines", ErrUnbalancedEntry, e.Type, e.TransactionID, len(e.Lines))
+	}
+
+	sums := make(map[string]money.Money)
+	for _, line := range e.Lines {
+		if line.Amount.IsZero() {
+			return fmt.Errorf("%w: zero amount on %s", ErrUnbalancedEntry, line.Account)
+		}
+		if line.Amount.Currency() != line.Account.Currency {
+			return fmt.Errorf("%w: %s amount on %s", money.ErrCurrencyMismatch, line.Amount.Currency(), line.Account)
+		}
+
+		sum, ok := sums[line.Account.Currency]
+		if !ok {
+			sum = money.New(0, line.Account.Currency)
+		}
+		sum, err := sum.Add(line.Amount)
+		if err != nil {
+			return err
+		}
+		sums[line.Account.Currency] = sum
+	}
+
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: %s lines of entry %s of transaction %d sum to %s", ErrUnbalancedEntry, currency, e.Type, e.TransactionID, sum)
+		}
+	}
+	return nil
+}
+
+// sortedLines lines in the order their accounts are locked, a fixed order prevents deadlocks between entries
+func (e Entry) sortedLines() []Line {
+	lines := append([]Line(nil), e.Lines...)
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Account.String() < lines[j].Account.String()
+	})
+	return lines
+}
+
+// Posting ledger changes made together with the change of the transaction status
+type Posting struct {
+	TransactionID int
+	// Entry posted to the journal, nil when the status change doesn't move funds
+	Entry *Entry
+	// Hold new status of the active hold of the transaction, empty when there is no hold to settle
+	Hold string
+}
+
+// IsZero the posting changes nothing
+func (p Posting) IsZero() bool {
+	return p.Entry == nil && p.Hold == ""
+}
+
+// ForTransition ledger changes of the transaction moving to status: deposits and captures credit the wallet when done,
+// withdrawals and refunds debit it and capture the hold when done and release the hold when they fail or expire.
+// Authorizations don't move funds, their captures do
+func ForTransition(tx models.Transaction, status string) Posting {
+	switch {
+	case creditsWallet(tx) && status == models.TransactionStatusDone:
+		return Posting{TransactionID: tx.ID, Entry: transferEntry(tx, tx.Type, tx.Amount)}
+	case debitsWallet(tx) && status == models.TransactionStatusDone:
+		return Posting{TransactionID: tx.ID, Entry: transferEntry(tx, tx.Type, negate(tx.Amount)), Hold: HoldCaptured}
+	case debitsWallet(tx) && (status == models.TransactionStatusFailed || status == models.TransactionStatusExpired):
+		return Posting{TransactionID: tx.ID, Hold: HoldReleased}
+	default:
+		return Posting{}
+	}
+}
+
+// ForDispute ledger changes of the dispute of tx moving to status: a lost dispute reverses the disputed amount
+// of the deposit or capture. The gateway has already returned the funds to the payer, so the wallet may go negative
+func ForDispute(tx models.Transaction, dispute models.Dispute, status string) Posting {
+	if status != models.DisputeStatusLost {
+		return Posting{}
+	}
+	return Posting{TransactionID: tx.ID, Entry: transferEntry(tx, EntryChargeback, negate(dispute.Amount))}
+}
+
+// creditsWallet the transaction brings funds into the user wallet, the entry type is the transaction type
+func creditsWallet(tx models.Transaction) bool {
+	return tx.Type == models.TransactionTypeDeposit || tx.Type == models.TransactionTypeCapture
+}
+
+// debitsWallet the transaction takes funds out of the user wallet, the entry type is the transaction type
+func debitsWallet(tx models.Transaction) bool {
+	return tx.Type == models.TransactionTypeWithdrawal || tx.Type == models.TransactionTypeRefund
+}
+
+// transferEntry moves walletAmount between the user wallet and the gateway clearing account
+func transferEntry(tx models.Transaction, entryType string, walletAmount money.Money) *Entry {
+	currency := tx.Amount.Currency()
+	return &Entry{
+		TransactionID: tx.ID,
+		Type:          entryType,
+		Description:   fmt.Sprintf("%s of transaction %d", entryType, tx.ID),
+		Lines: []Line{
+			{Account: UserWallet(tx.UserID, currency), Amount: walletAmount},
+			{Account: GatewayClearing(tx.GatewayID, currency), Amount: negate(walletAmount)},
+		},
+	}
+}
+
+func negate(m money.Money) money.Money {
+	return money.New(-m.Minor(), m.Currency())
+}
+
+// Balance of the user wallet, Available is Balance less the funds reserved by withdrawals and refunds in progress
+type Balance struct {
+	UserID    int
+	Balance   money.Money
+	Reserved  money.Money
+	Available money.Money
+}
+
+type Ledger interface {
+	// Reserve holds amount of the user wallet for the withdrawal or refund, ErrInsufficientFunds is returned
+	// when the available balance is lower. Reserving the same transaction again is a no-op
+	Reserve(transactionID, userID int, amount money.Money) error
+	// ReserveOverdraft holds amount like Reserve beyond the available balance, which may go negative. Refunds return
+	// funds the gateway has received, they aren't limited by what the user has left like a lost dispute
+	ReserveOverdraft(transactionID, userID int, amount money.Money) error
+	// Balance of the user wallet in the currency, zero when the wallet has no entries yet
+	Balance(userID int, currency string) (Balance, error)
+	// Balances of the user wallets in every currency the user has, ordered by currency
+	Balances(userID int) ([]Balance, error)
+	// Statement lines of the user wallet in the currency posted in [from, to) with running balances
+	Statement(userID int, currency string, from, to time.Time) (models.Statement, error)
+}
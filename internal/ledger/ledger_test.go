@@ -0,0 +1,154 @@
+package ledger
+
+import (
+	"testing"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntry_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []Line
+		wantErr error
+	}{
+		{
+			name: "balanced",
+			lines: []Line{
+				{Account: UserWallet(1, "EUR"), Amount: money.MustParse("10", "EUR")},
+				{Account: GatewayClearing(10, "EUR"), Amount: money.MustParse("-7.50", "EUR")},
+				{Account: GatewayClearing(11, "EUR"), Amount: money.MustParse("-2.50", "EUR")},
+			},
+		},
+		{
+			name: "unbalanced",
+			lines: []Line{
+				{Account: UserWallet(1, "EUR"), Amount: money.MustParse("10", "EUR")},
+				{Account: GatewayClearing(10, "EUR"), Amount: money.MustParse("-9.99", "EUR")},
+			},
+			wantErr: ErrUnbalancedEntry,
+		},
+		{
+			name: "balances in one currency only",
+			lines: []Line{
+				{Account: UserWallet(1, "EUR"), Amount: money.MustParse("10", "EUR")},
+				{Account: GatewayClearing(10, "USD"), Amount: money.MustParse("-10", "USD")},
+			},
+			wantErr: ErrUnbalancedEntry,
+		},
+		{
+			name:    "single line",
+			lines:   []Line{{Account: UserWallet(1, "EUR"), Amount: money.MustParse("10", "EUR")}},
+			wantErr: ErrUnbalancedEntry,
+		},
+		{
+			name: "zero line",
+			lines: []Line{
+				{Account: UserWallet(1, "EUR"), Amount: money.MustParse("0", "EUR")},
+				{Account: GatewayClearing(10, "EUR"), Amount: money.MustParse("0", "EUR")},
+			},
+			wantErr: ErrUnbalancedEntry,
+		},
+		{
+			name: "amount in another currency than the account",
+			lines: []Line{
+				{Account: UserWallet(1, "EUR"), Amount: money.MustParse("10", "USD")},
+				{Account: GatewayClearing(10, "EUR"), Amount: money.MustParse("-10", "USD")},
+			},
+			wantErr: money.ErrCurrencyMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Entry{TransactionID: 7, Type: EntryDeposit, Lines: tt.lines}.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestForTransition(t *testing.T) {
+	deposit := models.Transaction{ID: 7, UserID: 1, GatewayID: 10, Type: models.TransactionTypeDeposit, Amount: money.MustParse("25.50", "EUR")}
+	withdrawal := deposit
+	withdrawal.Type = models.TransactionTypeWithdrawal
+	refund := deposit
+	refund.Type, refund.ParentID = models.TransactionTypeRefund, 6
+	capture := deposit
+	capture.Type, capture.ParentID = models.TransactionTypeCapture, 6
+	authorization := deposit
+	authorization.Type = models.TransactionTypeAuthorization
+
+	posting := ForTransition(deposit, models.TransactionStatusDone)
+	assert.Empty(t, posting.Hold)
+	if assert.NotNil(t, posting.Entry) {
+		assert.NoError(t, posting.Entry.Validate())
+		assert.Equal(t, EntryDeposit, posting.Entry.Type)
+		assert.Equal(t, Line{Account: UserWallet(1, "EUR"), Amount: money.MustParse("25.50", "EUR")}, posting.Entry.Lines[0])
+	}
+
+	posting = ForTransition(withdrawal, models.TransactionStatusDone)
+	assert.Equal(t, HoldCaptured, posting.Hold)
+	if assert.NotNil(t, posting.Entry) {
+		assert.NoError(t, posting.Entry.Validate())
+		assert.Equal(t, EntryWithdrawal, posting.Entry.Type)
+		assert.Equal(t, Line{Account: UserWallet(1, "EUR"), Amount: money.MustParse("-25.50", "EUR")}, posting.Entry.Lines[0])
+	}
+
+	posting = ForTransition(capture, models.TransactionStatusDone)
+	assert.Empty(t, posting.Hold)
+	if assert.NotNil(t, posting.Entry) {
+		assert.NoError(t, posting.Entry.Validate())
+		assert.Equal(t, EntryCapture, posting.Entry.Type)
+		assert.Equal(t, Line{Account: UserWallet(1, "EUR"), Amount: money.MustParse("25.50", "EUR")}, posting.Entry.Lines[0])
+	}
+
+	posting = ForTransition(refund, models.TransactionStatusDone)
+	assert.Equal(t, HoldCaptured, posting.Hold)
+	if assert.NotNil(t, posting.Entry) {
+		assert.NoError(t, posting.Entry.Validate())
+		assert.Equal(t, EntryRefund, posting.Entry.Type)
+		assert.Equal(t, Line{Account: UserWallet(1, "EUR"), Amount: money.MustParse("-25.50", "EUR")}, posting.Entry.Lines[0])
+	}
+
+	for _, status := range []string{models.TransactionStatusFailed, models.TransactionStatusExpired} {
+		assert.Equal(t, Posting{TransactionID: 7, Hold: HoldReleased}, ForTransition(withdrawal, status), status)
+		assert.Equal(t, Posting{TransactionID: 7, Hold: HoldReleased}, ForTransition(refund, status), status)
+	}
+
+	// funds move only when the transaction is done
+	assert.True(t, ForTransition(deposit, models.TransactionStatusPending).IsZero())
+	assert.True(t, ForTransition(deposit, models.TransactionStatusFailed).IsZero())
+	assert.True(t, ForTransition(deposit, models.TransactionStatusReversed).IsZero())
+	// the authorized amount stays with the gateway until it is captured
+	for _, status := range []string{models.TransactionStatusAuthorized, models.TransactionStatusCaptured, models.TransactionStatusVoided} {
+		assert.True(t, ForTransition(authorization, status).IsZero(), status)
+	}
+	assert.True(t, ForTransition(withdrawal, models.TransactionStatusSubmitted).IsZero())
+}
+
+func TestForDispute(t *testing.T) {
+	deposit := models.Transaction{ID: 7, UserID: 1, GatewayID: 10, Type: models.TransactionTypeDeposit, Amount: money.MustParse("25.50", "EUR")}
+	dispute := models.Dispute{ID: 3, TransactionID: 7, Amount: money.MustParse("20", "EUR")}
+
+	posting := ForDispute(deposit, dispute, models.DisputeStatusLost)
+	assert.Empty(t, posting.Hold)
+	if assert.NotNil(t, posting.Entry) {
+		assert.NoError(t, posting.Entry.Validate())
+		assert.Equal(t, EntryChargeback, posting.Entry.Type)
+		assert.Equal(t, []Line{
+			{Account: UserWallet(1, "EUR"), Amount: money.MustParse("-20", "EUR")},
+			{Account: GatewayClearing(10, "EUR"), Amount: money.MustParse("20", "EUR")},
+		}, posting.Entry.Lines)
+	}
+
+	for _, status := range []string{models.DisputeStatusOpened, models.DisputeStatusEvidenceSubmitted, models.DisputeStatusWon} {
+		assert.True(t, ForDispute(deposit, dispute, status).IsZero(), status)
+	}
+}
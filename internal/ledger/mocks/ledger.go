@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ledger.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	ledger "payment-gateway/internal/ledger"
+	models "payment-gateway/internal/models"
+	money "payment-gateway/internal/money"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLedger is a mock of Ledger interface.
+type MockLedger struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerMockRecorder
+}
+
+// MockLedgerMockRecorder is the mock recorder for MockLedger.
+type MockLedgerMockRecorder struct {
+	mock *MockLedger
+}
+
+// NewMockLedger creates a new mock instance.
+func NewMockLedger(ctrl *gomock.Controller) *MockLedger {
+	mock := &MockLedger{ctrl: ctrl}
+	mock.recorder = &MockLedgerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedger) EXPECT() *MockLedgerMockRecorder {
+	return m.recorder
+}
+
+// Balance mocks base method.
+func (m *MockLedger) Balance(userID int, currency string) (ledger.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balance", userID, currency)
+	ret0, _ := ret[0].(ledger.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Balance indicates an expected call of Balance.
+func (mr *MockLedgerMockRecorder) Balance(userID, currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockLedger)(nil).Balance), userID, currency)
+}
+
+// Balances mocks base method.
+func (m *MockLedger) Balances(userID int) ([]ledger.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Balances", userID)
+	ret0, _ := ret[0].([]ledger.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Balances indicates an expected call of Balances.
+func (mr *MockLedgerMockRecorder) Balances(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balances", reflect.TypeOf((*MockLedger)(nil).Balances), userID)
+}
+
+// Reserve mocks base method.
+func (m *MockLedger) Reserve(transactionID, userID int, amount money.Money) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", transactionID, userID, amount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockLedgerMockRecorder) Reserve(transactionID, userID, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockLedger)(nil).Reserve), transactionID, userID, amount)
+}
+
+// ReserveOverdraft mocks base method.
+func (m *MockLedger) ReserveOverdraft(transactionID, userID int, amount money.Money) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveOverdraft", transactionID, userID, amount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReserveOverdraft indicates an expected call of ReserveOverdraft.
+func (mr *MockLedgerMockRecorder) ReserveOverdraft(transactionID, userID, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveOverdraft", reflect.TypeOf((*MockLedger)(nil).ReserveOverdraft), transactionID, userID, amount)
+}
+
+// Statement mocks base method.
+func (m *MockLedger) Statement(userID int, currency string, from, to time.Time) (models.Statement, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Statement", userID, currency, from, to)
+	ret0, _ := ret[0].(models.Statement)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Statement indicates an expected call of Statement.
+func (mr *MockLedgerMockRecorder) Statement(userID, currency, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Statement", reflect.TypeOf((*MockLedger)(nil).Statement), userID, currency, from, to)
+}
@@ -0,0 +1,294 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+type postgresLedger struct {
+	db *sql.DB
+}
+
+// NewPostgresLedger keeps the ledger in the ledger_accounts, journal_entries, journal_lines and ledger_holds tables,
+// amounts are stored in integer minor units of the account currency
+func NewPostgresLedger(db *sql.DB) Ledger {
+	return &postgresLedger{
+		db: db,
+	}
+}
+
+// Execer is *sql.Tx of the caller, postings are applied in the SQL transaction of the status change
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (l *postgresLedger) Reserve(transactionID, userID int, amount money.Money) error {
+	return l.reserve(transactionID, userID, amount, false)
+}
+
+func (l *postgresLedger) ReserveOverdraft(transactionID, userID int, amount money.Money) error {
+	return l.reserve(transactionID, userID, amount, true)
+}
+
+// reserve inserts the hold of the transaction, the available balance is checked unless overdraft is set
+func (l *postgresLedger) reserve(transactionID, userID int, amount money.Money, overdraft bool) error {
+	dbTx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin reservation: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	// the wallet row lock serializes the reservations and postings of the user
+	accountID, balance, reserved, err := lockAccount(dbTx, UserWallet(userID, amount.Currency()))
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	err = dbTx.QueryRow(`SELECT EXISTS (SELECT 1 FROM ledger_holds WHERE transaction_id = $1)`, transactionID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check ledger hold: %v", err)
+	}
+	if exists {
+		return nil
+	}
+
+	if available := balance - reserved; !overdraft && available < amount.Minor() {
+		return fmt.Errorf("%w: available %s, requested %s", ErrInsufficientFunds,
+			money.New(available, amount.Currency()), amount)
+	}
+
+	_, err = dbTx.Exec(`INSERT INTO ledger_holds (transaction_id, account_id, amount, status, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		transactionID, accountID, amount.Minor(), HoldActive, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to insert ledger hold: %v", err)
+	}
+
+	_, err = dbTx.Exec(`UPDATE ledger_accounts SET reserved = reserved + $1 WHERE id = $2`, amount.Minor(), accountID)
+	if err != nil {
+		return fmt.Errorf("failed to reserve funds: %v", err)
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit reservation: %v", err)
+	}
+	return nil
+}
+
+func (l *postgresLedger) Balance(userID int, currency string) (Balance, error) {
+	currency = money.NormalizeCurrency(currency)
+
+	var balance, reserved int64
+	err := l.db.QueryRow(`SELECT balance, reserved FROM ledger_accounts WHERE type = $1 AND owner_id = $2 AND currency = $3`,
+		AccountUserWallet, userID, currency).Scan(&balance, &reserved)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Balance{}, fmt.Errorf("failed to fetch balance: %v", err)
+	}
+
+	return Balance{
+		UserID:    userID,
+		Balance:   money.New(balance, currency),
+		Reserved:  money.New(reserved, currency),
+		Available: money.New(balance-reserved, currency),
+	}, nil
+}
+
+func (l *postgresLedger) Balances(userID int) ([]Balance, error) {
+	rows, err := l.db.Query(`SELECT currency, balance, reserved FROM ledger_accounts WHERE type = $1 AND owner_id = $2 ORDER BY currency`,
+		AccountUserWallet, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch balances: %v", err)
+	}
+	defer rows.Close()
+
+	var balances []Balance
+	for rows.Next() {
+		var (
+			currency          string
+			balance, reserved int64
+		)
+		if err := rows.Scan(&currency, &balance, &reserved); err != nil {
+			return nil, fmt.Errorf("failed to scan balance: %v", err)
+		}
+		balances = append(balances, Balance{
+			UserID:    userID,
+			Balance:   money.New(balance, currency),
+			Reserved:  money.New(reserved, currency),
+			Available: money.New(balance-reserved, currency),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch balances: %v", err)
+	}
+	return balances, nil
+}
+
+func (l *postgresLedger) Statement(userID int, currency string, from, to time.Time) (models.Statement, error) {
+	account := UserWallet(userID, currency)
+	statement := models.Statement{
+		UserID:   userID,
+		Currency: account.Currency,
+		From:     from,
+		To:       to,
+		Opening:  money.New(0, account.Currency),
+		Lines:    []models.StatementLine{},
+	}
+
+	// the opening balance and the lines are read from one snapshot, entries posted meanwhile are not half counted
+	dbTx, err := l.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return statement, fmt.Errorf("failed to begin statement: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	var opening int64
+	err = dbTx.QueryRow(`
+		SELECT COALESCE(SUM(l.amount), 0)
+		FROM journal_lines l
+		JOIN journal_entries e ON e.id = l.entry_id
+		JOIN ledger_accounts a ON a.id = l.account_id
+		WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = $3 AND e.created_at < $4`,
+		account.Type, account.OwnerID, account.Currency, from).Scan(&opening)
+	if err != nil {
+		return statement, fmt.Errorf("failed to fetch opening balance: %v", err)
+	}
+	statement.Opening = money.New(opening, account.Currency)
+
+	rows, err := dbTx.Query(`
+		SELECT e.id, e.transaction_id, e.type, e.description, e.created_at, l.amount
+		FROM journal_lines l
+		JOIN journal_entries e ON e.id = l.entry_id
+		JOIN ledger_accounts a ON a.id = l.account_id
+		WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = $3 AND e.created_at >= $4 AND e.created_at < $5
+		ORDER BY e.created_at, e.id`,
+		account.Type, account.OwnerID, account.Currency, from, to)
+	if err != nil {
+		return statement, fmt.Errorf("failed to fetch statement lines: %v", err)
+	}
+	defer rows.Close()
+
+	balance := opening
+	for rows.Next() {
+		var (
+			line   models.StatementLine
+			amount int64
+		)
+		if err := rows.Scan(&line.EntryID, &line.TransactionID, &line.Type, &line.Description, &line.CreatedAt, &amount); err != nil {
+			return statement, fmt.Errorf("failed to scan statement line: %v", err)
+		}
+		balance += amount
+		line.Amount = money.New(amount, account.Currency)
+		line.Balance = money.New(balance, account.Currency)
+		statement.Lines = append(statement.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return statement, fmt.Errorf("failed to fetch statement lines: %v", err)
+	}
+
+	statement.Closing = money.New(balance, account.Currency)
+	return statement, nil
+}
+
+// Apply posts the entry and settles the hold of the posting in the SQL transaction of the caller.
+// An entry already posted for the transaction is skipped, so the posting can be applied again
+func Apply(dbTx Execer, posting Posting) error {
+	if posting.Entry != nil {
+		if err := post(dbTx, *posting.Entry); err != nil {
+			return err
+		}
+	}
+
+	if posting.Hold != "" {
+		return settleHold(dbTx, posting.TransactionID, posting.Hold)
+	}
+	return nil
+}
+
+func post(dbTx Execer, entry Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	var entryID int64
+	err := dbTx.QueryRow(`
+		INSERT INTO journal_entries (transaction_id, type, description, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (transaction_id, type) DO NOTHING
+		RETURNING id`, entry.TransactionID, entry.Type, entry.Description, time.Now()).Scan(&entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert journal entry: %v", err)
+	}
+
+	for _, line := range entry.sortedLines() {
+		accountID, _, _, err := lockAccount(dbTx, line.Account)
+		if err != nil {
+			return err
+		}
+
+		_, err = dbTx.Exec(`INSERT INTO journal_lines (entry_id, account_id, amount, currency) VALUES ($1, $2, $3, $4)`,
+			entryID, accountID, line.Amount.Minor(), line.Amount.Currency())
+		if err != nil {
+			return fmt.Errorf("failed to insert journal line: %v", err)
+		}
+
+		_, err = dbTx.Exec(`UPDATE ledger_accounts SET balance = balance + $1 WHERE id = $2`, line.Amount.Minor(), accountID)
+		if err != nil {
+			return fmt.Errorf("failed to update account balance: %v", err)
+		}
+	}
+	return nil
+}
+
+// settleHold captures or releases the active hold of the transaction, the reserved funds are freed either way
+func settleHold(dbTx Execer, transactionID int, status string) error {
+	var (
+		accountID int64
+		amount    int64
+	)
+	err := dbTx.QueryRow(`
+		UPDATE ledger_holds SET status = $1, settled_at = $2
+		WHERE transaction_id = $3 AND status = $4
+		RETURNING account_id, amount`, status, time.Now(), transactionID, HoldActive).Scan(&accountID, &amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to settle ledger hold: %v", err)
+	}
+
+	_, err = dbTx.Exec(`UPDATE ledger_accounts SET reserved = reserved - $1 WHERE id = $2`, amount, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to free reserved funds: %v", err)
+	}
+	return nil
+}
+
+// lockAccount creates the account on first use and locks its row until the end of the SQL transaction
+func lockAccount(dbTx Execer, account Account) (int64, int64, int64, error) {
+	_, err := dbTx.Exec(`
+		INSERT INTO ledger_accounts (type, owner_id, currency, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (type, owner_id, currency) DO NOTHING`, account.Type, account.OwnerID, account.Currency, time.Now())
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to create ledger account %s: %v", account, err)
+	}
+
+	var id, balance, reserved int64
+	err = dbTx.QueryRow(`
+		SELECT id, balance, reserved FROM ledger_accounts
+		WHERE type = $1 AND owner_id = $2 AND currency = $3
+		FOR UPDATE`, account.Type, account.OwnerID, account.Currency).Scan(&id, &balance, &reserved)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to lock ledger account %s: %v", account, err)
+	}
+	return id, balance, reserved, nil
+}
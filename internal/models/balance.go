@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"payment-gateway/internal/money"
+)
+
+// UserBalance funds of the user in one currency
+type UserBalance struct {
+	Currency string `json:"currency" xml:"currency"`
+	// Balance funds posted to the user wallet by the done transactions
+	Balance money.Money `json:"balance" xml:"balance"`
+	// Available balance less the funds reserved by the withdrawals in progress
+	Available money.Money `json:"available" xml:"available"`
+	Reserved  money.Money `json:"reserved" xml:"reserved"`
+	// Pending deposits in progress, credited when they are done
+	Pending money.Money `json:"pending" xml:"pending"`
+}
+
+// Statement postings of the user wallet in [From, To), Closing is Opening plus the amounts of the lines
+type Statement struct {
+	UserID   int             `json:"userID" xml:"userID"`
+	Currency string          `json:"currency" xml:"currency"`
+	From     time.Time       `json:"from" xml:"from"`
+	To       time.Time       `json:"to" xml:"to"`
+	Opening  money.Money     `json:"openingBalance" xml:"openingBalance"`
+	Closing  money.Money     `json:"closingBalance" xml:"closingBalance"`
+	Lines    []StatementLine `json:"lines" xml:"lines>line"`
+}
+
+// StatementLine journal line of the user wallet, Balance is the running balance after the line
+type StatementLine struct {
+	EntryID       int64       `json:"entryID" xml:"entryID"`
+	TransactionID int         `json:"transactionID" xml:"transactionID"`
+	Type          string      `json:"type" xml:"type"`
+	Description   string      `json:"description" xml:"description"`
+	Amount        money.Money `json:"amount" xml:"amount"`
+	Balance       money.Money `json:"balance" xml:"balance"`
+	CreatedAt     time.Time   `json:"createdAt" xml:"createdAt"`
+}
+
+// MarshalCSV one record per line between the opening and closing balance records,
+// amounts are signed decimals in the statement currency
+func (s Statement) MarshalCSV() ([][]string, error) {
+	records := make([][]string, 0, len(s.Lines)+3)
+	records = append(records,
+		[]string{"createdAt", "entryID", "transactionID", "type", "description", "amount", "balance", "currency"},
+		[]string{s.From.UTC().Format(time.RFC3339), "", "", "opening_balance", "", "", s.Opening.String(), s.Currency},
+	)
+
+	for _, line := range s.Lines {
+		records = append(records, []string{
+			line.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(line.EntryID, 10),
+			strconv.Itoa(line.TransactionID),
+			line.Type,
+			line.Description,
+			line.Amount.String(),
+			line.Balance.String(),
+			s.Currency,
+		})
+	}
+
+	records = append(records, []string{s.To.UTC().Format(time.RFC3339), "", "", "closing_balance", "", "", s.Closing.String(), s.Currency})
+	return records, nil
+}
@@ -6,6 +6,7 @@ type Country struct {
 	ID        int
 	Name      string
 	Code      string
+	Currency  string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
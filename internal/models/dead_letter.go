@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	DeadLetterStatusFailed   = "failed"
+	DeadLetterStatusReplayed = "replayed"
+)
+
+// DeadLetter message that could not be published to kafka, kept in dead_letters until it is replayed
+type DeadLetter struct {
+	ID          int64  `json:"id" xml:"id"`
+	Topic       string `json:"topic" xml:"topic"`
+	Key         string `json:"key" xml:"key"`
+	ContentType string `json:"contentType" xml:"contentType"`
+	// Payload base64 in JSON
+	Payload []byte `json:"payload" xml:"payload"`
+	// OutboxID outbox message the dead letter was moved from, 0 when it was not published by the relay
+	OutboxID   int64      `json:"outboxID,omitempty" xml:"outboxID,omitempty"`
+	Error      string     `json:"error" xml:"error"`
+	Attempts   int        `json:"attempts" xml:"attempts"`
+	Status     string     `json:"status" xml:"status"`
+	CreatedAt  time.Time  `json:"createdAt" xml:"createdAt"`
+	ReplayedAt *time.Time `json:"replayedAt,omitempty" xml:"replayedAt,omitempty"`
+}
+
+// DeadLetterFilter empty fields match every dead letter, results are ordered by id
+type DeadLetterFilter struct {
+	Topic  string
+	Key    string
+	Status string
+	// AfterID returns dead letters with a greater id, the last id of the previous page
+	AfterID int64
+	Limit   int
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"payment-gateway/internal/money"
+)
+
+// Dispute statuses, opened → evidence_submitted → won/lost, an opened dispute may be decided without evidence
+const (
+	DisputeStatusOpened            = "opened"
+	DisputeStatusEvidenceSubmitted = "evidence_submitted"
+	DisputeStatusWon               = "won"
+	DisputeStatusLost              = "lost"
+)
+
+// Dispute chargeback of a done deposit or capture raised by the payer with the gateway, a transaction has at most one
+type Dispute struct {
+	ID            int
+	TransactionID int
+	GatewayID     int
+	// GatewayReference dispute id on the gateway side
+	GatewayReference string
+	// Amount disputed in the transaction currency, at most the transaction amount
+	Amount    money.Money
+	Reason    string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// DisputeEvidence document sent to the gateway to contest the dispute
+type DisputeEvidence struct {
+	ID          int       `json:"id" xml:"id"`
+	DisputeID   int       `json:"disputeID" xml:"disputeID"`
+	Name        string    `json:"name" xml:"name"`
+	ContentType string    `json:"contentType" xml:"contentType"`
+	Description string    `json:"description,omitempty" xml:"description,omitempty"`
+	Size        int       `json:"size" xml:"size"`
+	CreatedAt   time.Time `json:"createdAt" xml:"createdAt"`
+	// Content the document, base64 in JSON, it is only loaded when the document is downloaded
+	Content []byte `json:"content,omitempty" xml:"content,omitempty"`
+}
+
+// DisputeNotice dispute of the transaction reported by its gateway
+type DisputeNotice struct {
+	TransactionID int
+	GatewayID     int
+	// Reference dispute id on the gateway side
+	Reference string
+	Status    string
+	// Amount zero when the gateway disputes the whole transaction
+	Amount money.Money
+	Reason string
+	// Actor gateway name
+	Actor string
+}
+
+// DisputeStatusUpdate requested change of the dispute status
+type DisputeStatusUpdate struct {
+	DisputeID int    `json:"-" xml:"-"`
+	Status    string `json:"status" xml:"status"`
+	Source    string `json:"-" xml:"-"`
+	Actor     string `json:"actor" xml:"actor"`
+	Reason    string `json:"reason" xml:"reason"`
+}
+
+// DisputeTransition applied change of the dispute status, kept in dispute_status_history
+type DisputeTransition struct {
+	ID        int
+	DisputeID int
+	From      string
+	To        string
+	Source    string
+	Actor     string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// DisputeFilter zero fields match every dispute, results are ordered by id
+type DisputeFilter struct {
+	TransactionID int
+	GatewayID     int
+	Status        string
+	// AfterID returns disputes with a greater id, the last id of the previous page
+	AfterID int
+	Limit   int
+}
+
+// DisputeDetail dispute with its evidence, without the documents, and status history
+type DisputeDetail struct {
+	Dispute
+	Evidence []DisputeEvidence
+	History  []DisputeTransition
+}
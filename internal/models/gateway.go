@@ -1,13 +1,87 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"payment-gateway/internal/money"
+)
+
+const (
+	GatewayStatusActive   = "active"
+	GatewayStatusInactive = "inactive"
+)
 
 type Gateway struct {
 	ID                  int
 	Name                string
 	DataFormatSupported string
+	BaseURL             string
+	HealthURL           string // BaseURL + "/health" when empty
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
 	Priority            int
 	Status              string
+	FeePercent          money.Decimal     // fee is amount * FeePercent / 100 + FeeFixed of the currency
+	Weight              int               // share of the traffic for the weighted routing, from gateway_countries
+	Currencies          []GatewayCurrency // from gateway_currencies
+	SettlementCurrency  string            // empty when the gateway settles in the transaction currency
+	TransactionTypes    []string
+	CallbackSignature   string // one of CallbackSignature*, callbacks are rejected when empty
+	CallbackSecret      string // HMAC key or RSA public key PEM encrypted with util.Encrypt
+}
+
+// GatewayCurrency currency accepted by the gateway with the fixed fee and the amount limits in that currency
+type GatewayCurrency struct {
+	Currency  string
+	FeeFixed  money.Decimal
+	MinAmount money.Decimal // 0 means no limit
+	MaxAmount money.Decimal // 0 means no limit
+}
+
+// Currency terms of the gateway in the currency, false when the gateway doesn't accept it
+func (g Gateway) Currency(currency string) (GatewayCurrency, bool) {
+	for _, c := range g.Currencies {
+		if money.NormalizeCurrency(c.Currency) == money.NormalizeCurrency(currency) {
+			return c, true
+		}
+	}
+	return GatewayCurrency{}, false
+}
+
+// GatewayHealth health state of the gateway exposed to the admin API
+type GatewayHealth struct {
+	GatewayID     int       `json:"gatewayID" xml:"gatewayID"`
+	Name          string    `json:"name" xml:"name"`
+	Healthy       bool      `json:"healthy" xml:"healthy"`
+	BreakerState  string    `json:"breakerState" xml:"breakerState"`
+	LastCheckedAt time.Time `json:"lastCheckedAt" xml:"lastCheckedAt"`
+	LastError     string    `json:"lastError,omitempty" xml:"lastError,omitempty"`
+	LatencyMs     int64     `json:"latencyMs" xml:"latencyMs"`
+}
+
+const (
+	CallbackSignatureHMAC = "hmac-sha256"
+	CallbackSignatureRSA  = "rsa-sha256"
+)
+
+const (
+	RoutingStrategyPriority    = "priority"
+	RoutingStrategyWeighted    = "weighted"
+	RoutingStrategyLowestFee   = "lowest_fee"
+	RoutingStrategySuccessRate = "success_rate"
+)
+
+// RoutingContext describes the transaction the gateway is looked for
+type RoutingContext struct {
+	CountryID int
+	Amount    money.Money
+	Type      string
+}
+
+// RoutingConfig gateway routing strategy configured for the country
+type RoutingConfig struct {
+	CountryID int
+	Strategy  string
+	// SuccessRateWindow period of the attempts used by the success rate strategy
+	SuccessRateWindow time.Duration
 }
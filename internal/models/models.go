@@ -1,12 +1,32 @@
 package models
 
+import (
+	"encoding/xml"
+	"sort"
+
+	"payment-gateway/internal/money"
+)
+
 // TransactionRequest a standard request structure for the transactions
 type TransactionRequest struct {
-	Amount    float64 `json:"amount" xml:"amount"`
-	UserID    int     `json:"user_id" xml:"user_id"`
-	GatewayID int     `json:"gateway_id" xml:"gateway_id"`
-	CountryID int     `json:"country_id" xml:"country_id"`
-	Currency  string  `json:"currency" xml:"currency"`
+	Amount    money.Decimal `json:"amount" xml:"amount"`
+	UserID    int           `json:"user_id" xml:"user_id"`
+	GatewayID int           `json:"gateway_id" xml:"gateway_id"`
+	CountryID int           `json:"country_id" xml:"country_id"`
+	Currency  string        `json:"currency" xml:"currency"`
+}
+
+// CaptureRequest capture of the authorization AuthorizationID, zero Amount captures the whole authorized amount
+type CaptureRequest struct {
+	AuthorizationID int           `json:"-" xml:"-"`
+	Amount          money.Decimal `json:"amount" xml:"amount"`
+}
+
+// RefundRequest refund of the deposit TransactionID, zero Amount refunds the whole refundable amount
+type RefundRequest struct {
+	TransactionID int           `json:"-" xml:"-"`
+	Amount        money.Decimal `json:"amount" xml:"amount"`
+	Reason        string        `json:"reason,omitempty" xml:"reason,omitempty"`
 }
 
 // APIResponse a standard response structure for the APIs
@@ -15,3 +35,54 @@ type APIResponse struct {
 	Message    string                 `json:"message" xml:"message"`
 	Data       map[string]interface{} `json:"data,omitempty" xml:"data,omitempty"`
 }
+
+// MarshalXML encoding/xml can't encode maps, every Data entry is an element named by its key, in key order
+func (r APIResponse) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(r.StatusCode, xml.StartElement{Name: xml.Name{Local: "status_code"}}); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(r.Message, xml.StartElement{Name: xml.Name{Local: "message"}}); err != nil {
+		return err
+	}
+
+	if len(r.Data) > 0 {
+		keys := make([]string, 0, len(r.Data))
+		for key := range r.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		data := xml.StartElement{Name: xml.Name{Local: "data"}}
+		if err := e.EncodeToken(data); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := e.EncodeElement(r.Data[key], xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(data.End()); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// CSVData the Data entry returned when text/csv is negotiated, false when no entry can be encoded as CSV
+func (r APIResponse) CSVData() (CSVMarshaler, bool) {
+	for _, value := range r.Data {
+		if data, ok := value.(CSVMarshaler); ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// CSVMarshaler response data that can be returned as text/csv, the first record is the header
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
@@ -1,25 +1,201 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"payment-gateway/internal/money"
+)
+
+// Transaction statuses, created → submitted → pending → done/failed/expired, done → reversed.
+// Authorizations are authorized instead of done, then captured, voided or expired
+const (
+	TransactionStatusCreated    = "created"
+	TransactionStatusSubmitted  = "submitted"
+	TransactionStatusPending    = "pending"
+	TransactionStatusFailed     = "failed"
+	TransactionStatusDone       = "done"
+	TransactionStatusExpired    = "expired"
+	TransactionStatusReversed   = "reversed"
+	TransactionStatusAuthorized = "authorized"
+	TransactionStatusCaptured   = "captured"
+	TransactionStatusVoided     = "voided"
+)
+
+// Status change sources recorded in the transaction status history, disputes are changed by callbacks and admins
 const (
-	TransactionStatusPending = "pending"
-	TransactionStatusFailed  = "failed"
-	TransactionStatusDone    = "done"
+	StatusSourceAPI      = "api"
+	StatusSourceCallback = "callback"
+	StatusSourcePoller   = "poller"
+	StatusSourceCommand  = "command"
+	StatusSourceExpiry   = "expiry"
+	// StatusSourceAdmin only changes disputes, the transactions have no admin status endpoint
+	StatusSourceAdmin = "admin"
 )
 
 const (
 	TransactionTypeDeposit    = "deposit"
 	TransactionTypeWithdrawal = "withdrawal"
+	// TransactionTypeRefund returns funds of a done deposit, it is sent to the gateway of the deposit
+	TransactionTypeRefund = "refund"
+	// TransactionTypeAuthorization holds the amount on the gateway without moving it, it is captured or voided later
+	TransactionTypeAuthorization = "authorization"
+	// TransactionTypeCapture collects the amount held by an authorization, it is sent to the gateway of the authorization
+	TransactionTypeCapture = "capture"
 )
 
 type Transaction struct {
 	ID        int
-	Amount    float64
+	Amount    money.Money
 	Type      string
 	Status    string
 	UserID    int
 	GatewayID int
 	CountryID int
 	CreatedAt time.Time
+	// GatewayReference transaction id on the gateway side
+	GatewayReference string
+	// Conversion is set when the gateway settles in another currency
+	Conversion *FXConversion
+	// ParentID deposit refunded by the refund or authorization collected by the capture, 0 for the other types
+	ParentID int
+	// Refunded sum of the done refunds of the deposit or capture
+	Refunded money.Money
+	// ExpiresAt authorization expires unless it is captured or voided before, zero for the other types
+	ExpiresAt time.Time
+}
+
+// RefundableAmount amount of the deposit or capture not refunded yet
+func (t Transaction) RefundableAmount() money.Money {
+	refundable, err := t.Amount.Sub(t.Refunded)
+	if err != nil {
+		return t.Amount
+	}
+	return refundable
+}
+
+// GatewayAmount amount sent to the gateway, converted to the settlement currency when needed
+func (t Transaction) GatewayAmount() money.Money {
+	if t.Conversion != nil {
+		return t.Conversion.Amount
+	}
+	return t.Amount
+}
+
+// FXConversion transaction amount in the settlement currency of the gateway and the rate used
+type FXConversion struct {
+	Amount money.Money
+	Rate   money.Decimal
+	Source string
+	RateAt time.Time
+}
+
+// StatusUpdate requested change of the transaction status
+type StatusUpdate struct {
+	TransactionID int
+	Status        string
+	// GatewayID gateway reporting the status, 0 when the status is not reported by a gateway
+	GatewayID int
+	Source    string
+	Actor     string
+	Reason    string
+}
+
+// StatusTransition applied change of the transaction status, kept in transaction_status_history
+type StatusTransition struct {
+	ID            int
+	TransactionID int
+	From          string
+	To            string
+	Source        string
+	Actor         string
+	Reason        string
+	CreatedAt     time.Time
+}
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+	OutboxStatusDead      = "dead"
+)
+
+// OutboxMessage event written together with the transaction change and published to kafka by the relay
+type OutboxMessage struct {
+	ID            int64
+	TransactionID int
+	ContentType   string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	CreatedAt     time.Time
+}
+
+const (
+	AttemptStatusSucceeded = "succeeded"
+	AttemptStatusFailed    = "failed"
+)
+
+// TransactionAttempt one call of the gateway made while routing the transaction
+type TransactionAttempt struct {
+	ID               int
+	TransactionID    int
+	GatewayID        int
+	AttemptNo        int
+	Status           string
+	Error            string
+	GatewayReference string
+	CreatedAt        time.Time
+}
+
+// GatewayStats attempts of the gateway over a period
+type GatewayStats struct {
+	Succeeded int
+	Total     int
+}
+
+// Transaction search sort fields, ties are ordered by id
+const (
+	TransactionSortCreatedAt = "created_at"
+	TransactionSortAmount    = "amount"
+)
+
+// TransactionFilter zero fields match every transaction, amounts are in the transaction currency
+// and the created_at range is [CreatedFrom, CreatedTo). ParentID matches the refunds of the deposit
+// or the captures of the authorization
+type TransactionFilter struct {
+	UserID      int
+	GatewayID   int
+	CountryID   int
+	ParentID    int
+	Type        string
+	Status      string
+	Currency    string
+	MinAmount   *money.Decimal
+	MaxAmount   *money.Decimal
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Sort        string
+	Desc        bool
+	// Cursor NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// TransactionCursor sort key of the last transaction of the page, the next page starts after it
+type TransactionCursor struct {
+	CreatedAt time.Time
+	Amount    money.Decimal
+	ID        int
+}
+
+// TransactionPage transactions matching the filter, NextCursor is empty on the last page
+type TransactionPage struct {
+	Transactions []Transaction
+	NextCursor   string
+}
+
+// TransactionDetail transaction with its status history and gateway attempts
+type TransactionDetail struct {
+	Transaction
+	History  []StatusTransition
+	Attempts []TransactionAttempt
 }
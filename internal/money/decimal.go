@@ -0,0 +1,259 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxScale more fractional digits than any currency or rate we store
+const maxScale = 18
+
+var (
+	ErrInvalidDecimal = errors.New("invalid decimal number")
+	ErrOverflow       = errors.New("decimal number out of range")
+)
+
+// Decimal exact decimal number equal to value * 10^-scale.
+// Amounts travel as Decimal until the currency is known and they become Money
+type Decimal struct {
+	value int64
+	scale int
+}
+
+func NewDecimal(value int64, scale int) Decimal {
+	return Decimal{value: value, scale: scale}
+}
+
+// ParseDecimal parses plain decimal notation like "100", "-0.5" or "12.340", exponents are not accepted
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+
+	intPart, fracPart, hasPoint := strings.Cut(digits, ".")
+	if intPart == "" || (hasPoint && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	if len(fracPart) > maxScale {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+
+	value, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	if strings.HasPrefix(s, "-") {
+		value = -value
+	}
+
+	return Decimal{value: value, scale: len(fracPart)}, nil
+}
+
+// MustParseDecimal like ParseDecimal but panics, for constants and tests
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Scale number of digits after the decimal point
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.value > 0:
+		return 1
+	case d.value < 0:
+		return -1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool {
+	return d.value == 0
+}
+
+// Cmp returns -1, 0 or +1 when d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	return d.big(maxScale).Cmp(other.big(maxScale))
+}
+
+// Rescale changes the number of fractional digits, it fails when digits would be lost or the value overflows
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	if scale < 0 || scale > maxScale {
+		return Decimal{}, fmt.Errorf("%w: scale %d", ErrOverflow, scale)
+	}
+	b := d.big(maxScale)
+	q, r := new(big.Int).QuoRem(b, pow10(maxScale-scale), new(big.Int))
+	if r.Sign() != 0 {
+		return Decimal{}, fmt.Errorf("%w: %s does not fit %d decimal places", ErrInvalidDecimal, d, scale)
+	}
+	if !q.IsInt64() {
+		return Decimal{}, fmt.Errorf("%w: %s", ErrOverflow, d)
+	}
+	return Decimal{value: q.Int64(), scale: scale}, nil
+}
+
+// Add sum of d and other with the larger scale of the two
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	return roundRat(new(big.Rat).Add(d.rat(), other.rat()), max(d.scale, other.scale))
+}
+
+// Div divides by other rounding half away from zero to scale digits
+func (d Decimal) Div(other Decimal, scale int) (Decimal, error) {
+	if other.IsZero() {
+		return Decimal{}, fmt.Errorf("%w: division by zero", ErrInvalidDecimal)
+	}
+	return roundRat(new(big.Rat).Quo(d.rat(), other.rat()), scale)
+}
+
+// Mul multiplies by other rounding half away from zero to scale digits
+func (d Decimal) Mul(other Decimal, scale int) (Decimal, error) {
+	return roundRat(new(big.Rat).Mul(d.rat(), other.rat()), scale)
+}
+
+func (d Decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(d.value), pow10(d.scale))
+}
+
+func roundRat(r *big.Rat, scale int) (Decimal, error) {
+	if scale < 0 || scale > maxScale {
+		return Decimal{}, fmt.Errorf("%w: scale %d", ErrOverflow, scale)
+	}
+
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(scale)))
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	// |rem| / denom >= 1/2
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(scaled.Sign())))
+	}
+	if !q.IsInt64() {
+		return Decimal{}, ErrOverflow
+	}
+	return Decimal{value: q.Int64(), scale: scale}, nil
+}
+
+// Float64 approximate value, only for ordering and logging
+func (d Decimal) Float64() float64 {
+	return float64(d.value) / math.Pow10(d.scale)
+}
+
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.value, 10)
+	if d.scale == 0 {
+		return s
+	}
+
+	sign := ""
+	if d.value < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= d.scale {
+		s = strings.Repeat("0", d.scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+}
+
+func (d Decimal) big(scale int) *big.Int {
+	b := big.NewInt(d.value)
+	return b.Mul(b, pow10(scale-d.scale))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// MarshalJSON writes the decimal as a JSON number without going through float64
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts JSON numbers and numeric strings
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+
+	parsed, err := ParseDecimal(string(data))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan reads Postgres NUMERIC, lib/pq returns it as text
+func (d *Decimal) Scan(src interface{}) error {
+	var (
+		parsed Decimal
+		err    error
+	)
+	switch v := src.(type) {
+	case nil:
+		parsed = Decimal{}
+	case []byte:
+		parsed, err = ParseDecimal(string(v))
+	case string:
+		parsed, err = ParseDecimal(v)
+	case int64:
+		parsed = Decimal{value: v}
+	default:
+		return fmt.Errorf("cannot scan %T into money.Decimal", src)
+	}
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value writes the decimal as text so NUMERIC gets the exact value
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,239 @@
+package money
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrPrecision        = errors.New("amount has more decimal places than the currency allows")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Money amount in integer minor units of the currency, 1.05 EUR is 105, 1.050 KWD is 1050
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// New money from minor units, the currency must be valid
+func New(minor int64, currency string) Money {
+	return Money{minor: minor, currency: NormalizeCurrency(currency)}
+}
+
+// FromDecimal converts the decimal to minor units of the currency,
+// amounts finer than the currency exponent are rejected instead of rounded
+func FromDecimal(amount Decimal, currency string) (Money, error) {
+	currency = NormalizeCurrency(currency)
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	minor, err := amount.Rescale(exp)
+	if err != nil {
+		if errors.Is(err, ErrOverflow) {
+			return Money{}, err
+		}
+		return Money{}, fmt.Errorf("%w: %s %s", ErrPrecision, amount, currency)
+	}
+
+	return Money{minor: minor.value, currency: currency}, nil
+}
+
+// Parse money from the decimal text and the currency code
+func Parse(amount, currency string) (Money, error) {
+	d, err := ParseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return FromDecimal(d, currency)
+}
+
+// MustParse like Parse but panics, for constants and tests
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Minor amount in minor units
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// Decimal amount in major units
+func (m Money) Decimal() Decimal {
+	exp, _ := Exponent(m.currency)
+	return Decimal{value: m.minor, scale: exp}
+}
+
+func (m Money) Sign() int {
+	return m.Decimal().Sign()
+}
+
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.minor > 0
+}
+
+// Float64 approximate value in major units, only for ordering and logging
+func (m Money) Float64() float64 {
+	return m.Decimal().Float64()
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.currency != other.currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, other.currency)
+	}
+	sum := m.minor + other.minor
+	if (sum > m.minor) != (other.minor > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: sum, currency: m.currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(Money{minor: -other.minor, currency: other.currency})
+}
+
+// Cmp compares amounts of the same currency
+func (m Money) Cmp(other Money) (int, error) {
+	if m.currency != other.currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, other.currency)
+	}
+	return m.Decimal().Cmp(other.Decimal()), nil
+}
+
+// Convert multiplies the amount by the exchange rate, the result is rounded
+// half away from zero to the exponent of the target currency
+func (m Money) Convert(rate Decimal, currency string) (Money, error) {
+	currency = NormalizeCurrency(currency)
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	converted, err := m.Decimal().Mul(rate, exp)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{minor: converted.value, currency: currency}, nil
+}
+
+// String amount with the currency exponent, without the currency code
+func (m Money) String() string {
+	return m.Decimal().String()
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON {"amount":"100.00","currency":"EUR"}, the amount is a string so clients do not parse it as float
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Amount   Decimal `json:"amount"`
+		Currency string  `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := FromDecimal(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+type moneyXML struct {
+	Amount   string `xml:"amount"`
+	Currency string `xml:"currency"`
+}
+
+// MarshalXML <amount>100.00</amount><currency>EUR</currency> inside the field element
+func (m Money) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(moneyXML{Amount: m.String(), Currency: m.currency}, start)
+}
+
+func (m *Money) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v moneyXML
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads the NUMERIC amount column, the currency has to be scanned first
+// with CurrencyScanner because the exponent depends on it
+func (m *Money) Scan(src interface{}) error {
+	if m.currency == "" {
+		return errors.New("money: currency must be scanned before the amount")
+	}
+
+	var d Decimal
+	if err := d.Scan(src); err != nil {
+		return err
+	}
+
+	parsed, err := FromDecimal(d, m.currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount in major units for a NUMERIC column, the currency is stored separately
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// CurrencyScanner scans the currency column into the money,
+// select it before the amount column: SELECT currency, amount ...
+func (m *Money) CurrencyScanner() sql.Scanner {
+	return currencyScanner{m: m}
+}
+
+type currencyScanner struct {
+	m *Money
+}
+
+func (s currencyScanner) Scan(src interface{}) error {
+	var currency sql.NullString
+	if err := currency.Scan(src); err != nil {
+		return err
+	}
+	if !IsValidCurrency(currency.String) {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, currency.String)
+	}
+	s.m.currency = NormalizeCurrency(currency.String)
+	return nil
+}
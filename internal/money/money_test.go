@@ -0,0 +1,166 @@
+package money
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Exponents(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		minor    int64
+		str      string
+	}{
+		{amount: "100", currency: "EUR", minor: 10000, str: "100.00"},
+		{amount: "0.1", currency: "usd", minor: 10, str: "0.10"},
+		{amount: "1500", currency: "JPY", minor: 1500, str: "1500"},
+		{amount: "1500.00", currency: "JPY", minor: 1500, str: "1500"},
+		{amount: "1.005", currency: "KWD", minor: 1005, str: "1.005"},
+		{amount: "999999999999.99", currency: "EUR", minor: 99999999999999, str: "999999999999.99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount+tt.currency, func(t *testing.T) {
+			m, err := Parse(tt.amount, tt.currency)
+			require.NoError(t, err)
+			assert.Equal(t, tt.minor, m.Minor())
+			assert.Equal(t, tt.str, m.String())
+			assert.Equal(t, NormalizeCurrency(tt.currency), m.Currency())
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse("10.001", "EUR")
+	assert.ErrorIs(t, err, ErrPrecision)
+
+	_, err = Parse("10.5", "JPY")
+	assert.ErrorIs(t, err, ErrPrecision)
+
+	_, err = Parse("10", "XXX")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+
+	for _, s := range []string{"", "abc", "1e5", "1.", ".5", "--1", "1,5"} {
+		_, err = ParseDecimal(s)
+		assert.ErrorIs(t, err, ErrInvalidDecimal, s)
+	}
+
+	_, err = Parse("92233720368547758.08", "EUR")
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	a := MustParse("0.10", "EUR")
+	b := MustParse("0.20", "EUR")
+
+	sum, err := a.Add(b)
+	require.NoError(t, err)
+	assert.Equal(t, "0.30", sum.String())
+
+	diff, err := a.Sub(b)
+	require.NoError(t, err)
+	assert.Equal(t, "-0.10", diff.String())
+
+	cmp, err := a.Cmp(b)
+	require.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+
+	_, err = a.Add(MustParse("1", "USD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(MustParse("100", "EUR"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"100.00","currency":"EUR"}`, string(data))
+
+	var m Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":12345678901234.56,"currency":"EUR"}`), &m))
+	assert.Equal(t, int64(1234567890123456), m.Minor())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"1.234","currency":"EUR"}`), &m))
+}
+
+func TestMoney_XML(t *testing.T) {
+	type payment struct {
+		XMLName xml.Name `xml:"payment"`
+		Amount  Money    `xml:"total"`
+	}
+
+	data, err := xml.Marshal(payment{Amount: MustParse("7.5", "KWD")})
+	require.NoError(t, err)
+	assert.Equal(t, `<payment><total><amount>7.500</amount><currency>KWD</currency></total></payment>`, string(data))
+
+	var got payment
+	require.NoError(t, xml.Unmarshal(data, &got))
+	assert.Equal(t, MustParse("7.5", "KWD"), got.Amount)
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	var req struct {
+		Amount Decimal `json:"amount"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":0.1}`), &req))
+	assert.Equal(t, NewDecimal(1, 1), req.Amount)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"19.99"}`), &req))
+	assert.Equal(t, "19.99", req.Amount.String())
+
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.Equal(t, `{"amount":19.99}`, string(data))
+}
+
+func TestMoney_ScanValue(t *testing.T) {
+	var m Money
+	assert.Error(t, m.Scan([]byte("10.0000")))
+
+	require.NoError(t, m.CurrencyScanner().Scan([]byte("JPY")))
+	require.NoError(t, m.Scan([]byte("1500.0000")))
+	assert.Equal(t, New(1500, "JPY"), m)
+
+	v, err := MustParse("0.05", "EUR").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "0.05", v)
+}
+
+func TestMoney_Convert(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		rate     string
+		currency string
+		want     string
+	}{
+		{amount: MustParse("100", "EUR"), rate: "1.0845", currency: "USD", want: "108.45"},
+		{amount: MustParse("10.01", "EUR"), rate: "0.5", currency: "USD", want: "5.01"},
+		{amount: MustParse("-10.01", "EUR"), rate: "0.5", currency: "USD", want: "-5.01"},
+		{amount: MustParse("1500", "JPY"), rate: "0.0061234", currency: "EUR", want: "9.19"},
+		{amount: MustParse("9.19", "EUR"), rate: "163.3", currency: "JPY", want: "1501"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.amount.Convert(MustParseDecimal(tt.rate), tt.currency)
+		require.NoError(t, err)
+		assert.Equal(t, MustParse(tt.want, tt.currency), got)
+	}
+}
+
+func TestDecimal_Div(t *testing.T) {
+	got, err := MustParseDecimal("1.0845").Div(MustParseDecimal("0.8571"), 8)
+	require.NoError(t, err)
+	assert.Equal(t, "1.26531327", got.String())
+
+	_, err = MustParseDecimal("1").Div(Decimal{}, 8)
+	assert.ErrorIs(t, err, ErrInvalidDecimal)
+}
+
+func TestDecimal_Add(t *testing.T) {
+	got, err := MustParseDecimal("0.015").Add(MustParseDecimal("-1.30"))
+	require.NoError(t, err)
+	assert.Equal(t, "-1.285", got.String())
+}
@@ -0,0 +1,102 @@
+//go:generate mockgen -source attempt.go -destination mocks/attempt.go -package mocks
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"payment-gateway/internal/models"
+
+	"github.com/lib/pq"
+)
+
+type AttemptRepository interface {
+	CreateAttempt(attempt models.TransactionAttempt) error
+	GetAttempts(transactionID int) ([]models.TransactionAttempt, error)
+	GetSuccessStats(gatewayIDs []int, since time.Time) (map[int]models.GatewayStats, error)
+}
+
+type attemptRepository struct {
+	db *sql.DB
+}
+
+func NewAttemptRepository(db *sql.DB) AttemptRepository {
+	return &attemptRepository{
+		db: db,
+	}
+}
+
+func (r *attemptRepository) CreateAttempt(attempt models.TransactionAttempt) error {
+	query := `INSERT INTO transaction_attempts (transaction_id, gateway_id, attempt_no, status, error, gateway_reference, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(query, attempt.TransactionID, attempt.GatewayID, attempt.AttemptNo, attempt.Status,
+		attempt.Error, attempt.GatewayReference, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to insert transaction attempt: %v", err)
+	}
+	return nil
+}
+
+// GetAttempts returns routing history of the transaction
+func (r *attemptRepository) GetAttempts(transactionID int) ([]models.TransactionAttempt, error) {
+	query := `
+		SELECT id, transaction_id, gateway_id, attempt_no, status, COALESCE(error, ''), COALESCE(gateway_reference, ''), created_at
+		FROM transaction_attempts
+		WHERE transaction_id = $1
+		ORDER BY attempt_no ASC
+	`
+	rows, err := r.db.Query(query, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction attempts: %v", err)
+	}
+	defer rows.Close()
+
+	var attempts []models.TransactionAttempt
+	for rows.Next() {
+		var attempt models.TransactionAttempt
+		if err := rows.Scan(&attempt.ID, &attempt.TransactionID, &attempt.GatewayID, &attempt.AttemptNo, &attempt.Status,
+			&attempt.Error, &attempt.GatewayReference, &attempt.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction attempt: %v", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// GetSuccessStats counts succeeded and total attempts per gateway made after since
+func (r *attemptRepository) GetSuccessStats(gatewayIDs []int, since time.Time) (map[int]models.GatewayStats, error) {
+	query := `
+		SELECT gateway_id,
+		       COUNT(*) FILTER (WHERE status = $1),
+		       COUNT(*)
+		FROM transaction_attempts
+		WHERE gateway_id = ANY($2) AND created_at >= $3
+		GROUP BY gateway_id
+	`
+	rows, err := r.db.Query(query, models.AttemptStatusSucceeded, pq.Array(gatewayIDs), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gateway success stats: %v", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[int]models.GatewayStats, len(gatewayIDs))
+	for rows.Next() {
+		var (
+			gatewayID int
+			stat      models.GatewayStats
+		)
+		if err := rows.Scan(&gatewayID, &stat.Succeeded, &stat.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan gateway success stats: %v", err)
+		}
+		stats[gatewayID] = stat
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,52 @@
+//go:generate mockgen -source callback_nonce.go -destination mocks/callback_nonce.go -package mocks
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type CallbackNonceRepository interface {
+	// UseNonce records the nonce of the gateway callback, it returns false when the nonce has been used already.
+	// Nonces received before expiredBefore are dropped, callbacks that old are rejected by the timestamp check
+	UseNonce(gatewayID int, nonce string, receivedAt, expiredBefore time.Time) (bool, error)
+	// ReleaseNonce drops the nonce of a callback which could not be processed, the gateway can deliver it again
+	ReleaseNonce(gatewayID int, nonce string) error
+}
+
+type callbackNonceRepository struct {
+	db *sql.DB
+}
+
+func NewCallbackNonceRepository(db *sql.DB) CallbackNonceRepository {
+	return &callbackNonceRepository{
+		db: db,
+	}
+}
+
+func (r *callbackNonceRepository) UseNonce(gatewayID int, nonce string, receivedAt, expiredBefore time.Time) (bool, error) {
+	if _, err := r.db.Exec(`DELETE FROM callback_nonces WHERE received_at < $1`, expiredBefore); err != nil {
+		return false, fmt.Errorf("failed to delete expired callback nonces: %v", err)
+	}
+
+	query := `INSERT INTO callback_nonces (gateway_id, nonce, received_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	result, err := r.db.Exec(query, gatewayID, nonce, receivedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert callback nonce: %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert callback nonce: %v", err)
+	}
+	return inserted == 1, nil
+}
+
+func (r *callbackNonceRepository) ReleaseNonce(gatewayID int, nonce string) error {
+	query := `DELETE FROM callback_nonces WHERE gateway_id = $1 AND nonce = $2`
+	if _, err := r.db.Exec(query, gatewayID, nonce); err != nil {
+		return fmt.Errorf("failed to delete callback nonce: %v", err)
+	}
+	return nil
+}
@@ -3,13 +3,16 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"payment-gateway/internal/models"
 )
 
-type CountryRepository interface{}
+type CountryRepository interface {
+	GetCountryByID(countryID int) (models.Country, error)
+}
 
 type countryRepository struct {
 	db *sql.DB
@@ -22,10 +25,10 @@ func NewCountryRepository(db *sql.DB) CountryRepository {
 }
 
 func (r *countryRepository) CreateCountry(db *sql.DB, country models.Country) error {
-	query := `INSERT INTO countries (name, code, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4) RETURNING id`
+	query := `INSERT INTO countries (name, code, currency, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
 
-	err := db.QueryRow(query, country.Name, country.Code, time.Now(), time.Now()).Scan(&country.ID)
+	err := db.QueryRow(query, country.Name, country.Code, country.Currency, time.Now(), time.Now()).Scan(&country.ID)
 	if err != nil {
 		return fmt.Errorf("failed to insert country: %w", err)
 	}
@@ -33,7 +36,7 @@ func (r *countryRepository) CreateCountry(db *sql.DB, country models.Country) er
 }
 
 func (r *countryRepository) GetCountries(db *sql.DB) ([]models.Country, error) {
-	rows, err := db.Query(`SELECT id, name, code, created_at, updated_at FROM countries`)
+	rows, err := db.Query(`SELECT id, name, code, currency, created_at, updated_at FROM countries`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch countries: %w", err)
 	}
@@ -42,7 +45,7 @@ func (r *countryRepository) GetCountries(db *sql.DB) ([]models.Country, error) {
 	var countries []models.Country
 	for rows.Next() {
 		var country models.Country
-		if err := rows.Scan(&country.ID, &country.Name, &country.Code, &country.CreatedAt, &country.UpdatedAt); err != nil {
+		if err := rows.Scan(&country.ID, &country.Name, &country.Code, &country.Currency, &country.CreatedAt, &country.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan country: %v", err)
 		}
 		countries = append(countries, country)
@@ -53,6 +56,22 @@ func (r *countryRepository) GetCountries(db *sql.DB) ([]models.Country, error) {
 	return countries, nil
 }
 
+func (r *countryRepository) GetCountryByID(countryID int) (models.Country, error) {
+	var country models.Country
+
+	query := `SELECT id, name, code, currency, created_at, updated_at FROM countries WHERE id = $1`
+
+	err := r.db.QueryRow(query, countryID).Scan(&country.ID, &country.Name, &country.Code, &country.Currency, &country.CreatedAt, &country.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Country{}, fmt.Errorf("no country found with id %d", countryID)
+		}
+		return models.Country{}, fmt.Errorf("failed to fetch country: %v", err)
+	}
+
+	return country, nil
+}
+
 func (r *countryRepository) GetSupportedCountriesByGateway(db *sql.DB, gatewayID int) ([]models.Country, error) {
 	query := `
 		SELECT c.id AS country_id, c.name AS country_name
@@ -0,0 +1,153 @@
+//go:generate mockgen -source dead_letter.go -destination mocks/dead_letter.go -package mocks
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"payment-gateway/internal/models"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+type DeadLetterRepository interface {
+	ListDeadLetters(filter models.DeadLetterFilter) ([]models.DeadLetter, error)
+	GetDeadLetter(id int64) (models.DeadLetter, error)
+	MarkReplayed(id int64, replayedAt time.Time) error
+	// MarkReplayFailed records the failed replay, the dead letter stays failed
+	MarkReplayFailed(id int64, lastErr string) error
+	// LaterPublished reports whether a later outbox message of the same transaction as the dead outbox message
+	// has been published
+	LaterPublished(outboxID int64) (bool, error)
+}
+
+type deadLetterRepository struct {
+	db *sql.DB
+}
+
+func NewDeadLetterRepository(db *sql.DB) DeadLetterRepository {
+	return &deadLetterRepository{
+		db: db,
+	}
+}
+
+func insertDeadLetter(db execer, deadLetter models.DeadLetter) error {
+	var outboxID sql.NullInt64
+	if deadLetter.OutboxID != 0 {
+		outboxID = sql.NullInt64{Int64: deadLetter.OutboxID, Valid: true}
+	}
+
+	query := `INSERT INTO dead_letters (topic, message_key, content_type, payload, outbox_id, error, attempts, status, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := db.Exec(query, deadLetter.Topic, deadLetter.Key, deadLetter.ContentType, deadLetter.Payload, outboxID,
+		deadLetter.Error, deadLetter.Attempts, models.DeadLetterStatusFailed, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to insert dead letter: %v", err)
+	}
+	return nil
+}
+
+const deadLetterColumns = `id, topic, message_key, content_type, payload, COALESCE(outbox_id, 0), error, attempts, status, created_at, replayed_at`
+
+func scanDeadLetter(row rowScanner) (models.DeadLetter, error) {
+	var (
+		deadLetter models.DeadLetter
+		replayedAt sql.NullTime
+	)
+	err := row.Scan(&deadLetter.ID, &deadLetter.Topic, &deadLetter.Key, &deadLetter.ContentType, &deadLetter.Payload,
+		&deadLetter.OutboxID, &deadLetter.Error, &deadLetter.Attempts, &deadLetter.Status, &deadLetter.CreatedAt, &replayedAt)
+	if err != nil {
+		return models.DeadLetter{}, err
+	}
+	if replayedAt.Valid {
+		deadLetter.ReplayedAt = &replayedAt.Time
+	}
+	return deadLetter, nil
+}
+
+func (r *deadLetterRepository) ListDeadLetters(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	conditions := []string{"id > $1"}
+	args := []interface{}{filter.AfterID}
+	addCondition := func(column, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		conditions = append(conditions, column+" = $"+strconv.Itoa(len(args)))
+	}
+	addCondition("topic", filter.Topic)
+	addCondition("message_key", filter.Key)
+	addCondition("status", filter.Status)
+
+	args = append(args, filter.Limit)
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY id LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dead letters: %v", err)
+	}
+	defer rows.Close()
+
+	var deadLetters []models.DeadLetter
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %v", err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (r *deadLetterRepository) GetDeadLetter(id int64) (models.DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE id = $1`
+
+	deadLetter, err := scanDeadLetter(r.db.QueryRow(query, id))
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.DeadLetter{}, fmt.Errorf("%w with ID: %d", ErrDeadLetterNotFound, id)
+	case err != nil:
+		return models.DeadLetter{}, fmt.Errorf("failed to fetch dead letter: %v", err)
+	default:
+		return deadLetter, nil
+	}
+}
+
+func (r *deadLetterRepository) MarkReplayed(id int64, replayedAt time.Time) error {
+	query := `UPDATE dead_letters SET status = $1, replayed_at = $2 WHERE id = $3`
+	if _, err := r.db.Exec(query, models.DeadLetterStatusReplayed, replayedAt, id); err != nil {
+		return fmt.Errorf("failed to mark dead letter replayed: %v", err)
+	}
+	return nil
+}
+
+func (r *deadLetterRepository) MarkReplayFailed(id int64, lastErr string) error {
+	query := `UPDATE dead_letters SET attempts = attempts + 1, error = $1 WHERE id = $2`
+	if _, err := r.db.Exec(query, lastErr, id); err != nil {
+		return fmt.Errorf("failed to record dead letter replay: %v", err)
+	}
+	return nil
+}
+
+func (r *deadLetterRepository) LaterPublished(outboxID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM outbox later
+			JOIN outbox dead ON dead.id = $1
+			WHERE later.transaction_id = dead.transaction_id AND later.id > dead.id AND later.status = $2
+		)`
+	var published bool
+	if err := r.db.QueryRow(query, outboxID, models.OutboxStatusPublished).Scan(&published); err != nil {
+		return false, fmt.Errorf("failed to check later outbox messages: %v", err)
+	}
+	return published, nil
+}
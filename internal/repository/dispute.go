@@ -0,0 +1,286 @@
+//go:generate mockgen -source dispute.go -destination mocks/dispute.go -package mocks
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+)
+
+var (
+	ErrDisputeNotFound  = errors.New("dispute not found")
+	ErrEvidenceNotFound = errors.New("dispute evidence not found")
+	// ErrDisputeExists the transaction already has a dispute
+	ErrDisputeExists = errors.New("transaction is already disputed")
+)
+
+type DisputeRepository interface {
+	// CreateDispute inserts the dispute and the outbox message built by newMessage in one SQL transaction,
+	// ErrDisputeExists is returned when the transaction already has a dispute
+	CreateDispute(dispute models.Dispute, newMessage func(models.Dispute) (models.OutboxMessage, error)) (int, error)
+	GetDispute(id int) (*models.Dispute, error)
+	// GetDisputeByTransaction returns ErrDisputeNotFound when the transaction is not disputed
+	GetDisputeByTransaction(transactionID int) (*models.Dispute, error)
+	ListDisputes(filter models.DisputeFilter) ([]models.Dispute, error)
+	// TransitionDispute moves the dispute from transition.From to transition.To, records the history, the outbox
+	// message and the ledger posting in one SQL transaction. ErrStatusConflict is returned when the dispute
+	// is no longer in transition.From
+	TransitionDispute(transition models.DisputeTransition, message models.OutboxMessage, posting ledger.Posting) error
+	GetDisputeHistory(disputeID int) ([]models.DisputeTransition, error)
+	AddEvidence(evidence models.DisputeEvidence) (int, error)
+	// ListEvidence returns the evidence of the dispute without the documents, oldest first
+	ListEvidence(disputeID int) ([]models.DisputeEvidence, error)
+	// GetEvidence returns the evidence of the dispute with its document
+	GetEvidence(disputeID, evidenceID int) (*models.DisputeEvidence, error)
+}
+
+type disputeRepository struct {
+	db *sql.DB
+}
+
+func NewDisputeRepository(db *sql.DB) DisputeRepository {
+	return &disputeRepository{
+		db: db,
+	}
+}
+
+const disputeColumns = `id, transaction_id, gateway_id, COALESCE(gateway_reference, ''), currency, amount, reason, status, created_at, updated_at`
+
+func scanDispute(row rowScanner) (models.Dispute, error) {
+	var dispute models.Dispute
+	err := row.Scan(&dispute.ID, &dispute.TransactionID, &dispute.GatewayID, &dispute.GatewayReference,
+		dispute.Amount.CurrencyScanner(), &dispute.Amount, &dispute.Reason, &dispute.Status, &dispute.CreatedAt, &dispute.UpdatedAt)
+	if err != nil {
+		return models.Dispute{}, err
+	}
+	return dispute, nil
+}
+
+func (r *disputeRepository) CreateDispute(dispute models.Dispute, newMessage func(models.Dispute) (models.OutboxMessage, error)) (int, error) {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin dispute insert: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	now := time.Now()
+	query := `INSERT INTO disputes (transaction_id, gateway_id, gateway_reference, amount, currency, reason, status, created_at, updated_at)
+			  VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $8)
+			  ON CONFLICT (transaction_id) DO NOTHING RETURNING id`
+	err = dbTx.QueryRow(query, dispute.TransactionID, dispute.GatewayID, dispute.GatewayReference, dispute.Amount,
+		dispute.Amount.Currency(), dispute.Reason, dispute.Status, now).Scan(&dispute.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, fmt.Errorf("%w: transaction %d", ErrDisputeExists, dispute.TransactionID)
+	case err != nil:
+		return 0, fmt.Errorf("failed to insert dispute: %v", err)
+	}
+	dispute.CreatedAt, dispute.UpdatedAt = now, now
+
+	message, err := newMessage(dispute)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build outbox message: %v", err)
+	}
+	if err := insertOutboxMessage(dbTx, message); err != nil {
+		return 0, err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit dispute insert: %v", err)
+	}
+	return dispute.ID, nil
+}
+
+func (r *disputeRepository) GetDispute(id int) (*models.Dispute, error) {
+	return r.getDispute(`SELECT `+disputeColumns+` FROM disputes WHERE id = $1`, id)
+}
+
+func (r *disputeRepository) GetDisputeByTransaction(transactionID int) (*models.Dispute, error) {
+	dispute, err := r.getDispute(`SELECT `+disputeColumns+` FROM disputes WHERE transaction_id = $1`, transactionID)
+	if errors.Is(err, ErrDisputeNotFound) {
+		return nil, fmt.Errorf("%w of transaction: %d", ErrDisputeNotFound, transactionID)
+	}
+	return dispute, err
+}
+
+func (r *disputeRepository) getDispute(query string, id int) (*models.Dispute, error) {
+	dispute, err := scanDispute(r.db.QueryRow(query, id))
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("%w with ID: %d", ErrDisputeNotFound, id)
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch dispute: %v", err)
+	default:
+		return &dispute, nil
+	}
+}
+
+func (r *disputeRepository) ListDisputes(filter models.DisputeFilter) ([]models.Dispute, error) {
+	conditions := []string{"id > $1"}
+	args := []interface{}{filter.AfterID}
+	addCondition := func(column string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, column+" = $"+strconv.Itoa(len(args)))
+	}
+	if filter.TransactionID != 0 {
+		addCondition("transaction_id", filter.TransactionID)
+	}
+	if filter.GatewayID != 0 {
+		addCondition("gateway_id", filter.GatewayID)
+	}
+	if filter.Status != "" {
+		addCondition("status", filter.Status)
+	}
+
+	args = append(args, filter.Limit)
+	query := `SELECT ` + disputeColumns + ` FROM disputes WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY id LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch disputes: %v", err)
+	}
+	defer rows.Close()
+
+	var disputes []models.Dispute
+	for rows.Next() {
+		dispute, err := scanDispute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dispute: %v", err)
+		}
+		disputes = append(disputes, dispute)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+func (r *disputeRepository) TransitionDispute(transition models.DisputeTransition, message models.OutboxMessage, posting ledger.Posting) error {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin dispute transition: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	now := time.Now()
+	// compare-and-set, the gateway and an operator may change the dispute at the same time
+	result, err := dbTx.Exec(`UPDATE disputes SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4`,
+		transition.To, now, transition.DisputeID, transition.From)
+	if err != nil {
+		return fmt.Errorf("failed to update dispute status: %v", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update dispute status: %v", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("%w: dispute %d is not %s", ErrStatusConflict, transition.DisputeID, transition.From)
+	}
+
+	query := `INSERT INTO dispute_status_history (dispute_id, from_status, to_status, source, actor, reason, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = dbTx.Exec(query, transition.DisputeID, transition.From, transition.To, transition.Source, transition.Actor,
+		transition.Reason, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert dispute status history: %v", err)
+	}
+
+	if err := insertOutboxMessage(dbTx, message); err != nil {
+		return err
+	}
+
+	if err := ledger.Apply(dbTx, posting); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit dispute transition: %v", err)
+	}
+	return nil
+}
+
+func (r *disputeRepository) GetDisputeHistory(disputeID int) ([]models.DisputeTransition, error) {
+	query := `SELECT id, dispute_id, from_status, to_status, source, actor, reason, created_at
+			  FROM dispute_status_history WHERE dispute_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dispute status history: %v", err)
+	}
+	defer rows.Close()
+
+	var history []models.DisputeTransition
+	for rows.Next() {
+		var change models.DisputeTransition
+		if err := rows.Scan(&change.ID, &change.DisputeID, &change.From, &change.To, &change.Source, &change.Actor,
+			&change.Reason, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dispute status history: %v", err)
+		}
+		history = append(history, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *disputeRepository) AddEvidence(evidence models.DisputeEvidence) (int, error) {
+	query := `INSERT INTO dispute_evidence (dispute_id, name, content_type, description, content, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := r.db.QueryRow(query, evidence.DisputeID, evidence.Name, evidence.ContentType, evidence.Description,
+		evidence.Content, time.Now()).Scan(&evidence.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert dispute evidence: %v", err)
+	}
+	return evidence.ID, nil
+}
+
+func (r *disputeRepository) ListEvidence(disputeID int) ([]models.DisputeEvidence, error) {
+	query := `SELECT id, dispute_id, name, content_type, description, LENGTH(content), created_at
+			  FROM dispute_evidence WHERE dispute_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, disputeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dispute evidence: %v", err)
+	}
+	defer rows.Close()
+
+	var evidence []models.DisputeEvidence
+	for rows.Next() {
+		var document models.DisputeEvidence
+		if err := rows.Scan(&document.ID, &document.DisputeID, &document.Name, &document.ContentType, &document.Description,
+			&document.Size, &document.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dispute evidence: %v", err)
+		}
+		evidence = append(evidence, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return evidence, nil
+}
+
+func (r *disputeRepository) GetEvidence(disputeID, evidenceID int) (*models.DisputeEvidence, error) {
+	query := `SELECT id, dispute_id, name, content_type, description, content, created_at
+			  FROM dispute_evidence WHERE id = $1 AND dispute_id = $2`
+
+	var document models.DisputeEvidence
+	err := r.db.QueryRow(query, evidenceID, disputeID).Scan(&document.ID, &document.DisputeID, &document.Name,
+		&document.ContentType, &document.Description, &document.Content, &document.CreatedAt)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("%w with ID: %d", ErrEvidenceNotFound, evidenceID)
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch dispute evidence: %v", err)
+	default:
+		document.Size = len(document.Content)
+		return &document, nil
+	}
+}
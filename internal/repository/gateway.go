@@ -3,17 +3,22 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"payment-gateway/internal/models"
+
+	"github.com/lib/pq"
 )
 
 type GatewayRepository interface {
 	GetAvailableGateways(countryID int) ([]models.Gateway, error)
 	CreateGateway(gateway models.Gateway) error
 	GetGateways() ([]models.Gateway, error)
+	GetGatewayByName(name string) (models.Gateway, error)
+	GetGatewayByID(id int) (models.Gateway, error)
 }
 
 type gatewayRepository struct {
@@ -26,12 +31,20 @@ func NewGatewayRepository(db *sql.DB) GatewayRepository {
 	}
 }
 
+// GetAvailableGateways returns active gateways of the country with their currencies and limits,
+// eligibility of the transaction itself is checked by the gateway service
 func (r *gatewayRepository) GetAvailableGateways(countryID int) ([]models.Gateway, error) {
 	query := `
 		SELECT g.id, 
 		       g.name, 
 		       g.data_format_supported, 
-		       g.priority
+		       g.base_url,
+		       g.health_url,
+		       g.priority,
+		       g.fee_percent,
+		       gc.weight,
+		       g.transaction_types,
+		       COALESCE(g.settlement_currency, '')
 		FROM gateways g
 		JOIN gateway_countries gc ON g.id = gc.gateway_id
 		WHERE gc.country_id = $1 AND g.status = 'active'
@@ -51,7 +64,8 @@ func (r *gatewayRepository) GetAvailableGateways(countryID int) ([]models.Gatewa
 	var gateways []models.Gateway
 	for rows.Next() {
 		var gateway models.Gateway
-		if err := rows.Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.Priority); err != nil {
+		if err := rows.Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.BaseURL, &gateway.HealthURL, &gateway.Priority,
+			&gateway.FeePercent, &gateway.Weight, pq.Array(&gateway.TransactionTypes), &gateway.SettlementCurrency); err != nil {
 			return nil, fmt.Errorf("failed to scan gateway: %v", err)
 		}
 		gateways = append(gateways, gateway)
@@ -61,14 +75,59 @@ func (r *gatewayRepository) GetAvailableGateways(countryID int) ([]models.Gatewa
 		return nil, fmt.Errorf("error scanning rows: %v", err)
 	}
 
+	if err := r.loadCurrencies(gateways); err != nil {
+		return nil, err
+	}
 	return gateways, nil
 }
 
+// loadCurrencies sets the currencies of the gateways with the fixed fee and the limits in each of them
+func (r *gatewayRepository) loadCurrencies(gateways []models.Gateway) error {
+	if len(gateways) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Gateway, len(gateways))
+	ids := make([]int64, 0, len(gateways))
+	for i := range gateways {
+		byID[gateways[i].ID] = &gateways[i]
+		ids = append(ids, int64(gateways[i].ID))
+	}
+
+	rows, err := r.db.Query(`
+		SELECT gateway_id, currency, fee_fixed, COALESCE(min_amount, 0), COALESCE(max_amount, 0)
+		FROM gateway_currencies
+		WHERE gateway_id = ANY($1)
+		ORDER BY gateway_id, currency
+	`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to fetch gateway currencies: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			gatewayID int
+			currency  models.GatewayCurrency
+		)
+		if err := rows.Scan(&gatewayID, &currency.Currency, &currency.FeeFixed, &currency.MinAmount, &currency.MaxAmount); err != nil {
+			return fmt.Errorf("failed to scan gateway currency: %v", err)
+		}
+		gw := byID[gatewayID]
+		gw.Currencies = append(gw.Currencies, currency)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch gateway currencies: %v", err)
+	}
+	return nil
+}
+
 func (r *gatewayRepository) CreateGateway(gateway models.Gateway) error {
-	query := `INSERT INTO gateway (name, data_format_supported, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4) RETURNING id`
+	query := `INSERT INTO gateways (name, data_format_supported, base_url, health_url, priority, status, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
 
-	err := r.db.QueryRow(query, gateway.Name, gateway.DataFormatSupported, time.Now(), time.Now()).Scan(&gateway.ID)
+	err := r.db.QueryRow(query, gateway.Name, gateway.DataFormatSupported, gateway.BaseURL, gateway.HealthURL,
+		gateway.Priority, gateway.Status, time.Now(), time.Now()).Scan(&gateway.ID)
 	if err != nil {
 		return fmt.Errorf("failed to insert gateway: %v", err)
 	}
@@ -76,7 +135,11 @@ func (r *gatewayRepository) CreateGateway(gateway models.Gateway) error {
 }
 
 func (r *gatewayRepository) GetGateways() ([]models.Gateway, error) {
-	rows, err := r.db.Query(`SELECT id, name, data_format_supported, created_at, updated_at FROM gateway`)
+	rows, err := r.db.Query(`
+		SELECT id, name, data_format_supported, base_url, health_url, COALESCE(priority, 0), COALESCE(status, ''), created_at, updated_at
+		FROM gateways
+		ORDER BY priority ASC
+	`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch gateway: %v", err)
 	}
@@ -85,7 +148,7 @@ func (r *gatewayRepository) GetGateways() ([]models.Gateway, error) {
 	var gateways []models.Gateway
 	for rows.Next() {
 		var gateway models.Gateway
-		if err := rows.Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.CreatedAt, &gateway.UpdatedAt); err != nil {
+		if err := rows.Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.BaseURL, &gateway.HealthURL, &gateway.Priority, &gateway.Status, &gateway.CreatedAt, &gateway.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan gateway: %v", err)
 		}
 		gateways = append(gateways, gateway)
@@ -95,3 +158,50 @@ func (r *gatewayRepository) GetGateways() ([]models.Gateway, error) {
 	}
 	return gateways, nil
 }
+
+// ErrGatewayNotFound returned by GetGatewayByName and GetGatewayByID for unknown gateways
+var ErrGatewayNotFound = errors.New("gateway not found")
+
+// GetGatewayByName returns gateway with its callback signature settings, the secret is still encrypted
+func (r *gatewayRepository) GetGatewayByName(name string) (models.Gateway, error) {
+	query := `
+		SELECT id, name, data_format_supported, base_url, COALESCE(status, ''),
+		       COALESCE(callback_signature, ''), COALESCE(callback_secret, '')
+		FROM gateways
+		WHERE name = $1
+	`
+	var gateway models.Gateway
+	err := r.db.QueryRow(query, name).Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.BaseURL,
+		&gateway.Status, &gateway.CallbackSignature, &gateway.CallbackSecret)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.Gateway{}, fmt.Errorf("%w: %s", ErrGatewayNotFound, name)
+	case err != nil:
+		return models.Gateway{}, fmt.Errorf("failed to fetch gateway: %v", err)
+	default:
+		return gateway, nil
+	}
+}
+
+// GetGatewayByID returns gateway a transaction has been processed by, whatever its status is now
+func (r *gatewayRepository) GetGatewayByID(id int) (models.Gateway, error) {
+	query := `
+		SELECT id, name, data_format_supported, base_url, health_url, COALESCE(status, ''),
+		       COALESCE(settlement_currency, '')
+		FROM gateways
+		WHERE id = $1
+	`
+	var gateway models.Gateway
+	err := r.db.QueryRow(query, id).Scan(&gateway.ID, &gateway.Name, &gateway.DataFormatSupported, &gateway.BaseURL,
+		&gateway.HealthURL, &gateway.Status, &gateway.SettlementCurrency)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.Gateway{}, fmt.Errorf("%w with ID: %d", ErrGatewayNotFound, id)
+	case err != nil:
+		return models.Gateway{}, fmt.Errorf("failed to fetch gateway: %v", err)
+	default:
+		return gateway, nil
+	}
+}
@@ -0,0 +1,95 @@
+//go:generate mockgen -source lock.go -destination mocks/lock.go -package mocks
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+)
+
+// Advisory lock keys of the workers which run on one replica at a time
+const (
+	PendingPollerLockKey       int64 = 7_240_001
+	AuthorizationExpiryLockKey int64 = 7_240_002
+)
+
+// LeaderLock elects one replica to run a worker
+type LeaderLock interface {
+	// TryAcquire takes the lock unless another replica holds it, a replica holding it already keeps it as long as
+	// its session is alive
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release gives the lock up so that another replica can take it
+	Release(ctx context.Context) error
+}
+
+// advisoryLock Postgres session advisory lock, it is held on a connection taken out of the pool and is released
+// by Postgres when the session ends, so a crashed replica doesn't keep it
+type advisoryLock struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewAdvisoryLock(db *sql.DB, key int64) LeaderLock {
+	return &advisoryLock{
+		db:  db,
+		key: key,
+	}
+}
+
+func (l *advisoryLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err != nil {
+			// the session is gone and the lock with it, another replica may have it by now
+			l.conn.Close()
+			l.conn = nil
+			return false, fmt.Errorf("lost advisory lock %d: %v", l.key, err)
+		}
+		return true, nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection for advisory lock: %v", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, fmt.Errorf("failed to acquire advisory lock %d: %v", l.key, err)
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+func (l *advisoryLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+
+	if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		// the connection must not go back to the pool with the lock, closing the session releases it
+		_ = l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return fmt.Errorf("failed to release advisory lock %d: %v", l.key, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: attempt.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	models "payment-gateway/internal/models"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAttemptRepository is a mock of AttemptRepository interface.
+type MockAttemptRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAttemptRepositoryMockRecorder
+}
+
+// MockAttemptRepositoryMockRecorder is the mock recorder for MockAttemptRepository.
+type MockAttemptRepositoryMockRecorder struct {
+	mock *MockAttemptRepository
+}
+
+// NewMockAttemptRepository creates a new mock instance.
+func NewMockAttemptRepository(ctrl *gomock.Controller) *MockAttemptRepository {
+	mock := &MockAttemptRepository{ctrl: ctrl}
+	mock.recorder = &MockAttemptRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAttemptRepository) EXPECT() *MockAttemptRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateAttempt mocks base method.
+func (m *MockAttemptRepository) CreateAttempt(attempt models.TransactionAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAttempt", attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAttempt indicates an expected call of CreateAttempt.
+func (mr *MockAttemptRepositoryMockRecorder) CreateAttempt(attempt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAttempt", reflect.TypeOf((*MockAttemptRepository)(nil).CreateAttempt), attempt)
+}
+
+// GetAttempts mocks base method.
+func (m *MockAttemptRepository) GetAttempts(transactionID int) ([]models.TransactionAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttempts", transactionID)
+	ret0, _ := ret[0].([]models.TransactionAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttempts indicates an expected call of GetAttempts.
+func (mr *MockAttemptRepositoryMockRecorder) GetAttempts(transactionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttempts", reflect.TypeOf((*MockAttemptRepository)(nil).GetAttempts), transactionID)
+}
+
+// GetSuccessStats mocks base method.
+func (m *MockAttemptRepository) GetSuccessStats(gatewayIDs []int, since time.Time) (map[int]models.GatewayStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSuccessStats", gatewayIDs, since)
+	ret0, _ := ret[0].(map[int]models.GatewayStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSuccessStats indicates an expected call of GetSuccessStats.
+func (mr *MockAttemptRepositoryMockRecorder) GetSuccessStats(gatewayIDs, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSuccessStats", reflect.TypeOf((*MockAttemptRepository)(nil).GetSuccessStats), gatewayIDs, since)
+}
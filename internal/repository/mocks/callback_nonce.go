@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: callback_nonce.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCallbackNonceRepository is a mock of CallbackNonceRepository interface.
+type MockCallbackNonceRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCallbackNonceRepositoryMockRecorder
+}
+
+// MockCallbackNonceRepositoryMockRecorder is the mock recorder for MockCallbackNonceRepository.
+type MockCallbackNonceRepositoryMockRecorder struct {
+	mock *MockCallbackNonceRepository
+}
+
+// NewMockCallbackNonceRepository creates a new mock instance.
+func NewMockCallbackNonceRepository(ctrl *gomock.Controller) *MockCallbackNonceRepository {
+	mock := &MockCallbackNonceRepository{ctrl: ctrl}
+	mock.recorder = &MockCallbackNonceRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCallbackNonceRepository) EXPECT() *MockCallbackNonceRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ReleaseNonce mocks base method.
+func (m *MockCallbackNonceRepository) ReleaseNonce(gatewayID int, nonce string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseNonce", gatewayID, nonce)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseNonce indicates an expected call of ReleaseNonce.
+func (mr *MockCallbackNonceRepositoryMockRecorder) ReleaseNonce(gatewayID, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseNonce", reflect.TypeOf((*MockCallbackNonceRepository)(nil).ReleaseNonce), gatewayID, nonce)
+}
+
+// UseNonce mocks base method.
+func (m *MockCallbackNonceRepository) UseNonce(gatewayID int, nonce string, receivedAt, expiredBefore time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseNonce", gatewayID, nonce, receivedAt, expiredBefore)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseNonce indicates an expected call of UseNonce.
+func (mr *MockCallbackNonceRepositoryMockRecorder) UseNonce(gatewayID, nonce, receivedAt, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseNonce", reflect.TypeOf((*MockCallbackNonceRepository)(nil).UseNonce), gatewayID, nonce, receivedAt, expiredBefore)
+}
@@ -5,6 +5,9 @@
 package mocks
 
 import (
+	models "payment-gateway/internal/models"
+	reflect "reflect"
+
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -30,3 +33,18 @@ func NewMockCountryRepository(ctrl *gomock.Controller) *MockCountryRepository {
 func (m *MockCountryRepository) EXPECT() *MockCountryRepositoryMockRecorder {
 	return m.recorder
 }
+
+// GetCountryByID mocks base method.
+func (m *MockCountryRepository) GetCountryByID(countryID int) (models.Country, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCountryByID", countryID)
+	ret0, _ := ret[0].(models.Country)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCountryByID indicates an expected call of GetCountryByID.
+func (mr *MockCountryRepositoryMockRecorder) GetCountryByID(countryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountryByID", reflect.TypeOf((*MockCountryRepository)(nil).GetCountryByID), countryID)
+}
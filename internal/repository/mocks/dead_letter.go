@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: dead_letter.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	models "payment-gateway/internal/models"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDeadLetterRepository is a mock of DeadLetterRepository interface.
+type MockDeadLetterRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadLetterRepositoryMockRecorder
+}
+
+// MockDeadLetterRepositoryMockRecorder is the mock recorder for MockDeadLetterRepository.
+type MockDeadLetterRepositoryMockRecorder struct {
+	mock *MockDeadLetterRepository
+}
+
+// NewMockDeadLetterRepository creates a new mock instance.
+func NewMockDeadLetterRepository(ctrl *gomock.Controller) *MockDeadLetterRepository {
+	mock := &MockDeadLetterRepository{ctrl: ctrl}
+	mock.recorder = &MockDeadLetterRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadLetterRepository) EXPECT() *MockDeadLetterRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetDeadLetter mocks base method.
+func (m *MockDeadLetterRepository) GetDeadLetter(id int64) (models.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadLetter", id)
+	ret0, _ := ret[0].(models.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadLetter indicates an expected call of GetDeadLetter.
+func (mr *MockDeadLetterRepositoryMockRecorder) GetDeadLetter(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadLetter", reflect.TypeOf((*MockDeadLetterRepository)(nil).GetDeadLetter), id)
+}
+
+// LaterPublished mocks base method.
+func (m *MockDeadLetterRepository) LaterPublished(outboxID int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LaterPublished", outboxID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LaterPublished indicates an expected call of LaterPublished.
+func (mr *MockDeadLetterRepositoryMockRecorder) LaterPublished(outboxID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LaterPublished", reflect.TypeOf((*MockDeadLetterRepository)(nil).LaterPublished), outboxID)
+}
+
+// ListDeadLetters mocks base method.
+func (m *MockDeadLetterRepository) ListDeadLetters(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", filter)
+	ret0, _ := ret[0].([]models.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *MockDeadLetterRepositoryMockRecorder) ListDeadLetters(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*MockDeadLetterRepository)(nil).ListDeadLetters), filter)
+}
+
+// MarkReplayFailed mocks base method.
+func (m *MockDeadLetterRepository) MarkReplayFailed(id int64, lastErr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkReplayFailed", id, lastErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkReplayFailed indicates an expected call of MarkReplayFailed.
+func (mr *MockDeadLetterRepositoryMockRecorder) MarkReplayFailed(id, lastErr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkReplayFailed", reflect.TypeOf((*MockDeadLetterRepository)(nil).MarkReplayFailed), id, lastErr)
+}
+
+// MarkReplayed mocks base method.
+func (m *MockDeadLetterRepository) MarkReplayed(id int64, replayedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkReplayed", id, replayedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkReplayed indicates an expected call of MarkReplayed.
+func (mr *MockDeadLetterRepositoryMockRecorder) MarkReplayed(id, replayedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkReplayed", reflect.TypeOf((*MockDeadLetterRepository)(nil).MarkReplayed), id, replayedAt)
+}
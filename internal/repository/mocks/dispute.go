@@ -0,0 +1,170 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: dispute.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	ledger "payment-gateway/internal/ledger"
+	models "payment-gateway/internal/models"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDisputeRepository is a mock of DisputeRepository interface.
+type MockDisputeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockDisputeRepositoryMockRecorder
+}
+
+// MockDisputeRepositoryMockRecorder is the mock recorder for MockDisputeRepository.
+type MockDisputeRepositoryMockRecorder struct {
+	mock *MockDisputeRepository
+}
+
+// NewMockDisputeRepository creates a new mock instance.
+func NewMockDisputeRepository(ctrl *gomock.Controller) *MockDisputeRepository {
+	mock := &MockDisputeRepository{ctrl: ctrl}
+	mock.recorder = &MockDisputeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDisputeRepository) EXPECT() *MockDisputeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddEvidence mocks base method.
+func (m *MockDisputeRepository) AddEvidence(evidence models.DisputeEvidence) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddEvidence", evidence)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddEvidence indicates an expected call of AddEvidence.
+func (mr *MockDisputeRepositoryMockRecorder) AddEvidence(evidence interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEvidence", reflect.TypeOf((*MockDisputeRepository)(nil).AddEvidence), evidence)
+}
+
+// CreateDispute mocks base method.
+func (m *MockDisputeRepository) CreateDispute(dispute models.Dispute, newMessage func(models.Dispute) (models.OutboxMessage, error)) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDispute", dispute, newMessage)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDispute indicates an expected call of CreateDispute.
+func (mr *MockDisputeRepositoryMockRecorder) CreateDispute(dispute, newMessage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDispute", reflect.TypeOf((*MockDisputeRepository)(nil).CreateDispute), dispute, newMessage)
+}
+
+// GetDispute mocks base method.
+func (m *MockDisputeRepository) GetDispute(id int) (*models.Dispute, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDispute", id)
+	ret0, _ := ret[0].(*models.Dispute)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDispute indicates an expected call of GetDispute.
+func (mr *MockDisputeRepositoryMockRecorder) GetDispute(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDispute", reflect.TypeOf((*MockDisputeRepository)(nil).GetDispute), id)
+}
+
+// GetDisputeByTransaction mocks base method.
+func (m *MockDisputeRepository) GetDisputeByTransaction(transactionID int) (*models.Dispute, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDisputeByTransaction", transactionID)
+	ret0, _ := ret[0].(*models.Dispute)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDisputeByTransaction indicates an expected call of GetDisputeByTransaction.
+func (mr *MockDisputeRepositoryMockRecorder) GetDisputeByTransaction(transactionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDisputeByTransaction", reflect.TypeOf((*MockDisputeRepository)(nil).GetDisputeByTransaction), transactionID)
+}
+
+// GetDisputeHistory mocks base method.
+func (m *MockDisputeRepository) GetDisputeHistory(disputeID int) ([]models.DisputeTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDisputeHistory", disputeID)
+	ret0, _ := ret[0].([]models.DisputeTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDisputeHistory indicates an expected call of GetDisputeHistory.
+func (mr *MockDisputeRepositoryMockRecorder) GetDisputeHistory(disputeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDisputeHistory", reflect.TypeOf((*MockDisputeRepository)(nil).GetDisputeHistory), disputeID)
+}
+
+// GetEvidence mocks base method.
+func (m *MockDisputeRepository) GetEvidence(disputeID, evidenceID int) (*models.DisputeEvidence, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEvidence", disputeID, evidenceID)
+	ret0, _ := ret[0].(*models.DisputeEvidence)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEvidence indicates an expected call of GetEvidence.
+func (mr *MockDisputeRepositoryMockRecorder) GetEvidence(disputeID, evidenceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEvidence", reflect.TypeOf((*MockDisputeRepository)(nil).GetEvidence), disputeID, evidenceID)
+}
+
+// ListDisputes mocks base method.
+func (m *MockDisputeRepository) ListDisputes(filter models.DisputeFilter) ([]models.Dispute, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDisputes", filter)
+	ret0, _ := ret[0].([]models.Dispute)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDisputes indicates an expected call of ListDisputes.
+func (mr *MockDisputeRepositoryMockRecorder) ListDisputes(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDisputes", reflect.TypeOf((*MockDisputeRepository)(nil).ListDisputes), filter)
+}
+
+// ListEvidence mocks base method.
+func (m *MockDisputeRepository) ListEvidence(disputeID int) ([]models.DisputeEvidence, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEvidence", disputeID)
+	ret0, _ := ret[0].([]models.DisputeEvidence)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEvidence indicates an expected call of ListEvidence.
+func (mr *MockDisputeRepositoryMockRecorder) ListEvidence(disputeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEvidence", reflect.TypeOf((*MockDisputeRepository)(nil).ListEvidence), disputeID)
+}
+
+// TransitionDispute mocks base method.
+func (m *MockDisputeRepository) TransitionDispute(transition models.DisputeTransition, message models.OutboxMessage, posting ledger.Posting) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransitionDispute", transition, message, posting)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransitionDispute indicates an expected call of TransitionDispute.
+func (mr *MockDisputeRepositoryMockRecorder) TransitionDispute(transition, message, posting interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransitionDispute", reflect.TypeOf((*MockDisputeRepository)(nil).TransitionDispute), transition, message, posting)
+}
@@ -63,6 +63,36 @@ func (mr *MockGatewayRepositoryMockRecorder) GetAvailableGateways(countryID inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAvailableGateways", reflect.TypeOf((*MockGatewayRepository)(nil).GetAvailableGateways), countryID)
 }
 
+// GetGatewayByID mocks base method.
+func (m *MockGatewayRepository) GetGatewayByID(id int) (models.Gateway, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGatewayByID", id)
+	ret0, _ := ret[0].(models.Gateway)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGatewayByID indicates an expected call of GetGatewayByID.
+func (mr *MockGatewayRepositoryMockRecorder) GetGatewayByID(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGatewayByID", reflect.TypeOf((*MockGatewayRepository)(nil).GetGatewayByID), id)
+}
+
+// GetGatewayByName mocks base method.
+func (m *MockGatewayRepository) GetGatewayByName(name string) (models.Gateway, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGatewayByName", name)
+	ret0, _ := ret[0].(models.Gateway)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGatewayByName indicates an expected call of GetGatewayByName.
+func (mr *MockGatewayRepositoryMockRecorder) GetGatewayByName(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGatewayByName", reflect.TypeOf((*MockGatewayRepository)(nil).GetGatewayByName), name)
+}
+
 // GetGateways mocks base method.
 func (m *MockGatewayRepository) GetGateways() ([]models.Gateway, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: routing.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	models "payment-gateway/internal/models"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRoutingRepository is a mock of RoutingRepository interface.
+type MockRoutingRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoutingRepositoryMockRecorder
+}
+
+// MockRoutingRepositoryMockRecorder is the mock recorder for MockRoutingRepository.
+type MockRoutingRepositoryMockRecorder struct {
+	mock *MockRoutingRepository
+}
+
+// NewMockRoutingRepository creates a new mock instance.
+func NewMockRoutingRepository(ctrl *gomock.Controller) *MockRoutingRepository {
+	mock := &MockRoutingRepository{ctrl: ctrl}
+	mock.recorder = &MockRoutingRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRoutingRepository) EXPECT() *MockRoutingRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetRoutingConfig mocks base method.
+func (m *MockRoutingRepository) GetRoutingConfig(countryID int) (models.RoutingConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoutingConfig", countryID)
+	ret0, _ := ret[0].(models.RoutingConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoutingConfig indicates an expected call of GetRoutingConfig.
+func (mr *MockRoutingRepositoryMockRecorder) GetRoutingConfig(countryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoutingConfig", reflect.TypeOf((*MockRoutingRepository)(nil).GetRoutingConfig), countryID)
+}
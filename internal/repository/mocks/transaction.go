@@ -5,8 +5,11 @@
 package mocks
 
 import (
+	ledger "payment-gateway/internal/ledger"
 	models "payment-gateway/internal/models"
+	money "payment-gateway/internal/money"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,19 +37,109 @@ func (m *MockTransactionRepository) EXPECT() *MockTransactionRepositoryMockRecor
 	return m.recorder
 }
 
+// CreateCapture mocks base method.
+func (m *MockTransactionRepository) CreateCapture(capture models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCapture", capture, newMessage)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCapture indicates an expected call of CreateCapture.
+func (mr *MockTransactionRepositoryMockRecorder) CreateCapture(capture, newMessage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCapture", reflect.TypeOf((*MockTransactionRepository)(nil).CreateCapture), capture, newMessage)
+}
+
+// CreateRefund mocks base method.
+func (m *MockTransactionRepository) CreateRefund(refund models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefund", refund, newMessage)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRefund indicates an expected call of CreateRefund.
+func (mr *MockTransactionRepositoryMockRecorder) CreateRefund(refund, newMessage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefund", reflect.TypeOf((*MockTransactionRepository)(nil).CreateRefund), refund, newMessage)
+}
+
 // CreateTransaction mocks base method.
-func (m *MockTransactionRepository) CreateTransaction(transaction models.Transaction) (int, error) {
+func (m *MockTransactionRepository) CreateTransaction(transaction models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTransaction", transaction)
+	ret := m.ctrl.Call(m, "CreateTransaction", transaction, newMessage)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateTransaction indicates an expected call of CreateTransaction.
-func (mr *MockTransactionRepositoryMockRecorder) CreateTransaction(transaction interface{}) *gomock.Call {
+func (mr *MockTransactionRepositoryMockRecorder) CreateTransaction(transaction, newMessage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransaction", reflect.TypeOf((*MockTransactionRepository)(nil).CreateTransaction), transaction, newMessage)
+}
+
+// GetExpiredAuthorizations mocks base method.
+func (m *MockTransactionRepository) GetExpiredAuthorizations(now time.Time, limit int) ([]models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiredAuthorizations", now, limit)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiredAuthorizations indicates an expected call of GetExpiredAuthorizations.
+func (mr *MockTransactionRepositoryMockRecorder) GetExpiredAuthorizations(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiredAuthorizations", reflect.TypeOf((*MockTransactionRepository)(nil).GetExpiredAuthorizations), now, limit)
+}
+
+// GetPendingAmounts mocks base method.
+func (m *MockTransactionRepository) GetPendingAmounts(userID int, transactionType string) ([]money.Money, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingAmounts", userID, transactionType)
+	ret0, _ := ret[0].([]money.Money)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingAmounts indicates an expected call of GetPendingAmounts.
+func (mr *MockTransactionRepositoryMockRecorder) GetPendingAmounts(userID, transactionType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingAmounts", reflect.TypeOf((*MockTransactionRepository)(nil).GetPendingAmounts), userID, transactionType)
+}
+
+// GetStalePendingTransactions mocks base method.
+func (m *MockTransactionRepository) GetStalePendingTransactions(now time.Time, pollAfter time.Duration, limit int) ([]models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStalePendingTransactions", now, pollAfter, limit)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStalePendingTransactions indicates an expected call of GetStalePendingTransactions.
+func (mr *MockTransactionRepositoryMockRecorder) GetStalePendingTransactions(now, pollAfter, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStalePendingTransactions", reflect.TypeOf((*MockTransactionRepository)(nil).GetStalePendingTransactions), now, pollAfter, limit)
+}
+
+// GetStatusHistory mocks base method.
+func (m *MockTransactionRepository) GetStatusHistory(transactionID int) ([]models.StatusTransition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusHistory", transactionID)
+	ret0, _ := ret[0].([]models.StatusTransition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusHistory indicates an expected call of GetStatusHistory.
+func (mr *MockTransactionRepositoryMockRecorder) GetStatusHistory(transactionID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransaction", reflect.TypeOf((*MockTransactionRepository)(nil).CreateTransaction), transaction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusHistory", reflect.TypeOf((*MockTransactionRepository)(nil).GetStatusHistory), transactionID)
 }
 
 // GetTransaction mocks base method.
@@ -64,31 +157,142 @@ func (mr *MockTransactionRepositoryMockRecorder) GetTransaction(transactionID in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransaction", reflect.TypeOf((*MockTransactionRepository)(nil).GetTransaction), transactionID)
 }
 
-// GetTransactions mocks base method.
-func (m *MockTransactionRepository) GetTransactions() ([]models.Transaction, error) {
+// MarkPolled mocks base method.
+func (m *MockTransactionRepository) MarkPolled(transactionID int, polledAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkPolled", transactionID, polledAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkPolled indicates an expected call of MarkPolled.
+func (mr *MockTransactionRepositoryMockRecorder) MarkPolled(transactionID, polledAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkPolled", reflect.TypeOf((*MockTransactionRepository)(nil).MarkPolled), transactionID, polledAt)
+}
+
+// SearchTransactions mocks base method.
+func (m *MockTransactionRepository) SearchTransactions(filter models.TransactionFilter, after *models.TransactionCursor) ([]models.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransactions")
+	ret := m.ctrl.Call(m, "SearchTransactions", filter, after)
 	ret0, _ := ret[0].([]models.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransactions indicates an expected call of GetTransactions.
-func (mr *MockTransactionRepositoryMockRecorder) GetTransactions() *gomock.Call {
+// SearchTransactions indicates an expected call of SearchTransactions.
+func (mr *MockTransactionRepositoryMockRecorder) SearchTransactions(filter, after interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchTransactions", reflect.TypeOf((*MockTransactionRepository)(nil).SearchTransactions), filter, after)
+}
+
+// TransitionStatus mocks base method.
+func (m *MockTransactionRepository) TransitionStatus(transition models.StatusTransition, message models.OutboxMessage, posting ledger.Posting) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransitionStatus", transition, message, posting)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransitionStatus indicates an expected call of TransitionStatus.
+func (mr *MockTransactionRepositoryMockRecorder) TransitionStatus(transition, message, posting interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransitionStatus", reflect.TypeOf((*MockTransactionRepository)(nil).TransitionStatus), transition, message, posting)
+}
+
+// UpdateConversion mocks base method.
+func (m *MockTransactionRepository) UpdateConversion(transactionID int, conversion models.FXConversion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConversion", transactionID, conversion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateConversion indicates an expected call of UpdateConversion.
+func (mr *MockTransactionRepositoryMockRecorder) UpdateConversion(transactionID, conversion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConversion", reflect.TypeOf((*MockTransactionRepository)(nil).UpdateConversion), transactionID, conversion)
+}
+
+// UpdateGateway mocks base method.
+func (m *MockTransactionRepository) UpdateGateway(transactionID, gatewayID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGateway", transactionID, gatewayID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateGateway indicates an expected call of UpdateGateway.
+func (mr *MockTransactionRepositoryMockRecorder) UpdateGateway(transactionID, gatewayID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactions", reflect.TypeOf((*MockTransactionRepository)(nil).GetTransactions))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGateway", reflect.TypeOf((*MockTransactionRepository)(nil).UpdateGateway), transactionID, gatewayID)
+}
+
+// UpdateGatewayReference mocks base method.
+func (m *MockTransactionRepository) UpdateGatewayReference(transactionID int, reference string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGatewayReference", transactionID, reference)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateGatewayReference indicates an expected call of UpdateGatewayReference.
+func (mr *MockTransactionRepositoryMockRecorder) UpdateGatewayReference(transactionID, reference interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGatewayReference", reflect.TypeOf((*MockTransactionRepository)(nil).UpdateGatewayReference), transactionID, reference)
+}
+
+// VoidAuthorization mocks base method.
+func (m *MockTransactionRepository) VoidAuthorization(transition models.StatusTransition, message models.OutboxMessage, posting ledger.Posting, void func() error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidAuthorization", transition, message, posting, void)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VoidAuthorization indicates an expected call of VoidAuthorization.
+func (mr *MockTransactionRepositoryMockRecorder) VoidAuthorization(transition, message, posting, void interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidAuthorization", reflect.TypeOf((*MockTransactionRepository)(nil).VoidAuthorization), transition, message, posting, void)
+}
+
+// MockrowScanner is a mock of rowScanner interface.
+type MockrowScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockrowScannerMockRecorder
+}
+
+// MockrowScannerMockRecorder is the mock recorder for MockrowScanner.
+type MockrowScannerMockRecorder struct {
+	mock *MockrowScanner
+}
+
+// NewMockrowScanner creates a new mock instance.
+func NewMockrowScanner(ctrl *gomock.Controller) *MockrowScanner {
+	mock := &MockrowScanner{ctrl: ctrl}
+	mock.recorder = &MockrowScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrowScanner) EXPECT() *MockrowScannerMockRecorder {
+	return m.recorder
 }
 
-// UpdateStatus mocks base method.
-func (m *MockTransactionRepository) UpdateStatus(transactionID int, status string) error {
+// Scan mocks base method.
+func (m *MockrowScanner) Scan(dest ...interface{}) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateStatus", transactionID, status)
+	varargs := []interface{}{}
+	for _, a := range dest {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Scan", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateStatus indicates an expected call of UpdateStatus.
-func (mr *MockTransactionRepositoryMockRecorder) UpdateStatus(transactionID, status interface{}) *gomock.Call {
+// Scan indicates an expected call of Scan.
+func (mr *MockrowScannerMockRecorder) Scan(dest ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockTransactionRepository)(nil).UpdateStatus), transactionID, status)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockrowScanner)(nil).Scan), dest...)
 }
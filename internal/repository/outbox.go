@@ -0,0 +1,127 @@
+//go:generate mockgen -source outbox.go -destination mocks/outbox.go -package mocks
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"payment-gateway/internal/models"
+)
+
+type OutboxRepository interface {
+	// ClaimPending leases up to limit due messages until leaseUntil, only the oldest pending message
+	// of every transaction is returned so the transaction events are published in order
+	ClaimPending(now, leaseUntil time.Time, limit int) ([]models.OutboxMessage, error)
+	MarkPublished(id int64) error
+	// MarkRetry records the failed attempt, the message is claimed again after nextAttemptAt
+	MarkRetry(id int64, nextAttemptAt time.Time, lastErr string) error
+	// MarkDead stops publishing the message and moves it to the dead letters,
+	// later messages of the transaction are released and the dead letter can't be replayed once they are published
+	MarkDead(id int64, deadLetter models.DeadLetter) error
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+// execer is either *sql.DB or *sql.Tx, outbox messages are inserted in the transaction of the change they describe
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertOutboxMessage(db execer, message models.OutboxMessage) error {
+	query := `INSERT INTO outbox (transaction_id, content_type, payload, status, next_attempt_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $5)`
+	_, err := db.Exec(query, message.TransactionID, message.ContentType, message.Payload, models.OutboxStatusPending, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox message: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) ClaimPending(now, leaseUntil time.Time, limit int) ([]models.OutboxMessage, error) {
+	// SKIP LOCKED lets several relays run, the lease hides claimed rows until they are marked
+	// or the relay holding them dies
+	query := `
+		UPDATE outbox SET next_attempt_at = $3
+		WHERE id IN (
+			SELECT o.id FROM outbox o
+			WHERE o.status = $4 AND o.next_attempt_at <= $1
+			  AND NOT EXISTS (
+			      SELECT 1 FROM outbox prev
+			      WHERE prev.transaction_id = o.transaction_id AND prev.status = $4 AND prev.id < o.id
+			  )
+			ORDER BY o.id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, transaction_id, content_type, payload, status, attempts, created_at
+	`
+	rows, err := r.db.Query(query, now, limit, leaseUntil, models.OutboxStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var message models.OutboxMessage
+		if err := rows.Scan(&message.ID, &message.TransactionID, &message.ContentType, &message.Payload, &message.Status,
+			&message.Attempts, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %v", err)
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkPublished(id int64) error {
+	query := `UPDATE outbox SET status = $1, attempts = attempts + 1, last_error = NULL, published_at = $2 WHERE id = $3`
+	if _, err := r.db.Exec(query, models.OutboxStatusPublished, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to mark outbox message published: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkRetry(id int64, nextAttemptAt time.Time, lastErr string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3`
+	if _, err := r.db.Exec(query, lastErr, nextAttemptAt, id); err != nil {
+		return fmt.Errorf("failed to reschedule outbox message: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkDead(id int64, deadLetter models.DeadLetter) error {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin outbox dead letter: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	query := `UPDATE outbox SET status = $1, attempts = attempts + 1, last_error = $2 WHERE id = $3`
+	if _, err := dbTx.Exec(query, models.OutboxStatusDead, deadLetter.Error, id); err != nil {
+		return fmt.Errorf("failed to mark outbox message dead: %v", err)
+	}
+
+	if err := insertDeadLetter(dbTx, deadLetter); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outbox dead letter: %v", err)
+	}
+	return nil
+}
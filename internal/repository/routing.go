@@ -0,0 +1,52 @@
+//go:generate mockgen -source routing.go -destination mocks/routing.go -package mocks
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"payment-gateway/internal/models"
+)
+
+const defaultSuccessRateWindow = time.Hour
+
+type RoutingRepository interface {
+	GetRoutingConfig(countryID int) (models.RoutingConfig, error)
+}
+
+type routingRepository struct {
+	db *sql.DB
+}
+
+func NewRoutingRepository(db *sql.DB) RoutingRepository {
+	return &routingRepository{
+		db: db,
+	}
+}
+
+// GetRoutingConfig returns routing configured for the country, priority routing is used by default
+func (r *routingRepository) GetRoutingConfig(countryID int) (models.RoutingConfig, error) {
+	config := models.RoutingConfig{
+		CountryID:         countryID,
+		Strategy:          models.RoutingStrategyPriority,
+		SuccessRateWindow: defaultSuccessRateWindow,
+	}
+
+	query := `SELECT strategy, success_rate_window_minutes FROM country_routing WHERE country_id = $1`
+
+	var windowMinutes int
+	err := r.db.QueryRow(query, countryID).Scan(&config.Strategy, &windowMinutes)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return config, nil
+	case err != nil:
+		return config, fmt.Errorf("failed to fetch routing config: %v", err)
+	}
+
+	if windowMinutes > 0 {
+		config.SuccessRateWindow = time.Duration(windowMinutes) * time.Minute
+	}
+	return config, nil
+}
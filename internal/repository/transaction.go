@@ -5,15 +5,80 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"payment-gateway/internal/ledger"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrTransactionNotFound returned by GetTransaction for unknown transaction ids
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrStatusConflict the transaction status has been changed concurrently
+	ErrStatusConflict = errors.New("transaction status has been changed concurrently")
+	// ErrRefundExceedsAmount the refunds of the deposit would return more than its amount
+	ErrRefundExceedsAmount = errors.New("refunds exceed the deposit amount")
+	// ErrCaptureExceedsAmount the capture is greater than the authorized amount
+	ErrCaptureExceedsAmount = errors.New("capture exceeds the authorized amount")
+	// ErrAlreadyCaptured the authorization has a capture which has not failed
+	ErrAlreadyCaptured = errors.New("authorization is already captured")
+	// ErrVoidInProgress the authorization is being voided on the gateway
+	ErrVoidInProgress = errors.New("authorization void is in progress")
+)
+
+// voidLease how long a void keeps the authorization from being captured or expired, longer than the gateway
+// timeout so that it only ends early when the service dies during the void
+const voidLease = time.Minute
+
 type TransactionRepository interface {
-	CreateTransaction(transaction models.Transaction) (int, error)
-	GetTransactions() ([]models.Transaction, error)
-	UpdateStatus(transactionID int, status string) error
+	CreateTransaction(transaction models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error)
+	// CreateRefund inserts the refund of refund.ParentID like CreateTransaction, ErrStatusConflict is returned when
+	// the parent isn't a done deposit anymore and ErrRefundExceedsAmount when the refunds of the deposit which have
+	// not failed would exceed its amount with this one
+	CreateRefund(refund models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error)
+	// CreateCapture inserts the capture of the authorized capture.ParentID like CreateTransaction, an authorization
+	// is captured once: ErrAlreadyCaptured is returned when it has a capture which has not failed, ErrVoidInProgress
+	// while it is being voided and ErrCaptureExceedsAmount when the capture is greater than the authorized amount
+	CreateCapture(capture models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error)
+	// VoidAuthorization leases the authorization and calls void without holding a lock, captures and the expiry of it
+	// are rejected until the void has ended. ErrAlreadyCaptured is returned when it has a capture which has not failed,
+	// ErrVoidInProgress when another void holds the lease and ErrStatusConflict when it isn't transition.From anymore,
+	// the transition is applied like TransitionStatus once void succeeds
+	VoidAuthorization(transition models.StatusTransition, message models.OutboxMessage, posting ledger.Posting, void func() error) error
+	// GetExpiredAuthorizations returns up to limit authorized authorizations without a capture in progress
+	// which have expired at now, the oldest first
+	GetExpiredAuthorizations(now time.Time, limit int) ([]models.Transaction, error)
+	// GetStalePendingTransactions returns up to limit pending transactions, and created or submitted ones left behind
+	// by an interrupted request, created or last polled longer ago than the pending_poll_after of their gateway,
+	// pollAfter when the gateway has none, the longest waiting first
+	GetStalePendingTransactions(now time.Time, pollAfter time.Duration, limit int) ([]models.Transaction, error)
+	// MarkPolled records that the gateway has been asked for the status of the transaction
+	MarkPolled(transactionID int, polledAt time.Time) error
+	// SearchTransactions returns up to filter.Limit transactions matching the filter, after the cursor when it is set
+	SearchTransactions(filter models.TransactionFilter, after *models.TransactionCursor) ([]models.Transaction, error)
+	GetStatusHistory(transactionID int) ([]models.StatusTransition, error)
+	// TransitionStatus moves the transaction from transition.From to transition.To, records it in the history,
+	// writes the event message to the outbox and applies the ledger posting in one SQL transaction.
+	// ErrStatusConflict is returned when the transaction is not in transition.From status anymore
+	TransitionStatus(transition models.StatusTransition, message models.OutboxMessage, posting ledger.Posting) error
 	GetTransaction(transactionID int) (*models.Transaction, error)
+	UpdateGatewayReference(transactionID int, reference string) error
+	UpdateGateway(transactionID int, gatewayID int) error
+	UpdateConversion(transactionID int, conversion models.FXConversion) error
+	// GetPendingAmounts sums the amounts of the user transactions of the type that are not final yet, per currency
+	GetPendingAmounts(userID int, transactionType string) ([]money.Money, error)
+}
+
+// transactionColumns read by scanTransaction
+const transactionColumns = `id, currency, amount, type, status, user_id, gateway_id, country_id, created_at,
+	COALESCE(gateway_reference, ''), COALESCE(settlement_currency, ''), settlement_amount, fx_rate, COALESCE(fx_source, ''), fx_rate_at,
+	COALESCE(parent_id, 0), (SELECT COALESCE(SUM(r.amount), 0) FROM transactions r WHERE r.parent_id = transactions.id AND r.type = 'refund' AND r.status = 'done'),
+	expires_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
 type transactionRepository struct {
@@ -26,28 +91,311 @@ func NewTransactionRepository(db *sql.DB) TransactionRepository {
 	}
 }
 
-func (r *transactionRepository) CreateTransaction(transaction models.Transaction) (int, error) {
-	query := `INSERT INTO transactions (amount, type, status, gateway_id, country_id, user_id, created_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+// CreateTransaction inserts the transaction and the outbox message built by newMessage in one SQL transaction,
+// newMessage gets the transaction with its id set
+func (r *transactionRepository) CreateTransaction(transaction models.Transaction,
+	newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction insert: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	id, err := insertTransaction(dbTx, transaction, newMessage)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction insert: %v", err)
+	}
+	return id, nil
+}
+
+// CreateRefund locks the deposit so that concurrent refunds are checked one after another
+func (r *transactionRepository) CreateRefund(refund models.Transaction,
+	newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin refund insert: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	deposit, err := lockParent(dbTx, refund.ParentID)
+	if err != nil {
+		return 0, err
+	}
+
+	// a dispute or a concurrent refund may have moved the deposit since it has been read
+	if deposit.txType != models.TransactionTypeDeposit || deposit.status != models.TransactionStatusDone {
+		return 0, fmt.Errorf("%w: transaction %d is a %s %s", ErrStatusConflict, refund.ParentID, deposit.status, deposit.txType)
+	}
+
+	total, err := deposit.children.Add(refund.Amount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum refunds: %v", err)
+	}
+	if cmp, _ := total.Cmp(deposit.amount); cmp > 0 {
+		return 0, fmt.Errorf("%w: %s of %s refunded, %s requested", ErrRefundExceedsAmount, deposit.children, deposit.amount, refund.Amount)
+	}
+
+	id, err := insertTransaction(dbTx, refund, newMessage)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit refund insert: %v", err)
+	}
+	return id, nil
+}
+
+// CreateCapture locks the authorization so that only one capture of it can be in progress or done
+func (r *transactionRepository) CreateCapture(capture models.Transaction,
+	newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin capture insert: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	authorization, err := lockParent(dbTx, capture.ParentID)
+	if err != nil {
+		return 0, err
+	}
+
+	if authorization.status != models.TransactionStatusAuthorized {
+		return 0, fmt.Errorf("%w: transaction %d is %s", ErrStatusConflict, capture.ParentID, authorization.status)
+	}
+	if authorization.count > 0 {
+		return 0, fmt.Errorf("%w: transaction %d", ErrAlreadyCaptured, capture.ParentID)
+	}
+	if authorization.voiding {
+		return 0, fmt.Errorf("%w: transaction %d", ErrVoidInProgress, capture.ParentID)
+	}
+	if cmp, _ := capture.Amount.Cmp(authorization.amount); cmp > 0 {
+		return 0, fmt.Errorf("%w: %s authorized, %s requested", ErrCaptureExceedsAmount, authorization.amount, capture.Amount)
+	}
+
+	id, err := insertTransaction(dbTx, capture, newMessage)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit capture insert: %v", err)
+	}
+	return id, nil
+}
+
+// VoidAuthorization leases the authorization in one SQL transaction and applies the transition in another,
+// the gateway call between them holds no lock
+func (r *transactionRepository) VoidAuthorization(transition models.StatusTransition, message models.OutboxMessage,
+	posting ledger.Posting, void func() error) error {
+	if err := r.leaseVoid(transition); err != nil {
+		return err
+	}
+
+	if err := void(); err != nil {
+		// the gateway has declined or not answered, the authorization can be captured or voided again
+		if _, releaseErr := r.db.Exec(`UPDATE transactions SET voiding_until = NULL WHERE id = $1`, transition.TransactionID); releaseErr != nil {
+			log.Printf("failed to release void lease: %v", releaseErr)
+		}
+		return err
+	}
+
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin void: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	// the lease kept captures and the expiry away, the compare-and-set still catches any other change
+	if err := transitionStatus(dbTx, transition, message, posting); err != nil {
+		return err
+	}
+	if _, err := dbTx.Exec(`UPDATE transactions SET voiding_until = NULL WHERE id = $1`, transition.TransactionID); err != nil {
+		return fmt.Errorf("failed to release void lease: %v", err)
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit void: %v", err)
+	}
+	return nil
+}
+
+// leaseVoid checks the authorization like CreateCapture and leases it for voidLease
+func (r *transactionRepository) leaseVoid(transition models.StatusTransition) error {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin void lease: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	authorization, err := lockParent(dbTx, transition.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	if authorization.status != transition.From {
+		return fmt.Errorf("%w: transaction %d is %s", ErrStatusConflict, transition.TransactionID, authorization.status)
+	}
+	if authorization.count > 0 {
+		return fmt.Errorf("%w: transaction %d", ErrAlreadyCaptured, transition.TransactionID)
+	}
+	if authorization.voiding {
+		return fmt.Errorf("%w: transaction %d", ErrVoidInProgress, transition.TransactionID)
+	}
+
+	_, err = dbTx.Exec(`UPDATE transactions SET voiding_until = $1 WHERE id = $2`, time.Now().Add(voidLease), transition.TransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to lease authorization void: %v", err)
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit void lease: %v", err)
+	}
+	return nil
+}
+
+// lockedParent deposit or authorization locked by lockParent with the sum and count of its refunds or captures
+// which have not failed or expired
+type lockedParent struct {
+	amount   money.Money
+	txType   string
+	status   string
+	children money.Money
+	count    int
+	// voiding a void of the authorization holds an unexpired lease
+	voiding bool
+}
+
+// lockParent locks the transaction row until dbTx ends, refunds and captures of it are checked one after another
+func lockParent(dbTx *sql.Tx, parentID int) (lockedParent, error) {
+	var parent lockedParent
+	err := dbTx.QueryRow(`SELECT currency, amount, type, status, COALESCE(voiding_until > $2, FALSE) FROM transactions WHERE id = $1 FOR UPDATE`,
+		parentID, time.Now()).Scan(parent.amount.CurrencyScanner(), &parent.amount, &parent.txType, &parent.status, &parent.voiding)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return parent, fmt.Errorf("%w with ID: %d", ErrTransactionNotFound, parentID)
+	case err != nil:
+		return parent, fmt.Errorf("failed to lock parent transaction: %v", err)
+	}
+
+	parent.children = money.New(0, parent.amount.Currency())
+	err = dbTx.QueryRow(`SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM transactions WHERE parent_id = $1 AND status NOT IN ($2, $3)`,
+		parentID, models.TransactionStatusFailed, models.TransactionStatusExpired).Scan(&parent.children, &parent.count)
+	if err != nil {
+		return parent, fmt.Errorf("failed to sum child transactions: %v", err)
+	}
+	return parent, nil
+}
+
+func insertTransaction(dbTx *sql.Tx, transaction models.Transaction,
+	newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+	query := `INSERT INTO transactions (amount, currency, type, status, gateway_id, country_id, user_id, parent_id, expires_at, created_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10) RETURNING id`
 
-	err := r.db.QueryRow(query, transaction.Amount, transaction.Type, transaction.Status, transaction.GatewayID, transaction.CountryID, transaction.UserID, time.Now()).Scan(&transaction.ID)
+	expiresAt := sql.NullTime{Time: transaction.ExpiresAt, Valid: !transaction.ExpiresAt.IsZero()}
+	err := dbTx.QueryRow(query, transaction.Amount, transaction.Amount.Currency(), transaction.Type, transaction.Status,
+		transaction.GatewayID, transaction.CountryID, transaction.UserID, transaction.ParentID, expiresAt, time.Now()).Scan(&transaction.ID)
 	if err != nil {
-		return transaction.ID, fmt.Errorf("failed to insert transaction: %v", err)
+		return 0, fmt.Errorf("failed to insert transaction: %v", err)
+	}
+
+	message, err := newMessage(transaction)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build outbox message: %v", err)
+	}
+	if err := insertOutboxMessage(dbTx, message); err != nil {
+		return 0, err
 	}
 	return transaction.ID, nil
 }
 
-func (r *transactionRepository) GetTransactions() ([]models.Transaction, error) {
-	rows, err := r.db.Query(`SELECT id, amount, type, status, user_id, gateway_id, country_id, created_at FROM transactions`)
+// SearchTransactions builds the WHERE clause from the set filter fields, the page is ordered by the sort column and id
+// so that after can continue it with a keyset condition
+func (r *transactionRepository) SearchTransactions(filter models.TransactionFilter, after *models.TransactionCursor) ([]models.Transaction, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if filter.UserID != 0 {
+		where("user_id = $%d", filter.UserID)
+	}
+	if filter.GatewayID != 0 {
+		where("gateway_id = $%d", filter.GatewayID)
+	}
+	if filter.CountryID != 0 {
+		where("country_id = $%d", filter.CountryID)
+	}
+	if filter.ParentID != 0 {
+		where("parent_id = $%d", filter.ParentID)
+	}
+	if filter.Type != "" {
+		where("type = $%d", filter.Type)
+	}
+	if filter.Status != "" {
+		where("status = $%d", filter.Status)
+	}
+	if filter.Currency != "" {
+		where("currency = $%d", money.NormalizeCurrency(filter.Currency))
+	}
+	if filter.MinAmount != nil {
+		where("amount >= $%d", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		where("amount <= $%d", *filter.MaxAmount)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where("created_at >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		where("created_at < $%d", filter.CreatedTo)
+	}
+
+	column, direction, comparison := "created_at", "ASC", ">"
+	if filter.Sort == models.TransactionSortAmount {
+		column = "amount"
+	}
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if after != nil {
+		var sortValue interface{} = after.CreatedAt
+		if column == "amount" {
+			sortValue = after.Amount
+		}
+		where("("+column+", id) "+comparison+" ($%d, $%d)", sortValue, after.ID)
+	}
+
+	query := `SELECT ` + transactionColumns + ` FROM transactions`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d", column, direction, direction, len(args))
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch transactions: %v", err)
 	}
 	defer rows.Close()
 
-	var transactions []models.Transaction
+	transactions := []models.Transaction{}
 	for rows.Next() {
-		var transaction models.Transaction
-		if err := rows.Scan(&transaction.ID, &transaction.Amount, &transaction.Type, &transaction.Status, &transaction.UserID, &transaction.GatewayID, &transaction.CountryID, &transaction.CreatedAt); err != nil {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %v", err)
 		}
 		transactions = append(transactions, transaction)
@@ -58,23 +406,233 @@ func (r *transactionRepository) GetTransactions() ([]models.Transaction, error)
 	return transactions, nil
 }
 
-func (r *transactionRepository) UpdateStatus(transactionID int, status string) error {
-	query := `UPDATE transactions SET status = $1 WHERE id = $2`
-	_, err := r.db.Exec(query, status, transactionID)
-	return err
+// GetStatusHistory returns the status changes of the transaction in the order they were applied
+func (r *transactionRepository) GetStatusHistory(transactionID int) ([]models.StatusTransition, error) {
+	rows, err := r.db.Query(`
+		SELECT id, transaction_id, from_status, to_status, source, actor, reason, created_at
+		FROM transaction_status_history
+		WHERE transaction_id = $1
+		ORDER BY id ASC`, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch status history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []models.StatusTransition{}
+	for rows.Next() {
+		var transition models.StatusTransition
+		if err := rows.Scan(&transition.ID, &transition.TransactionID, &transition.From, &transition.To, &transition.Source,
+			&transition.Actor, &transition.Reason, &transition.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %v", err)
+		}
+		history = append(history, transition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *transactionRepository) TransitionStatus(transition models.StatusTransition, message models.OutboxMessage, posting ledger.Posting) error {
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin status transition: %v", err)
+	}
+	defer dbTx.Rollback()
+
+	if err := transitionStatus(dbTx, transition, message, posting); err != nil {
+		return err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit status transition: %v", err)
+	}
+	return nil
+}
+
+// transitionStatus applies the transition of TransitionStatus within dbTx
+func transitionStatus(dbTx *sql.Tx, transition models.StatusTransition, message models.OutboxMessage, posting ledger.Posting) error {
+	// compare-and-set, the status may have been changed by a concurrent callback
+	result, err := dbTx.Exec(`UPDATE transactions SET status = $1 WHERE id = $2 AND status = $3`,
+		transition.To, transition.TransactionID, transition.From)
+	if err != nil {
+		return fmt.Errorf("failed to update transaction status: %v", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update transaction status: %v", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("%w: transaction %d is not %s", ErrStatusConflict, transition.TransactionID, transition.From)
+	}
+
+	query := `INSERT INTO transaction_status_history (transaction_id, from_status, to_status, source, actor, reason, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = dbTx.Exec(query, transition.TransactionID, transition.From, transition.To, transition.Source, transition.Actor,
+		transition.Reason, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to insert status history: %v", err)
+	}
+
+	if err := insertOutboxMessage(dbTx, message); err != nil {
+		return err
+	}
+
+	return ledger.Apply(dbTx, posting)
+}
+
+// UpdateGatewayReference stores transaction id returned by the gateway
+func (r *transactionRepository) UpdateGatewayReference(transactionID int, reference string) error {
+	query := `UPDATE transactions SET gateway_reference = $1 WHERE id = $2`
+	_, err := r.db.Exec(query, reference, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to update gateway reference: %v", err)
+	}
+	return nil
+}
+
+// UpdateGateway moves transaction to the gateway which has processed it after failover
+func (r *transactionRepository) UpdateGateway(transactionID int, gatewayID int) error {
+	query := `UPDATE transactions SET gateway_id = $1 WHERE id = $2`
+	_, err := r.db.Exec(query, gatewayID, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to update transaction gateway: %v", err)
+	}
+	return nil
+}
+
+// UpdateConversion stores the settlement amount and the exchange rate snapshot used for the gateway
+func (r *transactionRepository) UpdateConversion(transactionID int, conversion models.FXConversion) error {
+	query := `UPDATE transactions
+			  SET settlement_currency = $1, settlement_amount = $2, fx_rate = $3, fx_source = $4, fx_rate_at = $5
+			  WHERE id = $6`
+	_, err := r.db.Exec(query, conversion.Amount.Currency(), conversion.Amount, conversion.Rate, conversion.Source,
+		conversion.RateAt, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to update transaction conversion: %v", err)
+	}
+	return nil
 }
 
 func (r *transactionRepository) GetTransaction(transactionID int) (*models.Transaction, error) {
-	query := `
-        SELECT id, amount, type, status, user_id, gateway_id, country_id, created_at 
-        FROM transactions 
-        WHERE id = $1
-    `
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1`
+
+	transaction, err := scanTransaction(r.db.QueryRow(query, transactionID))
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("%w with ID: %d", ErrTransactionNotFound, transactionID)
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch transaction: %v", err)
+	default:
+		return &transaction, nil
+	}
+}
+
+func (r *transactionRepository) GetPendingAmounts(userID int, transactionType string) ([]money.Money, error) {
+	rows, err := r.db.Query(`
+		SELECT currency, SUM(amount) FROM transactions
+		WHERE user_id = $1 AND type = $2 AND status IN ($3, $4, $5)
+		GROUP BY currency ORDER BY currency`,
+		userID, transactionType, models.TransactionStatusCreated, models.TransactionStatusSubmitted, models.TransactionStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending amounts: %v", err)
+	}
+	defer rows.Close()
+
+	var amounts []money.Money
+	for rows.Next() {
+		var amount money.Money
+		if err := rows.Scan(amount.CurrencyScanner(), &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan pending amount: %v", err)
+		}
+		amounts = append(amounts, amount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch pending amounts: %v", err)
+	}
+	return amounts, nil
+}
+
+func (r *transactionRepository) GetExpiredAuthorizations(now time.Time, limit int) ([]models.Transaction, error) {
+	// an authorization with a capture in progress is settled by the capture, one being voided by the void
+	query := `SELECT ` + transactionColumns + ` FROM transactions
+			  WHERE status = $1 AND expires_at <= $2 AND (voiding_until IS NULL OR voiding_until <= $2)
+			  AND NOT EXISTS (SELECT 1 FROM transactions c WHERE c.parent_id = transactions.id AND c.status NOT IN ($3, $4))
+			  ORDER BY expires_at, id
+			  LIMIT $5`
+	rows, err := r.db.Query(query, models.TransactionStatusAuthorized, now,
+		models.TransactionStatusFailed, models.TransactionStatusExpired, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch expired authorizations: %v", err)
+	}
+	defer rows.Close()
+
+	var authorizations []models.Transaction
+	for rows.Next() {
+		authorization, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %v", err)
+		}
+		authorizations = append(authorizations, authorization)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return authorizations, nil
+}
+
+func (r *transactionRepository) GetStalePendingTransactions(now time.Time, pollAfter time.Duration, limit int) ([]models.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions
+			  WHERE status IN ($1, $5, $6)
+			  AND COALESCE(polled_at, created_at) <= $2::timestamp - make_interval(secs => COALESCE(
+			      (SELECT g.pending_poll_after FROM gateways g WHERE g.id = transactions.gateway_id), $3))
+			  ORDER BY COALESCE(polled_at, created_at), id
+			  LIMIT $4`
+	rows, err := r.db.Query(query, models.TransactionStatusPending, now, int(pollAfter.Seconds()), limit,
+		models.TransactionStatusCreated, models.TransactionStatusSubmitted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stale pending transactions: %v", err)
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %v", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
 
-	var transaction models.Transaction
+func (r *transactionRepository) MarkPolled(transactionID int, polledAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE transactions SET polled_at = $1 WHERE id = $2`, polledAt, transactionID)
+	if err != nil {
+		return fmt.Errorf("failed to update transaction polled_at: %v", err)
+	}
+	return nil
+}
+
+func scanTransaction(row rowScanner) (models.Transaction, error) {
+	var (
+		transaction        models.Transaction
+		settlementCurrency string
+		settlementAmount   money.Decimal
+		rate               money.Decimal
+		source             string
+		rateAt             sql.NullTime
+		refunded           money.Decimal
+		expiresAt          sql.NullTime
+	)
 
-	err := r.db.QueryRow(query, transactionID).Scan(
+	err := row.Scan(
 		&transaction.ID,
+		transaction.Amount.CurrencyScanner(),
 		&transaction.Amount,
 		&transaction.Type,
 		&transaction.Status,
@@ -82,14 +640,38 @@ func (r *transactionRepository) GetTransaction(transactionID int) (*models.Trans
 		&transaction.GatewayID,
 		&transaction.CountryID,
 		&transaction.CreatedAt,
+		&transaction.GatewayReference,
+		&settlementCurrency,
+		&settlementAmount,
+		&rate,
+		&source,
+		&rateAt,
+		&transaction.ParentID,
+		&refunded,
+		&expiresAt,
 	)
+	if err != nil {
+		return transaction, err
+	}
+	transaction.ExpiresAt = expiresAt.Time
 
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		return nil, fmt.Errorf("transaction not found with ID: %d", transactionID)
-	case err != nil:
-		return nil, fmt.Errorf("failed to fetch transaction: %v", err)
-	default:
-		return &transaction, nil
+	transaction.Refunded, err = money.FromDecimal(refunded, transaction.Amount.Currency())
+	if err != nil {
+		return transaction, err
 	}
+
+	if settlementCurrency != "" {
+		amount, err := money.FromDecimal(settlementAmount, settlementCurrency)
+		if err != nil {
+			return transaction, err
+		}
+		transaction.Conversion = &models.FXConversion{
+			Amount: amount,
+			Rate:   rate,
+			Source: source,
+			RateAt: rateAt.Time,
+		}
+	}
+
+	return transaction, nil
 }
@@ -9,6 +9,9 @@ import (
 	"time"
 )
 
+// ErrUserNotFound returned by GetUserByID for unknown user ids
+var ErrUserNotFound = errors.New("user not found")
+
 type UserRepository interface {
 	CreateUser(user models.User) error
 	GetUserByID(userID int) (models.User, error)
@@ -52,7 +55,7 @@ func (r *userRepository) GetUserByID(userID int) (models.User, error) {
 	err := r.db.QueryRow(query, userID).Scan(&user.ID, &user.Username, &user.Email, &user.CountryID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.User{}, fmt.Errorf("no user found with id %d", userID)
+			return models.User{}, fmt.Errorf("%w with ID: %d", ErrUserNotFound, userID)
 		}
 		return models.User{}, fmt.Errorf("failed to fetch user: %v", err)
 	}
@@ -0,0 +1,127 @@
+//go:generate mockgen -source account.go -destination mocks/account.go -package mocks
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+)
+
+const (
+	// DefaultStatementPeriod statement period ending at to when from is not set
+	DefaultStatementPeriod = 30 * 24 * time.Hour
+	// MaxStatementPeriod longest statement period, longer statements are requested page by page
+	MaxStatementPeriod = 366 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidCurrency = errors.New("invalid currency")
+	ErrInvalidPeriod   = errors.New("invalid statement period")
+)
+
+type Service interface {
+	// Balances of the user in every currency with posted, reserved or pending funds, ordered by currency
+	Balances(userID int) ([]models.UserBalance, error)
+	// Statement of the user wallet in the currency for [from, to), zero to is now and zero from is
+	// DefaultStatementPeriod before to
+	Statement(userID int, currency string, from, to time.Time) (models.Statement, error)
+}
+
+type service struct {
+	userRepo  repository.UserRepository
+	transRepo repository.TransactionRepository
+	ledger    ledger.Ledger
+	now       func() time.Time
+}
+
+func NewService(userRepo repository.UserRepository, transRepo repository.TransactionRepository, ledger ledger.Ledger) Service {
+	return &service{
+		userRepo:  userRepo,
+		transRepo: transRepo,
+		ledger:    ledger,
+		now:       time.Now,
+	}
+}
+
+func (s *service) Balances(userID int) ([]models.UserBalance, error) {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+
+	posted, err := s.ledger.Balances(userID)
+	if err != nil {
+		log.Printf("Error ledger.Balances: %v", err)
+		return nil, err
+	}
+
+	pending, err := s.transRepo.GetPendingAmounts(userID, models.TransactionTypeDeposit)
+	if err != nil {
+		log.Printf("Error db.GetPendingAmounts: %v", err)
+		return nil, err
+	}
+
+	balances := make(map[string]*models.UserBalance)
+	balanceOf := func(currency string) *models.UserBalance {
+		if b, ok := balances[currency]; ok {
+			return b
+		}
+		zero := money.New(0, currency)
+		b := &models.UserBalance{Currency: currency, Balance: zero, Available: zero, Reserved: zero, Pending: zero}
+		balances[currency] = b
+		return b
+	}
+
+	for _, p := range posted {
+		b := balanceOf(p.Balance.Currency())
+		b.Balance, b.Available, b.Reserved = p.Balance, p.Available, p.Reserved
+	}
+	for _, amount := range pending {
+		balanceOf(amount.Currency()).Pending = amount
+	}
+
+	result := make([]models.UserBalance, 0, len(balances))
+	for _, b := range balances {
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+func (s *service) Statement(userID int, currency string, from, to time.Time) (models.Statement, error) {
+	if !money.IsValidCurrency(currency) {
+		return models.Statement{}, fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+
+	if to.IsZero() {
+		to = s.now()
+	}
+	if from.IsZero() {
+		from = to.Add(-DefaultStatementPeriod)
+	}
+	if !from.Before(to) {
+		return models.Statement{}, fmt.Errorf("%w: from must be before to", ErrInvalidPeriod)
+	}
+	if to.Sub(from) > MaxStatementPeriod {
+		return models.Statement{}, fmt.Errorf("%w: longer than %d days", ErrInvalidPeriod, int(MaxStatementPeriod.Hours()/24))
+	}
+
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return models.Statement{}, err
+	}
+
+	statement, err := s.ledger.Statement(userID, currency, from, to)
+	if err != nil {
+		log.Printf("Error ledger.Statement: %v", err)
+		return models.Statement{}, err
+	}
+	return statement, nil
+}
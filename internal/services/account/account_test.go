@@ -0,0 +1,124 @@
+package account
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/ledger"
+	mockLedger "payment-gateway/internal/ledger/mocks"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/repository/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+type testService struct {
+	*service
+	userRepo  *mocks.MockUserRepository
+	transRepo *mocks.MockTransactionRepository
+	ledger    *mockLedger.MockLedger
+}
+
+func newTestService(ctrl *gomock.Controller) testService {
+	userRepo := mocks.NewMockUserRepository(ctrl)
+	transRepo := mocks.NewMockTransactionRepository(ctrl)
+	l := mockLedger.NewMockLedger(ctrl)
+
+	s := NewService(userRepo, transRepo, l).(*service)
+	s.now = func() time.Time { return now }
+
+	return testService{service: s, userRepo: userRepo, transRepo: transRepo, ledger: l}
+}
+
+func TestBalances(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	s.userRepo.EXPECT().GetUserByID(1).Return(models.User{ID: 1}, nil)
+	s.ledger.EXPECT().Balances(1).Return([]ledger.Balance{{
+		UserID:    1,
+		Balance:   money.MustParse("100", "EUR"),
+		Reserved:  money.MustParse("30", "EUR"),
+		Available: money.MustParse("70", "EUR"),
+	}}, nil)
+	s.transRepo.EXPECT().GetPendingAmounts(1, models.TransactionTypeDeposit).Return([]money.Money{
+		money.MustParse("5000", "JPY"),
+		money.MustParse("20", "EUR"),
+	}, nil)
+
+	balances, err := s.Balances(1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.UserBalance{
+		{
+			Currency:  "EUR",
+			Balance:   money.MustParse("100", "EUR"),
+			Available: money.MustParse("70", "EUR"),
+			Reserved:  money.MustParse("30", "EUR"),
+			Pending:   money.MustParse("20", "EUR"),
+		},
+		{
+			// the first deposit in the currency is not done yet, the wallet has no postings
+			Currency:  "JPY",
+			Balance:   money.New(0, "JPY"),
+			Available: money.New(0, "JPY"),
+			Reserved:  money.New(0, "JPY"),
+			Pending:   money.MustParse("5000", "JPY"),
+		},
+	}, balances)
+}
+
+func TestBalances_UnknownUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	s.userRepo.EXPECT().GetUserByID(9).Return(models.User{}, fmt.Errorf("%w with ID: %d", repository.ErrUserNotFound, 9))
+
+	_, err := s.Balances(9)
+	assert.ErrorIs(t, err, repository.ErrUserNotFound)
+}
+
+func TestStatement_DefaultPeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := newTestService(ctrl)
+	statement := models.Statement{UserID: 1, Currency: "EUR"}
+	s.userRepo.EXPECT().GetUserByID(1).Return(models.User{ID: 1}, nil)
+	s.ledger.EXPECT().Statement(1, "eur", now.Add(-DefaultStatementPeriod), now).Return(statement, nil)
+
+	result, err := s.Statement(1, "eur", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, statement, result)
+}
+
+func TestStatement_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		from, to time.Time
+		wantErr  error
+	}{
+		{name: "unknown currency", currency: "XYZ", wantErr: ErrInvalidCurrency},
+		{name: "from after to", currency: "EUR", from: now, to: now.Add(-time.Hour), wantErr: ErrInvalidPeriod},
+		{name: "too long", currency: "EUR", from: now.AddDate(-2, 0, 0), to: now, wantErr: ErrInvalidPeriod},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			_, err := newTestService(ctrl).Statement(1, tt.currency, tt.from, tt.to)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
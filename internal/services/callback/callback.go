@@ -0,0 +1,240 @@
+//go:generate mockgen -source callback.go -destination mocks/callback.go -package mocks
+package callback
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/dispute"
+	"payment-gateway/internal/services/transaction"
+	"payment-gateway/internal/util"
+)
+
+const (
+	// DefaultTolerance max difference between the callback timestamp and our clock
+	DefaultTolerance = 5 * time.Minute
+	maxNonceLength   = 255
+)
+
+var (
+	ErrUnknownGateway   = errors.New("unknown gateway")
+	ErrInvalidSignature = errors.New("invalid callback signature")
+	ErrStaleCallback    = errors.New("callback timestamp is outside of the allowed window")
+	ErrReplayedCallback = errors.New("callback nonce has already been used")
+)
+
+// Request signed notification posted by the gateway
+type Request struct {
+	Gateway string
+	// Signature base64 of the HMAC or RSA signature of SignedPayload
+	Signature string
+	// Timestamp unix time in seconds when the callback was signed
+	Timestamp string
+	Nonce     string
+	Body      []byte
+}
+
+type Service interface {
+	// Handle verifies the callback and updates the transaction with the status reported by the gateway,
+	// chargebacks are applied to the dispute of the transaction
+	Handle(req Request) error
+}
+
+type callbackService struct {
+	gatewayRepo  repository.GatewayRepository
+	nonceRepo    repository.CallbackNonceRepository
+	registry     *adapters.Registry
+	transactions transaction.TransactionService
+	disputes     dispute.Service
+	secretKey    []byte
+	tolerance    time.Duration
+	now          func() time.Time
+}
+
+// NewService secretKey decrypts the gateway callback secrets stored with util.Encrypt
+func NewService(
+	gatewayRepo repository.GatewayRepository,
+	nonceRepo repository.CallbackNonceRepository,
+	registry *adapters.Registry,
+	transactions transaction.TransactionService,
+	disputes dispute.Service,
+	secretKey []byte,
+	tolerance time.Duration,
+) Service {
+	return &callbackService{
+		gatewayRepo:  gatewayRepo,
+		nonceRepo:    nonceRepo,
+		registry:     registry,
+		transactions: transactions,
+		disputes:     disputes,
+		secretKey:    secretKey,
+		tolerance:    tolerance,
+		now:          time.Now,
+	}
+}
+
+// SignedPayload is the data signed by the gateway: "<timestamp>.<nonce>.<raw body>"
+func SignedPayload(timestamp, nonce string, body []byte) []byte {
+	payload := make([]byte, 0, len(timestamp)+len(nonce)+len(body)+2)
+	payload = append(payload, timestamp...)
+	payload = append(payload, '.')
+	payload = append(payload, nonce...)
+	payload = append(payload, '.')
+	return append(payload, body...)
+}
+
+func (s *callbackService) Handle(req Request) error {
+	gw, err := s.gatewayRepo.GetGatewayByName(req.Gateway)
+	if errors.Is(err, repository.ErrGatewayNotFound) {
+		return fmt.Errorf("%w: %s", ErrUnknownGateway, req.Gateway)
+	}
+	if err != nil {
+		log.Printf("Error db.GetGatewayByName: %v", err)
+		return err
+	}
+
+	if req.Nonce == "" || len(req.Nonce) > maxNonceLength {
+		return fmt.Errorf("%w: invalid nonce", ErrInvalidSignature)
+	}
+
+	if err := s.verifySignature(gw, req); err != nil {
+		return err
+	}
+
+	now := s.now()
+	timestamp, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrStaleCallback, req.Timestamp)
+	}
+	if diff := now.Sub(time.Unix(timestamp, 0)); diff > s.tolerance || diff < -s.tolerance {
+		return fmt.Errorf("%w: %s", ErrStaleCallback, time.Unix(timestamp, 0).UTC())
+	}
+
+	// a callback signed at now+tolerance is accepted until now+2*tolerance, its nonce must be kept that long
+	fresh, err := s.nonceRepo.UseNonce(gw.ID, req.Nonce, now, now.Add(-2*s.tolerance))
+	if err != nil {
+		log.Printf("Error db.UseNonce: %v", err)
+		return err
+	}
+	if !fresh {
+		return fmt.Errorf("%w: %s", ErrReplayedCallback, req.Nonce)
+	}
+
+	// the nonce only guards callbacks which have been applied, the gateway retries the failed ones with the same nonce
+	if err := s.process(gw, req); err != nil {
+		if releaseErr := s.nonceRepo.ReleaseNonce(gw.ID, req.Nonce); releaseErr != nil {
+			log.Printf("Error db.ReleaseNonce: %v", releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// process applies the verified callback to the transaction or its dispute
+func (s *callbackService) process(gw models.Gateway, req Request) error {
+	adapter, err := s.registry.Get(gw.Name)
+	if err != nil {
+		return err
+	}
+
+	cb, err := adapter.ParseCallback(gw, req.Body)
+	if err != nil {
+		return err
+	}
+
+	if cb.Dispute != nil {
+		_, err := s.disputes.Ingest(models.DisputeNotice{
+			TransactionID: cb.TransactionID,
+			GatewayID:     gw.ID,
+			Reference:     cb.Dispute.Reference,
+			Status:        cb.Dispute.Status,
+			Amount:        cb.Dispute.Amount,
+			Reason:        cb.Dispute.Reason,
+			Actor:         gw.Name,
+		})
+		return err
+	}
+
+	return s.transactions.UpdateStatus(models.StatusUpdate{
+		TransactionID: cb.TransactionID,
+		Status:        cb.Status,
+		GatewayID:     gw.ID,
+		Source:        models.StatusSourceCallback,
+		Actor:         gw.Name,
+		Reason:        "callback, gateway reference " + cb.Reference,
+	})
+}
+
+// verifySignature checks the signature with the gateway secret, gateways without a secret can't send callbacks
+func (s *callbackService) verifySignature(gw models.Gateway, req Request) error {
+	if gw.CallbackSignature == "" || gw.CallbackSecret == "" {
+		return fmt.Errorf("%w: callbacks are not configured for gateway %s", ErrInvalidSignature, gw.Name)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	secret, err := util.Decrypt(s.secretKey, gw.CallbackSecret)
+	if err != nil {
+		log.Printf("Error util.Decrypt: gateway=%s: %v", gw.Name, err)
+		return fmt.Errorf("failed to decrypt callback secret of gateway %s", gw.Name)
+	}
+
+	payload := SignedPayload(req.Timestamp, req.Nonce, req.Body)
+
+	switch gw.CallbackSignature {
+	case models.CallbackSignatureHMAC:
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(payload)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+	case models.CallbackSignatureRSA:
+		key, err := parsePublicKey(secret)
+		if err != nil {
+			log.Printf("Error parsePublicKey: gateway=%s: %v", gw.Name, err)
+			return fmt.Errorf("invalid callback public key of gateway %s", gw.Name)
+		}
+		digest := sha256.Sum256(payload)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: unsupported signature %q of gateway %s", ErrInvalidSignature, gw.CallbackSignature, gw.Name)
+	}
+
+	return nil
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return rsaKey, nil
+}
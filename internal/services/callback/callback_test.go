@@ -0,0 +1,290 @@
+package callback
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/repository/mocks"
+	mockDispute "payment-gateway/internal/services/dispute/mocks"
+	mockTransaction "payment-gateway/internal/services/transaction/mocks"
+	"payment-gateway/internal/util"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	secretKey = []byte("0123456789abcdef0123456789abcdef")
+	now       = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	body      = []byte(`{"id":"jp-1","merchant_reference":"7","status":"approved"}`)
+)
+
+func hmacGateway(t *testing.T, secret []byte) models.Gateway {
+	encrypted, err := util.Encrypt(secretKey, secret)
+	require.NoError(t, err)
+	return models.Gateway{ID: 3, Name: adapters.JSONPayName, CallbackSignature: models.CallbackSignatureHMAC, CallbackSecret: encrypted}
+}
+
+func hmacSign(secret []byte, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(SignedPayload(timestamp, nonce, body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTestService(ctrl *gomock.Controller) (*callbackService, *mocks.MockGatewayRepository, *mocks.MockCallbackNonceRepository, *mockTransaction.MockTransactionService) {
+	gatewayRepo := mocks.NewMockGatewayRepository(ctrl)
+	nonceRepo := mocks.NewMockCallbackNonceRepository(ctrl)
+	transactions := mockTransaction.NewMockTransactionService(ctrl)
+
+	service := NewService(gatewayRepo, nonceRepo, adapters.NewDefaultRegistry(nil), transactions, nil, secretKey, DefaultTolerance).(*callbackService)
+	service.now = func() time.Time { return now }
+
+	return service, gatewayRepo, nonceRepo, transactions
+}
+
+func TestHandle_HMAC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, gatewayRepo, nonceRepo, transactions := newTestService(ctrl)
+	secret := []byte("jsonpay-secret")
+	timestamp := strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+
+	gatewayRepo.EXPECT().GetGatewayByName(adapters.JSONPayName).Return(hmacGateway(t, secret), nil)
+	nonceRepo.EXPECT().UseNonce(3, "n-1", now, now.Add(-2*DefaultTolerance)).Return(true, nil)
+	transactions.EXPECT().UpdateStatus(models.StatusUpdate{
+		TransactionID: 7,
+		Status:        models.TransactionStatusDone,
+		GatewayID:     3,
+		Source:        models.StatusSourceCallback,
+		Actor:         adapters.JSONPayName,
+		Reason:        "callback, gateway reference jp-1",
+	}).Return(nil)
+
+	err := service.Handle(Request{
+		Gateway:   adapters.JSONPayName,
+		Signature: hmacSign(secret, timestamp, "n-1", body),
+		Timestamp: timestamp,
+		Nonce:     "n-1",
+		Body:      body,
+	})
+	assert.NoError(t, err)
+}
+
+func TestHandle_FailedUpdateReleasesNonce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, gatewayRepo, nonceRepo, transactions := newTestService(ctrl)
+	secret := []byte("jsonpay-secret")
+	timestamp := strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+	req := Request{
+		Gateway:   adapters.JSONPayName,
+		Signature: hmacSign(secret, timestamp, "n-1", body),
+		Timestamp: timestamp,
+		Nonce:     "n-1",
+		Body:      body,
+	}
+	dbErr := errors.New("connection reset")
+
+	gatewayRepo.EXPECT().GetGatewayByName(adapters.JSONPayName).Return(hmacGateway(t, secret), nil).Times(2)
+	gomock.InOrder(
+		nonceRepo.EXPECT().UseNonce(3, "n-1", now, gomock.Any()).Return(true, nil),
+		nonceRepo.EXPECT().ReleaseNonce(3, "n-1").Return(nil),
+		// the gateway delivers the callback again with the same nonce
+		nonceRepo.EXPECT().UseNonce(3, "n-1", now, gomock.Any()).Return(true, nil),
+	)
+	gomock.InOrder(
+		transactions.EXPECT().UpdateStatus(gomock.Any()).Return(dbErr),
+		transactions.EXPECT().UpdateStatus(gomock.Any()).Return(nil),
+	)
+
+	assert.ErrorIs(t, service.Handle(req), dbErr)
+	assert.NoError(t, service.Handle(req))
+}
+
+func TestHandle_Dispute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, gatewayRepo, nonceRepo, _ := newTestService(ctrl)
+	disputes := mockDispute.NewMockService(ctrl)
+	service.disputes = disputes
+
+	secret := []byte("jsonpay-secret")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	disputeBody := []byte(`{"id":"jp-1","merchant_reference":"7","dispute":{"id":"dp-1","status":"lost","reason":"fraudulent"}}`)
+
+	gatewayRepo.EXPECT().GetGatewayByName(adapters.JSONPayName).Return(hmacGateway(t, secret), nil)
+	nonceRepo.EXPECT().UseNonce(3, "n-3", now, gomock.Any()).Return(true, nil)
+	// the chargeback doesn't change the status of the transaction
+	disputes.EXPECT().Ingest(models.DisputeNotice{
+		TransactionID: 7,
+		GatewayID:     3,
+		Reference:     "dp-1",
+		Status:        models.DisputeStatusLost,
+		Reason:        "fraudulent",
+		Actor:         adapters.JSONPayName,
+	}).Return(&models.Dispute{ID: 1}, nil)
+
+	err := service.Handle(Request{
+		Gateway:   adapters.JSONPayName,
+		Signature: hmacSign(secret, timestamp, "n-3", disputeBody),
+		Timestamp: timestamp,
+		Nonce:     "n-3",
+		Body:      disputeBody,
+	})
+	assert.NoError(t, err)
+}
+
+func TestHandle_RSA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, gatewayRepo, nonceRepo, transactions := newTestService(ctrl)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	encrypted, err := util.Encrypt(secretKey, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	xmlBody := []byte(`<PaymentNotification><Reference>7</Reference><TransactionID>X-1</TransactionID><ResultCode>05</ResultCode></PaymentNotification>`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	digest := sha256.Sum256(SignedPayload(timestamp, "n-2", xmlBody))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	gatewayRepo.EXPECT().GetGatewayByName(adapters.XMLPayName).Return(models.Gateway{
+		ID: 4, Name: adapters.XMLPayName, CallbackSignature: models.CallbackSignatureRSA, CallbackSecret: encrypted,
+	}, nil)
+	nonceRepo.EXPECT().UseNonce(4, "n-2", now, gomock.Any()).Return(true, nil)
+	transactions.EXPECT().UpdateStatus(gomock.Any()).DoAndReturn(func(update models.StatusUpdate) error {
+		assert.Equal(t, 4, update.GatewayID)
+		assert.Equal(t, models.TransactionStatusFailed, update.Status)
+		return nil
+	})
+
+	err = service.Handle(Request{
+		Gateway:   adapters.XMLPayName,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		Timestamp: timestamp,
+		Nonce:     "n-2",
+		Body:      xmlBody,
+	})
+	assert.NoError(t, err)
+}
+
+func TestHandle_Rejected(t *testing.T) {
+	secret := []byte("jsonpay-secret")
+	fresh := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		gateway   models.Gateway
+		timestamp string
+		signature string
+		nonceUsed bool
+		body      []byte
+		wantErr   error
+	}{
+		{
+			name:      "wrong secret",
+			gateway:   hmacGateway(t, secret),
+			timestamp: fresh,
+			signature: hmacSign([]byte("other"), fresh, "n-1", body),
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "body changed",
+			gateway:   hmacGateway(t, secret),
+			timestamp: fresh,
+			signature: hmacSign(secret, fresh, "n-1", []byte(`{}`)),
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "callbacks not configured",
+			gateway:   models.Gateway{ID: 3, Name: adapters.JSONPayName},
+			timestamp: fresh,
+			signature: hmacSign(secret, fresh, "n-1", body),
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "stale timestamp",
+			gateway:   hmacGateway(t, secret),
+			timestamp: stale,
+			signature: hmacSign(secret, stale, "n-1", body),
+			wantErr:   ErrStaleCallback,
+		},
+		{
+			name:      "replayed nonce",
+			gateway:   hmacGateway(t, secret),
+			timestamp: fresh,
+			signature: hmacSign(secret, fresh, "n-1", body),
+			nonceUsed: true,
+			wantErr:   ErrReplayedCallback,
+		},
+		{
+			name:      "unknown provider status",
+			gateway:   hmacGateway(t, secret),
+			timestamp: fresh,
+			signature: hmacSign(secret, fresh, "n-1", []byte(`{"merchant_reference":"7","status":"weird"}`)),
+			body:      []byte(`{"merchant_reference":"7","status":"weird"}`),
+			wantErr:   adapters.ErrInvalidCallback,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			service, gatewayRepo, nonceRepo, _ := newTestService(ctrl)
+
+			gatewayRepo.EXPECT().GetGatewayByName(adapters.JSONPayName).Return(tt.gateway, nil)
+			nonceRepo.EXPECT().UseNonce(3, "n-1", now, gomock.Any()).Return(!tt.nonceUsed, nil).MaxTimes(1)
+			nonceRepo.EXPECT().ReleaseNonce(3, "n-1").Return(nil).MaxTimes(1)
+
+			reqBody := body
+			if tt.body != nil {
+				reqBody = tt.body
+			}
+
+			err := service.Handle(Request{
+				Gateway:   adapters.JSONPayName,
+				Signature: tt.signature,
+				Timestamp: tt.timestamp,
+				Nonce:     "n-1",
+				Body:      reqBody,
+			})
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHandle_UnknownGateway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service, gatewayRepo, _, _ := newTestService(ctrl)
+	gatewayRepo.EXPECT().GetGatewayByName("nopay").Return(models.Gateway{}, repository.ErrGatewayNotFound)
+
+	err := service.Handle(Request{Gateway: "nopay", Nonce: "n-1"})
+	assert.ErrorIs(t, err, ErrUnknownGateway)
+}
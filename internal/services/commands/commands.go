@@ -0,0 +1,287 @@
+//go:generate mockgen -source commands.go -destination mocks/commands.go -package mocks
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"payment-gateway/internal/codec"
+	"payment-gateway/internal/idempotency"
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/transaction"
+)
+
+const (
+	Topic           = "payments.commands"
+	ReplyTopic      = "payments.commands.replies"
+	DeadLetterTopic = "payments.commands.dlq"
+	ConsumerGroup   = "payment-gateway"
+
+	TypeDeposit      = "deposit"
+	TypeWithdrawal   = "withdrawal"
+	TypeUpdateStatus = "update_status"
+
+	ReplyStatusOK       = "ok"
+	ReplyStatusRejected = "rejected"
+
+	// AvroSubject schema registry subject of Reply
+	AvroSubject = "payments.commands.CommandReply"
+
+	maxCommandIDLength = 200
+	// command ids share the idempotency store with the HTTP API
+	idempotencyKeyPrefix = "command "
+)
+
+var ErrCommandInProgress = errors.New("command with the same id is in progress")
+
+//go:embed command_reply.avsc
+var replySchema string
+
+// Command sent by the back-office systems, ID is the idempotency key of the command
+type Command struct {
+	XMLName xml.Name `json:"-" xml:"command"`
+	ID      string   `json:"command_id" xml:"command_id"`
+	Type    string   `json:"type" xml:"type"`
+	// Transaction request of the deposit and withdrawal commands
+	Transaction *models.TransactionRequest `json:"transaction,omitempty" xml:"transaction,omitempty"`
+	// Status of the update_status command
+	Status *StatusCommand `json:"status,omitempty" xml:"status,omitempty"`
+}
+
+// StatusCommand moves the transaction to the status
+type StatusCommand struct {
+	TransactionID int    `json:"transaction_id" xml:"transaction_id"`
+	Status        string `json:"status" xml:"status"`
+	Actor         string `json:"actor" xml:"actor"`
+	Reason        string `json:"reason,omitempty" xml:"reason,omitempty"`
+}
+
+// Reply published to ReplyTopic for every executed command, commands rejected by the
+// transaction service are replied with ReplyStatusRejected and the error
+type Reply struct {
+	XMLName           xml.Name  `json:"-" xml:"command_reply"`
+	CommandID         string    `json:"command_id" xml:"command_id"`
+	Type              string    `json:"type" xml:"type"`
+	Status            string    `json:"status" xml:"status"`
+	TransactionID     int       `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"`
+	TransactionStatus string    `json:"transaction_status,omitempty" xml:"transaction_status,omitempty"`
+	Error             string    `json:"error,omitempty" xml:"error,omitempty"`
+	ProcessedAt       time.Time `json:"processed_at" xml:"processed_at"`
+}
+
+// AvroSchema schema of Reply, it is registered under AvroSubject
+func (r Reply) AvroSchema() (string, string) {
+	return AvroSubject, replySchema
+}
+
+// AvroNative goavro representation of the reply
+func (r Reply) AvroNative() map[string]any {
+	return map[string]any{
+		"command_id":         r.CommandID,
+		"type":               r.Type,
+		"status":             r.Status,
+		"transaction_id":     int32(r.TransactionID),
+		"transaction_status": r.TransactionStatus,
+		"error":              r.Error,
+		"processed_at":       r.ProcessedAt,
+	}
+}
+
+type Handler interface {
+	// Handle executes the command in the message and publishes the reply. Commands that can't be decoded
+	// are returned as kafka.ErrPoisonMessage, other errors are retried by the consumer
+	Handle(ctx context.Context, msg kafka.Message) error
+}
+
+type handler struct {
+	transactions transaction.TransactionService
+	publisher    kafka.KafkaPublisher
+	store        idempotency.Store
+	now          func() time.Time
+}
+
+// NewHandler store keeps the replies of the executed commands, redelivered commands get the stored reply
+func NewHandler(transactions transaction.TransactionService, publisher kafka.KafkaPublisher, store idempotency.Store) Handler {
+	return &handler{
+		transactions: transactions,
+		publisher:    publisher,
+		store:        store,
+		now:          time.Now,
+	}
+}
+
+func (h *handler) Handle(ctx context.Context, msg kafka.Message) error {
+	cmd, err := decode(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrPoisonMessage, err)
+	}
+
+	key := idempotencyKeyPrefix + cmd.ID
+	sum := sha256.Sum256(msg.Value)
+	hash := hex.EncodeToString(sum[:])
+
+	record, err := h.store.Acquire(ctx, key, hash)
+	if err != nil {
+		return err
+	}
+
+	if record != nil {
+		switch {
+		case record.RequestHash != hash:
+			return fmt.Errorf("%w: command id %s is already used with another command", kafka.ErrPoisonMessage, cmd.ID)
+		case record.Response == nil:
+			return fmt.Errorf("%w: %s", ErrCommandInProgress, cmd.ID)
+		}
+
+		// the reply may not have been published before the redelivery
+		var reply Reply
+		if err := json.Unmarshal(record.Response.Body, &reply); err != nil {
+			return fmt.Errorf("failed to decode stored reply: %v", err)
+		}
+		return h.publish(ctx, reply)
+	}
+
+	reply, err := h.execute(cmd)
+	if err != nil {
+		if releaseErr := h.store.Release(ctx, key); releaseErr != nil {
+			log.Printf("Error store.Release: %v", releaseErr)
+		}
+		return err
+	}
+
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	// the outcome is in the reply, the status code only marks the command as completed
+	err = h.store.Complete(ctx, key, idempotency.Record{
+		RequestHash: hash,
+		Response:    &idempotency.Response{StatusCode: http.StatusOK, ContentType: codec.ContentTypeJSON, Body: body},
+	})
+	if err != nil {
+		log.Printf("Error store.Complete: %v", err)
+	}
+
+	return h.publish(ctx, reply)
+}
+
+func (h *handler) execute(cmd Command) (Reply, error) {
+	reply := Reply{
+		CommandID: cmd.ID,
+		Type:      cmd.Type,
+		Status:    ReplyStatusOK,
+	}
+
+	var (
+		tx  *models.Transaction
+		err error
+	)
+	switch cmd.Type {
+	case TypeDeposit:
+		tx, err = h.transactions.Deposit(*cmd.Transaction)
+	case TypeWithdrawal:
+		tx, err = h.transactions.Withdrawal(*cmd.Transaction)
+	case TypeUpdateStatus:
+		err = h.transactions.UpdateStatus(models.StatusUpdate{
+			TransactionID: cmd.Status.TransactionID,
+			Status:        cmd.Status.Status,
+			Source:        models.StatusSourceCommand,
+			Actor:         cmd.Status.Actor,
+			Reason:        cmd.Status.Reason,
+		})
+		reply.TransactionID = cmd.Status.TransactionID
+		if err == nil {
+			reply.TransactionStatus = cmd.Status.Status
+		}
+	}
+
+	if tx != nil {
+		reply.TransactionID = tx.ID
+		reply.TransactionStatus = tx.Status
+	}
+
+	if err != nil {
+		if !isRejected(err) {
+			log.Printf("Error executing command %s: %v", cmd.ID, err)
+			return Reply{}, err
+		}
+		reply.Status = ReplyStatusRejected
+		reply.Error = err.Error()
+	}
+
+	reply.ProcessedAt = h.now().UTC()
+	return reply, nil
+}
+
+func (h *handler) publish(ctx context.Context, reply Reply) error {
+	if err := h.publisher.Publish(ctx, ReplyTopic, reply.CommandID, reply); err != nil {
+		return fmt.Errorf("failed to publish reply of command %s: %w", reply.CommandID, err)
+	}
+	return nil
+}
+
+// decode reads the command in the format of the message content type, json when it is not set
+func decode(msg kafka.Message) (Command, error) {
+	format := msg.ContentType()
+	if format == "" {
+		format = codec.FormatJSON
+	}
+
+	c, err := codec.Lookup(format)
+	if err != nil {
+		return Command{}, err
+	}
+
+	var cmd Command
+	if err := c.Unmarshal(msg.Value, &cmd); err != nil {
+		return Command{}, fmt.Errorf("invalid command: %v", err)
+	}
+
+	if cmd.ID == "" || len(cmd.ID) > maxCommandIDLength {
+		return Command{}, errors.New("command_id is required and must not exceed 200 characters")
+	}
+
+	switch cmd.Type {
+	case TypeDeposit, TypeWithdrawal:
+		if cmd.Transaction == nil {
+			return Command{}, fmt.Errorf("%s command %s has no transaction", cmd.Type, cmd.ID)
+		}
+	case TypeUpdateStatus:
+		if cmd.Status == nil {
+			return Command{}, fmt.Errorf("%s command %s has no status", cmd.Type, cmd.ID)
+		}
+	default:
+		return Command{}, fmt.Errorf("unknown command type %q", cmd.Type)
+	}
+
+	return cmd, nil
+}
+
+// isRejected the transaction service refused the command, executing it again gives the same result
+func isRejected(err error) bool {
+	var illegal *transaction.IllegalTransitionError
+	return errors.As(err, &illegal) ||
+		errors.Is(err, repository.ErrStatusConflict) ||
+		errors.Is(err, repository.ErrTransactionNotFound) ||
+		errors.Is(err, transaction.ErrInvalidAmount) ||
+		errors.Is(err, transaction.ErrInvalidUser) ||
+		errors.Is(err, transaction.ErrInvalidCurrency) ||
+		errors.Is(err, transaction.ErrCurrencyNotAllowed) ||
+		errors.Is(err, transaction.ErrInvalidStatus) ||
+		errors.Is(err, transaction.ErrGatewayMismatch) ||
+		errors.Is(err, transaction.ErrGatewayFailed) ||
+		errors.Is(err, ledger.ErrInsufficientFunds)
+}
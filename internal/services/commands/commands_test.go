@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/idempotency"
+	"payment-gateway/internal/kafka"
+	mockKafka "payment-gateway/internal/kafka/mocks"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/services/transaction"
+	mockTransaction "payment-gateway/internal/services/transaction/mocks"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+const depositCommand = `{"command_id":"cmd-1","type":"deposit","transaction":{"amount":"10.00","user_id":1,"currency":"EUR"}}`
+
+func newTestHandler(t *testing.T) (*handler, *mockTransaction.MockTransactionService, *mockKafka.MockKafkaPublisher) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	transactions := mockTransaction.NewMockTransactionService(ctrl)
+	publisher := mockKafka.NewMockKafkaPublisher(ctrl)
+	store := idempotency.NewRedisStore(client, time.Hour, time.Minute)
+
+	h := NewHandler(transactions, publisher, store).(*handler)
+	h.now = func() time.Time { return now }
+
+	return h, transactions, publisher
+}
+
+func message(value string, contentType string) kafka.Message {
+	msg := kafka.Message{Topic: Topic, Value: []byte(value), Headers: map[string]string{}}
+	if contentType != "" {
+		msg.Headers["content-type"] = contentType
+	}
+	return msg
+}
+
+func TestHandle_Deposit(t *testing.T) {
+	h, transactions, publisher := newTestHandler(t)
+
+	transactions.EXPECT().Deposit(models.TransactionRequest{
+		Amount: money.MustParseDecimal("10.00"), UserID: 1, Currency: "EUR",
+	}).Return(&models.Transaction{ID: 7, Status: models.TransactionStatusPending}, nil)
+
+	want := Reply{
+		CommandID: "cmd-1", Type: TypeDeposit, Status: ReplyStatusOK,
+		TransactionID: 7, TransactionStatus: models.TransactionStatusPending, ProcessedAt: now,
+	}
+	// the redelivered command gets the stored reply without a second deposit
+	publisher.EXPECT().Publish(gomock.Any(), ReplyTopic, "cmd-1", want).Return(nil).Times(2)
+
+	require.NoError(t, h.Handle(context.Background(), message(depositCommand, "")))
+	require.NoError(t, h.Handle(context.Background(), message(depositCommand, "")))
+}
+
+func TestHandle_UpdateStatusXML(t *testing.T) {
+	h, transactions, publisher := newTestHandler(t)
+
+	command := `<command><command_id>cmd-2</command_id><type>update_status</type>` +
+		`<status><transaction_id>7</transaction_id><status>done</status><actor>backoffice:42</actor></status></command>`
+
+	transactions.EXPECT().UpdateStatus(models.StatusUpdate{
+		TransactionID: 7, Status: models.TransactionStatusDone, Source: models.StatusSourceCommand, Actor: "backoffice:42",
+	}).Return(nil)
+	publisher.EXPECT().Publish(gomock.Any(), ReplyTopic, "cmd-2", Reply{
+		CommandID: "cmd-2", Type: TypeUpdateStatus, Status: ReplyStatusOK,
+		TransactionID: 7, TransactionStatus: models.TransactionStatusDone, ProcessedAt: now,
+	}).Return(nil)
+
+	require.NoError(t, h.Handle(context.Background(), message(command, "application/xml")))
+}
+
+func TestHandle_Rejected(t *testing.T) {
+	h, transactions, publisher := newTestHandler(t)
+
+	command := `{"command_id":"cmd-3","type":"update_status","status":{"transaction_id":7,"status":"created"}}`
+	illegal := &transaction.IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusDone, To: models.TransactionStatusCreated}
+
+	transactions.EXPECT().UpdateStatus(gomock.Any()).Return(illegal)
+	publisher.EXPECT().Publish(gomock.Any(), ReplyTopic, "cmd-3", Reply{
+		CommandID: "cmd-3", Type: TypeUpdateStatus, Status: ReplyStatusRejected,
+		TransactionID: 7, Error: illegal.Error(), ProcessedAt: now,
+	}).Return(nil)
+
+	require.NoError(t, h.Handle(context.Background(), message(command, "")))
+}
+
+func TestHandle_TransientErrorIsRetried(t *testing.T) {
+	h, transactions, publisher := newTestHandler(t)
+
+	gomock.InOrder(
+		transactions.EXPECT().Deposit(gomock.Any()).Return(nil, errors.New("connection refused")),
+		transactions.EXPECT().Deposit(gomock.Any()).Return(&models.Transaction{ID: 7, Status: models.TransactionStatusPending}, nil),
+	)
+	publisher.EXPECT().Publish(gomock.Any(), ReplyTopic, "cmd-1", gomock.Any()).Return(nil)
+
+	// the key is released so the redelivered command is executed again
+	err := h.Handle(context.Background(), message(depositCommand, ""))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, kafka.ErrPoisonMessage)
+
+	require.NoError(t, h.Handle(context.Background(), message(depositCommand, "")))
+}
+
+func TestHandle_PoisonMessages(t *testing.T) {
+	tests := map[string]kafka.Message{
+		"invalid json":        message(`{"command_id":`, ""),
+		"unsupported format":  message(depositCommand, "application/protobuf"),
+		"missing command id":  message(`{"type":"deposit","transaction":{"amount":"10.00","user_id":1,"currency":"EUR"}}`, ""),
+		"unknown type":        message(`{"command_id":"cmd-1","type":"refund"}`, ""),
+		"missing transaction": message(`{"command_id":"cmd-1","type":"withdrawal"}`, ""),
+		"missing status":      message(`{"command_id":"cmd-1","type":"update_status"}`, ""),
+	}
+
+	for name, msg := range tests {
+		t.Run(name, func(t *testing.T) {
+			h, _, _ := newTestHandler(t)
+			assert.ErrorIs(t, h.Handle(context.Background(), msg), kafka.ErrPoisonMessage)
+		})
+	}
+}
+
+func TestHandle_ReusedCommandID(t *testing.T) {
+	h, transactions, publisher := newTestHandler(t)
+
+	transactions.EXPECT().Deposit(gomock.Any()).Return(&models.Transaction{ID: 7, Status: models.TransactionStatusPending}, nil)
+	publisher.EXPECT().Publish(gomock.Any(), ReplyTopic, "cmd-1", gomock.Any()).Return(nil)
+
+	require.NoError(t, h.Handle(context.Background(), message(depositCommand, "")))
+
+	other := `{"command_id":"cmd-1","type":"withdrawal","transaction":{"amount":"10.00","user_id":1,"currency":"EUR"}}`
+	assert.ErrorIs(t, h.Handle(context.Background(), message(other, "")), kafka.ErrPoisonMessage)
+}
+
+func TestReply_Avro(t *testing.T) {
+	registry := kafka.NewLocalRegistry()
+	serializer, err := kafka.NewSerializer(kafka.FormatAvro, registry)
+	require.NoError(t, err)
+
+	reply := Reply{CommandID: "cmd-1", Type: TypeDeposit, Status: ReplyStatusOK, TransactionID: 7, ProcessedAt: now}
+	data, err := serializer.Serialize(reply)
+	require.NoError(t, err)
+
+	native, err := kafka.DecodeAvro(registry, data)
+	require.NoError(t, err)
+	got := native.(map[string]any)
+	assert.Equal(t, "cmd-1", got["command_id"])
+	assert.Equal(t, int32(7), got["transaction_id"])
+	assert.True(t, now.Equal(got["processed_at"].(time.Time)))
+}
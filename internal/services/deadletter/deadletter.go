@@ -0,0 +1,165 @@
+//go:generate mockgen -source deadletter.go -destination mocks/deadletter.go -package mocks
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+
+	replayTimeout = 10 * time.Second
+)
+
+var (
+	ErrAlreadyReplayed = errors.New("dead letter has already been replayed")
+	// ErrReplayFailed kafka rejected the replayed message, the dead letter stays failed
+	ErrReplayFailed = errors.New("dead letter replay failed")
+	// ErrSuperseded a later event of the transaction has been published, replaying the dead letter would
+	// deliver the events out of order
+	ErrSuperseded = errors.New("dead letter is superseded by a later event of the transaction")
+)
+
+// ReplayResult outcome of replaying the dead letters matching a filter
+type ReplayResult struct {
+	Replayed int `json:"replayed" xml:"replayed"`
+	Failed   int `json:"failed" xml:"failed"`
+	// Skipped superseded dead letters, see ErrSuperseded
+	Skipped int `json:"skipped" xml:"skipped"`
+	// LastID id of the last dead letter tried, the next page starts after it
+	LastID int64 `json:"lastID" xml:"lastID"`
+}
+
+type Service interface {
+	List(filter models.DeadLetterFilter) ([]models.DeadLetter, error)
+	Get(id int64) (models.DeadLetter, error)
+	// Replay publishes the dead letter to its topic again
+	Replay(ctx context.Context, id int64) (models.DeadLetter, error)
+	// ReplayAll replays one page of failed dead letters matching the filter, failures are recorded
+	// on the dead letters and don't stop the replay
+	ReplayAll(ctx context.Context, filter models.DeadLetterFilter) (ReplayResult, error)
+}
+
+type service struct {
+	repo      repository.DeadLetterRepository
+	publisher kafka.KafkaPublisher
+	now       func() time.Time
+}
+
+func NewService(repo repository.DeadLetterRepository, publisher kafka.KafkaPublisher) Service {
+	return &service{
+		repo:      repo,
+		publisher: publisher,
+		now:       time.Now,
+	}
+}
+
+func (s *service) List(filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	return s.repo.ListDeadLetters(normalize(filter))
+}
+
+func (s *service) Get(id int64) (models.DeadLetter, error) {
+	return s.repo.GetDeadLetter(id)
+}
+
+func (s *service) Replay(ctx context.Context, id int64) (models.DeadLetter, error) {
+	deadLetter, err := s.repo.GetDeadLetter(id)
+	if err != nil {
+		return models.DeadLetter{}, err
+	}
+	if deadLetter.Status == models.DeadLetterStatusReplayed {
+		return deadLetter, fmt.Errorf("%w: %d", ErrAlreadyReplayed, id)
+	}
+
+	if err := s.replay(ctx, &deadLetter); err != nil {
+		return deadLetter, err
+	}
+	return deadLetter, nil
+}
+
+func (s *service) ReplayAll(ctx context.Context, filter models.DeadLetterFilter) (ReplayResult, error) {
+	filter = normalize(filter)
+	filter.Status = models.DeadLetterStatusFailed
+
+	deadLetters, err := s.repo.ListDeadLetters(filter)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	var result ReplayResult
+	for i := range deadLetters {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		result.LastID = deadLetters[i].ID
+		if err := s.replay(ctx, &deadLetters[i]); errors.Is(err, ErrSuperseded) {
+			log.Printf("Skipping dead letter %d: %v", deadLetters[i].ID, err)
+			result.Skipped++
+			continue
+		} else if err != nil {
+			log.Printf("Error replaying dead letter %d: %v", deadLetters[i].ID, err)
+			result.Failed++
+			continue
+		}
+		result.Replayed++
+	}
+
+	return result, nil
+}
+
+// replay publishes the dead letter and updates it with the outcome
+func (s *service) replay(ctx context.Context, deadLetter *models.DeadLetter) error {
+	// transaction events are published in order, consumers which have seen a later one keep its state
+	if deadLetter.OutboxID != 0 {
+		published, err := s.repo.LaterPublished(deadLetter.OutboxID)
+		if err != nil {
+			log.Printf("Error db.LaterPublished: %v", err)
+			return err
+		}
+		if published {
+			return fmt.Errorf("%w, id %d", ErrSuperseded, deadLetter.ID)
+		}
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+
+	err := s.publisher.PublishMessage(publishCtx, deadLetter.Topic, deadLetter.Key, deadLetter.Payload, deadLetter.ContentType)
+	if err != nil {
+		deadLetter.Attempts++
+		deadLetter.Error = err.Error()
+		if markErr := s.repo.MarkReplayFailed(deadLetter.ID, err.Error()); markErr != nil {
+			log.Printf("Error db.MarkReplayFailed: %v", markErr)
+		}
+		return fmt.Errorf("%w, id %d: %w", ErrReplayFailed, deadLetter.ID, err)
+	}
+
+	replayedAt := s.now()
+	// a failed update leaves the dead letter failed, replaying it again publishes a duplicate
+	if err := s.repo.MarkReplayed(deadLetter.ID, replayedAt); err != nil {
+		return err
+	}
+	deadLetter.Status = models.DeadLetterStatusReplayed
+	deadLetter.ReplayedAt = &replayedAt
+	return nil
+}
+
+func normalize(filter models.DeadLetterFilter) models.DeadLetterFilter {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit > MaxLimit {
+		filter.Limit = MaxLimit
+	}
+	return filter
+}
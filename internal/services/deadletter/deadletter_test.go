@@ -0,0 +1,153 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mockPublisher "payment-gateway/internal/kafka/mocks"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService(ctrl *gomock.Controller) (*service, *mocks.MockDeadLetterRepository, *mockPublisher.MockKafkaPublisher) {
+	repo := mocks.NewMockDeadLetterRepository(ctrl)
+	publisher := mockPublisher.NewMockKafkaPublisher(ctrl)
+
+	s := NewService(repo, publisher).(*service)
+	s.now = func() time.Time { return now }
+
+	return s, repo, publisher
+}
+
+func deadLetter(id int64, status string) models.DeadLetter {
+	return models.DeadLetter{
+		ID: id, Topic: "transactions.json", Key: "7", ContentType: "application/json",
+		Payload: []byte(`{"transaction_id":7}`), Error: "circuit breaker is open", Attempts: 10, Status: status,
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, publisher := newTestService(ctrl)
+
+	gomock.InOrder(
+		repo.EXPECT().GetDeadLetter(int64(1)).Return(deadLetter(1, models.DeadLetterStatusFailed), nil),
+		publisher.EXPECT().PublishMessage(gomock.Any(), "transactions.json", "7", []byte(`{"transaction_id":7}`), "application/json").Return(nil),
+		repo.EXPECT().MarkReplayed(int64(1), now).Return(nil),
+	)
+
+	replayed, err := s.Replay(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeadLetterStatusReplayed, replayed.Status)
+	assert.Equal(t, now, *replayed.ReplayedAt)
+}
+
+func TestReplay_AlreadyReplayed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, _ := newTestService(ctrl)
+	repo.EXPECT().GetDeadLetter(int64(1)).Return(deadLetter(1, models.DeadLetterStatusReplayed), nil)
+
+	_, err := s.Replay(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrAlreadyReplayed)
+}
+
+func TestReplay_PublishFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, publisher := newTestService(ctrl)
+	publishErr := errors.New("kafka write failed")
+
+	repo.EXPECT().GetDeadLetter(int64(1)).Return(deadLetter(1, models.DeadLetterStatusFailed), nil)
+	publisher.EXPECT().PublishMessage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(publishErr)
+	repo.EXPECT().MarkReplayFailed(int64(1), publishErr.Error()).Return(nil)
+
+	failed, err := s.Replay(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrReplayFailed)
+	assert.ErrorIs(t, err, publishErr)
+	assert.Equal(t, models.DeadLetterStatusFailed, failed.Status)
+	assert.Equal(t, 11, failed.Attempts)
+}
+
+func TestReplay_Superseded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, _ := newTestService(ctrl)
+	dead := deadLetter(1, models.DeadLetterStatusFailed)
+	dead.OutboxID = 40
+
+	repo.EXPECT().GetDeadLetter(int64(1)).Return(dead, nil)
+	repo.EXPECT().LaterPublished(int64(40)).Return(true, nil)
+
+	_, err := s.Replay(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrSuperseded)
+}
+
+func TestReplayAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, publisher := newTestService(ctrl)
+	publishErr := errors.New("kafka write failed")
+
+	// only failed dead letters are replayed, the limit defaults to DefaultLimit
+	repo.EXPECT().ListDeadLetters(models.DeadLetterFilter{
+		Topic: "transactions.json", Status: models.DeadLetterStatusFailed, AfterID: 5, Limit: DefaultLimit,
+	}).Return([]models.DeadLetter{deadLetter(6, models.DeadLetterStatusFailed), deadLetter(8, models.DeadLetterStatusFailed)}, nil)
+
+	gomock.InOrder(
+		publisher.EXPECT().PublishMessage(gomock.Any(), "transactions.json", "7", gomock.Any(), "application/json").Return(publishErr),
+		repo.EXPECT().MarkReplayFailed(int64(6), publishErr.Error()).Return(nil),
+		publisher.EXPECT().PublishMessage(gomock.Any(), "transactions.json", "7", gomock.Any(), "application/json").Return(nil),
+		repo.EXPECT().MarkReplayed(int64(8), now).Return(nil),
+	)
+
+	result, err := s.ReplayAll(context.Background(), models.DeadLetterFilter{Topic: "transactions.json", AfterID: 5})
+	require.NoError(t, err)
+	assert.Equal(t, ReplayResult{Replayed: 1, Failed: 1, LastID: 8}, result)
+}
+
+func TestList_LimitIsCapped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, _ := newTestService(ctrl)
+	repo.EXPECT().ListDeadLetters(models.DeadLetterFilter{Key: "7", Limit: MaxLimit}).Return(nil, nil)
+
+	_, err := s.List(models.DeadLetterFilter{Key: "7", Limit: 5000})
+	assert.NoError(t, err)
+}
+
+func TestReplayAll_SkipsSuperseded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s, repo, publisher := newTestService(ctrl)
+	superseded, latest := deadLetter(6, models.DeadLetterStatusFailed), deadLetter(8, models.DeadLetterStatusFailed)
+	superseded.OutboxID, latest.OutboxID = 40, 41
+
+	repo.EXPECT().ListDeadLetters(gomock.Any()).Return([]models.DeadLetter{superseded, latest}, nil)
+	gomock.InOrder(
+		repo.EXPECT().LaterPublished(int64(40)).Return(true, nil),
+		repo.EXPECT().LaterPublished(int64(41)).Return(false, nil),
+		publisher.EXPECT().PublishMessage(gomock.Any(), "transactions.json", "7", gomock.Any(), "application/json").Return(nil),
+		repo.EXPECT().MarkReplayed(int64(8), now).Return(nil),
+	)
+
+	result, err := s.ReplayAll(context.Background(), models.DeadLetterFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, ReplayResult{Replayed: 1, Skipped: 1, LastID: 8}, result)
+}
@@ -0,0 +1,319 @@
+//go:generate mockgen -source dispute.go -destination mocks/dispute.go -package mocks
+package dispute
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/transaction"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+
+	// MaxEvidenceSize largest evidence document accepted
+	MaxEvidenceSize = 5 << 20
+
+	// maxTransitionAttempts compare-and-set attempts when the status is changed concurrently
+	maxTransitionAttempts = 3
+)
+
+var (
+	// ErrNotDisputable only done or reversed deposits and captures can be disputed
+	ErrNotDisputable = errors.New("transaction can't be disputed")
+	ErrInvalidAmount = errors.New("invalid dispute amount")
+	ErrInvalidStatus = errors.New("invalid dispute status")
+	// ErrIllegalTransition the dispute can't move from its current status to the requested one
+	ErrIllegalTransition = errors.New("illegal dispute status change")
+	ErrInvalidEvidence   = errors.New("invalid dispute evidence")
+	// ErrDisputeClosed evidence can't be attached to a won or lost dispute
+	ErrDisputeClosed = errors.New("dispute is closed")
+)
+
+// transitions statuses the dispute may move to from its current status, won and lost are final
+var transitions = map[string][]string{
+	models.DisputeStatusOpened:            {models.DisputeStatusEvidenceSubmitted, models.DisputeStatusWon, models.DisputeStatusLost},
+	models.DisputeStatusEvidenceSubmitted: {models.DisputeStatusWon, models.DisputeStatusLost},
+}
+
+type Service interface {
+	// Ingest applies the dispute reported by the gateway, the dispute is opened on the first notice
+	Ingest(notice models.DisputeNotice) (*models.Dispute, error)
+	List(filter models.DisputeFilter) ([]models.Dispute, error)
+	// Get returns the dispute with its evidence, without the documents, and status history
+	Get(id int) (*models.DisputeDetail, error)
+	// AddEvidence attaches the document to the open dispute
+	AddEvidence(evidence models.DisputeEvidence) (*models.DisputeEvidence, error)
+	// GetEvidence returns the evidence with its document
+	GetEvidence(disputeID, evidenceID int) (*models.DisputeEvidence, error)
+	// UpdateStatus moves the dispute to update.Status, a lost dispute reverses the disputed amount in the ledger
+	UpdateStatus(update models.DisputeStatusUpdate) (*models.Dispute, error)
+}
+
+type service struct {
+	repo       repository.DisputeRepository
+	transRepo  repository.TransactionRepository
+	serializer kafka.Serializer
+	now        func() time.Time
+}
+
+func NewService(repo repository.DisputeRepository, transRepo repository.TransactionRepository, serializer kafka.Serializer) Service {
+	return &service{
+		repo:       repo,
+		transRepo:  transRepo,
+		serializer: serializer,
+		now:        time.Now,
+	}
+}
+
+// CanTransition reports whether the dispute in status from may move to status to
+func CanTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidStatus(status string) bool {
+	switch status {
+	case models.DisputeStatusOpened, models.DisputeStatusEvidenceSubmitted, models.DisputeStatusWon, models.DisputeStatusLost:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *service) Ingest(notice models.DisputeNotice) (*models.Dispute, error) {
+	if !isValidStatus(notice.Status) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, notice.Status)
+	}
+
+	tx, err := s.transRepo.GetTransaction(notice.TransactionID)
+	if err != nil {
+		log.Printf("Error db.GetTransaction: %v", err)
+		return nil, err
+	}
+	if tx.GatewayID != notice.GatewayID {
+		return nil, fmt.Errorf("%w: transaction %d, gateway %d", transaction.ErrGatewayMismatch, tx.ID, notice.GatewayID)
+	}
+
+	dispute, err := s.repo.GetDisputeByTransaction(tx.ID)
+	if errors.Is(err, repository.ErrDisputeNotFound) {
+		dispute, err = s.open(tx, notice)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	update := models.DisputeStatusUpdate{
+		DisputeID: dispute.ID,
+		Status:    notice.Status,
+		Source:    models.StatusSourceCallback,
+		Actor:     notice.Actor,
+		Reason:    "callback, gateway dispute reference " + notice.Reference,
+	}
+	if err := s.transition(dispute, tx, update); err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// open creates the dispute of tx in opened status, a dispute opened concurrently by another notice is returned instead
+func (s *service) open(tx *models.Transaction, notice models.DisputeNotice) (*models.Dispute, error) {
+	if !isDisputable(tx) {
+		return nil, fmt.Errorf("%w: transaction %d is a %s %s", ErrNotDisputable, tx.ID, tx.Status, tx.Type)
+	}
+
+	amount := notice.Amount
+	if amount.IsZero() {
+		amount = tx.Amount
+	}
+	if cmp, err := amount.Cmp(tx.Amount); err != nil || amount.Sign() <= 0 || cmp > 0 {
+		return nil, fmt.Errorf("%w: %s %s of transaction %d of %s %s", ErrInvalidAmount, amount, amount.Currency(),
+			tx.ID, tx.Amount, tx.Amount.Currency())
+	}
+
+	dispute := &models.Dispute{
+		TransactionID:    tx.ID,
+		GatewayID:        tx.GatewayID,
+		GatewayReference: notice.Reference,
+		Amount:           amount,
+		Reason:           notice.Reason,
+		Status:           models.DisputeStatusOpened,
+	}
+
+	var err error
+	dispute.ID, err = s.repo.CreateDispute(*dispute, func(created models.Dispute) (models.OutboxMessage, error) {
+		update := models.DisputeStatusUpdate{Source: models.StatusSourceCallback, Actor: notice.Actor, Reason: notice.Reason}
+		return events.NewDisputeEvent(created, *tx, "", update, s.now()).OutboxMessage(s.serializer)
+	})
+	if errors.Is(err, repository.ErrDisputeExists) {
+		return s.repo.GetDisputeByTransaction(tx.ID)
+	}
+	if err != nil {
+		log.Printf("Error db.CreateDispute: %v", err)
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// isDisputable the payer can charge back funds which have reached the user wallet, also when they have been refunded since
+func isDisputable(tx *models.Transaction) bool {
+	if tx.Type != models.TransactionTypeDeposit && tx.Type != models.TransactionTypeCapture {
+		return false
+	}
+	return tx.Status == models.TransactionStatusDone || tx.Status == models.TransactionStatusReversed
+}
+
+func (s *service) List(filter models.DisputeFilter) ([]models.Dispute, error) {
+	if filter.Status != "" && !isValidStatus(filter.Status) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, filter.Status)
+	}
+	return s.repo.ListDisputes(normalize(filter))
+}
+
+func (s *service) Get(id int) (*models.DisputeDetail, error) {
+	dispute, err := s.repo.GetDispute(id)
+	if err != nil {
+		return nil, err
+	}
+
+	evidence, err := s.repo.ListEvidence(id)
+	if err != nil {
+		log.Printf("Error db.ListEvidence: %v", err)
+		return nil, err
+	}
+	history, err := s.repo.GetDisputeHistory(id)
+	if err != nil {
+		log.Printf("Error db.GetDisputeHistory: %v", err)
+		return nil, err
+	}
+
+	return &models.DisputeDetail{Dispute: *dispute, Evidence: evidence, History: history}, nil
+}
+
+func (s *service) AddEvidence(evidence models.DisputeEvidence) (*models.DisputeEvidence, error) {
+	evidence.Name = strings.TrimSpace(evidence.Name)
+	switch {
+	case evidence.Name == "":
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidEvidence)
+	case len(evidence.Content) == 0:
+		return nil, fmt.Errorf("%w: document is empty", ErrInvalidEvidence)
+	case len(evidence.Content) > MaxEvidenceSize:
+		return nil, fmt.Errorf("%w: document is larger than %d bytes", ErrInvalidEvidence, MaxEvidenceSize)
+	}
+	if evidence.ContentType == "" {
+		evidence.ContentType = "application/octet-stream"
+	}
+
+	dispute, err := s.repo.GetDispute(evidence.DisputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status == models.DisputeStatusWon || dispute.Status == models.DisputeStatusLost {
+		return nil, fmt.Errorf("%w: dispute %d is %s", ErrDisputeClosed, dispute.ID, dispute.Status)
+	}
+
+	evidence.Size = len(evidence.Content)
+	evidence.CreatedAt = s.now()
+	if evidence.ID, err = s.repo.AddEvidence(evidence); err != nil {
+		log.Printf("Error db.AddEvidence: %v", err)
+		return nil, err
+	}
+
+	// the document is not sent back
+	evidence.Content = nil
+	return &evidence, nil
+}
+
+func (s *service) GetEvidence(disputeID, evidenceID int) (*models.DisputeEvidence, error) {
+	return s.repo.GetEvidence(disputeID, evidenceID)
+}
+
+func (s *service) UpdateStatus(update models.DisputeStatusUpdate) (*models.Dispute, error) {
+	if !isValidStatus(update.Status) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, update.Status)
+	}
+
+	dispute, err := s.repo.GetDispute(update.DisputeID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.transRepo.GetTransaction(dispute.TransactionID)
+	if err != nil {
+		log.Printf("Error db.GetTransaction: %v", err)
+		return nil, err
+	}
+
+	if err := s.transition(dispute, tx, update); err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+// transition moves the dispute to update.Status with its event and ledger posting, repeating the current status
+// is a no-op so duplicated notices are accepted. When the status is changed concurrently the transition is checked
+// again against the new status
+func (s *service) transition(dispute *models.Dispute, tx *models.Transaction, update models.DisputeStatusUpdate) error {
+	for attempt := 1; ; attempt++ {
+		if dispute.Status == update.Status {
+			return nil
+		}
+		if !CanTransition(dispute.Status, update.Status) {
+			return fmt.Errorf("%w: dispute %d from %s to %s", ErrIllegalTransition, dispute.ID, dispute.Status, update.Status)
+		}
+
+		changed := *dispute
+		changed.Status = update.Status
+		message, err := events.NewDisputeEvent(changed, *tx, dispute.Status, update, s.now()).OutboxMessage(s.serializer)
+		if err != nil {
+			log.Printf("Error events.OutboxMessage: %v", err)
+			return err
+		}
+
+		err = s.repo.TransitionDispute(models.DisputeTransition{
+			DisputeID: dispute.ID,
+			From:      dispute.Status,
+			To:        update.Status,
+			Source:    update.Source,
+			Actor:     update.Actor,
+			Reason:    update.Reason,
+		}, message, ledger.ForDispute(*tx, *dispute, update.Status))
+		if err == nil {
+			dispute.Status = update.Status
+			return nil
+		}
+		if !errors.Is(err, repository.ErrStatusConflict) || attempt == maxTransitionAttempts {
+			log.Printf("Error db.TransitionDispute: %v", err)
+			return err
+		}
+
+		current, err := s.repo.GetDispute(dispute.ID)
+		if err != nil {
+			log.Printf("Error db.GetDispute: %v", err)
+			return err
+		}
+		dispute.Status = current.Status
+	}
+}
+
+func normalize(filter models.DisputeFilter) models.DisputeFilter {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit > MaxLimit {
+		filter.Limit = MaxLimit
+	}
+	return filter
+}
@@ -0,0 +1,257 @@
+package dispute
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/repository/mocks"
+	"payment-gateway/internal/services/transaction"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jsonSerializer, _ = kafka.NewSerializer("", nil)
+
+func doneDeposit() *models.Transaction {
+	return &models.Transaction{
+		ID: 7, UserID: 1, GatewayID: 10, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusDone,
+		Amount: money.MustParse("100", "EUR"), GatewayReference: "pay_7",
+	}
+}
+
+func openedDispute() *models.Dispute {
+	return &models.Dispute{
+		ID: 3, TransactionID: 7, GatewayID: 10, GatewayReference: "dp_1", Amount: money.MustParse("40", "EUR"),
+		Reason: "fraudulent", Status: models.DisputeStatusOpened,
+	}
+}
+
+func eventType(t *testing.T, message models.OutboxMessage) string {
+	var event events.DisputeEvent
+	require.NoError(t, json.Unmarshal(message.Payload, &event))
+	return event.EventType
+}
+
+func TestIngest_OpensDispute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewService(mockRepo, mockTransRepo, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockRepo.EXPECT().GetDisputeByTransaction(7).Return(nil, fmt.Errorf("%w of transaction: %d", repository.ErrDisputeNotFound, 7))
+	// the gateway disputes the whole transaction when it sends no amount
+	mockRepo.EXPECT().CreateDispute(models.Dispute{
+		TransactionID: 7, GatewayID: 10, GatewayReference: "dp_1", Amount: money.MustParse("100", "EUR"),
+		Reason: "fraudulent", Status: models.DisputeStatusOpened,
+	}, gomock.Any()).DoAndReturn(func(dispute models.Dispute, newMessage func(models.Dispute) (models.OutboxMessage, error)) (int, error) {
+		dispute.ID = 3
+		message, err := newMessage(dispute)
+		require.NoError(t, err)
+		assert.Equal(t, 7, message.TransactionID)
+		assert.Equal(t, events.TypeDisputeOpened, eventType(t, message))
+		return 3, nil
+	})
+
+	dispute, err := service.Ingest(models.DisputeNotice{
+		TransactionID: 7, GatewayID: 10, Reference: "dp_1", Status: models.DisputeStatusOpened, Reason: "fraudulent", Actor: "jsonpay",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, dispute.ID)
+	assert.Equal(t, models.DisputeStatusOpened, dispute.Status)
+}
+
+func TestIngest_LostReversesLedger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewService(mockRepo, mockTransRepo, jsonSerializer)
+
+	deposit := doneDeposit()
+	mockTransRepo.EXPECT().GetTransaction(7).Return(deposit, nil)
+	mockRepo.EXPECT().GetDisputeByTransaction(7).Return(openedDispute(), nil)
+	mockRepo.EXPECT().TransitionDispute(models.DisputeTransition{
+		DisputeID: 3, From: models.DisputeStatusOpened, To: models.DisputeStatusLost, Source: models.StatusSourceCallback,
+		Actor: "jsonpay", Reason: "callback, gateway dispute reference dp_1",
+	}, gomock.Any(), ledger.ForDispute(*deposit, *openedDispute(), models.DisputeStatusLost)).
+		DoAndReturn(func(_ models.DisputeTransition, message models.OutboxMessage, posting ledger.Posting) error {
+			assert.Equal(t, events.TypeDisputeLost, eventType(t, message))
+			assert.Equal(t, "-40.00", posting.Entry.Lines[0].Amount.String())
+			return nil
+		})
+
+	dispute, err := service.Ingest(models.DisputeNotice{
+		TransactionID: 7, GatewayID: 10, Reference: "dp_1", Status: models.DisputeStatusLost, Actor: "jsonpay",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DisputeStatusLost, dispute.Status)
+}
+
+func TestIngest_DuplicateNoticeIsNoOp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewService(mockRepo, mockTransRepo, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockRepo.EXPECT().GetDisputeByTransaction(7).Return(openedDispute(), nil)
+
+	_, err := service.Ingest(models.DisputeNotice{TransactionID: 7, GatewayID: 10, Reference: "dp_1", Status: models.DisputeStatusOpened})
+	require.NoError(t, err)
+}
+
+func TestIngest_Rejected(t *testing.T) {
+	pending := doneDeposit()
+	pending.Status = models.TransactionStatusPending
+	withdrawal := doneDeposit()
+	withdrawal.Type = models.TransactionTypeWithdrawal
+
+	tests := []struct {
+		name    string
+		tx      *models.Transaction
+		notice  models.DisputeNotice
+		wantErr error
+	}{
+		{name: "pending deposit", tx: pending, notice: models.DisputeNotice{GatewayID: 10}, wantErr: ErrNotDisputable},
+		{name: "withdrawal", tx: withdrawal, notice: models.DisputeNotice{GatewayID: 10}, wantErr: ErrNotDisputable},
+		{name: "other gateway", tx: doneDeposit(), notice: models.DisputeNotice{GatewayID: 11}, wantErr: transaction.ErrGatewayMismatch},
+		{name: "exceeds amount", tx: doneDeposit(), notice: models.DisputeNotice{GatewayID: 10, Amount: money.MustParse("100.01", "EUR")}, wantErr: ErrInvalidAmount},
+		{name: "other currency", tx: doneDeposit(), notice: models.DisputeNotice{GatewayID: 10, Amount: money.MustParse("10", "USD")}, wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockDisputeRepository(ctrl)
+			mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+			service := NewService(mockRepo, mockTransRepo, jsonSerializer)
+
+			mockTransRepo.EXPECT().GetTransaction(7).Return(tt.tx, nil)
+			mockRepo.EXPECT().GetDisputeByTransaction(7).Return(nil, repository.ErrDisputeNotFound).AnyTimes()
+
+			tt.notice.TransactionID, tt.notice.Status = 7, models.DisputeStatusOpened
+			_, err := service.Ingest(tt.notice)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewService(mockRepo, mockTransRepo, jsonSerializer)
+
+	mockRepo.EXPECT().GetDispute(3).Return(openedDispute(), nil)
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockRepo.EXPECT().TransitionDispute(models.DisputeTransition{
+		DisputeID: 3, From: models.DisputeStatusOpened, To: models.DisputeStatusEvidenceSubmitted, Source: models.StatusSourceAdmin,
+		Actor: "alice", Reason: "receipt uploaded",
+	}, gomock.Any(), ledger.Posting{}).Return(nil)
+
+	dispute, err := service.UpdateStatus(models.DisputeStatusUpdate{
+		DisputeID: 3, Status: models.DisputeStatusEvidenceSubmitted, Source: models.StatusSourceAdmin, Actor: "alice", Reason: "receipt uploaded",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DisputeStatusEvidenceSubmitted, dispute.Status)
+}
+
+func TestUpdateStatus_ConcurrentChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewService(mockRepo, mockTransRepo, jsonSerializer)
+
+	won := openedDispute()
+	won.Status = models.DisputeStatusWon
+
+	mockRepo.EXPECT().GetDispute(3).Return(openedDispute(), nil)
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockRepo.EXPECT().TransitionDispute(gomock.Any(), gomock.Any(), gomock.Any()).Return(repository.ErrStatusConflict)
+	// the gateway has decided the dispute in the meantime, a won dispute can't be lost anymore
+	mockRepo.EXPECT().GetDispute(3).Return(won, nil)
+
+	_, err := service.UpdateStatus(models.DisputeStatusUpdate{DisputeID: 3, Status: models.DisputeStatusLost, Source: models.StatusSourceAdmin})
+	assert.ErrorIs(t, err, ErrIllegalTransition)
+}
+
+func TestUpdateStatus_InvalidStatus(t *testing.T) {
+	service := NewService(nil, nil, jsonSerializer)
+
+	_, err := service.UpdateStatus(models.DisputeStatusUpdate{DisputeID: 3, Status: "closed"})
+	assert.ErrorIs(t, err, ErrInvalidStatus)
+}
+
+func TestAddEvidence(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	service := NewService(mockRepo, nil, jsonSerializer)
+
+	mockRepo.EXPECT().GetDispute(3).Return(openedDispute(), nil)
+	mockRepo.EXPECT().AddEvidence(gomock.Any()).DoAndReturn(func(evidence models.DisputeEvidence) (int, error) {
+		assert.Equal(t, "receipt.pdf", evidence.Name)
+		assert.Equal(t, []byte("%PDF"), evidence.Content)
+		return 5, nil
+	})
+
+	evidence, err := service.AddEvidence(models.DisputeEvidence{DisputeID: 3, Name: " receipt.pdf ", ContentType: "application/pdf", Content: []byte("%PDF")})
+
+	require.NoError(t, err)
+	assert.Equal(t, 5, evidence.ID)
+	assert.Equal(t, 4, evidence.Size)
+	assert.Nil(t, evidence.Content)
+}
+
+func TestAddEvidence_Rejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockDisputeRepository(ctrl)
+	service := NewService(mockRepo, nil, jsonSerializer)
+
+	_, err := service.AddEvidence(models.DisputeEvidence{DisputeID: 3, Content: []byte("%PDF")})
+	assert.ErrorIs(t, err, ErrInvalidEvidence)
+
+	_, err = service.AddEvidence(models.DisputeEvidence{DisputeID: 3, Name: "big.pdf", Content: make([]byte, MaxEvidenceSize+1)})
+	assert.ErrorIs(t, err, ErrInvalidEvidence)
+
+	lost := openedDispute()
+	lost.Status = models.DisputeStatusLost
+	mockRepo.EXPECT().GetDispute(3).Return(lost, nil)
+
+	_, err = service.AddEvidence(models.DisputeEvidence{DisputeID: 3, Name: "receipt.pdf", Content: []byte("%PDF")})
+	assert.ErrorIs(t, err, ErrDisputeClosed)
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(models.DisputeStatusOpened, models.DisputeStatusLost))
+	assert.True(t, CanTransition(models.DisputeStatusEvidenceSubmitted, models.DisputeStatusWon))
+	assert.False(t, CanTransition(models.DisputeStatusEvidenceSubmitted, models.DisputeStatusOpened))
+	assert.False(t, CanTransition(models.DisputeStatusWon, models.DisputeStatusLost))
+}
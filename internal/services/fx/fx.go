@@ -0,0 +1,117 @@
+//go:generate mockgen -source fx.go -destination mocks/fx.go -package mocks
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+const (
+	DefaultCacheTTL = 10 * time.Minute
+
+	// rateScale digits kept of the cross rate, the stored rate reproduces the converted amount
+	rateScale = 8
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+type Service interface {
+	// Convert returns the amount in the currency and the rate snapshot used for the conversion
+	Convert(ctx context.Context, amount money.Money, currency string) (*models.FXConversion, error)
+}
+
+type service struct {
+	provider RateProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	table    *RateTable
+	loadedAt time.Time
+}
+
+func NewService(provider RateProvider, ttl time.Duration) Service {
+	return &service{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+func (s *service) Convert(ctx context.Context, amount money.Money, currency string) (*models.FXConversion, error) {
+	currency = money.NormalizeCurrency(currency)
+
+	table, err := s.rates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := table.rate(amount.Currency())
+	if err != nil {
+		return nil, err
+	}
+	to, err := table.rate(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := to.Div(from, rateScale)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate %s/%s rate: %w", amount.Currency(), currency, err)
+	}
+
+	converted, err := amount.Convert(rate, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FXConversion{
+		Amount: converted,
+		Rate:   rate,
+		Source: table.Source,
+		RateAt: table.Timestamp,
+	}, nil
+}
+
+// rates returns the cached rate table, it is reloaded from the provider after ttl
+func (s *service) rates(ctx context.Context) (*RateTable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.table != nil && s.now().Sub(s.loadedAt) < s.ttl {
+		return s.table, nil
+	}
+
+	table, err := s.provider.Rates(ctx)
+	if err != nil {
+		log.Printf("Error provider.Rates: %v", err)
+		return nil, err
+	}
+
+	s.table = table
+	s.loadedAt = s.now()
+
+	return table, nil
+}
+
+// rate price of 1 base currency in the currency
+func (t *RateTable) rate(currency string) (money.Decimal, error) {
+	if currency == t.Base {
+		return money.NewDecimal(1, 0), nil
+	}
+
+	for code, rate := range t.Rates {
+		if money.NormalizeCurrency(code) == currency && rate.Sign() > 0 {
+			return rate, nil
+		}
+	}
+
+	return money.Decimal{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, t.Base, currency)
+}
@@ -0,0 +1,127 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// providerFunc stub rate provider
+type providerFunc func(ctx context.Context) (*RateTable, error)
+
+func (f providerFunc) Rates(ctx context.Context) (*RateTable, error) {
+	return f(ctx)
+}
+
+const ratesJSON = `{"base":"EUR","timestamp":"2024-03-01T12:00:00Z","rates":{"USD":1.0845,"GBP":"0.8571","JPY":162.5}}`
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(ratesJSON), 0o600))
+
+	table, err := NewFileProvider(path).Rates(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", table.Base)
+	assert.Equal(t, "file:"+path, table.Source)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), table.Timestamp)
+	assert.Equal(t, "0.8571", table.Rates["GBP"].String())
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(ratesJSON))
+	}))
+	defer server.Close()
+
+	table, err := NewHTTPProvider(server.Client(), server.URL).Rates(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, table.Source)
+	assert.Len(t, table.Rates, 3)
+}
+
+func TestHTTPProvider_ResponseTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"base":"EUR","source":"` + strings.Repeat("x", maxRatesSize) + `"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPProvider(server.Client(), server.URL).Rates(context.Background())
+	assert.ErrorContains(t, err, "response exceeds")
+}
+
+func TestConvert(t *testing.T) {
+	table, err := decodeRates([]byte(ratesJSON), "test")
+	require.NoError(t, err)
+
+	calls := 0
+	service := NewService(providerFunc(func(context.Context) (*RateTable, error) {
+		calls++
+		return table, nil
+	}), time.Minute)
+
+	tests := []struct {
+		amount   money.Money
+		currency string
+		want     money.Money
+		rate     string
+	}{
+		{amount: money.MustParse("100", "EUR"), currency: "USD", want: money.MustParse("108.45", "USD"), rate: "1.08450000"},
+		{amount: money.MustParse("108.45", "USD"), currency: "eur", want: money.MustParse("100", "EUR"), rate: "0.92208391"},
+		{amount: money.MustParse("50", "GBP"), currency: "USD", want: money.MustParse("63.27", "USD"), rate: "1.26531327"},
+		{amount: money.MustParse("1000", "JPY"), currency: "EUR", want: money.MustParse("6.15", "EUR"), rate: "0.00615385"},
+	}
+
+	for _, tt := range tests {
+		conversion, err := service.Convert(context.Background(), tt.amount, tt.currency)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, conversion.Amount)
+		assert.Equal(t, tt.rate, conversion.Rate.String())
+		assert.Equal(t, "test", conversion.Source)
+		assert.Equal(t, table.Timestamp, conversion.RateAt)
+	}
+
+	_, err = service.Convert(context.Background(), money.MustParse("1", "EUR"), "CHF")
+	assert.ErrorIs(t, err, ErrRateNotFound)
+	assert.Equal(t, 1, calls)
+}
+
+func TestConvert_CacheExpires(t *testing.T) {
+	table, err := decodeRates([]byte(ratesJSON), "test")
+	require.NoError(t, err)
+
+	responses := []*RateTable{table, nil}
+	provider := providerFunc(func(context.Context) (*RateTable, error) {
+		next := responses[0]
+		responses = responses[1:]
+		if next == nil {
+			return nil, errors.New("provider down")
+		}
+		return next, nil
+	})
+
+	now := time.Now()
+	service := NewService(provider, time.Minute).(*service)
+	service.now = func() time.Time { return now }
+
+	_, err = service.Convert(context.Background(), money.MustParse("1", "EUR"), "USD")
+	require.NoError(t, err)
+
+	now = now.Add(59 * time.Second)
+	_, err = service.Convert(context.Background(), money.MustParse("1", "EUR"), "USD")
+	require.NoError(t, err)
+
+	now = now.Add(time.Second)
+	_, err = service.Convert(context.Background(), money.MustParse("1", "EUR"), "USD")
+	assert.EqualError(t, err, "provider down")
+}
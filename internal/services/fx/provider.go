@@ -0,0 +1,113 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"payment-gateway/internal/money"
+)
+
+// RateTable exchange rates against one base currency, Rates["USD"] is the price of 1 Base in USD
+type RateTable struct {
+	Base      string                   `json:"base"`
+	Rates     map[string]money.Decimal `json:"rates"`
+	Timestamp time.Time                `json:"timestamp"`
+	// Source where the rates come from, stored on the converted transactions
+	Source string `json:"source"`
+}
+
+type RateProvider interface {
+	Rates(ctx context.Context) (*RateTable, error)
+}
+
+type fileProvider struct {
+	path string
+}
+
+// NewFileProvider reads rates from a JSON file:
+//
+//	{"base": "EUR", "timestamp": "2024-01-02T15:04:05Z", "rates": {"USD": 1.0845, "GBP": 0.8571}}
+func NewFileProvider(path string) RateProvider {
+	return &fileProvider{
+		path: path,
+	}
+}
+
+func (p *fileProvider) Rates(_ context.Context) (*RateTable, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	return decodeRates(data, "file:"+p.path)
+}
+
+// maxRatesSize far more than a rate table of every currency
+const maxRatesSize = 1 << 20
+
+type httpProvider struct {
+	client *http.Client
+	url    string
+}
+
+// NewHTTPProvider fetches rates in the file provider format with GET url
+func NewHTTPProvider(client *http.Client, url string) RateProvider {
+	return &httpProvider{
+		client: client,
+		url:    url,
+	}
+}
+
+func (p *httpProvider) Rates(ctx context.Context) (*RateTable, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch rates: status %d", resp.StatusCode)
+	}
+
+	// one byte over the limit tells a rate table that is too large from one that fits exactly
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRatesSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates: %w", err)
+	}
+	if len(data) > maxRatesSize {
+		return nil, fmt.Errorf("failed to read rates: response exceeds %d bytes", maxRatesSize)
+	}
+
+	return decodeRates(data, p.url)
+}
+
+func decodeRates(data []byte, source string) (*RateTable, error) {
+	var table RateTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to decode rates: %w", err)
+	}
+
+	if !money.IsValidCurrency(table.Base) {
+		return nil, fmt.Errorf("invalid rates base currency %q", table.Base)
+	}
+	table.Base = money.NormalizeCurrency(table.Base)
+
+	if table.Source == "" {
+		table.Source = source
+	}
+	if table.Timestamp.IsZero() {
+		table.Timestamp = time.Now()
+	}
+
+	return &table, nil
+}
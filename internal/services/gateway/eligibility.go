@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"payment-gateway/internal/models"
+)
+
+// EligibilityRule reports whether the gateway can process the transaction described by the routing context
+type EligibilityRule func(gw models.Gateway, rc models.RoutingContext) bool
+
+// DefaultEligibilityRules gateway must support the transaction type, the currency and the amount
+var DefaultEligibilityRules = []EligibilityRule{
+	SupportsType,
+	SupportsCurrency,
+	WithinAmountLimits,
+}
+
+func SupportsType(gw models.Gateway, rc models.RoutingContext) bool {
+	if len(gw.TransactionTypes) == 0 {
+		return true
+	}
+	for _, t := range gw.TransactionTypes {
+		if t == rc.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsCurrency gateway must have the currency in gateway_currencies
+func SupportsCurrency(gw models.Gateway, rc models.RoutingContext) bool {
+	_, ok := gw.Currency(rc.Amount.Currency())
+	return ok
+}
+
+// WithinAmountLimits amount must be within the limits of the gateway in the transaction currency
+func WithinAmountLimits(gw models.Gateway, rc models.RoutingContext) bool {
+	limits, ok := gw.Currency(rc.Amount.Currency())
+	if !ok {
+		return false
+	}
+	amount := rc.Amount.Decimal()
+	if limits.MinAmount.Sign() > 0 && amount.Cmp(limits.MinAmount) < 0 {
+		return false
+	}
+	if limits.MaxAmount.Sign() > 0 && amount.Cmp(limits.MaxAmount) > 0 {
+		return false
+	}
+	return true
+}
+
+func eligible(gw models.Gateway, rc models.RoutingContext, rules []EligibilityRule) bool {
+	for _, rule := range rules {
+		if !rule(gw, rc) {
+			return false
+		}
+	}
+	return true
+}
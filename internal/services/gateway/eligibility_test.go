@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"testing"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEligibility(t *testing.T) {
+	gw := models.Gateway{
+		Currencies: []models.GatewayCurrency{
+			{Currency: "EUR", MinAmount: money.MustParseDecimal("10"), MaxAmount: money.MustParseDecimal("1000")},
+			{Currency: "USD", MinAmount: money.MustParseDecimal("1000"), MaxAmount: money.MustParseDecimal("5000")},
+		},
+		TransactionTypes: []string{models.TransactionTypeDeposit},
+	}
+	rc := models.RoutingContext{CountryID: 1, Amount: money.MustParse("50", "eur"), Type: models.TransactionTypeDeposit}
+
+	tests := []struct {
+		name   string
+		modify func(gw *models.Gateway, rc *models.RoutingContext)
+		want   bool
+	}{
+		{name: "eligible", modify: func(*models.Gateway, *models.RoutingContext) {}, want: true},
+		{name: "below min amount", modify: func(_ *models.Gateway, rc *models.RoutingContext) { rc.Amount = money.MustParse("9.99", "EUR") }, want: false},
+		{name: "above max amount", modify: func(_ *models.Gateway, rc *models.RoutingContext) { rc.Amount = money.MustParse("1000.01", "EUR") }, want: false},
+		{name: "no limits", modify: func(gw *models.Gateway, rc *models.RoutingContext) {
+			gw.Currencies = []models.GatewayCurrency{{Currency: "EUR"}}
+			rc.Amount = money.MustParse("1000000", "EUR")
+		}, want: true},
+		{name: "limits of the currency", modify: func(_ *models.Gateway, rc *models.RoutingContext) { rc.Amount = money.MustParse("50", "USD") }, want: false},
+		{name: "unsupported currency", modify: func(_ *models.Gateway, rc *models.RoutingContext) { rc.Amount = money.MustParse("50", "GBP") }, want: false},
+		{name: "no currencies", modify: func(gw *models.Gateway, _ *models.RoutingContext) { gw.Currencies = nil }, want: false},
+		{name: "unsupported type", modify: func(_ *models.Gateway, rc *models.RoutingContext) {
+			rc.Type = models.TransactionTypeWithdrawal
+		}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, rc := gw, rc
+			tt.modify(&gw, &rc)
+			assert.Equal(t, tt.want, eligible(gw, rc, DefaultEligibilityRules))
+		})
+	}
+}
@@ -3,13 +3,17 @@
 package gateway
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"log"
 
+	"payment-gateway/internal/adapters"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
 	"payment-gateway/internal/repository"
 	"payment-gateway/internal/util"
+
+	"github.com/sony/gobreaker"
 )
 
 const (
@@ -18,57 +22,191 @@ const (
 )
 
 type ServiceGateway interface {
-	GetGateway(countryID int) (*models.Gateway, error)
-	Deposit(req models.Transaction) error
-	Withdrawal(req models.Transaction) error
+	GetGateway(rc models.RoutingContext) (*models.Gateway, error)
+	// GetGateways returns gateways able to process the transaction in the failover order
+	GetGateways(rc models.RoutingContext) ([]models.Gateway, error)
+	Deposit(gw *models.Gateway, req models.Transaction) (*adapters.Response, error)
+	Withdrawal(gw *models.Gateway, req models.Transaction) (*adapters.Response, error)
+	// GetGatewayByID returns the gateway a transaction has been processed by, refunds go back to it
+	GetGatewayByID(id int) (*models.Gateway, error)
+	Refund(gw *models.Gateway, refund, original models.Transaction) (*adapters.Response, error)
+	Authorize(gw *models.Gateway, req models.Transaction) (*adapters.Response, error)
+	Capture(gw *models.Gateway, capture, authorization models.Transaction) (*adapters.Response, error)
+	Void(gw *models.Gateway, authorization models.Transaction) (*adapters.Response, error)
+	// Status asks the gateway for the status of the transaction it has not reported yet
+	Status(ctx context.Context, gw *models.Gateway, tx models.Transaction) (*adapters.Response, error)
 }
 
 type serviceGateway struct {
 	gatewayRepo repository.GatewayRepository
+	routingRepo repository.RoutingRepository
+	adapters    *adapters.Registry
+	health      HealthChecker
+	strategies  map[string]RoutingStrategy
+	rules       []EligibilityRule
 }
 
-func NewServiceGateway(gatewayRepo repository.GatewayRepository) ServiceGateway {
+func NewServiceGateway(
+	gatewayRepo repository.GatewayRepository,
+	routingRepo repository.RoutingRepository,
+	registry *adapters.Registry,
+	health HealthChecker,
+	strategies map[string]RoutingStrategy,
+) ServiceGateway {
 	return &serviceGateway{
 		gatewayRepo: gatewayRepo,
+		routingRepo: routingRepo,
+		adapters:    registry,
+		health:      health,
+		strategies:  strategies,
+		rules:       DefaultEligibilityRules,
 	}
 }
 
-func (s *serviceGateway) GetGateway(countryID int) (*models.Gateway, error) {
-	gateways, err := s.gatewayRepo.GetAvailableGateways(countryID)
-	if err != nil || len(gateways) == 0 {
+func (s *serviceGateway) GetGateway(rc models.RoutingContext) (*models.Gateway, error) {
+	gateways, err := s.GetGateways(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gateways[0], nil
+}
+
+func (s *serviceGateway) GetGateways(rc models.RoutingContext) ([]models.Gateway, error) {
+	gateways, err := s.gatewayRepo.GetAvailableGateways(rc.CountryID)
+	if err != nil {
 		log.Printf("Error repo.GetAvailableGateways: %v", err)
 		return nil, errors.New(GatewayError)
 	}
 
+	candidates := make([]models.Gateway, 0, len(gateways))
 	for i := range gateways {
-		if s.ping() {
-			return &gateways[i], nil
+		if eligible(gateways[i], rc, s.rules) {
+			candidates = append(candidates, gateways[i])
+		}
+	}
+
+	if len(candidates) == 0 {
+		log.Printf("No eligible gateway for country=%d currency=%s amount=%s type=%s", rc.CountryID, rc.Amount.Currency(), rc.Amount, rc.Type)
+		return nil, errors.New(GatewayError)
+	}
+
+	available := make([]models.Gateway, 0, len(candidates))
+	for i := range candidates {
+		if s.health.Available(candidates[i]) {
+			available = append(available, candidates[i])
 		}
 	}
 
-	return nil, errors.New(gatewayErrPing)
+	if len(available) == 0 {
+		return nil, errors.New(gatewayErrPing)
+	}
+
+	return s.order(rc, available), nil
+}
+
+// order applies the routing strategy configured for the country,
+// priority order is kept when the strategy can't be applied
+func (s *serviceGateway) order(rc models.RoutingContext, gateways []models.Gateway) []models.Gateway {
+	config, err := s.routingRepo.GetRoutingConfig(rc.CountryID)
+	if err != nil {
+		log.Printf("Error repo.GetRoutingConfig: %v", err)
+		return gateways
+	}
+
+	strategy, ok := s.strategies[config.Strategy]
+	if !ok {
+		log.Printf("Unknown routing strategy %q for country %d", config.Strategy, rc.CountryID)
+		return gateways
+	}
+
+	ordered, err := strategy.Order(gateways, rc, config)
+	if err != nil {
+		log.Printf("Error routing strategy %s: %v", config.Strategy, err)
+		return gateways
+	}
+
+	return ordered
+}
+
+func (s *serviceGateway) Deposit(gw *models.Gateway, req models.Transaction) (*adapters.Response, error) {
+	return s.call(context.Background(), gw, "Deposit", req.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Deposit(ctx, *gw, req)
+	})
+}
+
+func (s *serviceGateway) Withdrawal(gw *models.Gateway, req models.Transaction) (*adapters.Response, error) {
+	return s.call(context.Background(), gw, "Withdrawal", req.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Withdrawal(ctx, *gw, req)
+	})
+}
+
+func (s *serviceGateway) GetGatewayByID(id int) (*models.Gateway, error) {
+	gw, err := s.gatewayRepo.GetGatewayByID(id)
+	if err != nil {
+		log.Printf("Error repo.GetGatewayByID: %v", err)
+		return nil, err
+	}
+
+	return &gw, nil
+}
+
+func (s *serviceGateway) Refund(gw *models.Gateway, refund, original models.Transaction) (*adapters.Response, error) {
+	return s.call(context.Background(), gw, "Refund", refund.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Refund(ctx, *gw, refund, original)
+	})
 }
 
-func (s *serviceGateway) Deposit(req models.Transaction) error {
-	// external request to Gateway here
-	amount := util.MaskData([]byte(fmt.Sprintf("%.2f", req.Amount)))
+func (s *serviceGateway) Authorize(gw *models.Gateway, req models.Transaction) (*adapters.Response, error) {
+	return s.call(context.Background(), gw, "Authorize", req.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Authorize(ctx, *gw, req)
+	})
+}
 
-	log.Printf("Gateway Deposit succes with amount: %v", amount)
+func (s *serviceGateway) Capture(gw *models.Gateway, capture, authorization models.Transaction) (*adapters.Response, error) {
+	return s.call(context.Background(), gw, "Capture", capture.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Capture(ctx, *gw, capture, authorization)
+	})
+}
+
+func (s *serviceGateway) Void(gw *models.Gateway, authorization models.Transaction) (*adapters.Response, error) {
+	return s.call(context.Background(), gw, "Void", authorization.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Void(ctx, *gw, authorization)
+	})
+}
 
-	return nil
+func (s *serviceGateway) Status(ctx context.Context, gw *models.Gateway, tx models.Transaction) (*adapters.Response, error) {
+	return s.call(ctx, gw, "Status", tx.Amount, func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error) {
+		return adapter.Status(ctx, *gw, tx)
+	})
 }
 
-func (s *serviceGateway) Withdrawal(req models.Transaction) error {
-	// external request to Gateway here
-	amount := util.MaskData([]byte(fmt.Sprintf("%.2f", req.Amount)))
+// call runs the operation with the adapter of the gateway behind its circuit breaker
+func (s *serviceGateway) call(ctx context.Context, gw *models.Gateway, operation string, amount money.Money,
+	fn func(ctx context.Context, adapter adapters.GatewayAdapter) (*adapters.Response, error)) (*adapters.Response, error) {
+	adapter, err := s.adapters.Get(gw.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.health.Execute(*gw, func() (*adapters.Response, error) {
+		return fn(ctx, adapter)
+	})
+	if err != nil {
+		log.Printf("Error adapter.%s gateway=%s: %v", operation, gw.Name, err)
+		return nil, err
+	}
 
-	log.Printf("Gateway Withdrawal succes with amount: %v", amount)
-	return nil
+	masked := util.MaskData([]byte(amount.String()))
+	log.Printf("Gateway %s %s status %s with amount: %v", gw.Name, operation, resp.Status, masked)
+
+	return resp, nil
 }
 
-// ping check gateway
-func (s *serviceGateway) ping() bool {
-	// Here you could implement an HTTP request to check the external gateway's health.
-	// For now, we assume the gateway is always healthy.
-	return true
+// IsRetryable reports whether the failed gateway call may be routed to the next gateway
+func IsRetryable(err error) bool {
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return true
+	}
+	return adapters.IsRetryable(err)
 }
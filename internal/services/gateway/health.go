@@ -0,0 +1,225 @@
+//go:generate mockgen -source health.go -destination mocks/health.go -package mocks
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+
+	"github.com/sony/gobreaker"
+)
+
+const (
+	DefaultProbeInterval = 15 * time.Second
+	probeTimeout         = 3 * time.Second
+	healthPath           = "/health"
+)
+
+// HealthChecker probes gateways in the background and keeps circuit breaker per gateway
+type HealthChecker interface {
+	// Run probes gateways every interval until ctx is done
+	Run(ctx context.Context)
+	// Available reports whether the gateway breaker is not open and its last probe succeeded
+	Available(gw models.Gateway) bool
+	// Execute runs the gateway call through the gateway circuit breaker
+	Execute(gw models.Gateway, call func() (*adapters.Response, error)) (*adapters.Response, error)
+	Statuses() []models.GatewayHealth
+}
+
+type probeResult struct {
+	name      string
+	healthy   bool
+	err       string
+	checkedAt time.Time
+	latency   time.Duration
+}
+
+type healthChecker struct {
+	gatewayRepo repository.GatewayRepository
+	client      *http.Client
+	interval    time.Duration
+
+	mu       sync.RWMutex
+	breakers map[int]*gobreaker.CircuitBreaker
+	probes   map[int]probeResult
+}
+
+func NewHealthChecker(gatewayRepo repository.GatewayRepository, client *http.Client, interval time.Duration) HealthChecker {
+	return &healthChecker{
+		gatewayRepo: gatewayRepo,
+		client:      client,
+		interval:    interval,
+		breakers:    make(map[int]*gobreaker.CircuitBreaker),
+		probes:      make(map[int]probeResult),
+	}
+}
+
+func (h *healthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.probeAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthChecker) Available(gw models.Gateway) bool {
+	if h.breaker(gw).State() == gobreaker.StateOpen {
+		return false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	// gateway which was not probed yet is considered healthy
+	probe, ok := h.probes[gw.ID]
+	return !ok || probe.healthy
+}
+
+func (h *healthChecker) Execute(gw models.Gateway, call func() (*adapters.Response, error)) (*adapters.Response, error) {
+	resp, err := h.breaker(gw).Execute(func() (interface{}, error) {
+		return call()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*adapters.Response), nil
+}
+
+func (h *healthChecker) Statuses() []models.GatewayHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	statuses := make([]models.GatewayHealth, 0, len(h.probes))
+	for id, probe := range h.probes {
+		state := gobreaker.StateClosed
+		if cb, ok := h.breakers[id]; ok {
+			state = cb.State()
+		}
+
+		statuses = append(statuses, models.GatewayHealth{
+			GatewayID:     id,
+			Name:          probe.name,
+			Healthy:       probe.healthy && state != gobreaker.StateOpen,
+			BreakerState:  state.String(),
+			LastCheckedAt: probe.checkedAt,
+			LastError:     probe.err,
+			LatencyMs:     probe.latency.Milliseconds(),
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].GatewayID < statuses[j].GatewayID
+	})
+
+	return statuses
+}
+
+func (h *healthChecker) probeAll(ctx context.Context) {
+	gateways, err := h.gatewayRepo.GetGateways()
+	if err != nil {
+		log.Printf("Error repo.GetGateways: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, gw := range gateways {
+		if gw.Status != models.GatewayStatusActive {
+			continue
+		}
+
+		wg.Add(1)
+		go func(gw models.Gateway) {
+			defer wg.Done()
+			h.probe(ctx, gw)
+		}(gw)
+	}
+	wg.Wait()
+}
+
+func (h *healthChecker) probe(ctx context.Context, gw models.Gateway) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	started := time.Now()
+	err := adapters.Probe(ctx, h.client, healthURL(gw))
+
+	result := probeResult{
+		name:      gw.Name,
+		healthy:   err == nil,
+		checkedAt: time.Now(),
+		latency:   time.Since(started),
+	}
+	if err != nil {
+		result.err = err.Error()
+		log.Printf("Gateway %s health probe failed: %v", gw.Name, err)
+	}
+
+	h.mu.Lock()
+	h.probes[gw.ID] = result
+	h.mu.Unlock()
+}
+
+func (h *healthChecker) breaker(gw models.Gateway) *gobreaker.CircuitBreaker {
+	h.mu.RLock()
+	cb, ok := h.breakers[gw.ID]
+	h.mu.RUnlock()
+	if ok {
+		return cb
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cb, ok = h.breakers[gw.ID]; !ok {
+		cb = gobreaker.NewCircuitBreaker(breakerSettings(gw.Name))
+		h.breakers[gw.ID] = cb
+	}
+	return cb
+}
+
+func breakerSettings(name string) gobreaker.Settings {
+	return gobreaker.Settings{
+		Name:        "Gateway:" + name,
+		MaxRequests: 1,
+		Interval:    30 * time.Second,
+		Timeout:     15 * time.Second,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures > 3
+		},
+		IsSuccessful: func(err error) bool {
+			// 4xx answers mean the gateway is up and rejected our request, local errors and calls cancelled
+			// by our side don't count against the gateway either
+			if err == nil || errors.Is(err, context.Canceled) {
+				return true
+			}
+			return !adapters.IsGatewayFailure(err)
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			log.Printf("Circuit breaker %s changed state from %s to %s", name, from, to)
+		},
+	}
+}
+
+func healthURL(gw models.Gateway) string {
+	if gw.HealthURL != "" {
+		return gw.HealthURL
+	}
+	return strings.TrimRight(gw.BaseURL, "/") + healthPath
+}
@@ -0,0 +1,153 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecker_Probe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, healthPath, r.URL.Path)
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	gateways := []models.Gateway{
+		{ID: 1, Name: "up", BaseURL: up.URL, Status: models.GatewayStatusActive},
+		{ID: 2, Name: "down", BaseURL: down.URL, Status: models.GatewayStatusActive},
+		{ID: 3, Name: "disabled", BaseURL: down.URL, Status: models.GatewayStatusInactive},
+	}
+
+	mockRepo := mocks.NewMockGatewayRepository(ctrl)
+	mockRepo.EXPECT().GetGateways().Return(gateways, nil)
+
+	checker := NewHealthChecker(mockRepo, adapters.NewHTTPClient(time.Second), time.Minute).(*healthChecker)
+	checker.probeAll(context.Background())
+
+	assert.True(t, checker.Available(gateways[0]))
+	assert.False(t, checker.Available(gateways[1]))
+	// not probed gateways are considered healthy
+	assert.True(t, checker.Available(gateways[2]))
+
+	statuses := checker.Statuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "up", statuses[0].Name)
+	assert.True(t, statuses[0].Healthy)
+	assert.Equal(t, "down", statuses[1].Name)
+	assert.False(t, statuses[1].Healthy)
+	assert.NotEmpty(t, statuses[1].LastError)
+}
+
+func TestHealthChecker_BreakerOpens(t *testing.T) {
+	checker := NewHealthChecker(nil, nil, time.Minute)
+	gw := models.Gateway{ID: 1, Name: "flaky"}
+
+	for i := 0; i < 3; i++ {
+		_, err := checker.Execute(gw, func() (*adapters.Response, error) {
+			return nil, &adapters.ProviderError{StatusCode: http.StatusBadGateway}
+		})
+		require.Error(t, err)
+	}
+	assert.True(t, checker.Available(gw))
+
+	_, err := checker.Execute(gw, func() (*adapters.Response, error) {
+		return nil, adapters.ErrTimeout
+	})
+	require.Error(t, err)
+	assert.False(t, checker.Available(gw))
+
+	_, err = checker.Execute(gw, func() (*adapters.Response, error) {
+		t.Fatal("call must not reach the gateway while breaker is open")
+		return nil, nil
+	})
+	assert.True(t, errors.Is(err, gobreaker.ErrOpenState))
+}
+
+func TestHealthChecker_ClientErrorsKeepBreakerClosed(t *testing.T) {
+	checker := NewHealthChecker(nil, nil, time.Minute)
+	gw := models.Gateway{ID: 1, Name: "strict"}
+
+	localErrors := []error{
+		&adapters.ProviderError{StatusCode: http.StatusUnprocessableEntity},
+		fmt.Errorf("%w: transaction 7", adapters.ErrMissingReference),
+		fmt.Errorf("%w: %q", adapters.ErrUnknownStatus, "on_hold"),
+		errors.New("failed to decode gateway response: unexpected EOF"),
+		fmt.Errorf("%w: %w", adapters.ErrTransport, context.Canceled),
+	}
+	for i := 0; i < 10; i++ {
+		for _, localErr := range localErrors {
+			_, _ = checker.Execute(gw, func() (*adapters.Response, error) {
+				return nil, localErr
+			})
+		}
+	}
+	assert.True(t, checker.Available(gw))
+}
+
+func TestHealthChecker_UnreachableGatewayOpensBreaker(t *testing.T) {
+	checker := NewHealthChecker(nil, nil, time.Minute)
+	gw := models.Gateway{ID: 1, Name: "gone"}
+
+	for i := 0; i < 4; i++ {
+		_, _ = checker.Execute(gw, func() (*adapters.Response, error) {
+			return nil, fmt.Errorf("%w: dial tcp: connection refused", adapters.ErrTransport)
+		})
+	}
+	assert.False(t, checker.Available(gw))
+}
+
+func TestGetGateway_SkipsUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	gateways := []models.Gateway{
+		{ID: 1, Name: "primary", BaseURL: down.URL, Status: models.GatewayStatusActive, Priority: 1, Currencies: []models.GatewayCurrency{{Currency: "EUR"}}},
+		{ID: 2, Name: "secondary", BaseURL: down.URL, Status: models.GatewayStatusActive, Priority: 2, Currencies: []models.GatewayCurrency{{Currency: "EUR"}}},
+	}
+
+	mockRepo := mocks.NewMockGatewayRepository(ctrl)
+	mockRepo.EXPECT().GetGateways().Return(gateways[:1], nil)
+	mockRepo.EXPECT().GetAvailableGateways(5).Return(gateways, nil).Times(2)
+	mockRoutingRepo := mocks.NewMockRoutingRepository(ctrl)
+	mockRoutingRepo.EXPECT().GetRoutingConfig(5).Return(models.RoutingConfig{Strategy: models.RoutingStrategyPriority}, nil).Times(2)
+
+	rc := models.RoutingContext{CountryID: 5, Amount: money.MustParse("10", "EUR"), Type: models.TransactionTypeDeposit}
+	checker := NewHealthChecker(mockRepo, adapters.NewHTTPClient(time.Second), time.Minute)
+	service := NewServiceGateway(mockRepo, mockRoutingRepo, adapters.NewRegistry(), checker, DefaultStrategies(nil))
+
+	gw, err := service.GetGateway(rc)
+	require.NoError(t, err)
+	assert.Equal(t, 1, gw.ID)
+
+	checker.(*healthChecker).probeAll(context.Background())
+
+	gw, err = service.GetGateway(rc)
+	require.NoError(t, err)
+	assert.Equal(t, 2, gw.ID)
+}
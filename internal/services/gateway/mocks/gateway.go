@@ -5,6 +5,8 @@
 package mocks
 
 import (
+	context "context"
+	adapters "payment-gateway/internal/adapters"
 	models "payment-gateway/internal/models"
 	reflect "reflect"
 
@@ -34,45 +36,152 @@ func (m *MockServiceGateway) EXPECT() *MockServiceGatewayMockRecorder {
 	return m.recorder
 }
 
+// Authorize mocks base method.
+func (m *MockServiceGateway) Authorize(gw *models.Gateway, req models.Transaction) (*adapters.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", gw, req)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockServiceGatewayMockRecorder) Authorize(gw, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockServiceGateway)(nil).Authorize), gw, req)
+}
+
+// Capture mocks base method.
+func (m *MockServiceGateway) Capture(gw *models.Gateway, capture, authorization models.Transaction) (*adapters.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capture", gw, capture, authorization)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Capture indicates an expected call of Capture.
+func (mr *MockServiceGatewayMockRecorder) Capture(gw, capture, authorization interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capture", reflect.TypeOf((*MockServiceGateway)(nil).Capture), gw, capture, authorization)
+}
+
 // Deposit mocks base method.
-func (m *MockServiceGateway) Deposit(req models.Transaction) error {
+func (m *MockServiceGateway) Deposit(gw *models.Gateway, req models.Transaction) (*adapters.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Deposit", req)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Deposit", gw, req)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Deposit indicates an expected call of Deposit.
-func (mr *MockServiceGatewayMockRecorder) Deposit(req interface{}) *gomock.Call {
+func (mr *MockServiceGatewayMockRecorder) Deposit(gw, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockServiceGateway)(nil).Deposit), req)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockServiceGateway)(nil).Deposit), gw, req)
 }
 
 // GetGateway mocks base method.
-func (m *MockServiceGateway) GetGateway(countryID int) (*models.Gateway, error) {
+func (m *MockServiceGateway) GetGateway(rc models.RoutingContext) (*models.Gateway, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetGateway", countryID)
+	ret := m.ctrl.Call(m, "GetGateway", rc)
 	ret0, _ := ret[0].(*models.Gateway)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetGateway indicates an expected call of GetGateway.
-func (mr *MockServiceGatewayMockRecorder) GetGateway(countryID interface{}) *gomock.Call {
+func (mr *MockServiceGatewayMockRecorder) GetGateway(rc interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGateway", reflect.TypeOf((*MockServiceGateway)(nil).GetGateway), countryID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGateway", reflect.TypeOf((*MockServiceGateway)(nil).GetGateway), rc)
+}
+
+// GetGatewayByID mocks base method.
+func (m *MockServiceGateway) GetGatewayByID(id int) (*models.Gateway, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGatewayByID", id)
+	ret0, _ := ret[0].(*models.Gateway)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGatewayByID indicates an expected call of GetGatewayByID.
+func (mr *MockServiceGatewayMockRecorder) GetGatewayByID(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGatewayByID", reflect.TypeOf((*MockServiceGateway)(nil).GetGatewayByID), id)
+}
+
+// GetGateways mocks base method.
+func (m *MockServiceGateway) GetGateways(rc models.RoutingContext) ([]models.Gateway, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGateways", rc)
+	ret0, _ := ret[0].([]models.Gateway)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGateways indicates an expected call of GetGateways.
+func (mr *MockServiceGatewayMockRecorder) GetGateways(rc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGateways", reflect.TypeOf((*MockServiceGateway)(nil).GetGateways), rc)
+}
+
+// Refund mocks base method.
+func (m *MockServiceGateway) Refund(gw *models.Gateway, refund, original models.Transaction) (*adapters.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refund", gw, refund, original)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refund indicates an expected call of Refund.
+func (mr *MockServiceGatewayMockRecorder) Refund(gw, refund, original interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refund", reflect.TypeOf((*MockServiceGateway)(nil).Refund), gw, refund, original)
+}
+
+// Status mocks base method.
+func (m *MockServiceGateway) Status(ctx context.Context, gw *models.Gateway, tx models.Transaction) (*adapters.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", ctx, gw, tx)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockServiceGatewayMockRecorder) Status(ctx, gw, tx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockServiceGateway)(nil).Status), ctx, gw, tx)
+}
+
+// Void mocks base method.
+func (m *MockServiceGateway) Void(gw *models.Gateway, authorization models.Transaction) (*adapters.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Void", gw, authorization)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Void indicates an expected call of Void.
+func (mr *MockServiceGatewayMockRecorder) Void(gw, authorization interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Void", reflect.TypeOf((*MockServiceGateway)(nil).Void), gw, authorization)
 }
 
 // Withdrawal mocks base method.
-func (m *MockServiceGateway) Withdrawal(req models.Transaction) error {
+func (m *MockServiceGateway) Withdrawal(gw *models.Gateway, req models.Transaction) (*adapters.Response, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Withdrawal", req)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Withdrawal", gw, req)
+	ret0, _ := ret[0].(*adapters.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Withdrawal indicates an expected call of Withdrawal.
-func (mr *MockServiceGatewayMockRecorder) Withdrawal(req interface{}) *gomock.Call {
+func (mr *MockServiceGatewayMockRecorder) Withdrawal(gw, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdrawal", reflect.TypeOf((*MockServiceGateway)(nil).Withdrawal), req)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdrawal", reflect.TypeOf((*MockServiceGateway)(nil).Withdrawal), gw, req)
 }
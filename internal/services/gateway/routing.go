@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+)
+
+// RoutingStrategy orders the available gateways, the first gateway gets the transaction
+// and the rest are used for failover
+type RoutingStrategy interface {
+	Order(gateways []models.Gateway, rc models.RoutingContext, config models.RoutingConfig) ([]models.Gateway, error)
+}
+
+// DefaultStrategies returns the strategies by the names stored in country_routing.strategy
+func DefaultStrategies(attemptRepo repository.AttemptRepository) map[string]RoutingStrategy {
+	return map[string]RoutingStrategy{
+		models.RoutingStrategyPriority:    PriorityStrategy{},
+		models.RoutingStrategyWeighted:    NewWeightedStrategy(rand.NewSource(time.Now().UnixNano())),
+		models.RoutingStrategyLowestFee:   LowestFeeStrategy{},
+		models.RoutingStrategySuccessRate: NewSuccessRateStrategy(attemptRepo),
+	}
+}
+
+// PriorityStrategy strict gateways.priority order
+type PriorityStrategy struct{}
+
+func (PriorityStrategy) Order(gateways []models.Gateway, _ models.RoutingContext, _ models.RoutingConfig) ([]models.Gateway, error) {
+	ordered := append([]models.Gateway(nil), gateways...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority < ordered[j].Priority
+	})
+	return ordered, nil
+}
+
+// WeightedStrategy picks the gateways at random in proportion to gateway_countries.weight,
+// gateways with zero weight go last in priority order
+type WeightedStrategy struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func NewWeightedStrategy(src rand.Source) *WeightedStrategy {
+	return &WeightedStrategy{
+		rnd: rand.New(src),
+	}
+}
+
+func (w *WeightedStrategy) Order(gateways []models.Gateway, rc models.RoutingContext, config models.RoutingConfig) ([]models.Gateway, error) {
+	byPriority, _ := PriorityStrategy{}.Order(gateways, rc, config)
+
+	var (
+		weighted []models.Gateway
+		rest     []models.Gateway
+		total    int
+	)
+	for _, gw := range byPriority {
+		if gw.Weight > 0 {
+			weighted = append(weighted, gw)
+			total += gw.Weight
+		} else {
+			rest = append(rest, gw)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ordered := make([]models.Gateway, 0, len(gateways))
+	for len(weighted) > 0 {
+		pick := w.rnd.Intn(total)
+		for i, gw := range weighted {
+			if pick < gw.Weight {
+				ordered = append(ordered, gw)
+				total -= gw.Weight
+				weighted = append(weighted[:i], weighted[i+1:]...)
+				break
+			}
+			pick -= gw.Weight
+		}
+	}
+
+	return append(ordered, rest...), nil
+}
+
+// LowestFeeStrategy gateway with the lowest fee for the transaction amount first
+type LowestFeeStrategy struct{}
+
+func (LowestFeeStrategy) Order(gateways []models.Gateway, rc models.RoutingContext, config models.RoutingConfig) ([]models.Gateway, error) {
+	ordered, _ := PriorityStrategy{}.Order(gateways, rc, config)
+
+	fees := make(map[int]money.Decimal, len(ordered))
+	for _, gw := range ordered {
+		f, err := fee(gw, rc.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute the fee of gateway %s: %w", gw.Name, err)
+		}
+		fees[gw.ID] = f
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return fees[ordered[i].ID].Cmp(fees[ordered[j].ID]) < 0
+	})
+	return ordered, nil
+}
+
+// fee exact fee of the gateway for the amount with the fixed fee in the amount currency, it is not rounded
+// to the currency so that close fees are still told apart
+func fee(gw models.Gateway, amount money.Money) (money.Decimal, error) {
+	value := amount.Decimal()
+	percent, err := value.Mul(gw.FeePercent, value.Scale()+gw.FeePercent.Scale())
+	if err != nil {
+		return money.Decimal{}, err
+	}
+	percent, err = percent.Div(money.NewDecimal(100, 0), percent.Scale()+2)
+	if err != nil {
+		return money.Decimal{}, err
+	}
+
+	terms, _ := gw.Currency(amount.Currency())
+	return percent.Add(terms.FeeFixed)
+}
+
+// SuccessRateStrategy gateway with the highest share of succeeded attempts over the configured window first
+type SuccessRateStrategy struct {
+	attemptRepo repository.AttemptRepository
+	now         func() time.Time
+}
+
+func NewSuccessRateStrategy(attemptRepo repository.AttemptRepository) *SuccessRateStrategy {
+	return &SuccessRateStrategy{
+		attemptRepo: attemptRepo,
+		now:         time.Now,
+	}
+}
+
+func (s *SuccessRateStrategy) Order(gateways []models.Gateway, rc models.RoutingContext, config models.RoutingConfig) ([]models.Gateway, error) {
+	ids := make([]int, 0, len(gateways))
+	for _, gw := range gateways {
+		ids = append(ids, gw.ID)
+	}
+
+	stats, err := s.attemptRepo.GetSuccessStats(ids, s.now().Add(-config.SuccessRateWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gateway success rates: %w", err)
+	}
+
+	ordered, _ := PriorityStrategy{}.Order(gateways, rc, config)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return successRate(stats[ordered[i].ID]) > successRate(stats[ordered[j].ID])
+	})
+	return ordered, nil
+}
+
+// successRate is smoothed so gateways without traffic start at 0.5 instead of the extremes
+func successRate(stat models.GatewayStats) float64 {
+	return float64(stat.Succeeded+1) / float64(stat.Total+2)
+}
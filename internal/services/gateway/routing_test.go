@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ids(gateways []models.Gateway) []int {
+	result := make([]int, 0, len(gateways))
+	for _, gw := range gateways {
+		result = append(result, gw.ID)
+	}
+	return result
+}
+
+func TestPriorityStrategy(t *testing.T) {
+	gateways := []models.Gateway{{ID: 1, Priority: 3}, {ID: 2, Priority: 1}, {ID: 3, Priority: 2}}
+
+	ordered, err := PriorityStrategy{}.Order(gateways, models.RoutingContext{}, models.RoutingConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 1}, ids(ordered))
+	assert.Equal(t, 1, gateways[0].ID, "input must not be reordered")
+}
+
+func TestWeightedStrategy_Split(t *testing.T) {
+	gateways := []models.Gateway{
+		{ID: 1, Priority: 1, Weight: 70},
+		{ID: 2, Priority: 2, Weight: 30},
+		{ID: 3, Priority: 3, Weight: 0},
+	}
+	strategy := NewWeightedStrategy(rand.NewSource(1))
+
+	const runs = 10000
+	first := map[int]int{}
+	for i := 0; i < runs; i++ {
+		ordered, err := strategy.Order(gateways, models.RoutingContext{}, models.RoutingConfig{})
+		require.NoError(t, err)
+		require.Len(t, ordered, 3)
+		assert.Equal(t, 3, ordered[2].ID, "zero weight gateway is only a fallback")
+		first[ordered[0].ID]++
+	}
+
+	assert.InDelta(t, 0.7, float64(first[1])/runs, 0.03)
+	assert.InDelta(t, 0.3, float64(first[2])/runs, 0.03)
+}
+
+func TestLowestFeeStrategy(t *testing.T) {
+	fixed := func(eur, jpy string) []models.GatewayCurrency {
+		return []models.GatewayCurrency{
+			{Currency: "EUR", FeeFixed: money.MustParseDecimal(eur)},
+			{Currency: "JPY", FeeFixed: money.MustParseDecimal(jpy)},
+		}
+	}
+	gateways := []models.Gateway{
+		{ID: 1, Priority: 1, FeePercent: money.MustParseDecimal("2.5"), Currencies: fixed("0", "0")},
+		{ID: 2, Priority: 2, FeePercent: money.MustParseDecimal("1.5"), Currencies: fixed("0.30", "40")},
+		{ID: 3, Priority: 3, FeePercent: money.MustParseDecimal("1"), Currencies: fixed("1.00", "150")},
+	}
+
+	tests := []struct {
+		amount money.Money
+		want   []int
+	}{
+		// 0.25 / 0.45 / 1.10
+		{amount: money.MustParse("10", "EUR"), want: []int{1, 2, 3}},
+		// 25.00 / 15.30 / 11.00
+		{amount: money.MustParse("1000", "EUR"), want: []int{3, 2, 1}},
+		// the fixed fees are in yen: 250 / 190 / 250
+		{amount: money.MustParse("10000", "JPY"), want: []int{2, 1, 3}},
+		// 0.7625 / 0.7575 / 1.305, apart by less than a cent
+		{amount: money.MustParse("30.50", "EUR"), want: []int{2, 1, 3}},
+	}
+
+	for _, tt := range tests {
+		ordered, err := LowestFeeStrategy{}.Order(gateways, models.RoutingContext{Amount: tt.amount}, models.RoutingConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, ids(ordered), tt.amount.String())
+	}
+}
+
+func TestSuccessRateStrategy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	gateways := []models.Gateway{{ID: 1, Priority: 1}, {ID: 2, Priority: 2}, {ID: 3, Priority: 3}}
+
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockAttemptRepo.EXPECT().GetSuccessStats([]int{1, 2, 3}, now.Add(-30*time.Minute)).Return(map[int]models.GatewayStats{
+		1: {Succeeded: 50, Total: 100},
+		2: {Succeeded: 95, Total: 100},
+	}, nil)
+
+	strategy := NewSuccessRateStrategy(mockAttemptRepo)
+	strategy.now = func() time.Time { return now }
+
+	ordered, err := strategy.Order(gateways, models.RoutingContext{}, models.RoutingConfig{SuccessRateWindow: 30 * time.Minute})
+	require.NoError(t, err)
+	// gateway 3 has no traffic and is ranked at the neutral 0.5, after gateway 1 by priority
+	assert.Equal(t, []int{2, 1, 3}, ids(ordered))
+}
@@ -0,0 +1,165 @@
+//go:generate mockgen -source relay.go -destination mocks/relay.go -package mocks
+
+package outbox
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+)
+
+const (
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 100
+	DefaultMaxAttempts = 10
+
+	// lease claimed messages are hidden from other relays for
+	lease = time.Minute
+	// batchTimeout a batch is published within, it ends before the lease so the published messages are marked
+	// while they are still claimed. The messages left are published by the next batch once their lease is over
+	batchTimeout   = lease - 15*time.Second
+	publishTimeout = 10 * time.Second
+	minBackoff     = time.Second
+	maxBackoff     = 5 * time.Minute
+)
+
+// Relay publishes outbox messages to kafka, messages failing maxAttempts times are marked dead
+type Relay interface {
+	// Run relays pending messages every interval until ctx is done
+	Run(ctx context.Context)
+	// RelayPending publishes one batch of due messages and returns how many were claimed
+	RelayPending(ctx context.Context) (int, error)
+}
+
+type relay struct {
+	outboxRepo   repository.OutboxRepository
+	publisher    kafka.KafkaPublisher
+	interval     time.Duration
+	batchSize    int
+	maxAttempts  int
+	batchTimeout time.Duration
+	now          func() time.Time
+}
+
+func NewRelay(outboxRepo repository.OutboxRepository, publisher kafka.KafkaPublisher, interval time.Duration, batchSize, maxAttempts int) Relay {
+	return &relay{
+		outboxRepo:   outboxRepo,
+		publisher:    publisher,
+		interval:     interval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		batchTimeout: batchTimeout,
+		now:          time.Now,
+	}
+}
+
+func (r *relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means there are more messages waiting
+		claimed, err := r.RelayPending(ctx)
+		if err != nil {
+			log.Printf("Error outbox relay: %v", err)
+		}
+		if claimed == r.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *relay) RelayPending(ctx context.Context) (int, error) {
+	now := r.now()
+	messages, err := r.outboxRepo.ClaimPending(now, now.Add(lease), r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	batchCtx, cancel := context.WithTimeout(ctx, r.batchTimeout)
+	defer cancel()
+
+	// the batch holds one message per transaction, failures don't reorder the transaction events
+	for i, message := range messages {
+		if batchCtx.Err() != nil {
+			log.Printf("Outbox batch stopped after %d of %d messages, the rest are published after their lease: %v",
+				i, len(messages), batchCtx.Err())
+			break
+		}
+		r.publish(batchCtx, message)
+	}
+
+	return len(messages), nil
+}
+
+func (r *relay) publish(ctx context.Context, message models.OutboxMessage) {
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	err := r.publisher.PublishTransaction(publishCtx, strconv.Itoa(message.TransactionID), message.Payload, message.ContentType)
+	if err == nil {
+		if err := r.outboxRepo.MarkPublished(message.ID); err != nil {
+			// the message is published again after the lease, consumers must tolerate duplicates
+			log.Printf("Error db.MarkPublished: %v", err)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// the batch has run out of time or the relay is stopping, kafka hasn't failed the message
+		log.Printf("Outbox message %d of transaction %d interrupted: %v", message.ID, message.TransactionID, err)
+		return
+	}
+
+	attempts := message.Attempts + 1
+	if attempts >= r.maxAttempts {
+		log.Printf("Outbox message %d of transaction %d is dead after %d attempts: %v", message.ID, message.TransactionID, attempts, err)
+		if err := r.outboxRepo.MarkDead(message.ID, deadLetter(message, attempts, err)); err != nil {
+			log.Printf("Error db.MarkDead: %v", err)
+		}
+		return
+	}
+
+	log.Printf("Outbox message %d of transaction %d failed, attempt %d: %v", message.ID, message.TransactionID, attempts, err)
+	if err := r.outboxRepo.MarkRetry(message.ID, r.now().Add(backoff(attempts)), err.Error()); err != nil {
+		log.Printf("Error db.MarkRetry: %v", err)
+	}
+}
+
+// deadLetter the message as it would have been published, so it can be replayed to the same topic
+func deadLetter(message models.OutboxMessage, attempts int, err error) models.DeadLetter {
+	// the topic is left empty when the content type has no topic, the replay fails with the same error
+	topic, _ := kafka.TransactionTopic(message.ContentType)
+
+	return models.DeadLetter{
+		Topic:       topic,
+		Key:         strconv.Itoa(message.TransactionID),
+		ContentType: message.ContentType,
+		Payload:     message.Payload,
+		OutboxID:    message.ID,
+		Error:       err.Error(),
+		Attempts:    attempts,
+	}
+}
+
+// backoff doubles the delay with every attempt up to maxBackoff
+func backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
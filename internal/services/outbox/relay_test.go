@@ -0,0 +1,105 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mockPublisher "payment-gateway/internal/kafka/mocks"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestRelay(ctrl *gomock.Controller) (*relay, *mocks.MockOutboxRepository, *mockPublisher.MockKafkaPublisher) {
+	outboxRepo := mocks.NewMockOutboxRepository(ctrl)
+	publisher := mockPublisher.NewMockKafkaPublisher(ctrl)
+
+	r := NewRelay(outboxRepo, publisher, time.Second, 10, 3).(*relay)
+	r.now = func() time.Time { return now }
+
+	return r, outboxRepo, publisher
+}
+
+func TestRelayPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	r, outboxRepo, publisher := newTestRelay(ctrl)
+	messages := []models.OutboxMessage{
+		{ID: 1, TransactionID: 7, ContentType: "application/json", Payload: []byte(`{"ID":7}`)},
+		{ID: 2, TransactionID: 8, ContentType: "application/json", Payload: []byte(`{"ID":8}`), Attempts: 1},
+		{ID: 3, TransactionID: 9, ContentType: "application/json", Payload: []byte(`{"ID":9}`), Attempts: 2},
+	}
+	publishErr := errors.New("kafka write failed")
+
+	outboxRepo.EXPECT().ClaimPending(now, now.Add(lease), 10).Return(messages, nil)
+	gomock.InOrder(
+		// messages are keyed by the transaction id
+		publisher.EXPECT().PublishTransaction(gomock.Any(), "7", []byte(`{"ID":7}`), "application/json").Return(nil),
+		outboxRepo.EXPECT().MarkPublished(int64(1)).Return(nil),
+		publisher.EXPECT().PublishTransaction(gomock.Any(), "8", gomock.Any(), "application/json").Return(publishErr),
+		outboxRepo.EXPECT().MarkRetry(int64(2), now.Add(2*time.Second), publishErr.Error()).Return(nil),
+		publisher.EXPECT().PublishTransaction(gomock.Any(), "9", gomock.Any(), "application/json").Return(publishErr),
+		outboxRepo.EXPECT().MarkDead(int64(3), models.DeadLetter{
+			Topic: "transactions.json", Key: "9", ContentType: "application/json", Payload: []byte(`{"ID":9}`),
+			OutboxID: 3, Error: publishErr.Error(), Attempts: 3,
+		}).Return(nil),
+	)
+
+	claimed, err := r.RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+}
+
+func TestRelayPending_ClaimError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	r, outboxRepo, _ := newTestRelay(ctrl)
+	outboxRepo.EXPECT().ClaimPending(gomock.Any(), gomock.Any(), 10).Return(nil, errors.New("db down"))
+
+	claimed, err := r.RelayPending(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, claimed)
+}
+
+func TestRelayPending_BatchTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	r, outboxRepo, publisher := newTestRelay(ctrl)
+	r.batchTimeout = 20 * time.Millisecond
+	messages := []models.OutboxMessage{
+		{ID: 1, TransactionID: 7, ContentType: "application/json"},
+		{ID: 2, TransactionID: 8, ContentType: "application/json"},
+		{ID: 3, TransactionID: 9, ContentType: "application/json"},
+	}
+
+	outboxRepo.EXPECT().ClaimPending(now, now.Add(lease), 10).Return(messages, nil)
+	publisher.EXPECT().PublishTransaction(gomock.Any(), "7", gomock.Any(), gomock.Any()).Return(nil)
+	outboxRepo.EXPECT().MarkPublished(int64(1)).Return(nil)
+	// the slow write runs out of the batch time, it isn't counted as a failed attempt
+	publisher.EXPECT().PublishTransaction(gomock.Any(), "8", gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ string, _ []byte, _ string) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	// the third message is left to the next batch once its lease is over
+
+	claimed, err := r.RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 8*time.Second, backoff(4))
+	assert.Equal(t, maxBackoff, backoff(20))
+}
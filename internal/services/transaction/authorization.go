@@ -0,0 +1,183 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+// AuthorizationTTL authorizations which are neither captured nor voided expire after it,
+// gateways release the held amount about a week after the authorization
+const AuthorizationTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrNotAuthorized only authorizations which are authorized and have not expired can be captured or voided
+	ErrNotAuthorized = errors.New("transaction is not an open authorization")
+	// ErrVoidDeclined the gateway has refused to release the authorized amount
+	ErrVoidDeclined = errors.New("void declined by gateway")
+)
+
+func (s *transactionService) Authorize(req models.TransactionRequest) (*models.Transaction, error) {
+	amount, err := s.validateTransaction(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, gateways, err := s.transaction(req.UserID, amount, models.TransactionTypeAuthorization)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.route(tx, gateways, s.gateway.Authorize); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+func (s *transactionService) Capture(req models.CaptureRequest) (*models.Transaction, error) {
+	authorization, err := s.openAuthorization(req.AuthorizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := authorization.Amount
+	if !req.Amount.IsZero() {
+		if req.Amount.Sign() < 0 {
+			return nil, fmt.Errorf("%w, must be greater than zero", ErrInvalidAmount)
+		}
+		if amount, err = money.FromDecimal(req.Amount, authorization.Amount.Currency()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+		}
+	}
+
+	// the authorized amount is held by the gateway which has authorized it
+	gw, err := s.gateway.GetGatewayByID(authorization.GatewayID)
+	if err != nil {
+		return nil, err
+	}
+
+	capture := &models.Transaction{
+		UserID:    authorization.UserID,
+		Amount:    amount,
+		GatewayID: authorization.GatewayID,
+		CountryID: authorization.CountryID,
+		Status:    models.TransactionStatusCreated,
+		Type:      models.TransactionTypeCapture,
+		ParentID:  authorization.ID,
+	}
+	if capture.Conversion, err = parentConversion(amount, authorization); err != nil {
+		return nil, err
+	}
+
+	capture.ID, err = s.transRepo.CreateCapture(*capture, s.createdMessage)
+	if err != nil {
+		log.Printf("Error db.CreateCapture: %v", err)
+		return nil, err
+	}
+
+	err = s.submitFollowUp(capture, gw, func() (*adapters.Response, error) {
+		return s.gateway.Capture(gw, *capture, *authorization)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return capture, nil
+}
+
+// Void releases the authorization on its gateway while the authorization is leased, a capture requested meanwhile
+// is rejected
+func (s *transactionService) Void(authorizationID int) (*models.Transaction, error) {
+	authorization, err := s.openAuthorization(authorizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	gw, err := s.gateway.GetGatewayByID(authorization.GatewayID)
+	if err != nil {
+		return nil, err
+	}
+
+	change, message, posting, err := s.statusChange(authorization, apiUpdate(authorization, models.TransactionStatusVoided, "voided on gateway "+gw.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	// the void is another attempt on the authorization, after the ones of its routing
+	attemptNo := 1
+	if attempts, err := s.attemptRepo.GetAttempts(authorization.ID); err != nil {
+		log.Printf("Error db.GetAttempts: %v", err)
+	} else {
+		attemptNo += len(attempts)
+	}
+
+	err = s.transRepo.VoidAuthorization(change, message, posting, func() error {
+		resp, err := s.gateway.Void(gw, *authorization)
+		s.recordAttempt(authorization, attemptNo, resp, err)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrGatewayFailed, err)
+		}
+		if resp.Status == models.TransactionStatusFailed {
+			return fmt.Errorf("%w: transaction %d on gateway %s", ErrVoidDeclined, authorization.ID, gw.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error db.VoidAuthorization: %v", err)
+		return nil, err
+	}
+
+	authorization.Status = models.TransactionStatusVoided
+	return authorization, nil
+}
+
+// openAuthorization authorization which can still be captured or voided
+func (s *transactionService) openAuthorization(authorizationID int) (*models.Transaction, error) {
+	authorization, err := s.transRepo.GetTransaction(authorizationID)
+	if err != nil {
+		log.Printf("Error db.GetTransaction: %v", err)
+		return nil, err
+	}
+
+	if authorization.Type != models.TransactionTypeAuthorization || authorization.Status != models.TransactionStatusAuthorized {
+		return nil, fmt.Errorf("%w: transaction %d is a %s %s", ErrNotAuthorized, authorization.ID, authorization.Status, authorization.Type)
+	}
+	if !authorization.ExpiresAt.IsZero() && !time.Now().Before(authorization.ExpiresAt) {
+		return nil, fmt.Errorf("%w: transaction %d has expired at %s", ErrNotAuthorized, authorization.ID,
+			authorization.ExpiresAt.Format(time.RFC3339))
+	}
+	return authorization, nil
+}
+
+func (s *transactionService) ExpireAuthorizations(now time.Time, limit int) (int, error) {
+	authorizations, err := s.transRepo.GetExpiredAuthorizations(now, limit)
+	if err != nil {
+		log.Printf("Error db.GetExpiredAuthorizations: %v", err)
+		return 0, err
+	}
+
+	expired := 0
+	for i := range authorizations {
+		authorization := &authorizations[i]
+		err = s.transition(authorization, models.StatusUpdate{
+			TransactionID: authorization.ID,
+			Status:        models.TransactionStatusExpired,
+			Source:        models.StatusSourceExpiry,
+			Reason:        "authorization expired at " + authorization.ExpiresAt.Format(time.RFC3339),
+		})
+		// a capture or void may have settled the authorization meanwhile, the others are still expired
+		if err != nil {
+			log.Printf("Error expiring authorization %d: %v", authorization.ID, err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,355 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/repository/mocks"
+	mockGateway "payment-gateway/internal/services/gateway/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openAuthorization() *models.Transaction {
+	return &models.Transaction{
+		ID: 20, UserID: 1, GatewayID: 10, CountryID: 2, Type: models.TransactionTypeAuthorization, Status: models.TransactionStatusAuthorized,
+		Amount: money.MustParse("100", "EUR"), GatewayReference: "auth_20", ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestAuthorize_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, nil, nil, jsonSerializer)
+
+	req := models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("100.00"), Currency: "EUR"}
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+
+	mockUserRepo.EXPECT().GetUserByID(1).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeAuthorization)).Return([]models.Gateway{*gw}, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(created models.Transaction, _ func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+			assert.Equal(t, models.TransactionTypeAuthorization, created.Type)
+			assert.WithinDuration(t, time.Now().Add(AuthorizationTTL), created.ExpiresAt, time.Minute)
+			return 20, nil
+		})
+	mockTransRepo.EXPECT().TransitionStatus(transition(20, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockGateway.EXPECT().Authorize(gw, gomock.Any()).Return(&adapters.Response{Reference: "auth_20", Status: models.TransactionStatusDone}, nil)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().UpdateGatewayReference(20, "auth_20").Return(nil)
+	// the gateway approves the authorization, nothing is posted until it is captured
+	mockTransRepo.EXPECT().TransitionStatus(transition(20, models.TransactionStatusSubmitted, models.TransactionStatusAuthorized), gomock.Any(),
+		ledger.Posting{}).Return(nil)
+
+	result, err := service.Authorize(req)
+	require.NoError(t, err)
+	assert.Equal(t, models.TransactionStatusAuthorized, result.Status)
+	assert.Equal(t, "auth_20", result.GatewayReference)
+}
+
+func TestCapture_PartialWithConversion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, nil, jsonSerializer)
+
+	rateAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	authorization := openAuthorization()
+	authorization.Conversion = &models.FXConversion{Amount: money.MustParse("108", "USD"), Rate: money.MustParseDecimal("1.08"), Source: "ecb", RateAt: rateAt}
+	// the capture is converted with the rate of the authorization
+	conversion := models.FXConversion{Amount: money.MustParse("64.80", "USD"), Rate: money.MustParseDecimal("1.08"), Source: "ecb", RateAt: rateAt}
+	capture := models.Transaction{
+		UserID: 1, Amount: money.MustParse("60", "EUR"), GatewayID: 10, CountryID: 2,
+		Status: models.TransactionStatusCreated, Type: models.TransactionTypeCapture, ParentID: 20, Conversion: &conversion,
+	}
+
+	mockTransRepo.EXPECT().GetTransaction(20).Return(authorization, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil)
+	mockTransRepo.EXPECT().CreateCapture(capture, gomock.Any()).Return(21, nil)
+	mockTransRepo.EXPECT().UpdateConversion(21, conversion).Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(21, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+
+	capture.ID, capture.Status = 21, models.TransactionStatusSubmitted
+	mockGateway.EXPECT().Capture(gw, capture, *authorization).Return(&adapters.Response{Reference: "cap_21", Status: models.TransactionStatusDone}, nil)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().UpdateGatewayReference(21, "cap_21").Return(nil)
+	// the captured amount is paid into the wallet like a deposit
+	mockTransRepo.EXPECT().TransitionStatus(transition(21, models.TransactionStatusSubmitted, models.TransactionStatusDone), gomock.Any(), ledger.Posting{
+		TransactionID: 21,
+		Entry: &ledger.Entry{
+			TransactionID: 21,
+			Type:          ledger.EntryCapture,
+			Description:   "capture of transaction 21",
+			Lines: []ledger.Line{
+				{Account: ledger.UserWallet(1, "EUR"), Amount: money.MustParse("60", "EUR")},
+				{Account: ledger.GatewayClearing(10, "EUR"), Amount: money.MustParse("-60", "EUR")},
+			},
+		},
+	}).Return(nil)
+	mockTransRepo.EXPECT().GetTransaction(20).Return(openAuthorization(), nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(20, models.TransactionStatusAuthorized, models.TransactionStatusCaptured), gomock.Any(), ledger.Posting{}).Return(nil)
+
+	result, err := service.Capture(models.CaptureRequest{AuthorizationID: 20, Amount: money.MustParseDecimal("60")})
+	require.NoError(t, err)
+	assert.Equal(t, 21, result.ID)
+	assert.Equal(t, 20, result.ParentID)
+	assert.Equal(t, models.TransactionStatusDone, result.Status)
+}
+
+func TestCapture_WholeAmountGatewayFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(20).Return(openAuthorization(), nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10, Name: "xmlpay"}, nil)
+	mockTransRepo.EXPECT().CreateCapture(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(capture models.Transaction, _ func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+			assert.Equal(t, money.MustParse("100", "EUR"), capture.Amount)
+			return 21, nil
+		})
+	mockTransRepo.EXPECT().TransitionStatus(transition(21, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockGateway.EXPECT().Capture(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, adapters.ErrTimeout)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	// the capture fails, the authorization stays open for another capture
+	mockTransRepo.EXPECT().TransitionStatus(transition(21, models.TransactionStatusSubmitted, models.TransactionStatusFailed), gomock.Any(), gomock.Any()).Return(nil)
+
+	result, err := service.Capture(models.CaptureRequest{AuthorizationID: 20})
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrGatewayFailed)
+}
+
+func TestCapture_Rejected(t *testing.T) {
+	expired := openAuthorization()
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	voided := openAuthorization()
+	voided.Status = models.TransactionStatusVoided
+	deposit := openAuthorization()
+	deposit.Type, deposit.Status = models.TransactionTypeDeposit, models.TransactionStatusDone
+
+	tests := []struct {
+		name    string
+		tx      *models.Transaction
+		amount  string
+		wantErr error
+	}{
+		{name: "expired", tx: expired, wantErr: ErrNotAuthorized},
+		{name: "voided", tx: voided, wantErr: ErrNotAuthorized},
+		{name: "not an authorization", tx: deposit, wantErr: ErrNotAuthorized},
+		{name: "negative amount", tx: openAuthorization(), amount: "-5", wantErr: ErrInvalidAmount},
+		{name: "finer than currency", tx: openAuthorization(), amount: "5.001", wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+			service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+			req := models.CaptureRequest{AuthorizationID: 20}
+			if tt.amount != "" {
+				req.Amount = money.MustParseDecimal(tt.amount)
+			}
+			mockTransRepo.EXPECT().GetTransaction(20).Return(tt.tx, nil)
+
+			_, err := service.Capture(req)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCapture_AlreadyCaptured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(20).Return(openAuthorization(), nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10}, nil)
+	mockTransRepo.EXPECT().CreateCapture(gomock.Any(), gomock.Any()).Return(0, fmt.Errorf("%w: transaction 20", repository.ErrAlreadyCaptured))
+
+	_, err := service.Capture(models.CaptureRequest{AuthorizationID: 20, Amount: money.MustParseDecimal("40")})
+	assert.ErrorIs(t, err, repository.ErrAlreadyCaptured)
+}
+
+func TestVoid(t *testing.T) {
+	tests := []struct {
+		name        string
+		resp        *adapters.Response
+		respErr     error
+		wantAttempt models.TransactionAttempt
+		wantStatus  string
+		wantErr     error
+	}{
+		{name: "voided", resp: &adapters.Response{Reference: "auth_20", Status: models.TransactionStatusDone},
+			wantAttempt: models.TransactionAttempt{Status: models.AttemptStatusSucceeded, GatewayReference: "auth_20"},
+			wantStatus:  models.TransactionStatusVoided},
+		{name: "declined", resp: &adapters.Response{Reference: "auth_20", Status: models.TransactionStatusFailed},
+			wantAttempt: models.TransactionAttempt{Status: models.AttemptStatusSucceeded, GatewayReference: "auth_20"},
+			wantErr:     ErrVoidDeclined},
+		{name: "gateway failure", respErr: adapters.ErrTimeout,
+			wantAttempt: models.TransactionAttempt{Status: models.AttemptStatusFailed, Error: adapters.ErrTimeout.Error()},
+			wantErr:     ErrGatewayFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+			mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+			mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+			service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, nil, jsonSerializer)
+
+			gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+			authorization := openAuthorization()
+			mockTransRepo.EXPECT().GetTransaction(20).Return(authorization, nil)
+			mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil)
+			mockAttemptRepo.EXPECT().GetAttempts(20).Return([]models.TransactionAttempt{{ID: 1, AttemptNo: 1}}, nil)
+
+			// the gateway is called with the authorization locked, the transition is applied when it returns nil
+			mockTransRepo.EXPECT().VoidAuthorization(transition(20, models.TransactionStatusAuthorized, models.TransactionStatusVoided),
+				gomock.Any(), ledger.Posting{}, gomock.Any()).DoAndReturn(
+				func(_ models.StatusTransition, _ models.OutboxMessage, _ ledger.Posting, void func() error) error {
+					return void()
+				})
+			mockGateway.EXPECT().Void(gw, *authorization).Return(tt.resp, tt.respErr)
+
+			// the void follows the routing attempt of the authorization
+			tt.wantAttempt.TransactionID, tt.wantAttempt.GatewayID, tt.wantAttempt.AttemptNo = 20, 10, 2
+			mockAttemptRepo.EXPECT().CreateAttempt(tt.wantAttempt).Return(nil)
+
+			result, err := service.Void(20)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, result.Status)
+		})
+	}
+}
+
+func TestVoid_CaptureInProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(20).Return(openAuthorization(), nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10, Name: "jsonpay"}, nil)
+	mockAttemptRepo.EXPECT().GetAttempts(20).Return(nil, errors.New("connection reset"))
+	// the locked authorization has a capture, the gateway isn't called
+	mockTransRepo.EXPECT().VoidAuthorization(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(fmt.Errorf("%w: transaction 20", repository.ErrAlreadyCaptured))
+
+	_, err := service.Void(20)
+	assert.ErrorIs(t, err, repository.ErrAlreadyCaptured)
+}
+
+func TestExpireAuthorizations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	first, second := openAuthorization(), openAuthorization()
+	second.ID = 22
+
+	mockTransRepo.EXPECT().GetExpiredAuthorizations(now, 10).Return([]models.Transaction{*first, *second}, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(20, models.TransactionStatusAuthorized, models.TransactionStatusExpired), gomock.Any(), ledger.Posting{}).
+		DoAndReturn(func(change models.StatusTransition, _ models.OutboxMessage, _ ledger.Posting) error {
+			assert.Equal(t, models.StatusSourceExpiry, change.Source)
+			return nil
+		})
+	// the second authorization fails, the batch goes on
+	mockTransRepo.EXPECT().TransitionStatus(transition(22, models.TransactionStatusAuthorized, models.TransactionStatusExpired), gomock.Any(), gomock.Any()).
+		Return(errors.New("connection reset"))
+
+	expired, err := service.ExpireAuthorizations(now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+}
+
+func TestUpdateStatus_DoneAuthorizationIsAuthorized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	pending := openAuthorization()
+	pending.Status = models.TransactionStatusPending
+	mockTransRepo.EXPECT().GetTransaction(20).Return(pending, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(20, models.TransactionStatusPending, models.TransactionStatusAuthorized), gomock.Any(), ledger.Posting{}).Return(nil)
+
+	err := service.UpdateStatus(models.StatusUpdate{TransactionID: 20, Status: models.TransactionStatusDone, GatewayID: 10, Source: models.StatusSourceCallback})
+	assert.NoError(t, err)
+}
+
+// stubExpiryService counts the expiry runs, each expires nothing
+type stubExpiryService struct {
+	TransactionService
+	runs atomic.Int32
+}
+
+func (s *stubExpiryService) ExpireAuthorizations(_ time.Time, _ int) (int, error) {
+	s.runs.Add(1)
+	return 0, nil
+}
+
+func TestExpirer_OnlyLeaderExpires(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lock := mocks.NewMockLeaderLock(ctrl)
+	service := &stubExpiryService{}
+
+	lock.EXPECT().TryAcquire(gomock.Any()).Return(false, nil).MinTimes(1)
+	lock.EXPECT().Release(gomock.Any()).Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	NewExpirer(service, lock, 10*time.Millisecond, 5).Run(ctx)
+
+	assert.Zero(t, service.runs.Load())
+}
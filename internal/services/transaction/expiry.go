@@ -0,0 +1,79 @@
+package transaction
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"payment-gateway/internal/repository"
+)
+
+const (
+	DefaultExpiryInterval  = time.Minute
+	DefaultExpiryBatchSize = 100
+)
+
+// Expirer expires the authorizations which have been neither captured nor voided in time
+type Expirer interface {
+	// Run expires due authorizations every interval until ctx is done, only the replica holding the lock expires
+	Run(ctx context.Context)
+}
+
+type expirer struct {
+	service   TransactionService
+	lock      repository.LeaderLock
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+}
+
+func NewExpirer(service TransactionService, lock repository.LeaderLock, interval time.Duration, batchSize int) Expirer {
+	return &expirer{
+		service:   service,
+		lock:      lock,
+		interval:  interval,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+}
+
+func (e *expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	defer func() {
+		// ctx is done, the lock is released with a fresh context so that another replica takes over right away
+		if err := e.lock.Release(context.Background()); err != nil {
+			log.Printf("Error lock.Release: %v", err)
+		}
+	}()
+
+	for {
+		// a full batch means there are more authorizations waiting
+		if e.expire(ctx) == e.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire returns the number of authorizations expired, none when another replica is the leader
+func (e *expirer) expire(ctx context.Context) int {
+	leader, err := e.lock.TryAcquire(ctx)
+	if err != nil {
+		log.Printf("Error lock.TryAcquire: %v", err)
+	}
+	if !leader {
+		return 0
+	}
+
+	expired, err := e.service.ExpireAuthorizations(e.now(), e.batchSize)
+	if err != nil {
+		log.Printf("Error authorization expiry: %v", err)
+	}
+	return expired
+}
@@ -5,35 +5,68 @@
 package mocks
 
 import (
-	gomock "github.com/golang/mock/gomock"
+	context "context"
 	models "payment-gateway/internal/models"
 	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
 )
 
-// MockTransactionService is a mock of TransactionService interface
+// MockTransactionService is a mock of TransactionService interface.
 type MockTransactionService struct {
 	ctrl     *gomock.Controller
 	recorder *MockTransactionServiceMockRecorder
 }
 
-// MockTransactionServiceMockRecorder is the mock recorder for MockTransactionService
+// MockTransactionServiceMockRecorder is the mock recorder for MockTransactionService.
 type MockTransactionServiceMockRecorder struct {
 	mock *MockTransactionService
 }
 
-// NewMockTransactionService creates a new mock instance
+// NewMockTransactionService creates a new mock instance.
 func NewMockTransactionService(ctrl *gomock.Controller) *MockTransactionService {
 	mock := &MockTransactionService{ctrl: ctrl}
 	mock.recorder = &MockTransactionServiceMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockTransactionService) EXPECT() *MockTransactionServiceMockRecorder {
 	return m.recorder
 }
 
-// Deposit mocks base method
+// Authorize mocks base method.
+func (m *MockTransactionService) Authorize(req models.TransactionRequest) (*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", req)
+	ret0, _ := ret[0].(*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockTransactionServiceMockRecorder) Authorize(req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockTransactionService)(nil).Authorize), req)
+}
+
+// Capture mocks base method.
+func (m *MockTransactionService) Capture(req models.CaptureRequest) (*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capture", req)
+	ret0, _ := ret[0].(*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Capture indicates an expected call of Capture.
+func (mr *MockTransactionServiceMockRecorder) Capture(req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capture", reflect.TypeOf((*MockTransactionService)(nil).Capture), req)
+}
+
+// Deposit mocks base method.
 func (m *MockTransactionService) Deposit(req models.TransactionRequest) (*models.Transaction, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Deposit", req)
@@ -42,37 +75,127 @@ func (m *MockTransactionService) Deposit(req models.TransactionRequest) (*models
 	return ret0, ret1
 }
 
-// Deposit indicates an expected call of Deposit
+// Deposit indicates an expected call of Deposit.
 func (mr *MockTransactionServiceMockRecorder) Deposit(req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockTransactionService)(nil).Deposit), req)
 }
 
-// Withdrawal mocks base method
-func (m *MockTransactionService) Withdrawal(req models.TransactionRequest) (*models.Transaction, error) {
+// ExpireAuthorizations mocks base method.
+func (m *MockTransactionService) ExpireAuthorizations(now time.Time, limit int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Withdrawal", req)
+	ret := m.ctrl.Call(m, "ExpireAuthorizations", now, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireAuthorizations indicates an expected call of ExpireAuthorizations.
+func (mr *MockTransactionServiceMockRecorder) ExpireAuthorizations(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireAuthorizations", reflect.TypeOf((*MockTransactionService)(nil).ExpireAuthorizations), now, limit)
+}
+
+// GetTransaction mocks base method.
+func (m *MockTransactionService) GetTransaction(transactionID int) (*models.TransactionDetail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransaction", transactionID)
+	ret0, _ := ret[0].(*models.TransactionDetail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransaction indicates an expected call of GetTransaction.
+func (mr *MockTransactionServiceMockRecorder) GetTransaction(transactionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransaction", reflect.TypeOf((*MockTransactionService)(nil).GetTransaction), transactionID)
+}
+
+// PollPending mocks base method.
+func (m *MockTransactionService) PollPending(ctx context.Context, now time.Time, limit int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PollPending", ctx, now, limit)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PollPending indicates an expected call of PollPending.
+func (mr *MockTransactionServiceMockRecorder) PollPending(ctx, now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PollPending", reflect.TypeOf((*MockTransactionService)(nil).PollPending), ctx, now, limit)
+}
+
+// Refund mocks base method.
+func (m *MockTransactionService) Refund(req models.RefundRequest) (*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refund", req)
 	ret0, _ := ret[0].(*models.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Withdrawal indicates an expected call of Withdrawal
-func (mr *MockTransactionServiceMockRecorder) Withdrawal(req interface{}) *gomock.Call {
+// Refund indicates an expected call of Refund.
+func (mr *MockTransactionServiceMockRecorder) Refund(req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdrawal", reflect.TypeOf((*MockTransactionService)(nil).Withdrawal), req)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refund", reflect.TypeOf((*MockTransactionService)(nil).Refund), req)
+}
+
+// SearchTransactions mocks base method.
+func (m *MockTransactionService) SearchTransactions(filter models.TransactionFilter) (models.TransactionPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchTransactions", filter)
+	ret0, _ := ret[0].(models.TransactionPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchTransactions indicates an expected call of SearchTransactions.
+func (mr *MockTransactionServiceMockRecorder) SearchTransactions(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchTransactions", reflect.TypeOf((*MockTransactionService)(nil).SearchTransactions), filter)
 }
 
-// UpdateStatus mocks base method
-func (m *MockTransactionService) UpdateStatus(txID int, gatewayID int64, status string) error {
+// UpdateStatus mocks base method.
+func (m *MockTransactionService) UpdateStatus(update models.StatusUpdate) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateStatus", txID, gatewayID, status)
+	ret := m.ctrl.Call(m, "UpdateStatus", update)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateStatus indicates an expected call of UpdateStatus
-func (mr *MockTransactionServiceMockRecorder) UpdateStatus(txID, gatewayID, status interface{}) *gomock.Call {
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockTransactionServiceMockRecorder) UpdateStatus(update interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockTransactionService)(nil).UpdateStatus), txID, gatewayID, status)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockTransactionService)(nil).UpdateStatus), update)
+}
+
+// Void mocks base method.
+func (m *MockTransactionService) Void(authorizationID int) (*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Void", authorizationID)
+	ret0, _ := ret[0].(*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Void indicates an expected call of Void.
+func (mr *MockTransactionServiceMockRecorder) Void(authorizationID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Void", reflect.TypeOf((*MockTransactionService)(nil).Void), authorizationID)
+}
+
+// Withdrawal mocks base method.
+func (m *MockTransactionService) Withdrawal(req models.TransactionRequest) (*models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Withdrawal", req)
+	ret0, _ := ret[0].(*models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Withdrawal indicates an expected call of Withdrawal.
+func (mr *MockTransactionServiceMockRecorder) Withdrawal(req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdrawal", reflect.TypeOf((*MockTransactionService)(nil).Withdrawal), req)
 }
@@ -0,0 +1,109 @@
+package transaction
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"payment-gateway/internal/models"
+)
+
+const (
+	// DefaultPendingPollAfter time the gateway has to report the result of a pending transaction before it is
+	// asked for it, gateways.pending_poll_after overrides it per gateway
+	DefaultPendingPollAfter = 15 * time.Minute
+	// PendingDeadline a transaction still pending this long after it has been created is expired
+	PendingDeadline = 24 * time.Hour
+)
+
+func (s *transactionService) PollPending(ctx context.Context, now time.Time, limit int) (int, error) {
+	transactions, err := s.transRepo.GetStalePendingTransactions(now, DefaultPendingPollAfter, limit)
+	if err != nil {
+		log.Printf("Error db.GetStalePendingTransactions: %v", err)
+		return 0, err
+	}
+
+	polled := 0
+	for i := range transactions {
+		if ctx.Err() != nil {
+			return polled, ctx.Err()
+		}
+
+		polled++
+		if err := s.pollPending(ctx, &transactions[i], now); err != nil {
+			log.Printf("Error polling transaction %d: %v", transactions[i].ID, err)
+			// the failed transaction moves behind the others, it doesn't hold up the next batches
+			if err := s.transRepo.MarkPolled(transactions[i].ID, now); err != nil {
+				log.Printf("Error db.MarkPolled: %v", err)
+			}
+		}
+	}
+
+	return polled, nil
+}
+
+// pollPending applies the status the gateway reports for tx through UpdateStatus like a callback would. A transaction
+// the gateway still has pending, or can't tell about, is polled again after the threshold and failed or expired past
+// the deadline. Created and submitted transactions have been left behind by a request which has stopped halfway
+func (s *transactionService) pollPending(ctx context.Context, tx *models.Transaction, now time.Time) error {
+	// the transaction is submitted before it is sent, a created one has never reached a gateway, see route
+	if tx.Status == models.TransactionStatusCreated {
+		return s.transition(tx, models.StatusUpdate{
+			TransactionID: tx.ID,
+			Status:        models.TransactionStatusFailed,
+			Source:        models.StatusSourcePoller,
+			Reason:        "not sent to a gateway",
+		})
+	}
+
+	gw, err := s.gateway.GetGatewayByID(tx.GatewayID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.gateway.Status(ctx, gw, *tx)
+	if err != nil {
+		log.Printf("Error gateway.Status: transaction %d: %v", tx.ID, err)
+	}
+	if err == nil && resp.Status != tx.Status {
+		if tx.GatewayReference == "" && resp.Reference != "" {
+			if err := s.transRepo.UpdateGatewayReference(tx.ID, resp.Reference); err != nil {
+				log.Printf("Error db.UpdateGatewayReference: %v", err)
+			}
+		}
+		err := s.UpdateStatus(models.StatusUpdate{
+			TransactionID: tx.ID,
+			Status:        resp.Status,
+			GatewayID:     tx.GatewayID,
+			Source:        models.StatusSourcePoller,
+			Actor:         gw.Name,
+			Reason:        "status query, gateway reference " + resp.Reference,
+		})
+		if err != nil || resp.Status != models.TransactionStatusPending {
+			return err
+		}
+		// the submitted transaction is pending on the gateway
+		tx.Status = models.TransactionStatusPending
+	}
+
+	if deadline := tx.CreatedAt.Add(PendingDeadline); !now.Before(deadline) {
+		// only pending transactions expire, the gateway has not confirmed it has received a submitted one
+		status := models.TransactionStatusExpired
+		if tx.Status == models.TransactionStatusSubmitted {
+			status = models.TransactionStatusFailed
+		}
+		return s.transition(tx, models.StatusUpdate{
+			TransactionID: tx.ID,
+			Status:        status,
+			Source:        models.StatusSourcePoller,
+			Actor:         gw.Name,
+			Reason:        tx.Status + " past the deadline " + deadline.Format(time.RFC3339),
+		})
+	}
+
+	if err := s.transRepo.MarkPolled(tx.ID, now); err != nil {
+		log.Printf("Error db.MarkPolled: %v", err)
+		return err
+	}
+	return nil
+}
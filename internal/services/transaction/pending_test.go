@@ -0,0 +1,262 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository/mocks"
+	mockGateway "payment-gateway/internal/services/gateway/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pollNow = time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+
+func pendingDeposit(id int, createdAt time.Time) models.Transaction {
+	return models.Transaction{
+		ID: id, UserID: 1, GatewayID: 10, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusPending,
+		Amount: money.MustParse("50", "EUR"), GatewayReference: "pay_1", CreatedAt: createdAt,
+	}
+}
+
+func TestPollPending_AppliesGatewayStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	tx := pendingDeposit(7, pollNow.Add(-time.Hour))
+	tx.GatewayReference = ""
+
+	mockTransRepo.EXPECT().GetStalePendingTransactions(pollNow, DefaultPendingPollAfter, 10).Return([]models.Transaction{tx}, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil)
+	mockGateway.EXPECT().Status(gomock.Any(), gw, tx).Return(&adapters.Response{Reference: "pay_7", Status: models.TransactionStatusFailed}, nil)
+	// the gateway has not answered the deposit with its id, the status query tells it
+	mockTransRepo.EXPECT().UpdateGatewayReference(7, "pay_7").Return(nil)
+	mockTransRepo.EXPECT().GetTransaction(7).Return(&tx, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusPending, models.TransactionStatusFailed), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(change models.StatusTransition, _ models.OutboxMessage, _ interface{}) error {
+			assert.Equal(t, models.StatusSourcePoller, change.Source)
+			assert.Equal(t, "jsonpay", change.Actor)
+			return nil
+		})
+
+	polled, err := service.PollPending(context.Background(), pollNow, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, polled)
+}
+
+func TestPollPending_StillPendingIsPolledAgainLater(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	pending, unreachable := pendingDeposit(7, pollNow.Add(-time.Hour)), pendingDeposit(8, pollNow.Add(-time.Hour))
+
+	mockTransRepo.EXPECT().GetStalePendingTransactions(pollNow, DefaultPendingPollAfter, 10).Return([]models.Transaction{pending, unreachable}, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil).Times(2)
+	mockGateway.EXPECT().Status(gomock.Any(), gw, pending).Return(&adapters.Response{Reference: "pay_1", Status: models.TransactionStatusPending}, nil)
+	mockGateway.EXPECT().Status(gomock.Any(), gw, unreachable).Return(nil, adapters.ErrTimeout)
+	mockTransRepo.EXPECT().MarkPolled(7, pollNow).Return(nil)
+	mockTransRepo.EXPECT().MarkPolled(8, pollNow).Return(nil)
+
+	polled, err := service.PollPending(context.Background(), pollNow, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, polled)
+}
+
+func TestPollPending_ExpiresPastDeadline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	tx := pendingDeposit(7, pollNow.Add(-PendingDeadline))
+
+	mockTransRepo.EXPECT().GetStalePendingTransactions(pollNow, DefaultPendingPollAfter, 10).Return([]models.Transaction{tx}, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil)
+	// the gateway is asked one last time, it can't tell either
+	mockGateway.EXPECT().Status(gomock.Any(), gw, tx).Return(nil, errors.New("gateway responded with status 404"))
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusPending, models.TransactionStatusExpired), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(change models.StatusTransition, _ models.OutboxMessage, _ interface{}) error {
+			assert.Equal(t, models.StatusSourcePoller, change.Source)
+			return nil
+		})
+
+	polled, err := service.PollPending(context.Background(), pollNow, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, polled)
+}
+
+func TestPollPending_FailuresMoveBack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	unknownGateway, conflicting := pendingDeposit(7, pollNow.Add(-time.Hour)), pendingDeposit(8, pollNow.Add(-time.Hour))
+	conflicting.GatewayID = 11
+	gw := &models.Gateway{ID: 11, Name: "xmlpay"}
+
+	mockTransRepo.EXPECT().GetStalePendingTransactions(pollNow, DefaultPendingPollAfter, 10).Return([]models.Transaction{unknownGateway, conflicting}, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(nil, errors.New("connection reset"))
+	mockGateway.EXPECT().GetGatewayByID(11).Return(gw, nil)
+	// the gateway reports a status the transaction can't move to
+	mockGateway.EXPECT().Status(gomock.Any(), gw, conflicting).Return(&adapters.Response{Reference: "pay_1", Status: models.TransactionStatusAuthorized}, nil)
+	mockTransRepo.EXPECT().GetTransaction(8).Return(&conflicting, nil)
+
+	// both are polled again after the others instead of staying at the head of the queue
+	mockTransRepo.EXPECT().MarkPolled(7, pollNow).Return(nil)
+	mockTransRepo.EXPECT().MarkPolled(8, pollNow).Return(nil)
+
+	polled, err := service.PollPending(context.Background(), pollNow, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, polled)
+}
+
+func TestPollPending_RecoversInterruptedRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	created, submitted, lost := pendingDeposit(7, pollNow.Add(-time.Hour)), pendingDeposit(8, pollNow.Add(-time.Hour)),
+		pendingDeposit(9, pollNow.Add(-PendingDeadline))
+	created.Status, submitted.Status, lost.Status =
+		models.TransactionStatusCreated, models.TransactionStatusSubmitted, models.TransactionStatusSubmitted
+
+	mockTransRepo.EXPECT().GetStalePendingTransactions(pollNow, DefaultPendingPollAfter, 10).Return([]models.Transaction{created, submitted, lost}, nil)
+
+	// the created deposit has never been sent, the gateway isn't asked
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusCreated, models.TransactionStatusFailed), gomock.Any(), gomock.Any()).Return(nil)
+
+	// the submitted deposit is pending on the gateway and is polled again later
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil).Times(2)
+	mockGateway.EXPECT().Status(gomock.Any(), gw, submitted).Return(&adapters.Response{Reference: "pay_1", Status: models.TransactionStatusPending}, nil)
+	mockTransRepo.EXPECT().GetTransaction(8).Return(&submitted, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusSubmitted, models.TransactionStatusPending), gomock.Any(), gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().MarkPolled(8, pollNow).Return(nil)
+
+	// the gateway doesn't know the deposit submitted a day ago
+	mockGateway.EXPECT().Status(gomock.Any(), gw, lost).Return(nil, errors.New("gateway responded with status 404"))
+	mockTransRepo.EXPECT().TransitionStatus(transition(9, models.TransactionStatusSubmitted, models.TransactionStatusFailed), gomock.Any(), gomock.Any()).Return(nil)
+
+	polled, err := service.PollPending(context.Background(), pollNow, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, polled)
+}
+
+func TestPollPending_StopsWhenCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	first, second := pendingDeposit(7, pollNow.Add(-time.Hour)), pendingDeposit(8, pollNow.Add(-time.Hour))
+
+	mockTransRepo.EXPECT().GetStalePendingTransactions(pollNow, DefaultPendingPollAfter, 10).Return([]models.Transaction{first, second}, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil)
+	mockGateway.EXPECT().Status(gomock.Any(), gw, first).DoAndReturn(func(ctx context.Context, _ *models.Gateway, _ models.Transaction) (*adapters.Response, error) {
+		// the service shuts down during the gateway call
+		cancel()
+		return nil, ctx.Err()
+	})
+	mockTransRepo.EXPECT().MarkPolled(7, pollNow).Return(nil)
+
+	polled, err := service.PollPending(ctx, pollNow, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, polled)
+}
+
+// stubPollService counts the polls, every poll returns a full batch until full is 0
+type stubPollService struct {
+	TransactionService
+	mu    sync.Mutex
+	polls int
+	full  int
+}
+
+func (s *stubPollService) PollPending(_ context.Context, _ time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.polls++
+	if s.full > 0 {
+		s.full--
+		return limit, nil
+	}
+	return 0, nil
+}
+
+func (s *stubPollService) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
+
+func TestPoller_OnlyLeaderPolls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lock := mocks.NewMockLeaderLock(ctrl)
+	service := &stubPollService{}
+
+	lock.EXPECT().TryAcquire(gomock.Any()).Return(false, nil).MinTimes(1)
+	lock.EXPECT().Release(gomock.Any()).Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	NewPoller(service, lock, 10*time.Millisecond, 5).Run(ctx)
+
+	assert.Zero(t, service.count())
+}
+
+func TestPoller_FullBatchPollsAgain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lock := mocks.NewMockLeaderLock(ctrl)
+	service := &stubPollService{full: 2}
+
+	lock.EXPECT().TryAcquire(gomock.Any()).Return(true, nil).MinTimes(3)
+	lock.EXPECT().Release(gomock.Any()).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewPoller(service, lock, time.Hour, 5).Run(ctx)
+		close(done)
+	}()
+
+	// two full batches and the last one are polled without waiting for the ticker
+	assert.Eventually(t, func() bool { return service.count() == 3 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"payment-gateway/internal/repository"
+)
+
+const (
+	DefaultPollInterval  = time.Minute
+	DefaultPollBatchSize = 100
+)
+
+// Poller asks the gateways for the status of the transactions whose callback has not arrived
+type Poller interface {
+	// Run polls the stale pending transactions every interval until ctx is done, only the replica holding
+	// the lock polls
+	Run(ctx context.Context)
+}
+
+type poller struct {
+	service   TransactionService
+	lock      repository.LeaderLock
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+}
+
+func NewPoller(service TransactionService, lock repository.LeaderLock, interval time.Duration, batchSize int) Poller {
+	return &poller{
+		service:   service,
+		lock:      lock,
+		interval:  interval,
+		batchSize: batchSize,
+		now:       time.Now,
+	}
+}
+
+func (p *poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	defer func() {
+		// ctx is done, the lock is released with a fresh context so that another replica takes over right away
+		if err := p.lock.Release(context.Background()); err != nil {
+			log.Printf("Error lock.Release: %v", err)
+		}
+	}()
+
+	for {
+		// a full batch means there are more transactions waiting
+		if p.poll(ctx) == p.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll returns the number of transactions polled, none when another replica is the leader
+func (p *poller) poll(ctx context.Context) int {
+	leader, err := p.lock.TryAcquire(ctx)
+	if err != nil {
+		log.Printf("Error lock.TryAcquire: %v", err)
+	}
+	if !leader {
+		return 0
+	}
+
+	polled, err := p.service.PollPending(ctx, p.now(), p.batchSize)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Error pending poll: %v", err)
+	}
+	return polled
+}
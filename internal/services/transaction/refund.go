@@ -0,0 +1,123 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+// ErrNotRefundable only done deposits with an amount left to refund can be refunded
+var ErrNotRefundable = errors.New("transaction can't be refunded")
+
+func (s *transactionService) Refund(req models.RefundRequest) (*models.Transaction, error) {
+	deposit, err := s.transRepo.GetTransaction(req.TransactionID)
+	if err != nil {
+		log.Printf("Error db.GetTransaction: %v", err)
+		return nil, err
+	}
+
+	if deposit.Type != models.TransactionTypeDeposit || deposit.Status != models.TransactionStatusDone {
+		return nil, fmt.Errorf("%w: transaction %d is a %s %s", ErrNotRefundable, deposit.ID, deposit.Status, deposit.Type)
+	}
+
+	amount, err := refundAmount(req, deposit)
+	if err != nil {
+		return nil, err
+	}
+
+	// refunds are returned by the gateway which has received the deposit
+	gw, err := s.gateway.GetGatewayByID(deposit.GatewayID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.Transaction{
+		UserID:    deposit.UserID,
+		Amount:    amount,
+		GatewayID: deposit.GatewayID,
+		CountryID: deposit.CountryID,
+		Status:    models.TransactionStatusCreated,
+		Type:      models.TransactionTypeRefund,
+		ParentID:  deposit.ID,
+	}
+	if refund.Conversion, err = parentConversion(amount, deposit); err != nil {
+		return nil, err
+	}
+
+	// the reason of the refund is published with its created event
+	refund.ID, err = s.transRepo.CreateRefund(*refund, func(tx models.Transaction) (models.OutboxMessage, error) {
+		update := models.StatusUpdate{Source: models.StatusSourceAPI, Reason: req.Reason}
+		return events.NewTransactionEvent(tx, "", update, time.Now()).OutboxMessage(s.serializer)
+	})
+	if err != nil {
+		log.Printf("Error db.CreateRefund: %v", err)
+		return nil, err
+	}
+
+	// the refunded funds leave the wallet, they are held like the funds of a withdrawal until the gateway answers.
+	// The user may have spent the deposit meanwhile, the refund is still owed to the payer and the wallet goes negative
+	if err = s.ledger.ReserveOverdraft(refund.ID, refund.UserID, refund.Amount); err != nil {
+		log.Printf("Error ledger.ReserveOverdraft: %v", err)
+		if failErr := s.transition(refund, apiUpdate(refund, models.TransactionStatusFailed, err.Error())); failErr != nil {
+			return nil, failErr
+		}
+		return nil, err
+	}
+
+	err = s.submitFollowUp(refund, gw, func() (*adapters.Response, error) {
+		return s.gateway.Refund(gw, *refund, *deposit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// refundAmount amount of the request in the deposit currency, the refundable rest of the deposit when it is not set
+func refundAmount(req models.RefundRequest, deposit *models.Transaction) (money.Money, error) {
+	if req.Amount.IsZero() {
+		refundable := deposit.RefundableAmount()
+		if !refundable.IsPositive() {
+			return money.Money{}, fmt.Errorf("%w: transaction %d is refunded", ErrNotRefundable, deposit.ID)
+		}
+		return refundable, nil
+	}
+
+	if req.Amount.Sign() < 0 {
+		return money.Money{}, fmt.Errorf("%w, must be greater than zero", ErrInvalidAmount)
+	}
+
+	amount, err := money.FromDecimal(req.Amount, deposit.Amount.Currency())
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+	}
+	return amount, nil
+}
+
+// parentConversion converts the refund or capture with the rate of its parent, the user gets back what has been
+// paid in and is charged what has been authorized
+func parentConversion(amount money.Money, parent *models.Transaction) (*models.FXConversion, error) {
+	if parent.Conversion == nil {
+		return nil, nil
+	}
+
+	settlement := parent.Conversion.Amount.Currency()
+	converted, err := amount.Convert(parent.Conversion.Rate, settlement)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s to %s: %v", ErrConversionFailed, amount.Currency(), settlement, err)
+	}
+
+	return &models.FXConversion{
+		Amount: converted,
+		Rate:   parent.Conversion.Rate,
+		Source: parent.Conversion.Source,
+		RateAt: parent.Conversion.RateAt,
+	}, nil
+}
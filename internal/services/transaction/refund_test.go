@@ -0,0 +1,252 @@
+package transaction
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/ledger"
+	mockLedger "payment-gateway/internal/ledger/mocks"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/repository/mocks"
+	mockGateway "payment-gateway/internal/services/gateway/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doneDeposit() *models.Transaction {
+	return &models.Transaction{
+		ID: 7, UserID: 1, GatewayID: 10, CountryID: 2, Type: models.TransactionTypeDeposit, Status: models.TransactionStatusDone,
+		Amount: money.MustParse("100", "EUR"), Refunded: money.MustParse("30", "EUR"), GatewayReference: "pay_7",
+	}
+}
+
+func TestRefund_Partial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockLedger := mockLedger.NewMockLedger(ctrl)
+
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, mockLedger, jsonSerializer)
+
+	gw := &models.Gateway{ID: 10, Name: "jsonpay"}
+	deposit := doneDeposit()
+	refund := models.Transaction{
+		UserID: 1, Amount: money.MustParse("25", "EUR"), GatewayID: 10, CountryID: 2,
+		Status: models.TransactionStatusCreated, Type: models.TransactionTypeRefund, ParentID: 7,
+	}
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(deposit, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(gw, nil)
+	mockTransRepo.EXPECT().CreateRefund(refund, gomock.Any()).Return(8, nil)
+	mockLedger.EXPECT().ReserveOverdraft(8, 1, money.MustParse("25", "EUR")).Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+
+	refund.ID, refund.Status = 8, models.TransactionStatusSubmitted
+	mockGateway.EXPECT().Refund(gw, refund, *deposit).Return(&adapters.Response{Reference: "ref_8", Status: models.TransactionStatusPending}, nil)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().UpdateGatewayReference(8, "ref_8").Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusSubmitted, models.TransactionStatusPending), gomock.Any(), gomock.Any()).Return(nil)
+
+	result, err := service.Refund(models.RefundRequest{TransactionID: 7, Amount: money.MustParseDecimal("25"), Reason: "order cancelled"})
+	require.NoError(t, err)
+	assert.Equal(t, 8, result.ID)
+	assert.Equal(t, 7, result.ParentID)
+	assert.Equal(t, models.TransactionStatusPending, result.Status)
+}
+
+func TestRefund_WholeRefundableAmountWithConversion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockLedger := mockLedger.NewMockLedger(ctrl)
+
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, mockLedger, jsonSerializer)
+
+	rateAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deposit := doneDeposit()
+	deposit.Conversion = &models.FXConversion{Amount: money.MustParse("108", "USD"), Rate: money.MustParseDecimal("1.08"), Source: "ecb", RateAt: rateAt}
+	// the refund is converted with the rate of the deposit, not the current one
+	conversion := models.FXConversion{Amount: money.MustParse("75.60", "USD"), Rate: money.MustParseDecimal("1.08"), Source: "ecb", RateAt: rateAt}
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(deposit, nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10, Name: "xmlpay"}, nil)
+	mockTransRepo.EXPECT().CreateRefund(gomock.Any(), gomock.Any()).Return(8, nil)
+	mockLedger.EXPECT().ReserveOverdraft(8, 1, money.MustParse("70", "EUR")).Return(nil)
+	mockTransRepo.EXPECT().UpdateConversion(8, conversion).Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockGateway.EXPECT().Refund(gomock.Any(), gomock.Any(), *deposit).Return(&adapters.Response{Reference: "X-8", Status: models.TransactionStatusPending}, nil)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().UpdateGatewayReference(8, "X-8").Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusSubmitted, models.TransactionStatusPending), gomock.Any(), gomock.Any()).Return(nil)
+
+	result, err := service.Refund(models.RefundRequest{TransactionID: 7})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("70", "EUR"), result.Amount)
+	assert.Equal(t, &conversion, result.Conversion)
+}
+
+func TestRefund_GatewayFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockLedger := mockLedger.NewMockLedger(ctrl)
+
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, mockAttemptRepo, nil, mockLedger, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10, Name: "jsonpay"}, nil)
+	mockTransRepo.EXPECT().CreateRefund(gomock.Any(), gomock.Any()).Return(8, nil)
+	mockLedger.EXPECT().ReserveOverdraft(8, 1, gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockGateway.EXPECT().Refund(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, adapters.ErrTimeout)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	// the refund is not routed to another gateway, it fails and releases the held funds
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusSubmitted, models.TransactionStatusFailed), gomock.Any(),
+		ledger.Posting{TransactionID: 8, Hold: ledger.HoldReleased}).Return(nil)
+
+	result, err := service.Refund(models.RefundRequest{TransactionID: 7, Amount: money.MustParseDecimal("10")})
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrGatewayFailed)
+}
+
+func TestRefund_Rejected(t *testing.T) {
+	pending := doneDeposit()
+	pending.Status = models.TransactionStatusPending
+	withdrawal := doneDeposit()
+	withdrawal.Type = models.TransactionTypeWithdrawal
+	refunded := doneDeposit()
+	refunded.Refunded = refunded.Amount
+
+	tests := []struct {
+		name    string
+		tx      *models.Transaction
+		amount  string
+		wantErr error
+	}{
+		{name: "not done", tx: pending, wantErr: ErrNotRefundable},
+		{name: "not a deposit", tx: withdrawal, wantErr: ErrNotRefundable},
+		{name: "fully refunded", tx: refunded, wantErr: ErrNotRefundable},
+		{name: "negative amount", tx: doneDeposit(), amount: "-5", wantErr: ErrInvalidAmount},
+		{name: "finer than currency", tx: doneDeposit(), amount: "5.001", wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+			service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+			req := models.RefundRequest{TransactionID: 7}
+			if tt.amount != "" {
+				req.Amount = money.MustParseDecimal(tt.amount)
+			}
+			mockTransRepo.EXPECT().GetTransaction(7).Return(tt.tx, nil)
+
+			_, err := service.Refund(req)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRefund_ExceedsDepositAmount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10}, nil)
+	mockTransRepo.EXPECT().CreateRefund(gomock.Any(), gomock.Any()).Return(0, fmt.Errorf("%w: 30.00 of 100.00 refunded, 80.00 requested", repository.ErrRefundExceedsAmount))
+
+	_, err := service.Refund(models.RefundRequest{TransactionID: 7, Amount: money.MustParseDecimal("80")})
+	assert.ErrorIs(t, err, repository.ErrRefundExceedsAmount)
+}
+
+func TestRefund_DepositChangedConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(mockGateway, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+	mockGateway.EXPECT().GetGatewayByID(10).Return(&models.Gateway{ID: 10}, nil)
+	// the deposit has been reversed by another refund since it has been read, nothing is reserved
+	mockTransRepo.EXPECT().CreateRefund(gomock.Any(), gomock.Any()).Return(0, fmt.Errorf("%w: transaction 7 is a reversed deposit", repository.ErrStatusConflict))
+
+	_, err := service.Refund(models.RefundRequest{TransactionID: 7, Amount: money.MustParseDecimal("20")})
+	assert.ErrorIs(t, err, repository.ErrStatusConflict)
+}
+
+func TestUpdateStatus_DoneRefundReversesRefundedDeposit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	refunded := doneDeposit()
+	refunded.Refunded = refunded.Amount
+
+	mockTransRepo.EXPECT().GetTransaction(8).Return(&models.Transaction{
+		ID: 8, UserID: 1, GatewayID: 10, Type: models.TransactionTypeRefund, ParentID: 7,
+		Status: models.TransactionStatusPending, Amount: money.MustParse("70", "EUR"),
+	}, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusPending, models.TransactionStatusDone), gomock.Any(), ledger.Posting{
+		TransactionID: 8,
+		Entry: &ledger.Entry{
+			TransactionID: 8,
+			Type:          ledger.EntryRefund,
+			Description:   "refund of transaction 8",
+			Lines: []ledger.Line{
+				{Account: ledger.UserWallet(1, "EUR"), Amount: money.MustParse("-70", "EUR")},
+				{Account: ledger.GatewayClearing(10, "EUR"), Amount: money.MustParse("70", "EUR")},
+			},
+		},
+		Hold: ledger.HoldCaptured,
+	}).Return(nil)
+	// the last refund has returned the whole deposit
+	mockTransRepo.EXPECT().GetTransaction(7).Return(refunded, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusDone, models.TransactionStatusReversed), gomock.Any(), ledger.Posting{}).Return(nil)
+
+	err := service.UpdateStatus(models.StatusUpdate{TransactionID: 8, Status: models.TransactionStatusDone, GatewayID: 10, Source: models.StatusSourceCallback})
+	assert.NoError(t, err)
+}
+
+func TestUpdateStatus_DonePartialRefundKeepsDeposit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(8).Return(&models.Transaction{
+		ID: 8, UserID: 1, GatewayID: 10, Type: models.TransactionTypeRefund, ParentID: 7,
+		Status: models.TransactionStatusPending, Amount: money.MustParse("30", "EUR"),
+	}, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusPending, models.TransactionStatusDone), gomock.Any(), gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().GetTransaction(7).Return(doneDeposit(), nil)
+
+	err := service.UpdateStatus(models.StatusUpdate{TransactionID: 8, Status: models.TransactionStatusDone, GatewayID: 10})
+	assert.NoError(t, err)
+}
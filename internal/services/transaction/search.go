@@ -0,0 +1,152 @@
+package transaction
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+)
+
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 500
+)
+
+// ErrInvalidFilter the search filter or cursor can't be used
+var ErrInvalidFilter = errors.New("invalid transaction filter")
+
+// searchCursor NextCursor content, the sort is kept so a cursor can't continue a page sorted differently
+type searchCursor struct {
+	Sort      string        `json:"s"`
+	Desc      bool          `json:"d,omitempty"`
+	CreatedAt time.Time     `json:"c"`
+	Amount    money.Decimal `json:"a"`
+	ID        int           `json:"i"`
+}
+
+func (s *transactionService) SearchTransactions(filter models.TransactionFilter) (models.TransactionPage, error) {
+	if err := validateFilter(&filter); err != nil {
+		return models.TransactionPage{}, err
+	}
+
+	after, err := decodeCursor(filter)
+	if err != nil {
+		return models.TransactionPage{}, err
+	}
+
+	// one more transaction tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	transactions, err := s.transRepo.SearchTransactions(filter, after)
+	if err != nil {
+		log.Printf("Error db.SearchTransactions: %v", err)
+		return models.TransactionPage{}, err
+	}
+
+	page := models.TransactionPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = encodeCursor(filter, page.Transactions[limit-1])
+	}
+	return page, nil
+}
+
+func (s *transactionService) GetTransaction(transactionID int) (*models.TransactionDetail, error) {
+	tx, err := s.transRepo.GetTransaction(transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.transRepo.GetStatusHistory(transactionID)
+	if err != nil {
+		log.Printf("Error db.GetStatusHistory: %v", err)
+		return nil, err
+	}
+
+	attempts, err := s.attemptRepo.GetAttempts(transactionID)
+	if err != nil {
+		log.Printf("Error db.GetAttempts: %v", err)
+		return nil, err
+	}
+
+	return &models.TransactionDetail{Transaction: *tx, History: history, Attempts: attempts}, nil
+}
+
+// validateFilter checks the filter values and sets the default sort and limit
+func validateFilter(filter *models.TransactionFilter) error {
+	switch filter.Type {
+	case "", models.TransactionTypeDeposit, models.TransactionTypeWithdrawal, models.TransactionTypeRefund,
+		models.TransactionTypeAuthorization, models.TransactionTypeCapture:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, filter.Type)
+	}
+
+	if filter.Status != "" && !isValidStatus(filter.Status) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, filter.Status)
+	}
+	if filter.Currency != "" && !money.IsValidCurrency(filter.Currency) {
+		return fmt.Errorf("%w: unknown currency %q", ErrInvalidFilter, filter.Currency)
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.Cmp(*filter.MaxAmount) > 0 {
+		return fmt.Errorf("%w: min_amount is greater than max_amount", ErrInvalidFilter)
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", ErrInvalidFilter)
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = models.TransactionSortCreatedAt
+	case models.TransactionSortCreatedAt, models.TransactionSortAmount:
+	default:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidFilter, filter.Sort)
+	}
+	// amounts of different currencies can't be compared
+	if filter.Currency == "" && (filter.MinAmount != nil || filter.MaxAmount != nil || filter.Sort == models.TransactionSortAmount) {
+		return fmt.Errorf("%w: currency is required with min_amount, max_amount and sort=amount", ErrInvalidFilter)
+	}
+
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = DefaultSearchLimit
+	case filter.Limit < 0 || filter.Limit > MaxSearchLimit:
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFilter, MaxSearchLimit)
+	}
+	return nil
+}
+
+func encodeCursor(filter models.TransactionFilter, last models.Transaction) string {
+	data, _ := json.Marshal(searchCursor{
+		Sort:      filter.Sort,
+		Desc:      filter.Desc,
+		CreatedAt: last.CreatedAt,
+		Amount:    last.Amount.Decimal(),
+		ID:        last.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(filter models.TransactionFilter) (*models.TransactionCursor, error) {
+	if filter.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+	}
+	if cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+		return nil, fmt.Errorf("%w: cursor belongs to a search with another sort", ErrInvalidFilter)
+	}
+
+	return &models.TransactionCursor{CreatedAt: cursor.CreatedAt, Amount: cursor.Amount, ID: cursor.ID}, nil
+}
@@ -0,0 +1,118 @@
+package transaction
+
+import (
+	"testing"
+	"time"
+
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
+	"payment-gateway/internal/repository/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func searchResult(ids ...int) []models.Transaction {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	transactions := make([]models.Transaction, 0, len(ids))
+	for _, id := range ids {
+		transactions = append(transactions, models.Transaction{
+			ID: id, Amount: money.MustParse("10", "EUR"), CreatedAt: created.Add(-time.Duration(id) * time.Minute),
+		})
+	}
+	return transactions
+}
+
+func TestSearchTransactions_Pages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	filter := models.TransactionFilter{UserID: 1, Desc: true, Limit: 2}
+
+	// the repository is asked for one more transaction to find out whether there is a next page
+	mockTransRepo.EXPECT().SearchTransactions(models.TransactionFilter{
+		UserID: 1, Desc: true, Limit: 3, Sort: models.TransactionSortCreatedAt,
+	}, (*models.TransactionCursor)(nil)).Return(searchResult(9, 8, 7), nil)
+
+	page, err := service.SearchTransactions(filter)
+	require.NoError(t, err)
+	assert.Len(t, page.Transactions, 2)
+	require.NotEmpty(t, page.NextCursor)
+
+	filter.Cursor = page.NextCursor
+	mockTransRepo.EXPECT().SearchTransactions(gomock.Any(), &models.TransactionCursor{
+		CreatedAt: searchResult(8)[0].CreatedAt, Amount: money.MustParseDecimal("10.00"), ID: 8,
+	}).Return(searchResult(7), nil)
+
+	page, err = service.SearchTransactions(filter)
+	require.NoError(t, err)
+	assert.Len(t, page.Transactions, 1)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestSearchTransactions_InvalidFilter(t *testing.T) {
+	minAmount, maxAmount := money.MustParseDecimal("100"), money.MustParseDecimal("10")
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cursor := encodeCursor(models.TransactionFilter{Sort: models.TransactionSortAmount}, searchResult(1)[0])
+
+	for name, filter := range map[string]models.TransactionFilter{
+		"type":                        {Type: "transfer"},
+		"status":                      {Status: "processing"},
+		"currency":                    {Currency: "XYZ"},
+		"amount range":                {Currency: "EUR", MinAmount: &minAmount, MaxAmount: &maxAmount},
+		"min amount of any currency":  {MinAmount: &maxAmount},
+		"max amount of any currency":  {MaxAmount: &maxAmount},
+		"amount sort of any currency": {Sort: models.TransactionSortAmount},
+		"created range":               {CreatedFrom: created, CreatedTo: created},
+		"sort":                        {Sort: "user_id"},
+		"limit":                       {Limit: MaxSearchLimit + 1},
+		"malformed cursor":            {Cursor: "not a cursor"},
+		"cursor of another sort":      {Cursor: cursor},
+	} {
+		ctrl := gomock.NewController(t)
+		service := NewTransactionService(nil, nil, nil, mocks.NewMockTransactionRepository(ctrl), nil, nil, nil, jsonSerializer)
+
+		_, err := service.SearchTransactions(filter)
+		assert.ErrorIs(t, err, ErrInvalidFilter, name)
+		ctrl.Finish()
+	}
+}
+
+func TestGetTransaction_Detail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, mockAttemptRepo, nil, nil, jsonSerializer)
+
+	tx := searchResult(7)[0]
+	history := []models.StatusTransition{{TransactionID: 7, From: models.TransactionStatusCreated, To: models.TransactionStatusSubmitted}}
+	attempts := []models.TransactionAttempt{{TransactionID: 7, GatewayID: 10, AttemptNo: 1, Status: models.AttemptStatusSucceeded}}
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(&tx, nil)
+	mockTransRepo.EXPECT().GetStatusHistory(7).Return(history, nil)
+	mockAttemptRepo.EXPECT().GetAttempts(7).Return(attempts, nil)
+
+	detail, err := service.GetTransaction(7)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TransactionDetail{Transaction: tx, History: history, Attempts: attempts}, detail)
+}
+
+func TestGetTransaction_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(nil, repository.ErrTransactionNotFound)
+
+	_, err := service.GetTransaction(7)
+	assert.ErrorIs(t, err, repository.ErrTransactionNotFound)
+}
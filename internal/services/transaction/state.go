@@ -0,0 +1,192 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/ledger"
+	"payment-gateway/internal/models"
+	"payment-gateway/internal/repository"
+)
+
+// maxTransitionAttempts compare-and-set attempts when the status is changed concurrently
+const maxTransitionAttempts = 3
+
+// paymentTransitions statuses the payment may move to from its current status, a done deposit is reversed
+// by its refunds, the other done payments and failed and expired payments are final
+var paymentTransitions = map[string][]string{
+	models.TransactionStatusCreated:   {models.TransactionStatusSubmitted, models.TransactionStatusFailed},
+	models.TransactionStatusSubmitted: {models.TransactionStatusPending, models.TransactionStatusDone, models.TransactionStatusFailed},
+	models.TransactionStatusPending:   {models.TransactionStatusDone, models.TransactionStatusFailed, models.TransactionStatusExpired},
+}
+
+// authorizationTransitions statuses the authorization may move to, it is authorized instead of done
+// and failed, expired, captured and voided are final
+var authorizationTransitions = map[string][]string{
+	models.TransactionStatusCreated:    {models.TransactionStatusSubmitted, models.TransactionStatusFailed},
+	models.TransactionStatusSubmitted:  {models.TransactionStatusPending, models.TransactionStatusAuthorized, models.TransactionStatusFailed},
+	models.TransactionStatusPending:    {models.TransactionStatusAuthorized, models.TransactionStatusFailed, models.TransactionStatusExpired},
+	models.TransactionStatusAuthorized: {models.TransactionStatusCaptured, models.TransactionStatusVoided, models.TransactionStatusExpired},
+}
+
+// depositTransitions only deposits are reversed, see settleParent
+var depositTransitions = withTransitions(paymentTransitions, models.TransactionStatusDone, models.TransactionStatusReversed)
+
+// transitions by transaction type, the ledger postings of ledger.ForTransition and settleParent only
+// exist for these paths
+var transitions = map[string]map[string][]string{
+	models.TransactionTypeDeposit:       depositTransitions,
+	models.TransactionTypeWithdrawal:    paymentTransitions,
+	models.TransactionTypeRefund:        paymentTransitions,
+	models.TransactionTypeCapture:       paymentTransitions,
+	models.TransactionTypeAuthorization: authorizationTransitions,
+}
+
+// settlementStatuses are only reached through settleParent, once the children of the transaction have
+// moved the funds, or through Void once the gateway released the authorization, UpdateStatus can't set them
+var settlementStatuses = map[string]bool{
+	models.TransactionStatusReversed: true,
+	models.TransactionStatusCaptured: true,
+	models.TransactionStatusVoided:   true,
+}
+
+func withTransitions(base map[string][]string, from string, to ...string) map[string][]string {
+	extended := make(map[string][]string, len(base)+1)
+	for status, next := range base {
+		extended[status] = next
+	}
+	extended[from] = append(append([]string(nil), extended[from]...), to...)
+	return extended
+}
+
+// IllegalTransitionError the transaction can't move from its current status to the requested one
+type IllegalTransitionError struct {
+	TransactionID int
+	From          string
+	To            string
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("illegal transition of transaction %d from %s to %s", e.TransactionID, e.From, e.To)
+}
+
+// CanTransition reports whether the transaction of the type in status from may move to status to
+func CanTransition(txType, from, to string) bool {
+	for _, status := range transitions[txType][from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidStatus(status string) bool {
+	switch status {
+	case models.TransactionStatusCreated, models.TransactionStatusSubmitted, models.TransactionStatusPending,
+		models.TransactionStatusDone, models.TransactionStatusFailed, models.TransactionStatusExpired,
+		models.TransactionStatusReversed, models.TransactionStatusAuthorized, models.TransactionStatusCaptured,
+		models.TransactionStatusVoided:
+		return true
+	default:
+		return false
+	}
+}
+
+// transition moves tx to update.Status, repeating the current status is a no-op so duplicated
+// notifications are accepted. When the status is changed concurrently the transition is checked again
+// against the new status
+func (s *transactionService) transition(tx *models.Transaction, update models.StatusUpdate) error {
+	for attempt := 1; ; attempt++ {
+		if tx.Status == update.Status {
+			return nil
+		}
+		change, message, posting, err := s.statusChange(tx, update)
+		if err != nil {
+			return err
+		}
+
+		err = s.transRepo.TransitionStatus(change, message, posting)
+		if err == nil {
+			tx.Status = update.Status
+			if tx.ParentID != 0 && tx.Status == models.TransactionStatusDone {
+				s.settleParent(tx, update)
+			}
+			return nil
+		}
+		if !errors.Is(err, repository.ErrStatusConflict) || attempt == maxTransitionAttempts {
+			log.Printf("Error db.TransitionStatus: %v", err)
+			return err
+		}
+
+		current, err := s.transRepo.GetTransaction(tx.ID)
+		if err != nil {
+			log.Printf("Error db.GetTransaction: %v", err)
+			return err
+		}
+		tx.Status = current.Status
+	}
+}
+
+// statusChange transition of tx to update.Status with its event message and ledger posting,
+// the ledger is posted together with the status so a done deposit always has its entry
+func (s *transactionService) statusChange(tx *models.Transaction, update models.StatusUpdate) (models.StatusTransition,
+	models.OutboxMessage, ledger.Posting, error) {
+	change := models.StatusTransition{
+		TransactionID: tx.ID,
+		From:          tx.Status,
+		To:            update.Status,
+		Source:        update.Source,
+		Actor:         update.Actor,
+		Reason:        update.Reason,
+	}
+	if !CanTransition(tx.Type, tx.Status, update.Status) {
+		return change, models.OutboxMessage{}, ledger.Posting{}, &IllegalTransitionError{TransactionID: tx.ID, From: tx.Status, To: update.Status}
+	}
+
+	changed := *tx
+	changed.Status = update.Status
+	message, err := events.NewTransactionEvent(changed, tx.Status, update, time.Now()).OutboxMessage(s.serializer)
+	if err != nil {
+		log.Printf("Error events.OutboxMessage: %v", err)
+		return change, message, ledger.Posting{}, err
+	}
+
+	return change, message, ledger.ForTransition(changed, update.Status), nil
+}
+
+// settleParent moves the parent of the done child: the authorization is captured by its capture and the deposit
+// is reversed once its done refunds have returned the whole amount. The child is already done so failures are only logged
+func (s *transactionService) settleParent(child *models.Transaction, update models.StatusUpdate) {
+	parent, err := s.transRepo.GetTransaction(child.ParentID)
+	if err != nil {
+		log.Printf("Error db.GetTransaction: %v", err)
+		return
+	}
+
+	var status, reason string
+	switch child.Type {
+	case models.TransactionTypeCapture:
+		status, reason = models.TransactionStatusCaptured, fmt.Sprintf("captured by transaction %d", child.ID)
+	case models.TransactionTypeRefund:
+		if parent.RefundableAmount().IsPositive() {
+			return
+		}
+		status, reason = models.TransactionStatusReversed, fmt.Sprintf("refunded by transaction %d", child.ID)
+	default:
+		return
+	}
+
+	err = s.transition(parent, models.StatusUpdate{
+		TransactionID: parent.ID,
+		Status:        status,
+		Source:        update.Source,
+		Actor:         update.Actor,
+		Reason:        reason,
+	})
+	if err != nil {
+		log.Printf("Error settling transaction %d of %s %d: %v", parent.ID, child.Type, child.ID, err)
+	}
+}
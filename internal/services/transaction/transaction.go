@@ -4,71 +4,125 @@ package transaction
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/events"
 	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/ledger"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
 	"payment-gateway/internal/repository"
+	"payment-gateway/internal/services/fx"
 	"payment-gateway/internal/services/gateway"
-	"payment-gateway/internal/util"
 )
 
 type transactionService struct {
-	gateway   gateway.ServiceGateway
-	userRepo  repository.UserRepository
-	transRepo repository.TransactionRepository
-	publisher kafka.KafkaPublisher
+	gateway     gateway.ServiceGateway
+	userRepo    repository.UserRepository
+	countryRepo repository.CountryRepository
+	transRepo   repository.TransactionRepository
+	attemptRepo repository.AttemptRepository
+	fx          fx.Service
+	ledger      ledger.Ledger
+	serializer  kafka.Serializer
 }
 
+// gatewayCall sends the transaction to the gateway
+type gatewayCall func(gw *models.Gateway, tx models.Transaction) (*adapters.Response, error)
+
 type TransactionService interface {
 	Deposit(req models.TransactionRequest) (*models.Transaction, error)
 	Withdrawal(req models.TransactionRequest) (*models.Transaction, error)
-	UpdateStatus(txID int, gatewayID int64, status string) error
+	// Refund returns the amount of the done deposit through its gateway as a refund transaction,
+	// refunds of the deposit which have not failed can't exceed its amount
+	Refund(req models.RefundRequest) (*models.Transaction, error)
+	// UpdateStatus applies the status reported by the gateway or set by an operator,
+	// *IllegalTransitionError is returned when the transaction can't move to the status
+	UpdateStatus(update models.StatusUpdate) error
+	// SearchTransactions returns a page of the transactions matching the filter,
+	// ErrInvalidFilter is returned for unknown values and cursors of another search
+	SearchTransactions(filter models.TransactionFilter) (models.TransactionPage, error)
+	// GetTransaction returns the transaction with its status history and gateway attempts
+	GetTransaction(transactionID int) (*models.TransactionDetail, error)
+	// Authorize holds the amount on the user's payment method through the routed gateway,
+	// the authorization expires after AuthorizationTTL unless it is captured or voided
+	Authorize(req models.TransactionRequest) (*models.Transaction, error)
+	// Capture collects the whole or a part of the authorized amount as a capture transaction,
+	// an authorization is captured once
+	Capture(req models.CaptureRequest) (*models.Transaction, error)
+	// Void releases the authorized amount on the gateway without collecting it
+	Void(authorizationID int) (*models.Transaction, error)
+	// ExpireAuthorizations moves up to limit authorizations past their expiry to expired,
+	// it returns the number of expired authorizations
+	ExpireAuthorizations(now time.Time, limit int) (int, error)
+	// PollPending asks the gateways for the status of up to limit transactions pending, or left created or
+	// submitted, for longer than the poll threshold of their gateway and applies it, a transaction pending past
+	// PendingDeadline is expired. It stops when ctx is done and returns the number of transactions polled
+	PollPending(ctx context.Context, now time.Time, limit int) (int, error)
 }
 
 const (
-	amountErr  = "invalid amount, must be greater than zero"
-	userErr    = "invalid user"
-	maxRetries = 3
+	amountErr   = "invalid amount"
+	userErr     = "invalid user"
+	currencyErr = "invalid currency, must be an ISO 4217 code"
+)
+
+var (
+	ErrGatewayFailed = errors.New("transaction failed on all gateways")
+
+	ErrInvalidAmount   = errors.New(amountErr)
+	ErrInvalidUser     = errors.New(userErr)
+	ErrInvalidCurrency = errors.New(currencyErr)
+	// ErrCurrencyNotAllowed currency differs from the currency of the user's country
+	ErrCurrencyNotAllowed = errors.New("currency is not allowed in the user's country")
+	// ErrConversionFailed amount could not be converted to the settlement currency of the gateway
+	ErrConversionFailed = errors.New("currency conversion failed")
+	// ErrGatewayMismatch status is reported by a gateway the transaction was not sent to
+	ErrGatewayMismatch = errors.New("transaction is processed by another gateway")
+	ErrInvalidStatus   = errors.New("invalid transaction status")
 )
 
 func NewTransactionService(
 	gw gateway.ServiceGateway,
 	userRepo repository.UserRepository,
+	countryRepo repository.CountryRepository,
 	transRepo repository.TransactionRepository,
-	kafkaPublisher kafka.KafkaPublisher,
-
+	attemptRepo repository.AttemptRepository,
+	fxService fx.Service,
+	ledger ledger.Ledger,
+	serializer kafka.Serializer,
 ) TransactionService {
 	return &transactionService{
-		gateway:   gw,
-		userRepo:  userRepo,
-		transRepo: transRepo,
-		publisher: kafkaPublisher,
+		gateway:     gw,
+		userRepo:    userRepo,
+		countryRepo: countryRepo,
+		transRepo:   transRepo,
+		attemptRepo: attemptRepo,
+		fx:          fxService,
+		ledger:      ledger,
+		serializer:  serializer,
 	}
 }
 
 func (s *transactionService) Deposit(req models.TransactionRequest) (*models.Transaction, error) {
-	if err := s.validateTransaction(req); err != nil {
+	amount, err := s.validateTransaction(req)
+	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.transaction(req, models.TransactionTypeDeposit)
+	tx, gateways, err := s.transaction(req.UserID, amount, models.TransactionTypeDeposit)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = util.RetryOperation(func() error {
-		err = s.gateway.Deposit(*tx)
-		return err
-	}, maxRetries); err != nil {
-
-		if err = s.transRepo.UpdateStatus(tx.ID, models.TransactionStatusFailed); err != nil {
-			return nil, errors.New("error s.transRepo.UpdateStatus")
-		}
-
-		return nil, errors.New("util.RetryOperation")
+	if err = s.route(tx, gateways, s.gateway.Deposit); err != nil {
+		return nil, err
 	}
 
 	return tx, nil
@@ -76,109 +130,280 @@ func (s *transactionService) Deposit(req models.TransactionRequest) (*models.Tra
 }
 
 func (s *transactionService) Withdrawal(req models.TransactionRequest) (*models.Transaction, error) {
-	if err := s.validateTransaction(req); err != nil {
+	amount, err := s.validateTransaction(req)
+	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.transaction(req, models.TransactionTypeWithdrawal)
+	tx, gateways, err := s.transaction(req.UserID, amount, models.TransactionTypeWithdrawal)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = util.RetryOperation(func() error {
-		err = s.gateway.Withdrawal(*tx)
-		return err
-	}, maxRetries); err != nil {
-
-		if err = s.transRepo.UpdateStatus(tx.ID, models.TransactionStatusFailed); err != nil {
-			return nil, errors.New("error s.transRepo.UpdateStatus")
+	// the funds are held until the withdrawal is done or fails, concurrent withdrawals can't spend them twice
+	if err = s.ledger.Reserve(tx.ID, tx.UserID, tx.Amount); err != nil {
+		log.Printf("Error ledger.Reserve: %v", err)
+		if failErr := s.transition(tx, apiUpdate(tx, models.TransactionStatusFailed, err.Error())); failErr != nil {
+			return nil, failErr
 		}
+		return nil, err
+	}
 
-		return nil, errors.New("util.RetryOperation")
+	if err = s.route(tx, gateways, s.gateway.Withdrawal); err != nil {
+		return nil, err
 	}
 
 	return tx, nil
 }
 
-func (s *transactionService) transaction(req models.TransactionRequest, transactionType string) (*models.Transaction, error) {
-	user, err := s.userRepo.GetUserByID(req.UserID)
+func (s *transactionService) transaction(userID int, amount money.Money, transactionType string) (*models.Transaction, []models.Gateway, error) {
+	user, err := s.userRepo.GetUserByID(userID)
 	if err != nil {
 		log.Printf("Error db.GetUserByID: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	gateway, err := s.gateway.GetGateway(req.UserID)
+	country, err := s.countryRepo.GetCountryByID(user.CountryID)
 	if err != nil {
-		return nil, err
+		log.Printf("Error db.GetCountryByID: %v", err)
+		return nil, nil, err
+	}
+
+	if amount.Currency() != money.NormalizeCurrency(country.Currency) {
+		return nil, nil, fmt.Errorf("%w: %s is not accepted in %s, expected %s",
+			ErrCurrencyNotAllowed, amount.Currency(), country.Code, strings.TrimSpace(country.Currency))
+	}
+
+	gateways, err := s.gateway.GetGateways(models.RoutingContext{
+		CountryID: user.CountryID,
+		Amount:    amount,
+		Type:      transactionType,
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	tx := models.Transaction{
 		UserID:    user.ID,
-		Amount:    req.Amount,
-		GatewayID: gateway.ID,
+		Amount:    amount,
+		GatewayID: gateways[0].ID,
 		CountryID: user.CountryID,
-		Status:    models.TransactionStatusPending,
+		Status:    models.TransactionStatusCreated,
 		Type:      transactionType,
 	}
+	if transactionType == models.TransactionTypeAuthorization {
+		tx.ExpiresAt = time.Now().Add(AuthorizationTTL)
+	}
 
-	tx.ID, err = s.transRepo.CreateTransaction(tx)
+	// the event is published by the outbox relay once the transaction is committed
+	tx.ID, err = s.transRepo.CreateTransaction(tx, s.createdMessage)
 	if err != nil {
 		log.Printf("Error db.CreateTransaction: %v", err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	return &tx, gateways, nil
+}
+
+// createdMessage outbox message announcing the new transaction
+func (s *transactionService) createdMessage(tx models.Transaction) (models.OutboxMessage, error) {
+	return events.NewTransactionEvent(tx, "", models.StatusUpdate{Source: models.StatusSourceAPI}, time.Now()).OutboxMessage(s.serializer)
+}
+
+// route sends the transaction to the gateways in priority order,
+// retryable failures (timeouts, 5xx, gateway unavailable) and missing exchange rates fail over to the next gateway
+func (s *transactionService) route(tx *models.Transaction, gateways []models.Gateway, call gatewayCall) error {
+	createdOn := tx.GatewayID
+
+	var lastErr error
+	attempts := 0
+	for i := range gateways {
+		gw := &gateways[i]
+		tx.GatewayID = gw.ID
+
+		var resp *adapters.Response
+		err := s.convert(tx, gw)
+		if err == nil {
+			if submitErr := s.transition(tx, apiUpdate(tx, models.TransactionStatusSubmitted, "sent to gateway "+gw.Name)); submitErr != nil {
+				return submitErr
+			}
+			// gateways skipped for a missing exchange rate have not been called and leave no attempt
+			attempts++
+			resp, err = call(gw, *tx)
+			s.recordAttempt(tx, attempts, resp, err)
+		}
+
+		if err == nil {
+			if gw.ID != createdOn {
+				if err = s.transRepo.UpdateGateway(tx.ID, gw.ID); err != nil {
+					log.Printf("Error db.UpdateGateway: %v", err)
+					return err
+				}
+			}
+			if tx.Conversion != nil {
+				if err = s.transRepo.UpdateConversion(tx.ID, *tx.Conversion); err != nil {
+					log.Printf("Error db.UpdateConversion: %v", err)
+					return err
+				}
+			}
+			return s.applyGatewayResponse(tx, gw, resp)
+		}
+
+		lastErr = err
+		if !gateway.IsRetryable(err) && !errors.Is(err, ErrConversionFailed) {
+			break
+		}
+		log.Printf("Gateway %s failed transaction %d, trying next gateway: %v", gw.Name, tx.ID, err)
+	}
+
+	if err := s.transition(tx, apiUpdate(tx, models.TransactionStatusFailed, lastErr.Error())); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", ErrGatewayFailed, lastErr)
+}
+
+// convert sets the amount in the settlement currency of the gateway,
+// the conversion is cleared for gateways settling in the transaction currency
+func (s *transactionService) convert(tx *models.Transaction, gw *models.Gateway) error {
+	tx.Conversion = nil
+
+	settlement := money.NormalizeCurrency(gw.SettlementCurrency)
+	if settlement == "" || settlement == tx.Amount.Currency() {
+		return nil
 	}
 
-	txByte, err := json.Marshal(tx)
+	conversion, err := s.fx.Convert(context.Background(), tx.Amount, settlement)
 	if err != nil {
-		log.Printf("Error json.Marshal: %v", err)
-		return nil, err
+		log.Printf("Error fx.Convert: %v", err)
+		return fmt.Errorf("%w: %s to %s: %v", ErrConversionFailed, tx.Amount.Currency(), settlement, err)
+	}
+
+	tx.Conversion = conversion
+	return nil
+}
+
+func (s *transactionService) recordAttempt(tx *models.Transaction, attemptNo int, resp *adapters.Response, callErr error) {
+	attempt := models.TransactionAttempt{
+		TransactionID: tx.ID,
+		GatewayID:     tx.GatewayID,
+		AttemptNo:     attemptNo,
+		Status:        models.AttemptStatusSucceeded,
+	}
+	if callErr != nil {
+		attempt.Status = models.AttemptStatusFailed
+		attempt.Error = callErr.Error()
+	} else {
+		attempt.GatewayReference = resp.Reference
+	}
+
+	// history must not break the payment flow
+	if err := s.attemptRepo.CreateAttempt(attempt); err != nil {
+		log.Printf("Error db.CreateAttempt: %v", err)
+	}
+}
+
+// applyGatewayResponse stores gateway reference and the status the gateway has answered with
+func (s *transactionService) applyGatewayResponse(tx *models.Transaction, gw *models.Gateway, resp *adapters.Response) error {
+	if err := s.transRepo.UpdateGatewayReference(tx.ID, resp.Reference); err != nil {
+		log.Printf("Error db.UpdateGatewayReference: %v", err)
+		return err
+	}
+	tx.GatewayReference = resp.Reference
+
+	return s.transition(tx, apiUpdate(tx, gatewayStatus(tx, resp.Status), "response of gateway "+gw.Name))
+}
+
+// submitFollowUp sends the refund or capture to the gateway of its parent, it is converted with the rate of the parent
+// and isn't failed over to other gateways
+func (s *transactionService) submitFollowUp(tx *models.Transaction, gw *models.Gateway,
+	call func() (*adapters.Response, error)) error {
+	if tx.Conversion != nil {
+		if err := s.transRepo.UpdateConversion(tx.ID, *tx.Conversion); err != nil {
+			log.Printf("Error db.UpdateConversion: %v", err)
+			return err
+		}
 	}
 
-	err = s.publisher.PublishTransaction(
-		context.Background(),
-		"txn-12345",
-		txByte,
-		"application/json",
-	)
+	if err := s.transition(tx, apiUpdate(tx, models.TransactionStatusSubmitted, "sent to gateway "+gw.Name)); err != nil {
+		return err
+	}
 
+	resp, err := call()
+	s.recordAttempt(tx, 1, resp, err)
 	if err != nil {
-		log.Printf("Failed to publish transaction: %v", err)
-		return nil, err
+		if failErr := s.transition(tx, apiUpdate(tx, models.TransactionStatusFailed, err.Error())); failErr != nil {
+			return failErr
+		}
+		return fmt.Errorf("%w: %v", ErrGatewayFailed, err)
 	}
 
-	return &tx, nil
+	return s.applyGatewayResponse(tx, gw, resp)
+}
+
+// gatewayStatus status of the transaction for the status reported by its gateway,
+// gateways approve authorizations like payments and the approved authorization is authorized
+func gatewayStatus(tx *models.Transaction, status string) string {
+	if tx.Type == models.TransactionTypeAuthorization && status == models.TransactionStatusDone {
+		return models.TransactionStatusAuthorized
+	}
+	return status
+}
+
+// apiUpdate status change made while the deposit or withdrawal request is processed
+func apiUpdate(tx *models.Transaction, status, reason string) models.StatusUpdate {
+	return models.StatusUpdate{
+		TransactionID: tx.ID,
+		Status:        status,
+		Source:        models.StatusSourceAPI,
+		Actor:         "user:" + strconv.Itoa(tx.UserID),
+		Reason:        reason,
+	}
 }
 
-func (s *transactionService) UpdateStatus(txID int, gatewayID int64, status string) error {
-	statusTx := models.TransactionStatusPending
-	// check status from external gateways
-	switch status {
-	case models.TransactionStatusPending:
-		statusTx = status
-	case models.TransactionStatusFailed:
-		statusTx = status
-	case models.TransactionStatusDone:
-		statusTx = status
-	default:
-		statusTx = models.TransactionStatusPending
+func (s *transactionService) UpdateStatus(update models.StatusUpdate) error {
+	if !isValidStatus(update.Status) {
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, update.Status)
 	}
 
-	_, err := s.transRepo.GetTransaction(txID)
+	tx, err := s.transRepo.GetTransaction(update.TransactionID)
 	if err != nil {
 		log.Printf("Error db.GetTransaction: %v", err)
 		return err
 	}
 
-	return s.transRepo.UpdateStatus(txID, statusTx)
+	// only the gateway processing the transaction may report its status
+	if update.GatewayID != 0 && tx.GatewayID != update.GatewayID {
+		return fmt.Errorf("%w: transaction %d, gateway %d", ErrGatewayMismatch, update.TransactionID, update.GatewayID)
+	}
+	if update.GatewayID != 0 {
+		update.Status = gatewayStatus(tx, update.Status)
+	}
+	if settlementStatuses[update.Status] && tx.Status != update.Status {
+		return &IllegalTransitionError{TransactionID: tx.ID, From: tx.Status, To: update.Status}
+	}
+
+	return s.transition(tx, update)
 }
 
-func (s *transactionService) validateTransaction(req models.TransactionRequest) error {
-	if req.Amount <= 0 {
-		return errors.New(amountErr)
+// validateTransaction returns the request amount in minor units of the request currency
+func (s *transactionService) validateTransaction(req models.TransactionRequest) (money.Money, error) {
+	if req.Amount.Sign() <= 0 {
+		return money.Money{}, fmt.Errorf("%w, must be greater than zero", ErrInvalidAmount)
 	}
 
 	if req.UserID <= 0 {
-		return errors.New(userErr)
+		return money.Money{}, ErrInvalidUser
 	}
 
-	return nil
+	if !money.IsValidCurrency(req.Currency) {
+		return money.Money{}, ErrInvalidCurrency
+	}
+
+	amount, err := money.FromDecimal(req.Amount, req.Currency)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%w: %w", ErrInvalidAmount, err)
+	}
+
+	return amount, nil
 }
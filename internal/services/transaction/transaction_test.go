@@ -1,32 +1,77 @@
 package transaction
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
-	mockPublisher "payment-gateway/internal/kafka/mocks"
+	"payment-gateway/internal/adapters"
+	"payment-gateway/internal/events"
+	"payment-gateway/internal/kafka"
+	"payment-gateway/internal/ledger"
+	mockLedger "payment-gateway/internal/ledger/mocks"
 	"payment-gateway/internal/models"
+	"payment-gateway/internal/money"
+	"payment-gateway/internal/repository"
 	"payment-gateway/internal/repository/mocks"
+	"payment-gateway/internal/services/fx"
+	mockFX "payment-gateway/internal/services/fx/mocks"
 	mockGateway "payment-gateway/internal/services/gateway/mocks"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 )
 
+var jsonSerializer, _ = kafka.NewSerializer("", nil)
+
+var country = models.Country{ID: 2, Code: "DE", Currency: "EUR"}
+
+func routingContext(req models.TransactionRequest, countryID int, transactionType string) models.RoutingContext {
+	amount, _ := money.FromDecimal(req.Amount, req.Currency)
+	return models.RoutingContext{
+		CountryID: countryID,
+		Amount:    amount,
+		Type:      transactionType,
+	}
+}
+
+// transitionMatcher matches the status transition ignoring its source, actor and reason
+type transitionMatcher struct {
+	transactionID int
+	from, to      string
+}
+
+func transition(transactionID int, from, to string) gomock.Matcher {
+	return transitionMatcher{transactionID: transactionID, from: from, to: to}
+}
+
+func (m transitionMatcher) Matches(x interface{}) bool {
+	t, ok := x.(models.StatusTransition)
+	return ok && t.TransactionID == m.transactionID && t.From == m.from && t.To == m.to
+}
+
+func (m transitionMatcher) String() string {
+	return fmt.Sprintf("transition of transaction %d from %s to %s", m.transactionID, m.from, m.to)
+}
+
 func TestDeposit_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
 	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
 	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
-	mockPublisher := mockPublisher.NewMockKafkaPublisher(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
 
-	service := NewTransactionService(mockGateway, mockUserRepo, mockTransRepo, mockPublisher)
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, nil, jsonSerializer)
 
 	req := models.TransactionRequest{
 		UserID:   1,
-		Amount:   100.00,
+		Amount:   money.MustParseDecimal("100.00"),
 		Currency: "EUR",
 	}
 
@@ -34,7 +79,7 @@ func TestDeposit_Success(t *testing.T) {
 	gw := &models.Gateway{ID: 10}
 	tx := models.Transaction{
 		UserID:    user.ID,
-		Amount:    req.Amount,
+		Amount:    money.MustParse("100.00", "EUR"),
 		GatewayID: gw.ID,
 		CountryID: user.CountryID,
 		Status:    models.TransactionStatusPending,
@@ -43,14 +88,31 @@ func TestDeposit_Success(t *testing.T) {
 
 	// Set expectations for repository and gateway calls.
 	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(user, nil)
-	mockGateway.EXPECT().GetGateway(req.UserID).Return(gw, nil)
-	mockTransRepo.EXPECT().CreateTransaction(gomock.Any()).Return(1, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeDeposit)).Return([]models.Gateway{*gw}, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(created models.Transaction, newMessage func(models.Transaction) (models.OutboxMessage, error)) (int, error) {
+			assert.Equal(t, models.TransactionStatusCreated, created.Status)
+
+			// the repository sets the id before the message is built in the same SQL transaction
+			created.ID = 1
+			message, err := newMessage(created)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, message.TransactionID)
+			assert.Equal(t, "application/json", message.ContentType)
+			assert.Contains(t, string(message.Payload), `"event_type":"transaction.created"`)
+			assert.Contains(t, string(message.Payload), `"transaction_id":1`)
+			return 1, nil
+		})
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
 
 	// Expect Deposit to be called once (adjusted from .Times(2) to .Times(1))
-	mockGateway.EXPECT().Deposit(gomock.Any()).Return(nil).Times(1)
+	mockGateway.EXPECT().Deposit(gw, gomock.Any()).Return(&adapters.Response{Reference: "ref-1", Status: models.TransactionStatusPending}, nil).Times(1)
+	mockTransRepo.EXPECT().TransitionStatus(transition(1, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().UpdateGatewayReference(1, "ref-1").Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(1, models.TransactionStatusSubmitted, models.TransactionStatusPending), gomock.Any(), gomock.Any()).Return(nil)
 
 	// Use a flexible matcher for the payload.
-	mockPublisher.EXPECT().PublishTransaction(gomock.Any(), gomock.Any(), gomock.Any(), "application/json").Return(nil)
 
 	result, err := service.Deposit(req)
 	assert.NoError(t, err)
@@ -65,14 +127,16 @@ func TestDeposit_Fail_InvalidUser(t *testing.T) {
 
 	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
 	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
 	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
-	mockPublisher := mockPublisher.NewMockKafkaPublisher(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
 
-	service := NewTransactionService(mockGateway, mockUserRepo, mockTransRepo, mockPublisher)
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, nil, jsonSerializer)
 
 	req := models.TransactionRequest{
 		UserID:   0, // Невалидный пользователь
-		Amount:   100.00,
+		Amount:   money.MustParseDecimal("100.00"),
 		Currency: "EUR",
 	}
 
@@ -87,23 +151,512 @@ func TestDeposit_Fail_TransactionError(t *testing.T) {
 
 	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
 	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
 	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
-	mockPublisher := mockPublisher.NewMockKafkaPublisher(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
 
-	service := NewTransactionService(mockGateway, mockUserRepo, mockTransRepo, mockPublisher)
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, nil, jsonSerializer)
 
 	req := models.TransactionRequest{
 		UserID:   1,
-		Amount:   100.00,
+		Amount:   money.MustParseDecimal("100.00"),
 		Currency: "EUR",
 	}
 
 	user := models.User{ID: 1, CountryID: 2}
 	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(user, nil)
-	mockGateway.EXPECT().GetGateway(req.UserID).Return(&models.Gateway{ID: 10}, nil)
-	mockTransRepo.EXPECT().CreateTransaction(gomock.Any()).Return(0, errors.New("db error"))
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeDeposit)).Return([]models.Gateway{{ID: 10}}, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).Return(0, errors.New("db error"))
 
 	result, err := service.Deposit(req)
 	assert.Nil(t, result)
 	assert.EqualError(t, err, "db error")
 }
+
+func TestWithdrawal_GatewayStatusApplied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
+	mockLedger := mockLedger.NewMockLedger(ctrl)
+
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, mockLedger, jsonSerializer)
+
+	req := models.TransactionRequest{
+		UserID:   1,
+		Amount:   money.MustParseDecimal("25.50"),
+		Currency: "EUR",
+	}
+
+	gw := &models.Gateway{ID: 10, Name: adapters.JSONPayName}
+	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeWithdrawal)).Return([]models.Gateway{*gw}, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).Return(7, nil)
+	mockLedger.EXPECT().Reserve(7, 1, money.MustParse("25.50", "EUR")).Return(nil)
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	mockGateway.EXPECT().Withdrawal(gw, gomock.Any()).Return(&adapters.Response{Reference: "pay_7", Status: models.TransactionStatusDone}, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().UpdateGatewayReference(7, "pay_7").Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusSubmitted, models.TransactionStatusDone), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ models.StatusTransition, _ models.OutboxMessage, posting ledger.Posting) error {
+			// the wallet is debited and the hold captured with the status change
+			assert.Equal(t, ledger.HoldCaptured, posting.Hold)
+			if assert.NotNil(t, posting.Entry) {
+				assert.Equal(t, ledger.EntryWithdrawal, posting.Entry.Type)
+				assert.Equal(t, money.MustParse("-25.50", "EUR"), posting.Entry.Lines[0].Amount)
+			}
+			return nil
+		})
+
+	result, err := service.Withdrawal(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, result.ID)
+	assert.Equal(t, "pay_7", result.GatewayReference)
+	assert.Equal(t, models.TransactionStatusDone, result.Status)
+}
+
+func TestWithdrawal_Fail_InsufficientFunds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
+	mockLedger := mockLedger.NewMockLedger(ctrl)
+
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, mockLedger, jsonSerializer)
+
+	req := models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("500"), Currency: "EUR"}
+
+	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeWithdrawal)).Return([]models.Gateway{{ID: 10}}, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).Return(8, nil)
+	mockLedger.EXPECT().Reserve(8, 1, money.MustParse("500", "EUR")).Return(fmt.Errorf("%w: available 20.00 EUR", ledger.ErrInsufficientFunds))
+	// the gateway is never called, the withdrawal fails before it is submitted
+	mockTransRepo.EXPECT().TransitionStatus(transition(8, models.TransactionStatusCreated, models.TransactionStatusFailed), gomock.Any(), gomock.Any()).Return(nil)
+
+	result, err := service.Withdrawal(req)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ledger.ErrInsufficientFunds)
+}
+
+func TestDeposit_FailoverToNextGateway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
+
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, nil, jsonSerializer)
+
+	req := models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("10"), Currency: "EUR"}
+	gateways := []models.Gateway{{ID: 10, Name: "primary"}, {ID: 20, Name: "secondary"}}
+
+	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeDeposit)).Return(gateways, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).Return(3, nil)
+
+	// the transaction is submitted once, failover keeps it submitted
+	gomock.InOrder(
+		mockTransRepo.EXPECT().TransitionStatus(transition(3, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil),
+		mockGateway.EXPECT().Deposit(&gateways[0], gomock.Any()).Return(nil, adapters.ErrTimeout),
+		mockAttemptRepo.EXPECT().CreateAttempt(models.TransactionAttempt{
+			TransactionID: 3, GatewayID: 10, AttemptNo: 1, Status: models.AttemptStatusFailed, Error: adapters.ErrTimeout.Error(),
+		}).Return(nil),
+		mockGateway.EXPECT().Deposit(&gateways[1], gomock.Any()).Return(&adapters.Response{Reference: "r-2", Status: models.TransactionStatusDone}, nil),
+		mockAttemptRepo.EXPECT().CreateAttempt(models.TransactionAttempt{
+			TransactionID: 3, GatewayID: 20, AttemptNo: 2, Status: models.AttemptStatusSucceeded, GatewayReference: "r-2",
+		}).Return(nil),
+		mockTransRepo.EXPECT().UpdateGateway(3, 20).Return(nil),
+		mockTransRepo.EXPECT().UpdateGatewayReference(3, "r-2").Return(nil),
+		mockTransRepo.EXPECT().TransitionStatus(transition(3, models.TransactionStatusSubmitted, models.TransactionStatusDone), gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	result, err := service.Deposit(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, result.GatewayID)
+	assert.Equal(t, models.TransactionStatusDone, result.Status)
+}
+
+func TestDeposit_NonRetryableErrorStopsFailover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
+
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, nil, jsonSerializer)
+
+	req := models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("10"), Currency: "EUR"}
+	gateways := []models.Gateway{{ID: 10}, {ID: 20}}
+
+	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeDeposit)).Return(gateways, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).Return(4, nil)
+	mockGateway.EXPECT().Deposit(&gateways[0], gomock.Any()).Return(nil, &adapters.ProviderError{StatusCode: 400})
+	mockAttemptRepo.EXPECT().CreateAttempt(gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(4, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(4, models.TransactionStatusSubmitted, models.TransactionStatusFailed), gomock.Any(), gomock.Any()).Return(nil)
+
+	result, err := service.Deposit(req)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrGatewayFailed)
+}
+
+func TestDeposit_Fail_InvalidCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := NewTransactionService(
+		mockGateway.NewMockServiceGateway(ctrl),
+		mocks.NewMockUserRepository(ctrl),
+		mocks.NewMockCountryRepository(ctrl),
+		mocks.NewMockTransactionRepository(ctrl),
+		mocks.NewMockAttemptRepository(ctrl),
+		mockFX.NewMockService(ctrl),
+		nil,
+		jsonSerializer,
+	)
+
+	for _, currency := range []string{"", "EU", "XYZ", "EURO"} {
+		result, err := service.Deposit(models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("10"), Currency: currency})
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrInvalidCurrency, currency)
+	}
+}
+
+func TestDeposit_Fail_CurrencyNotAllowedInCountry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+
+	service := NewTransactionService(
+		mockGateway.NewMockServiceGateway(ctrl),
+		mockUserRepo,
+		mockCountryRepo,
+		mocks.NewMockTransactionRepository(ctrl),
+		mocks.NewMockAttemptRepository(ctrl),
+		mockFX.NewMockService(ctrl),
+		nil,
+		jsonSerializer,
+	)
+
+	req := models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("10"), Currency: "usd"}
+	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+
+	result, err := service.Deposit(req)
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrCurrencyNotAllowed)
+	assert.Contains(t, err.Error(), "USD")
+}
+
+func TestDeposit_Fail_AmountFinerThanCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := NewTransactionService(
+		mockGateway.NewMockServiceGateway(ctrl),
+		mocks.NewMockUserRepository(ctrl),
+		mocks.NewMockCountryRepository(ctrl),
+		mocks.NewMockTransactionRepository(ctrl),
+		mocks.NewMockAttemptRepository(ctrl),
+		mockFX.NewMockService(ctrl),
+		nil,
+		jsonSerializer,
+	)
+
+	for _, req := range []models.TransactionRequest{
+		{UserID: 1, Amount: money.MustParseDecimal("10.001"), Currency: "EUR"},
+		{UserID: 1, Amount: money.MustParseDecimal("100.5"), Currency: "JPY"},
+	} {
+		result, err := service.Deposit(req)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+		assert.ErrorIs(t, err, money.ErrPrecision)
+	}
+}
+
+func TestDeposit_ConvertsToSettlementCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGateway := mockGateway.NewMockServiceGateway(ctrl)
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	mockCountryRepo := mocks.NewMockCountryRepository(ctrl)
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	mockAttemptRepo := mocks.NewMockAttemptRepository(ctrl)
+	mockFX := mockFX.NewMockService(ctrl)
+
+	service := NewTransactionService(mockGateway, mockUserRepo, mockCountryRepo, mockTransRepo, mockAttemptRepo, mockFX, nil, jsonSerializer)
+
+	req := models.TransactionRequest{UserID: 1, Amount: money.MustParseDecimal("100"), Currency: "EUR"}
+	amount := money.MustParse("100", "EUR")
+	gateways := []models.Gateway{{ID: 10, SettlementCurrency: "CHF"}, {ID: 20, SettlementCurrency: "USD"}}
+	conversion := &models.FXConversion{
+		Amount: money.MustParse("108.45", "USD"),
+		Rate:   money.MustParseDecimal("1.0845"),
+		Source: "static",
+		RateAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(models.User{ID: 1, CountryID: 2}, nil)
+	mockCountryRepo.EXPECT().GetCountryByID(2).Return(country, nil)
+	mockGateway.EXPECT().GetGateways(routingContext(req, 2, models.TransactionTypeDeposit)).Return(gateways, nil)
+	mockTransRepo.EXPECT().CreateTransaction(gomock.Any(), gomock.Any()).Return(5, nil)
+	// the gateway without an exchange rate isn't called, the deposit is the first attempt
+	mockAttemptRepo.EXPECT().CreateAttempt(models.TransactionAttempt{
+		TransactionID: 5, GatewayID: 20, AttemptNo: 1, Status: models.AttemptStatusSucceeded, GatewayReference: "r-5",
+	}).Return(nil)
+
+	gomock.InOrder(
+		mockFX.EXPECT().Convert(gomock.Any(), amount, "CHF").Return(nil, fx.ErrRateNotFound),
+		mockFX.EXPECT().Convert(gomock.Any(), amount, "USD").Return(conversion, nil),
+		mockTransRepo.EXPECT().TransitionStatus(transition(5, models.TransactionStatusCreated, models.TransactionStatusSubmitted), gomock.Any(), gomock.Any()).Return(nil),
+		mockGateway.EXPECT().Deposit(&gateways[1], gomock.Any()).DoAndReturn(func(_ *models.Gateway, tx models.Transaction) (*adapters.Response, error) {
+			assert.Equal(t, amount, tx.Amount)
+			assert.Equal(t, conversion.Amount, tx.GatewayAmount())
+			return &adapters.Response{Reference: "r-5", Status: models.TransactionStatusPending}, nil
+		}),
+		mockTransRepo.EXPECT().UpdateGateway(5, 20).Return(nil),
+		mockTransRepo.EXPECT().UpdateConversion(5, *conversion).Return(nil),
+		mockTransRepo.EXPECT().UpdateGatewayReference(5, "r-5").Return(nil),
+		mockTransRepo.EXPECT().TransitionStatus(transition(5, models.TransactionStatusSubmitted, models.TransactionStatusPending), gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	result, err := service.Deposit(req)
+	assert.NoError(t, err)
+	assert.Equal(t, amount, result.Amount)
+	assert.Equal(t, conversion, result.Conversion)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		txType    string
+		update    models.StatusUpdate
+		wantApply bool
+		wantErr   error
+	}{
+		{
+			name:      "pending to done",
+			current:   models.TransactionStatusPending,
+			update:    models.StatusUpdate{Status: models.TransactionStatusDone, GatewayID: 10, Source: models.StatusSourceCallback},
+			wantApply: true,
+		},
+		{
+			name:    "repeated status is a no-op",
+			current: models.TransactionStatusDone,
+			update:  models.StatusUpdate{Status: models.TransactionStatusDone, GatewayID: 10, Source: models.StatusSourceCallback},
+		},
+		{
+			name:    "reversed only by refunds",
+			current: models.TransactionStatusDone,
+			update:  models.StatusUpdate{Status: models.TransactionStatusReversed, Source: models.StatusSourceCommand},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusDone, To: models.TransactionStatusReversed},
+		},
+		{
+			name:    "done back to pending",
+			current: models.TransactionStatusDone,
+			update:  models.StatusUpdate{Status: models.TransactionStatusPending, GatewayID: 10, Source: models.StatusSourceCallback},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusDone, To: models.TransactionStatusPending},
+		},
+		{
+			name:    "failed is final",
+			current: models.TransactionStatusFailed,
+			update:  models.StatusUpdate{Status: models.TransactionStatusDone, GatewayID: 10, Source: models.StatusSourceCallback},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusFailed, To: models.TransactionStatusDone},
+		},
+		{
+			name:    "withdrawal is not reversed",
+			txType:  models.TransactionTypeWithdrawal,
+			current: models.TransactionStatusDone,
+			update:  models.StatusUpdate{Status: models.TransactionStatusReversed, Source: models.StatusSourceCommand},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusDone, To: models.TransactionStatusReversed},
+		},
+		{
+			name:    "deposit is not authorized",
+			current: models.TransactionStatusPending,
+			update:  models.StatusUpdate{Status: models.TransactionStatusAuthorized, Source: models.StatusSourceCommand, Actor: "backoffice:ops"},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusPending, To: models.TransactionStatusAuthorized},
+		},
+		{
+			name:    "authorization is not done",
+			txType:  models.TransactionTypeAuthorization,
+			current: models.TransactionStatusPending,
+			update:  models.StatusUpdate{Status: models.TransactionStatusDone, Source: models.StatusSourceCommand, Actor: "backoffice:ops"},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusPending, To: models.TransactionStatusDone},
+		},
+		{
+			name:    "captured only by captures",
+			txType:  models.TransactionTypeAuthorization,
+			current: models.TransactionStatusAuthorized,
+			update:  models.StatusUpdate{Status: models.TransactionStatusCaptured, Source: models.StatusSourceCommand},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusAuthorized, To: models.TransactionStatusCaptured},
+		},
+		{
+			name:    "voided only on the gateway",
+			txType:  models.TransactionTypeAuthorization,
+			current: models.TransactionStatusAuthorized,
+			update:  models.StatusUpdate{Status: models.TransactionStatusVoided, GatewayID: 10, Source: models.StatusSourceCallback},
+			wantErr: &IllegalTransitionError{TransactionID: 7, From: models.TransactionStatusAuthorized, To: models.TransactionStatusVoided},
+		},
+		{
+			name:    "unknown status",
+			current: models.TransactionStatusPending,
+			update:  models.StatusUpdate{Status: "processing", GatewayID: 10},
+			wantErr: ErrInvalidStatus,
+		},
+		{
+			name:    "another gateway",
+			current: models.TransactionStatusPending,
+			update:  models.StatusUpdate{Status: models.TransactionStatusDone, GatewayID: 11},
+			wantErr: ErrGatewayMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+			service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+			tt.update.TransactionID = 7
+			txType := tt.txType
+			if txType == "" {
+				txType = models.TransactionTypeDeposit
+			}
+			mockTransRepo.EXPECT().GetTransaction(7).Return(&models.Transaction{ID: 7, Type: txType, GatewayID: 10, Status: tt.current}, nil).MaxTimes(1)
+			if tt.wantApply {
+				mockTransRepo.EXPECT().TransitionStatus(models.StatusTransition{
+					TransactionID: 7,
+					From:          tt.current,
+					To:            tt.update.Status,
+					Source:        tt.update.Source,
+					Actor:         tt.update.Actor,
+				}, gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			err := service.UpdateStatus(tt.update)
+
+			var illegal *IllegalTransitionError
+			switch {
+			case errors.As(tt.wantErr, &illegal):
+				assert.Equal(t, tt.wantErr, err)
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUpdateStatus_ConcurrentChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	// the poller has expired the transaction between the read and the compare-and-set of the callback
+	gomock.InOrder(
+		mockTransRepo.EXPECT().GetTransaction(7).Return(&models.Transaction{ID: 7, Type: models.TransactionTypeDeposit, GatewayID: 10, Status: models.TransactionStatusPending}, nil),
+		mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusPending, models.TransactionStatusDone), gomock.Any(), gomock.Any()).
+			Return(repository.ErrStatusConflict),
+		mockTransRepo.EXPECT().GetTransaction(7).Return(&models.Transaction{ID: 7, Type: models.TransactionTypeDeposit, GatewayID: 10, Status: models.TransactionStatusExpired}, nil),
+	)
+
+	err := service.UpdateStatus(models.StatusUpdate{TransactionID: 7, Status: models.TransactionStatusDone, GatewayID: 10})
+
+	var illegal *IllegalTransitionError
+	assert.ErrorAs(t, err, &illegal)
+	assert.Equal(t, models.TransactionStatusExpired, illegal.From)
+}
+
+func TestUpdateStatus_EmitsEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(&models.Transaction{
+		ID: 7, Type: models.TransactionTypeDeposit, GatewayID: 10, Status: models.TransactionStatusPending, Amount: money.MustParse("10", "EUR"),
+	}, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusPending, models.TransactionStatusDone), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ models.StatusTransition, message models.OutboxMessage, _ ledger.Posting) error {
+			var event events.TransactionEvent
+			assert.NoError(t, json.Unmarshal(message.Payload, &event))
+
+			assert.Equal(t, 7, message.TransactionID)
+			assert.NotEmpty(t, event.EventID)
+			assert.Equal(t, events.TypeCompleted, event.EventType)
+			assert.Equal(t, events.SchemaVersion, event.SchemaVersion)
+			assert.Equal(t, models.TransactionStatusDone, event.Status)
+			assert.Equal(t, models.TransactionStatusPending, event.PreviousStatus)
+			assert.Equal(t, models.StatusSourceCallback, event.Source)
+			assert.Equal(t, "10.00", event.Amount)
+			return nil
+		})
+
+	err := service.UpdateStatus(models.StatusUpdate{
+		TransactionID: 7, Status: models.TransactionStatusDone, GatewayID: 10, Source: models.StatusSourceCallback,
+	})
+	assert.NoError(t, err)
+}
+
+func TestUpdateStatus_PostsDepositEntry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransRepo := mocks.NewMockTransactionRepository(ctrl)
+	service := NewTransactionService(nil, nil, nil, mockTransRepo, nil, nil, nil, jsonSerializer)
+
+	mockTransRepo.EXPECT().GetTransaction(7).Return(&models.Transaction{
+		ID: 7, UserID: 1, GatewayID: 10, Type: models.TransactionTypeDeposit,
+		Status: models.TransactionStatusPending, Amount: money.MustParse("10", "EUR"),
+	}, nil)
+	mockTransRepo.EXPECT().TransitionStatus(transition(7, models.TransactionStatusPending, models.TransactionStatusDone), gomock.Any(), ledger.Posting{
+		TransactionID: 7,
+		Entry: &ledger.Entry{
+			TransactionID: 7,
+			Type:          ledger.EntryDeposit,
+			Description:   "deposit of transaction 7",
+			Lines: []ledger.Line{
+				{Account: ledger.UserWallet(1, "EUR"), Amount: money.MustParse("10", "EUR")},
+				{Account: ledger.GatewayClearing(10, "EUR"), Amount: money.MustParse("-10", "EUR")},
+			},
+		},
+	}).Return(nil)
+
+	err := service.UpdateStatus(models.StatusUpdate{TransactionID: 7, Status: models.TransactionStatusDone, GatewayID: 10})
+	assert.NoError(t, err)
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -14,10 +15,11 @@ const (
 	contentTypeApplicationJson = "application/json"
 	contentTypeTextXml         = "text/xml"
 	contentTypeApplicationXml  = "application/xml"
+	contentTypeTextCsv         = "text/csv"
 )
 
-// DecodeRequest decodes the incoming request based on content type
-func DecodeRequest(r *http.Request, request *models.TransactionRequest) error {
+// DecodeRequest decodes the incoming request into the pointer based on content type
+func DecodeRequest(r *http.Request, request interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 
 	switch contentType {
@@ -32,10 +34,11 @@ func DecodeRequest(r *http.Request, request *models.TransactionRequest) error {
 	}
 }
 
-// EncodeResponse encode response
+// EncodeResponse encode response, text/csv is negotiated only for the responses with CSV data, see csvData
 func EncodeResponse(w http.ResponseWriter, r *http.Request, response interface{}) error {
 	acceptHeader := r.Header.Get("Accept")
 	var contentType string
+	csvResponse, hasCSV := csvData(response)
 
 	// Split Accept header into parts and check each
 	for _, part := range strings.Split(acceptHeader, ",") {
@@ -55,6 +58,10 @@ func EncodeResponse(w http.ResponseWriter, r *http.Request, response interface{}
 		case contentTypeApplicationJson, contentTypeTextXml, contentTypeApplicationXml:
 			contentType = mediaType
 			break // Use the first supported type
+		case contentTypeTextCsv:
+			if hasCSV {
+				contentType = mediaType
+			}
 		}
 		if contentType != "" {
 			break
@@ -77,8 +84,28 @@ func EncodeResponse(w http.ResponseWriter, r *http.Request, response interface{}
 		if err := xml.NewEncoder(w).Encode(response); err != nil {
 			return fmt.Errorf("error encoding XML: %w", err)
 		}
+	case contentTypeTextCsv:
+		records, err := csvResponse.MarshalCSV()
+		if err != nil {
+			return fmt.Errorf("error encoding CSV: %w", err)
+		}
+		if err := csv.NewWriter(w).WriteAll(records); err != nil {
+			return fmt.Errorf("error encoding CSV: %w", err)
+		}
 	default:
 		return fmt.Errorf("unsupported Accept type: %s", contentType)
 	}
 	return nil
 }
+
+// csvData the response itself or the CSV data of the APIResponse
+func csvData(response interface{}) (models.CSVMarshaler, bool) {
+	switch v := response.(type) {
+	case models.CSVMarshaler:
+		return v, true
+	case models.APIResponse:
+		return v.CSVData()
+	default:
+		return nil, false
+	}
+}